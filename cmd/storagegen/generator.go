@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+)
+
+// kind classifies a field type by the code generated for it
+type kind int
+
+const (
+	kindString kind = iota
+	kindInt
+	kindUint
+	kindFloat
+	kindBool
+	kindMarshaler
+	kindPtr
+	kindSlice
+	kindArray
+	kindMap
+	// kindReflect fields are encoded through reflection at runtime
+	kindReflect
+)
+
+// fieldType describes how a value of a field type is generated
+type fieldType struct {
+	kind kind
+	// expr is the Go source of the type, used for conversions and make
+	expr string
+	bits int
+	elem *fieldType
+	key  *fieldType
+}
+
+// field is an encoded field of a record, path is the selector from the
+// record including the names of promoted embedded structs
+type field struct {
+	path string
+	typ  *fieldType
+}
+
+type generator struct {
+	buf       bytes.Buffer
+	pkg       string
+	specs     map[string]ast.Expr
+	generated map[string]bool
+	resolving map[string]bool
+	usesSort  bool
+	usesFmt   bool
+	tmp       int
+}
+
+// generate parses the package in dir and returns the formatted source of
+// the methods of the given types. The file named outputName is skipped so
+// that a previous output does not take part in the parse.
+func generate(dir string, typeNames []string, outputName string) ([]byte, error) {
+	g := &generator{
+		specs:     make(map[string]ast.Expr),
+		generated: make(map[string]bool),
+		resolving: make(map[string]bool),
+	}
+	if err := g.parse(dir, outputName); err != nil {
+		return nil, err
+	}
+	for _, name := range typeNames {
+		g.generated[name] = true
+	}
+
+	var body bytes.Buffer
+	for _, name := range typeNames {
+		if err := g.generateType(name); err != nil {
+			return nil, err
+		}
+		body.Write(g.buf.Bytes())
+		g.buf.Reset()
+	}
+
+	g.printf("// Code generated by \"storagegen -type %s\"; DO NOT EDIT.\n\n", strings.Join(typeNames, ","))
+	g.printf("package %s\n\n", g.pkg)
+	g.printf("import (\n")
+	if g.usesFmt {
+		g.printf("\"fmt\"\n")
+	}
+	if g.usesSort {
+		g.printf("\"sort\"\n")
+	}
+	if g.usesFmt || g.usesSort {
+		g.printf("\n")
+	}
+	g.printf("\"github.com/priyanshujain/go-storage/encoding\"\n)\n")
+	g.buf.Write(body.Bytes())
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+func (g *generator) parse(dir, outputName string) error {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	for _, name := range names {
+		base := filepath.Base(name)
+		if strings.HasSuffix(base, "_test.go") || base == outputName {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		if g.pkg == "" {
+			g.pkg = file.Name.Name
+		}
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if typeSpec.TypeParams != nil {
+					continue
+				}
+				g.specs[typeSpec.Name.Name] = typeSpec.Type
+			}
+		}
+	}
+	if g.pkg == "" {
+		return fmt.Errorf("no Go files in %s", dir)
+	}
+	return nil
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// newVar returns a fresh local variable name
+func (g *generator) newVar(prefix string) string {
+	g.tmp++
+	return fmt.Sprintf("%s%d", prefix, g.tmp)
+}
+
+func (g *generator) generateType(name string) error {
+	st, ok := g.structOf(name)
+	if !ok {
+		return fmt.Errorf("type %s is not a struct declared in package %s", name, g.pkg)
+	}
+	fields, err := g.fields(st)
+	if err != nil {
+		return fmt.Errorf("type %s: %v", name, err)
+	}
+
+	g.printf("\n// MarshalStorage encodes x in the format of encoding.Encode.\n")
+	g.printf("func (x %s) MarshalStorage() (string, error) {\n", name)
+	g.printf("w := encoding.NewWriter()\ndefer w.Release()\n")
+	g.printf("if err := x.writeStorage(w); err != nil {\nreturn \"\", err\n}\n")
+	g.printf("return w.Record(), nil\n}\n")
+
+	g.tmp = 0
+	g.printf("\nfunc (x *%s) writeStorage(w *encoding.Writer) error {\n", name)
+	for _, f := range fields {
+		g.encode("x."+f.path, f.typ)
+	}
+	g.printf("return nil\n}\n")
+
+	g.printf("\n// UnmarshalStorage decodes a record produced by MarshalStorage or encoding.Encode into x.\n")
+	g.printf("func (x *%s) UnmarshalStorage(record string) error {\n", name)
+	g.printf("r, err := encoding.NewReader(record, %d)\n", len(fields))
+	g.printf("if err != nil {\nreturn err\n}\ndefer r.Release()\n")
+	g.printf("return x.readStorage(r)\n}\n")
+
+	g.tmp = 0
+	g.printf("\nfunc (x *%s) readStorage(r *encoding.Reader) error {\n", name)
+	for _, f := range fields {
+		g.decode("x."+f.path, f.typ)
+	}
+	g.printf("return nil\n}\n")
+	return nil
+}
+
+// structOf follows named types declared in the package to a struct type
+func (g *generator) structOf(name string) (*ast.StructType, bool) {
+	seen := make(map[string]bool)
+	for !seen[name] {
+		seen[name] = true
+		switch t := g.specs[name].(type) {
+		case *ast.StructType:
+			return t, true
+		case *ast.Ident:
+			name = t.Name
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// fields lists the encoded fields of a struct in the order the encoding
+// package uses: unexported fields are skipped and the fields of embedded
+// structs are promoted in place. A field is shadowed by a field of the same
+// name at a shallower depth, fields of the same name at the same depth hide
+// each other and an embedded struct itself shadows nothing.
+func (g *generator) fields(st *ast.StructType) ([]field, error) {
+	candidates, err := g.promoted(st, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	// depth is the depth of the shallowest fields of each name and count
+	// their number
+	depth := make(map[string]int)
+	count := make(map[string]int)
+	for _, c := range candidates {
+		if c.expanded {
+			continue
+		}
+		d, ok := depth[c.name]
+		switch {
+		case !ok || c.depth < d:
+			depth[c.name], count[c.name] = c.depth, 1
+		case c.depth == d:
+			count[c.name]++
+		}
+	}
+	var fields []field
+	for _, c := range candidates {
+		if c.expanded || c.depth != depth[c.name] || count[c.name] > 1 || !ast.IsExported(c.name) {
+			continue
+		}
+		typ, err := g.resolve(c.expr)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", c.name, err)
+		}
+		fields = append(fields, field{path: c.path, typ: typ})
+	}
+	return fields, nil
+}
+
+// candidate is a field of a struct or of the structs it embeds, expanded is
+// set for an embedded struct whose fields are promoted in its place
+type candidate struct {
+	name     string
+	path     string
+	depth    int
+	expr     ast.Expr
+	expanded bool
+}
+
+// promoted lists the fields of a struct and of the structs it embeds in
+// declaration order, prefix is the selector of the struct
+func (g *generator) promoted(st *ast.StructType, prefix string, depth int) ([]candidate, error) {
+	var candidates []candidate
+	for _, f := range st.Fields.List {
+		if len(f.Names) > 0 {
+			for _, name := range f.Names {
+				candidates = append(candidates, candidate{name: name.Name, path: prefix + name.Name, depth: depth, expr: f.Type})
+			}
+			continue
+		}
+
+		// embedded field
+		var name string
+		switch t := f.Type.(type) {
+		case *ast.Ident:
+			name = t.Name
+			if st, ok := g.structOf(name); ok {
+				candidates = append(candidates, candidate{name: name, path: prefix + name, depth: depth, expr: f.Type, expanded: true})
+				promoted, err := g.promoted(st, prefix+name+".", depth+1)
+				if err != nil {
+					return nil, err
+				}
+				candidates = append(candidates, promoted...)
+				continue
+			}
+		case *ast.StarExpr:
+			ident, ok := t.X.(*ast.Ident)
+			if !ok {
+				return nil, fmt.Errorf("unsupported embedded field %s", types.ExprString(f.Type))
+			}
+			name = ident.Name
+		default:
+			return nil, fmt.Errorf("cannot promote the fields of embedded %s declared in another package", types.ExprString(f.Type))
+		}
+		candidates = append(candidates, candidate{name: name, path: prefix + name, depth: depth, expr: f.Type})
+	}
+	return candidates, nil
+}
+
+var basicTypes = map[string]fieldType{
+	"string":  {kind: kindString},
+	"int":     {kind: kindInt, bits: 0},
+	"int8":    {kind: kindInt, bits: 8},
+	"int16":   {kind: kindInt, bits: 16},
+	"int32":   {kind: kindInt, bits: 32},
+	"rune":    {kind: kindInt, bits: 32},
+	"int64":   {kind: kindInt, bits: 64},
+	"uint":    {kind: kindUint, bits: 0},
+	"uint8":   {kind: kindUint, bits: 8},
+	"byte":    {kind: kindUint, bits: 8},
+	"uint16":  {kind: kindUint, bits: 16},
+	"uint32":  {kind: kindUint, bits: 32},
+	"uint64":  {kind: kindUint, bits: 64},
+	"float32": {kind: kindFloat, bits: 32},
+	"float64": {kind: kindFloat, bits: 64},
+	"bool":    {kind: kindBool},
+}
+
+// resolve classifies a field type expression
+func (g *generator) resolve(expr ast.Expr) (*fieldType, error) {
+	source := types.ExprString(expr)
+	switch t := expr.(type) {
+	case *ast.ParenExpr:
+		return g.resolve(t.X)
+	case *ast.Ident:
+		if basic, ok := basicTypes[t.Name]; ok {
+			basic.expr = t.Name
+			return &basic, nil
+		}
+		spec, ok := g.specs[t.Name]
+		if !ok {
+			// predeclared interfaces and unknown identifiers
+			return &fieldType{kind: kindReflect, expr: source}, nil
+		}
+		if _, ok := spec.(*ast.StructType); ok {
+			if g.generated[t.Name] {
+				return &fieldType{kind: kindMarshaler, expr: source}, nil
+			}
+			return &fieldType{kind: kindReflect, expr: source}, nil
+		}
+		if g.resolving[t.Name] {
+			return &fieldType{kind: kindReflect, expr: source}, nil
+		}
+		g.resolving[t.Name] = true
+		underlying, err := g.resolve(spec)
+		delete(g.resolving, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		switch underlying.kind {
+		case kindString, kindInt, kindUint, kindFloat, kindBool, kindSlice, kindArray, kindMap:
+			// keep the named type for conversions and make
+			named := *underlying
+			named.expr = source
+			return &named, nil
+		}
+		// methods are not inherited by defined types and named pointer
+		// types cannot be allocated with new, leave them to reflection
+		return &fieldType{kind: kindReflect, expr: source}, nil
+	case *ast.StarExpr:
+		elem, err := g.resolve(t.X)
+		if err != nil {
+			return nil, err
+		}
+		return &fieldType{kind: kindPtr, expr: source, elem: elem}, nil
+	case *ast.ArrayType:
+		elem, err := g.resolve(t.Elt)
+		if err != nil {
+			return nil, err
+		}
+		if t.Len == nil {
+			return &fieldType{kind: kindSlice, expr: source, elem: elem}, nil
+		}
+		return &fieldType{kind: kindArray, expr: source, elem: elem}, nil
+	case *ast.MapType:
+		key, err := g.resolve(t.Key)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := g.resolve(t.Value)
+		if err != nil {
+			return nil, err
+		}
+		switch key.kind {
+		case kindString, kindInt, kindUint, kindBool:
+			return &fieldType{kind: kindMap, expr: source, key: key, elem: elem}, nil
+		}
+		// other keys need the type aware ordering of the encoding package
+		return &fieldType{kind: kindReflect, expr: source}, nil
+	case *ast.ChanType, *ast.FuncType:
+		return nil, fmt.Errorf("unsupported type %s", source)
+	}
+	// interfaces, anonymous structs and types from other packages
+	return &fieldType{kind: kindReflect, expr: source}, nil
+}
+
+// encode generates the code writing the value of expr
+func (g *generator) encode(expr string, t *fieldType) {
+	switch t.kind {
+	case kindString:
+		g.printf("w.WriteString(string(%s))\n", expr)
+	case kindInt:
+		g.printf("w.WriteInt(int64(%s))\n", expr)
+	case kindUint:
+		g.printf("w.WriteUint(uint64(%s))\n", expr)
+	case kindFloat:
+		g.printf("w.WriteFloat(float64(%s), %d)\n", expr, t.bits)
+	case kindBool:
+		g.printf("w.WriteBool(bool(%s))\n", expr)
+	case kindMarshaler:
+		// nested generated records are written in place
+		g.printf("w.BeginStruct()\n")
+		g.printf("if err := %s.writeStorage(w); err != nil {\nreturn err\n}\n", expr)
+		g.printf("w.EndStruct()\n")
+	case kindPtr:
+		g.printf("if %s == nil {\nw.WriteNil()\n} else {\nw.WritePtr()\n", expr)
+		g.encode("(*"+expr+")", t.elem)
+		g.printf("}\n")
+	case kindSlice, kindArray:
+		i := g.newVar("i")
+		if t.kind == kindSlice {
+			g.printf("w.BeginList(%s == nil)\n", expr)
+		} else {
+			g.printf("w.BeginArray()\n")
+		}
+		g.printf("for %s := range %s {\n", i, expr)
+		g.encode(expr+"["+i+"]", t.elem)
+		g.printf("}\nw.EndList()\n")
+	case kindMap:
+		g.usesSort = true
+		keys, k, v := g.newVar("keys"), g.newVar("k"), g.newVar("v")
+		g.printf("%s := make([]%s, 0, len(%s))\n", keys, t.key.expr, expr)
+		g.printf("for %s := range %s {\n%s = append(%s, %s)\n}\n", k, expr, keys, keys, k)
+		if t.key.kind == kindBool {
+			g.printf("sort.Slice(%s, func(i, j int) bool { return !%s[i] && %s[j] })\n", keys, keys, keys)
+		} else {
+			g.printf("sort.Slice(%s, func(i, j int) bool { return %s[i] < %s[j] })\n", keys, keys, keys)
+		}
+		g.printf("w.BeginMap(%s == nil)\n", expr)
+		g.printf("for _, %s := range %s {\n", k, keys)
+		g.encode(k, t.key)
+		g.printf("%s := %s[%s]\n", v, expr, k)
+		g.encode(v, t.elem)
+		g.printf("}\nw.EndMap()\n")
+	case kindReflect:
+		// copy the value so that only the copy escapes to the heap
+		v := g.newVar("v")
+		g.printf("%s := %s\n", v, expr)
+		g.printf("if err := w.WriteValue(&%s); err != nil {\nreturn err\n}\n", v)
+	}
+}
+
+// decode generates the code reading a value into the assignable target
+func (g *generator) decode(target string, t *fieldType) {
+	switch t.kind {
+	case kindString, kindInt, kindUint, kindFloat, kindBool:
+		v := g.newVar("v")
+		switch t.kind {
+		case kindString:
+			g.printf("%s, err := r.ReadString()\n", v)
+		case kindInt:
+			g.printf("%s, err := r.ReadInt(%d)\n", v, t.bits)
+		case kindUint:
+			g.printf("%s, err := r.ReadUint(%d)\n", v, t.bits)
+		case kindFloat:
+			g.printf("%s, err := r.ReadFloat(%d)\n", v, t.bits)
+		case kindBool:
+			g.printf("%s, err := r.ReadBool()\n", v)
+		}
+		g.printf("if err != nil {\nreturn err\n}\n")
+		g.printf("%s = %s(%s)\n", target, t.expr, v)
+	case kindMarshaler:
+		g.usesFmt = true
+		st, _ := g.structOf(t.expr)
+		fields, _ := g.fields(st)
+		g.printf("if err := r.BeginStruct(%d); err != nil {\nreturn err\n}\n", len(fields))
+		g.printf("if err := %s.readStorage(r); err != nil {\nreturn fmt.Errorf(\"%%v: %%w\", err, encoding.ErrParseStruct)\n}\n", target)
+		g.printf("r.EndStruct()\n")
+	case kindPtr:
+		isNil := g.newVar("isNil")
+		g.printf("%s, err := r.ReadPtr()\n", isNil)
+		g.printf("if err != nil {\nreturn err\n}\n")
+		g.printf("if %s {\n%s = nil\n} else {\n%s = new(%s)\n", isNil, target, target, t.elem.expr)
+		g.decode("(*"+target+")", t.elem)
+		g.printf("}\n")
+	case kindSlice:
+		n, isNil, i := g.newVar("n"), g.newVar("isNil"), g.newVar("i")
+		g.printf("%s, %s, err := r.BeginList()\n", n, isNil)
+		g.printf("if err != nil {\nreturn err\n}\n")
+		g.printf("if %s {\n%s = nil\n} else {\n%s = make(%s, %s)\n", isNil, target, target, t.expr, n)
+		g.printf("for %s := range %s {\n", i, target)
+		g.decode(target+"["+i+"]", t.elem)
+		g.printf("}\n}\nr.EndList()\n")
+	case kindArray:
+		i := g.newVar("i")
+		g.printf("if err := r.BeginArray(len(%s)); err != nil {\nreturn err\n}\n", target)
+		g.printf("for %s := range %s {\n", i, target)
+		g.decode(target+"["+i+"]", t.elem)
+		g.printf("}\nr.EndList()\n")
+	case kindMap:
+		n, isNil, i, k, v := g.newVar("n"), g.newVar("isNil"), g.newVar("i"), g.newVar("k"), g.newVar("v")
+		g.printf("%s, %s, err := r.BeginMap()\n", n, isNil)
+		g.printf("if err != nil {\nreturn err\n}\n")
+		g.printf("if %s {\n%s = nil\n} else {\n%s = make(%s, %s)\n", isNil, target, target, t.expr, n)
+		g.printf("for %s := 0; %s < %s; %s++ {\n", i, i, n, i)
+		g.printf("var %s %s\n", k, t.key.expr)
+		g.decode(k, t.key)
+		g.printf("var %s %s\n", v, t.elem.expr)
+		g.decode(v, t.elem)
+		g.printf("%s[%s] = %s\n", target, k, v)
+		g.printf("}\n}\nr.EndMap()\n")
+	case kindReflect:
+		g.printf("if err := r.ReadValue(&%s); err != nil {\nreturn err\n}\n", target)
+	}
+}
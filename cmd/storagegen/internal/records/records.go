@@ -0,0 +1,96 @@
+// Package records holds record types with generated codecs. Its tests check
+// that the generated methods are wire compatible with the reflective codec
+// of the encoding package.
+package records
+
+import (
+	"github.com/priyanshujain/go-storage/encoding"
+)
+
+//go:generate go run github.com/priyanshujain/go-storage/cmd/storagegen -type Person,Address,Order,Profile -output records_storage.go
+
+type Status string
+
+type Tags []string
+
+type Event interface {
+	EventName() string
+}
+
+type Shipped struct {
+	Carrier string
+}
+
+func (Shipped) EventName() string { return "Shipped" }
+
+func init() {
+	encoding.RegisterName("records.Shipped", Shipped{})
+}
+
+type Base struct {
+	Id      string
+	Version uint32
+}
+
+type audit struct {
+	CreatedBy string
+}
+
+type Address struct {
+	Street     string
+	City       string
+	PostalCode int
+	Location   [2]float64
+}
+
+type Person struct {
+	Base
+	audit
+	Name      string
+	Age       int
+	Score     float32
+	Active    bool
+	Initial   rune
+	Status    Status
+	Tags      Tags
+	Home      Address
+	Previous  []Address
+	Manager   *Person
+	Nickname  *string
+	Counters  map[string]int64
+	ByLevel   map[int]*Address
+	Flags     map[bool]string
+	Weights   map[float64]string
+	Matrix    [][]int8
+	Event     Event
+	Extra     interface{}
+	Anonymous struct{ A, B int }
+	private   string
+}
+
+type Order struct {
+	Id       string
+	Customer *Person
+	Lines    [3]uint16
+	Shipping *Address
+	Notes    map[Status][]string
+}
+
+type Revision struct {
+	Version uint32
+	Label   string
+}
+
+type Draft struct {
+	Label string
+	Body  string
+}
+
+// Profile shadows the Id of Base, the Version and Label fields promoted at
+// the same depth hide each other
+type Profile struct {
+	Base
+	Revision
+	Draft
+	Id int
+}
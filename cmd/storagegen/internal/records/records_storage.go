@@ -0,0 +1,654 @@
+// Code generated by "storagegen -type Person,Address,Order,Profile"; DO NOT EDIT.
+
+package records
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/priyanshujain/go-storage/encoding"
+)
+
+// MarshalStorage encodes x in the format of encoding.Encode.
+func (x Person) MarshalStorage() (string, error) {
+	w := encoding.NewWriter()
+	defer w.Release()
+	if err := x.writeStorage(w); err != nil {
+		return "", err
+	}
+	return w.Record(), nil
+}
+
+func (x *Person) writeStorage(w *encoding.Writer) error {
+	w.WriteString(string(x.Base.Id))
+	w.WriteUint(uint64(x.Base.Version))
+	w.WriteString(string(x.audit.CreatedBy))
+	w.WriteString(string(x.Name))
+	w.WriteInt(int64(x.Age))
+	w.WriteFloat(float64(x.Score), 32)
+	w.WriteBool(bool(x.Active))
+	w.WriteInt(int64(x.Initial))
+	w.WriteString(string(x.Status))
+	w.BeginList(x.Tags == nil)
+	for i1 := range x.Tags {
+		w.WriteString(string(x.Tags[i1]))
+	}
+	w.EndList()
+	w.BeginStruct()
+	if err := x.Home.writeStorage(w); err != nil {
+		return err
+	}
+	w.EndStruct()
+	w.BeginList(x.Previous == nil)
+	for i2 := range x.Previous {
+		w.BeginStruct()
+		if err := x.Previous[i2].writeStorage(w); err != nil {
+			return err
+		}
+		w.EndStruct()
+	}
+	w.EndList()
+	if x.Manager == nil {
+		w.WriteNil()
+	} else {
+		w.WritePtr()
+		w.BeginStruct()
+		if err := (*x.Manager).writeStorage(w); err != nil {
+			return err
+		}
+		w.EndStruct()
+	}
+	if x.Nickname == nil {
+		w.WriteNil()
+	} else {
+		w.WritePtr()
+		w.WriteString(string((*x.Nickname)))
+	}
+	keys3 := make([]string, 0, len(x.Counters))
+	for k4 := range x.Counters {
+		keys3 = append(keys3, k4)
+	}
+	sort.Slice(keys3, func(i, j int) bool { return keys3[i] < keys3[j] })
+	w.BeginMap(x.Counters == nil)
+	for _, k4 := range keys3 {
+		w.WriteString(string(k4))
+		v5 := x.Counters[k4]
+		w.WriteInt(int64(v5))
+	}
+	w.EndMap()
+	keys6 := make([]int, 0, len(x.ByLevel))
+	for k7 := range x.ByLevel {
+		keys6 = append(keys6, k7)
+	}
+	sort.Slice(keys6, func(i, j int) bool { return keys6[i] < keys6[j] })
+	w.BeginMap(x.ByLevel == nil)
+	for _, k7 := range keys6 {
+		w.WriteInt(int64(k7))
+		v8 := x.ByLevel[k7]
+		if v8 == nil {
+			w.WriteNil()
+		} else {
+			w.WritePtr()
+			w.BeginStruct()
+			if err := (*v8).writeStorage(w); err != nil {
+				return err
+			}
+			w.EndStruct()
+		}
+	}
+	w.EndMap()
+	keys9 := make([]bool, 0, len(x.Flags))
+	for k10 := range x.Flags {
+		keys9 = append(keys9, k10)
+	}
+	sort.Slice(keys9, func(i, j int) bool { return !keys9[i] && keys9[j] })
+	w.BeginMap(x.Flags == nil)
+	for _, k10 := range keys9 {
+		w.WriteBool(bool(k10))
+		v11 := x.Flags[k10]
+		w.WriteString(string(v11))
+	}
+	w.EndMap()
+	v12 := x.Weights
+	if err := w.WriteValue(&v12); err != nil {
+		return err
+	}
+	w.BeginList(x.Matrix == nil)
+	for i13 := range x.Matrix {
+		w.BeginList(x.Matrix[i13] == nil)
+		for i14 := range x.Matrix[i13] {
+			w.WriteInt(int64(x.Matrix[i13][i14]))
+		}
+		w.EndList()
+	}
+	w.EndList()
+	v15 := x.Event
+	if err := w.WriteValue(&v15); err != nil {
+		return err
+	}
+	v16 := x.Extra
+	if err := w.WriteValue(&v16); err != nil {
+		return err
+	}
+	v17 := x.Anonymous
+	if err := w.WriteValue(&v17); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalStorage decodes a record produced by MarshalStorage or encoding.Encode into x.
+func (x *Person) UnmarshalStorage(record string) error {
+	r, err := encoding.NewReader(record, 22)
+	if err != nil {
+		return err
+	}
+	defer r.Release()
+	return x.readStorage(r)
+}
+
+func (x *Person) readStorage(r *encoding.Reader) error {
+	v1, err := r.ReadString()
+	if err != nil {
+		return err
+	}
+	x.Base.Id = string(v1)
+	v2, err := r.ReadUint(32)
+	if err != nil {
+		return err
+	}
+	x.Base.Version = uint32(v2)
+	v3, err := r.ReadString()
+	if err != nil {
+		return err
+	}
+	x.audit.CreatedBy = string(v3)
+	v4, err := r.ReadString()
+	if err != nil {
+		return err
+	}
+	x.Name = string(v4)
+	v5, err := r.ReadInt(0)
+	if err != nil {
+		return err
+	}
+	x.Age = int(v5)
+	v6, err := r.ReadFloat(32)
+	if err != nil {
+		return err
+	}
+	x.Score = float32(v6)
+	v7, err := r.ReadBool()
+	if err != nil {
+		return err
+	}
+	x.Active = bool(v7)
+	v8, err := r.ReadInt(32)
+	if err != nil {
+		return err
+	}
+	x.Initial = rune(v8)
+	v9, err := r.ReadString()
+	if err != nil {
+		return err
+	}
+	x.Status = Status(v9)
+	n10, isNil11, err := r.BeginList()
+	if err != nil {
+		return err
+	}
+	if isNil11 {
+		x.Tags = nil
+	} else {
+		x.Tags = make(Tags, n10)
+		for i12 := range x.Tags {
+			v13, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			x.Tags[i12] = string(v13)
+		}
+	}
+	r.EndList()
+	if err := r.BeginStruct(4); err != nil {
+		return err
+	}
+	if err := x.Home.readStorage(r); err != nil {
+		return fmt.Errorf("%v: %w", err, encoding.ErrParseStruct)
+	}
+	r.EndStruct()
+	n14, isNil15, err := r.BeginList()
+	if err != nil {
+		return err
+	}
+	if isNil15 {
+		x.Previous = nil
+	} else {
+		x.Previous = make([]Address, n14)
+		for i16 := range x.Previous {
+			if err := r.BeginStruct(4); err != nil {
+				return err
+			}
+			if err := x.Previous[i16].readStorage(r); err != nil {
+				return fmt.Errorf("%v: %w", err, encoding.ErrParseStruct)
+			}
+			r.EndStruct()
+		}
+	}
+	r.EndList()
+	isNil17, err := r.ReadPtr()
+	if err != nil {
+		return err
+	}
+	if isNil17 {
+		x.Manager = nil
+	} else {
+		x.Manager = new(Person)
+		if err := r.BeginStruct(22); err != nil {
+			return err
+		}
+		if err := (*x.Manager).readStorage(r); err != nil {
+			return fmt.Errorf("%v: %w", err, encoding.ErrParseStruct)
+		}
+		r.EndStruct()
+	}
+	isNil18, err := r.ReadPtr()
+	if err != nil {
+		return err
+	}
+	if isNil18 {
+		x.Nickname = nil
+	} else {
+		x.Nickname = new(string)
+		v19, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		(*x.Nickname) = string(v19)
+	}
+	n20, isNil21, err := r.BeginMap()
+	if err != nil {
+		return err
+	}
+	if isNil21 {
+		x.Counters = nil
+	} else {
+		x.Counters = make(map[string]int64, n20)
+		for i22 := 0; i22 < n20; i22++ {
+			var k23 string
+			v25, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			k23 = string(v25)
+			var v24 int64
+			v26, err := r.ReadInt(64)
+			if err != nil {
+				return err
+			}
+			v24 = int64(v26)
+			x.Counters[k23] = v24
+		}
+	}
+	r.EndMap()
+	n27, isNil28, err := r.BeginMap()
+	if err != nil {
+		return err
+	}
+	if isNil28 {
+		x.ByLevel = nil
+	} else {
+		x.ByLevel = make(map[int]*Address, n27)
+		for i29 := 0; i29 < n27; i29++ {
+			var k30 int
+			v32, err := r.ReadInt(0)
+			if err != nil {
+				return err
+			}
+			k30 = int(v32)
+			var v31 *Address
+			isNil33, err := r.ReadPtr()
+			if err != nil {
+				return err
+			}
+			if isNil33 {
+				v31 = nil
+			} else {
+				v31 = new(Address)
+				if err := r.BeginStruct(4); err != nil {
+					return err
+				}
+				if err := (*v31).readStorage(r); err != nil {
+					return fmt.Errorf("%v: %w", err, encoding.ErrParseStruct)
+				}
+				r.EndStruct()
+			}
+			x.ByLevel[k30] = v31
+		}
+	}
+	r.EndMap()
+	n34, isNil35, err := r.BeginMap()
+	if err != nil {
+		return err
+	}
+	if isNil35 {
+		x.Flags = nil
+	} else {
+		x.Flags = make(map[bool]string, n34)
+		for i36 := 0; i36 < n34; i36++ {
+			var k37 bool
+			v39, err := r.ReadBool()
+			if err != nil {
+				return err
+			}
+			k37 = bool(v39)
+			var v38 string
+			v40, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			v38 = string(v40)
+			x.Flags[k37] = v38
+		}
+	}
+	r.EndMap()
+	if err := r.ReadValue(&x.Weights); err != nil {
+		return err
+	}
+	n41, isNil42, err := r.BeginList()
+	if err != nil {
+		return err
+	}
+	if isNil42 {
+		x.Matrix = nil
+	} else {
+		x.Matrix = make([][]int8, n41)
+		for i43 := range x.Matrix {
+			n44, isNil45, err := r.BeginList()
+			if err != nil {
+				return err
+			}
+			if isNil45 {
+				x.Matrix[i43] = nil
+			} else {
+				x.Matrix[i43] = make([]int8, n44)
+				for i46 := range x.Matrix[i43] {
+					v47, err := r.ReadInt(8)
+					if err != nil {
+						return err
+					}
+					x.Matrix[i43][i46] = int8(v47)
+				}
+			}
+			r.EndList()
+		}
+	}
+	r.EndList()
+	if err := r.ReadValue(&x.Event); err != nil {
+		return err
+	}
+	if err := r.ReadValue(&x.Extra); err != nil {
+		return err
+	}
+	if err := r.ReadValue(&x.Anonymous); err != nil {
+		return err
+	}
+	return nil
+}
+
+// MarshalStorage encodes x in the format of encoding.Encode.
+func (x Address) MarshalStorage() (string, error) {
+	w := encoding.NewWriter()
+	defer w.Release()
+	if err := x.writeStorage(w); err != nil {
+		return "", err
+	}
+	return w.Record(), nil
+}
+
+func (x *Address) writeStorage(w *encoding.Writer) error {
+	w.WriteString(string(x.Street))
+	w.WriteString(string(x.City))
+	w.WriteInt(int64(x.PostalCode))
+	w.BeginArray()
+	for i1 := range x.Location {
+		w.WriteFloat(float64(x.Location[i1]), 64)
+	}
+	w.EndList()
+	return nil
+}
+
+// UnmarshalStorage decodes a record produced by MarshalStorage or encoding.Encode into x.
+func (x *Address) UnmarshalStorage(record string) error {
+	r, err := encoding.NewReader(record, 4)
+	if err != nil {
+		return err
+	}
+	defer r.Release()
+	return x.readStorage(r)
+}
+
+func (x *Address) readStorage(r *encoding.Reader) error {
+	v1, err := r.ReadString()
+	if err != nil {
+		return err
+	}
+	x.Street = string(v1)
+	v2, err := r.ReadString()
+	if err != nil {
+		return err
+	}
+	x.City = string(v2)
+	v3, err := r.ReadInt(0)
+	if err != nil {
+		return err
+	}
+	x.PostalCode = int(v3)
+	if err := r.BeginArray(len(x.Location)); err != nil {
+		return err
+	}
+	for i4 := range x.Location {
+		v5, err := r.ReadFloat(64)
+		if err != nil {
+			return err
+		}
+		x.Location[i4] = float64(v5)
+	}
+	r.EndList()
+	return nil
+}
+
+// MarshalStorage encodes x in the format of encoding.Encode.
+func (x Order) MarshalStorage() (string, error) {
+	w := encoding.NewWriter()
+	defer w.Release()
+	if err := x.writeStorage(w); err != nil {
+		return "", err
+	}
+	return w.Record(), nil
+}
+
+func (x *Order) writeStorage(w *encoding.Writer) error {
+	w.WriteString(string(x.Id))
+	if x.Customer == nil {
+		w.WriteNil()
+	} else {
+		w.WritePtr()
+		w.BeginStruct()
+		if err := (*x.Customer).writeStorage(w); err != nil {
+			return err
+		}
+		w.EndStruct()
+	}
+	w.BeginArray()
+	for i1 := range x.Lines {
+		w.WriteUint(uint64(x.Lines[i1]))
+	}
+	w.EndList()
+	if x.Shipping == nil {
+		w.WriteNil()
+	} else {
+		w.WritePtr()
+		w.BeginStruct()
+		if err := (*x.Shipping).writeStorage(w); err != nil {
+			return err
+		}
+		w.EndStruct()
+	}
+	keys2 := make([]Status, 0, len(x.Notes))
+	for k3 := range x.Notes {
+		keys2 = append(keys2, k3)
+	}
+	sort.Slice(keys2, func(i, j int) bool { return keys2[i] < keys2[j] })
+	w.BeginMap(x.Notes == nil)
+	for _, k3 := range keys2 {
+		w.WriteString(string(k3))
+		v4 := x.Notes[k3]
+		w.BeginList(v4 == nil)
+		for i5 := range v4 {
+			w.WriteString(string(v4[i5]))
+		}
+		w.EndList()
+	}
+	w.EndMap()
+	return nil
+}
+
+// UnmarshalStorage decodes a record produced by MarshalStorage or encoding.Encode into x.
+func (x *Order) UnmarshalStorage(record string) error {
+	r, err := encoding.NewReader(record, 5)
+	if err != nil {
+		return err
+	}
+	defer r.Release()
+	return x.readStorage(r)
+}
+
+func (x *Order) readStorage(r *encoding.Reader) error {
+	v1, err := r.ReadString()
+	if err != nil {
+		return err
+	}
+	x.Id = string(v1)
+	isNil2, err := r.ReadPtr()
+	if err != nil {
+		return err
+	}
+	if isNil2 {
+		x.Customer = nil
+	} else {
+		x.Customer = new(Person)
+		if err := r.BeginStruct(22); err != nil {
+			return err
+		}
+		if err := (*x.Customer).readStorage(r); err != nil {
+			return fmt.Errorf("%v: %w", err, encoding.ErrParseStruct)
+		}
+		r.EndStruct()
+	}
+	if err := r.BeginArray(len(x.Lines)); err != nil {
+		return err
+	}
+	for i3 := range x.Lines {
+		v4, err := r.ReadUint(16)
+		if err != nil {
+			return err
+		}
+		x.Lines[i3] = uint16(v4)
+	}
+	r.EndList()
+	isNil5, err := r.ReadPtr()
+	if err != nil {
+		return err
+	}
+	if isNil5 {
+		x.Shipping = nil
+	} else {
+		x.Shipping = new(Address)
+		if err := r.BeginStruct(4); err != nil {
+			return err
+		}
+		if err := (*x.Shipping).readStorage(r); err != nil {
+			return fmt.Errorf("%v: %w", err, encoding.ErrParseStruct)
+		}
+		r.EndStruct()
+	}
+	n6, isNil7, err := r.BeginMap()
+	if err != nil {
+		return err
+	}
+	if isNil7 {
+		x.Notes = nil
+	} else {
+		x.Notes = make(map[Status][]string, n6)
+		for i8 := 0; i8 < n6; i8++ {
+			var k9 Status
+			v11, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			k9 = Status(v11)
+			var v10 []string
+			n12, isNil13, err := r.BeginList()
+			if err != nil {
+				return err
+			}
+			if isNil13 {
+				v10 = nil
+			} else {
+				v10 = make([]string, n12)
+				for i14 := range v10 {
+					v15, err := r.ReadString()
+					if err != nil {
+						return err
+					}
+					v10[i14] = string(v15)
+				}
+			}
+			r.EndList()
+			x.Notes[k9] = v10
+		}
+	}
+	r.EndMap()
+	return nil
+}
+
+// MarshalStorage encodes x in the format of encoding.Encode.
+func (x Profile) MarshalStorage() (string, error) {
+	w := encoding.NewWriter()
+	defer w.Release()
+	if err := x.writeStorage(w); err != nil {
+		return "", err
+	}
+	return w.Record(), nil
+}
+
+func (x *Profile) writeStorage(w *encoding.Writer) error {
+	w.WriteString(string(x.Draft.Body))
+	w.WriteInt(int64(x.Id))
+	return nil
+}
+
+// UnmarshalStorage decodes a record produced by MarshalStorage or encoding.Encode into x.
+func (x *Profile) UnmarshalStorage(record string) error {
+	r, err := encoding.NewReader(record, 2)
+	if err != nil {
+		return err
+	}
+	defer r.Release()
+	return x.readStorage(r)
+}
+
+func (x *Profile) readStorage(r *encoding.Reader) error {
+	v1, err := r.ReadString()
+	if err != nil {
+		return err
+	}
+	x.Draft.Body = string(v1)
+	v2, err := r.ReadInt(0)
+	if err != nil {
+		return err
+	}
+	x.Id = int(v2)
+	return nil
+}
@@ -0,0 +1,213 @@
+package records
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/priyanshujain/go-storage/encoding"
+)
+
+func samplePerson() Person {
+	nickname := ""
+	manager := Person{Base: Base{Id: "m"}, Name: "Manager", Tags: Tags{"boss"}}
+	return Person{
+		Base:     Base{Id: "p-1", Version: 7},
+		audit:    audit{CreatedBy: "admin"},
+		Name:     "Jane",
+		Age:      -41,
+		Score:    0.1,
+		Active:   true,
+		Initial:  'J',
+		Status:   "active",
+		Tags:     Tags{"a", "", "c"},
+		Home:     Address{Street: "Main", City: "Pune", PostalCode: 411001, Location: [2]float64{18.52, 73.85}},
+		Previous: []Address{{City: "Delhi"}, {City: "Mumbai", PostalCode: 1}},
+		Manager:  &manager,
+		Nickname: &nickname,
+		Counters: map[string]int64{"z": 1, "a": -2, "m": 3},
+		ByLevel:  map[int]*Address{2: nil, -1: {City: "Goa"}, 10: {}},
+		Flags:    map[bool]string{true: "yes", false: "no"},
+		Weights:  map[float64]string{1.5: "x", -3: "y"},
+		Matrix:   [][]int8{{1, 2}, {-128, 127}},
+		Event:    Shipped{Carrier: "ups"},
+		Extra:    Shipped{Carrier: "dhl"},
+	}
+}
+
+func TestGeneratedCodecs(t *testing.T) {
+	shipping := Address{City: "Pune"}
+	records := []struct {
+		name    string
+		record  interface{}
+		decoded func() interface{}
+	}{
+		{"zero person", Person{}, func() interface{} { return &Person{} }},
+		{"populated person", samplePerson(), func() interface{} { return &Person{} }},
+		{"empty collections", Person{
+			Tags:     Tags{},
+			Previous: []Address{},
+			Counters: map[string]int64{},
+			ByLevel:  map[int]*Address{0: nil},
+			Flags:    map[bool]string{},
+			Matrix:   [][]int8{{}, nil, {0}},
+		}, func() interface{} { return &Person{} }},
+		{"zero elements", Person{
+			Tags:     Tags{""},
+			Previous: []Address{{}},
+			Matrix:   [][]int8{nil},
+		}, func() interface{} { return &Person{} }},
+		{"zero address", Address{}, func() interface{} { return &Address{} }},
+		{"zero order", Order{}, func() interface{} { return &Order{} }},
+		{"populated order", Order{
+			Id:       "o-1",
+			Customer: &Person{Name: "Jane", Age: 30},
+			Lines:    [3]uint16{1, 0, 65535},
+			Shipping: &shipping,
+			Notes:    map[Status][]string{"late": {"call"}, "gift": {"wrap", "card"}},
+		}, func() interface{} { return &Order{} }},
+		{"zero profile", Profile{}, func() interface{} { return &Profile{} }},
+	}
+
+	for _, tt := range records {
+		t.Run(tt.name, func(t *testing.T) {
+			reflective, err := encoding.Encode(tt.record, encoding.IgnoreMarshalers())
+			if err != nil {
+				t.Fatalf("Failed to encode with reflection: %v", err)
+			}
+			generated, err := tt.record.(encoding.Marshaler).MarshalStorage()
+			if err != nil {
+				t.Fatalf("Failed to encode with generated code: %v", err)
+			}
+			if generated != reflective {
+				t.Fatalf("Generated and reflective encodings differ.\nGenerated:  %q\nReflective: %q", generated, reflective)
+			}
+			preferred, err := encoding.Encode(tt.record)
+			if err != nil || preferred != generated {
+				t.Fatalf("Expected Encode to use the generated method, got %q (%v)", preferred, err)
+			}
+
+			fromGenerated := tt.decoded()
+			if err := fromGenerated.(encoding.Unmarshaler).UnmarshalStorage(reflective); err != nil {
+				t.Fatalf("Failed to decode with generated code: %v", err)
+			}
+			fromReflection := tt.decoded()
+			if err := encoding.Decode(generated, fromReflection, encoding.IgnoreMarshalers()); err != nil {
+				t.Fatalf("Failed to decode with reflection: %v", err)
+			}
+			if !reflect.DeepEqual(fromGenerated, fromReflection) {
+				t.Errorf("Generated and reflective decodings differ.\nGenerated:  %+v\nReflective: %+v", fromGenerated, fromReflection)
+			}
+			if !reflect.DeepEqual(reflect.ValueOf(fromGenerated).Elem().Interface(), tt.record) {
+				t.Errorf("Decoded record does not match.\nExpected: %+v\nGot: %+v", tt.record, fromGenerated)
+			}
+		})
+	}
+}
+
+func TestGeneratedCodecsSkipUnexportedFields(t *testing.T) {
+	person := samplePerson()
+	person.private = "secret"
+	encodedData, err := encoding.Encode(person)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	decodedData := Person{private: "untouched"}
+	if err := encoding.Decode(encodedData, &decodedData); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if decodedData.private != "untouched" {
+		t.Errorf("Expected unexported field to be left alone, got %q", decodedData.private)
+	}
+}
+
+func TestGeneratedCodecsShadowedFields(t *testing.T) {
+	profile := Profile{
+		Base:     Base{Id: "hidden", Version: 1},
+		Revision: Revision{Version: 2, Label: "a"},
+		Draft:    Draft{Label: "b", Body: "text"},
+		Id:       3,
+	}
+	reflective, err := encoding.Encode(profile, encoding.IgnoreMarshalers())
+	if err != nil {
+		t.Fatalf("Failed to encode with reflection: %v", err)
+	}
+	generated, err := profile.MarshalStorage()
+	if err != nil {
+		t.Fatalf("Failed to encode with generated code: %v", err)
+	}
+	if generated != reflective {
+		t.Fatalf("Generated and reflective encodings differ.\nGenerated:  %q\nReflective: %q", generated, reflective)
+	}
+
+	// only the fields not shadowed are encoded
+	expected := Profile{Draft: Draft{Body: "text"}, Id: 3}
+	var fromGenerated, fromReflection Profile
+	if err := fromGenerated.UnmarshalStorage(reflective); err != nil {
+		t.Fatalf("Failed to decode with generated code: %v", err)
+	}
+	if err := encoding.Decode(generated, &fromReflection, encoding.IgnoreMarshalers()); err != nil {
+		t.Fatalf("Failed to decode with reflection: %v", err)
+	}
+	for _, decoded := range []Profile{fromGenerated, fromReflection} {
+		if decoded != expected {
+			t.Errorf("Expected %+v but got %+v", expected, decoded)
+		}
+	}
+}
+
+func FuzzPerson(f *testing.F) {
+	record, err := encoding.Encode(samplePerson())
+	if err != nil {
+		f.Fatalf("Failed to encode seed: %v", err)
+	}
+	f.Add(record)
+	f.Add("")
+	f.Fuzz(func(t *testing.T, record string) {
+		var decoded Person
+		if err := decoded.UnmarshalStorage(record); err != nil {
+			return
+		}
+		encoded, err := decoded.MarshalStorage()
+		if err != nil {
+			t.Fatalf("Failed to encode a decoded record: %v", err)
+		}
+		var again Person
+		if err := again.UnmarshalStorage(encoded); err != nil {
+			t.Fatalf("Failed to decode %q: %v", encoded, err)
+		}
+		if reencoded, _ := again.MarshalStorage(); reencoded != encoded {
+			t.Fatalf("Expected %q but got %q", encoded, reencoded)
+		}
+	})
+}
+
+func BenchmarkPerson(b *testing.B) {
+	person := samplePerson()
+	encodedData, _ := encoding.Encode(person)
+	b.Run("encode generated", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = encoding.Encode(person)
+		}
+	})
+	b.Run("encode reflective", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = encoding.Encode(person, encoding.IgnoreMarshalers())
+		}
+	})
+	b.Run("decode generated", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var decodedData Person
+			_ = encoding.Decode(encodedData, &decodedData)
+		}
+	})
+	b.Run("decode reflective", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var decodedData Person
+			_ = encoding.Decode(encodedData, &decodedData, encoding.IgnoreMarshalers())
+		}
+	})
+}
@@ -0,0 +1,62 @@
+// Storagegen generates reflection-free MarshalStorage and UnmarshalStorage
+// methods for record types. The methods produce and read exactly the format
+// of the encoding package, and encoding.Encode and encoding.Decode use them
+// automatically when they are present.
+//
+// Usage:
+//
+//	//go:generate go run github.com/priyanshujain/go-storage/cmd/storagegen -type Person,Address
+//
+// The named types must be structs declared in the package in the current
+// directory, or in the directory given as argument. Fields of types the
+// generator has no specialised code for, such as interfaces or types from
+// other packages, are encoded through reflection.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of type names; must be set")
+	output    = flag.String("output", "", "output file name; default srcdir/<type>_storage.go")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of storagegen:\n")
+	fmt.Fprintf(os.Stderr, "\tstoragegen -type T [directory]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	types := strings.Split(*typeNames, ",")
+
+	outputName := *output
+	if outputName == "" {
+		outputName = filepath.Join(dir, strings.ToLower(types[0])+"_storage.go")
+	}
+
+	src, err := generate(dir, types, filepath.Base(outputName))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "storagegen: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(outputName, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "storagegen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Run("golden", func(t *testing.T) {
+		dir := filepath.Join("internal", "records")
+		src, err := generate(dir, []string{"Person", "Address", "Order", "Profile"}, "records_storage.go")
+		if err != nil {
+			t.Fatalf("Failed to generate: %v", err)
+		}
+		golden, err := os.ReadFile(filepath.Join(dir, "records_storage.go"))
+		if err != nil {
+			t.Fatalf("Failed to read golden file: %v", err)
+		}
+		if !bytes.Equal(src, golden) {
+			t.Error("Expected the generated code to match records_storage.go, run go generate ./...")
+		}
+	})
+
+	tests := []struct {
+		name  string
+		src   string
+		types []string
+		err   string
+	}{
+		{"not a struct", "type Name string\n", []string{"Name"}, "is not a struct"},
+		{"unknown type", "type Name string\n", []string{"Missing"}, "is not a struct"},
+		{"chan field", "type Job struct {\n\tDone chan bool\n}\n", []string{"Job"}, "field Done: unsupported type chan bool"},
+		{"func field", "type Job struct {\n\tRun func()\n}\n", []string{"Job"}, "field Run: unsupported type func()"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := "package jobs\n\n" + tt.src
+			if err := os.WriteFile(filepath.Join(dir, "jobs.go"), []byte(src), 0644); err != nil {
+				t.Fatalf("Failed to write source: %v", err)
+			}
+			_, err := generate(dir, tt.types, "jobs_storage.go")
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected an error containing %q but got %v", tt.err, err)
+			}
+		})
+	}
+
+	t.Run("empty directory", func(t *testing.T) {
+		if _, err := generate(t.TempDir(), []string{"Job"}, "job_storage.go"); err == nil {
+			t.Error("Expected an error but got nil")
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/priyanshujain/go-storage/encoding"
+	"github.com/priyanshujain/go-storage/query"
+)
+
+// start an aggregation over the table of tableType
+func (db *Database) Aggregate(ctx context.Context, tableType interface{}) *query.Aggregation {
+	return query.NewAggregation(ctx, db, tableType)
+}
+
+// run an aggregation, records are decoded one at a time and only when the
+// aggregation can not be answered from an index
+func (db *Database) ExecuteAggregate(ctx context.Context, spec query.AggregateSpec) ([]query.Group, error) {
+	if err := db.lock.RLock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.lock.RUnlock()
+
+	tableName := reflect.TypeOf(spec.TableType).Name()
+	table, ok := db.Tables[tableName]
+	if !ok {
+		return nil, ErrInvalidTableName
+	}
+	if err := spec.Check(table.Fields); err != nil {
+		return nil, err
+	}
+	acc := query.NewAccumulator(spec, table.Fields)
+
+	fields := spec.Fields()
+	if len(fields) == 0 {
+		if err := acc.AddN(nil, len(table.live(table.Records))); err != nil {
+			return nil, err
+		}
+		return acc.Groups(), nil
+	}
+	// the entries of an index include expired records
+	if index, ok := table.Indexes[fields[0]]; ok && len(fields) == 1 && !table.expiring {
+		if err := index.aggregate(ctx, acc); err != nil {
+			return nil, err
+		}
+		return acc.Groups(), nil
+	}
+
+	// only the fields of the aggregation are decoded
+	candidates, _ := table.candidates(spec.Conditions)
+	record := reflect.New(table.Fields)
+	for i, r := range candidates {
+		if err := interrupted(ctx, i); err != nil {
+			return nil, err
+		}
+		record.Elem().Set(reflect.Zero(table.Fields))
+		if err := encoding.Decode(r.Value, record.Interface(), encoding.Fields(fields...)); err != nil {
+			return nil, fmt.Errorf("error decoding record: %v %w", err, ErrInvalidEncoding)
+		}
+		if err := acc.Add(record.Interface()); err != nil {
+			return nil, err
+		}
+	}
+	return acc.Groups(), nil
+}
+
+// aggregate adds each run of equal values of the index to the accumulator
+func (idx *Index) aggregate(ctx context.Context, acc *query.Accumulator) error {
+	for start := 0; start < len(idx.entries); {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := start + 1
+		for end < len(idx.entries) {
+			result, _ := query.Compare(idx.entries[start].value, idx.entries[end].value)
+			if result != 0 {
+				break
+			}
+			end++
+		}
+		if err := acc.AddN(idx.entries[start].value, end-start); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
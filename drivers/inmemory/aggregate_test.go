@@ -0,0 +1,155 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/priyanshujain/go-storage/query"
+)
+
+func TestDatabase_Aggregate(t *testing.T) {
+	ctx := context.Background()
+	db := newPeopleDatabase(t, 100)
+
+	// expected groups by city of people older than 30 computed by hand
+	type stats struct {
+		count, ageSum, maxAge int
+		scoreSum              float64
+		scores                int
+		minName               string
+		ages                  map[int]bool
+	}
+	byCity := make(map[string]*stats)
+	for i := 0; i < 100; i++ {
+		p := newPerson(i, 100)
+		if p.Age <= 30 {
+			continue
+		}
+		s, ok := byCity[p.City]
+		if !ok {
+			s = &stats{minName: p.Name, ages: make(map[int]bool)}
+			byCity[p.City] = s
+		}
+		s.count++
+		s.ageSum += p.Age
+		s.ages[p.Age] = true
+		if p.Age > s.maxAge {
+			s.maxAge = p.Age
+		}
+		if p.Name < s.minName {
+			s.minName = p.Name
+		}
+		if p.Score != nil {
+			s.scoreSum += *p.Score
+			s.scores++
+		}
+	}
+	var expected []query.Group
+	for _, city := range []string{"Delhi", "Mumbai", "Pune"} {
+		s := byCity[city]
+		expected = append(expected, query.Group{
+			Key:    []interface{}{city},
+			Values: []interface{}{s.count, int64(s.ageSum), s.scoreSum / float64(s.scores), s.minName, s.maxAge, len(s.ages)},
+		})
+	}
+
+	aggregate := func() *query.Aggregation {
+		return db.Aggregate(ctx, Person{}).Where("Age", ">", 30).GroupBy("City").
+			Count().Sum("Age").Avg("Score").Min("Name").Max("Age").CountDistinct("Age")
+	}
+	run := func(t *testing.T) {
+		groups, err := aggregate().Run()
+		if err != nil {
+			t.Fatalf("Failed to run aggregation: %v", err)
+		}
+		if !reflect.DeepEqual(groups, expected) {
+			t.Errorf("Expected %v but got %v", expected, groups)
+		}
+	}
+
+	t.Run("without indexes", run)
+	for _, field := range []string{"Age", "City"} {
+		if err := db.CreateIndex(ctx, Person{}, field); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+	}
+	t.Run("with indexes", run)
+
+	t.Run("from an index", func(t *testing.T) {
+		// answered without decoding, so the values of the records are not
+		// needed
+		db := newPeopleDatabase(t, 100)
+		if err := db.CreateIndex(ctx, Person{}, "Age"); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+		for _, r := range db.Tables["Person"].Records {
+			r.Value = "invalid"
+		}
+
+		groups, err := db.Aggregate(ctx, Person{}).Where("Age", ">=", 57).GroupBy("Age").Count().Sum("Age").Run()
+		if err != nil {
+			t.Fatalf("Failed to run aggregation: %v", err)
+		}
+		expected := []query.Group{
+			{Key: []interface{}{57}, Values: []interface{}{2, int64(114)}},
+			{Key: []interface{}{58}, Values: []interface{}{2, int64(116)}},
+			{Key: []interface{}{59}, Values: []interface{}{2, int64(118)}},
+		}
+		if !reflect.DeepEqual(groups, expected) {
+			t.Errorf("Expected %v but got %v", expected, groups)
+		}
+
+		groups, err = db.Aggregate(ctx, Person{}).Count().Run()
+		if err != nil {
+			t.Fatalf("Failed to run aggregation: %v", err)
+		}
+		if !reflect.DeepEqual(groups[0].Values, []interface{}{100}) {
+			t.Errorf("Expected a count of 100 but got %v", groups[0].Values)
+		}
+
+		_, err = db.Aggregate(ctx, Person{}).GroupBy("City").Count().Run()
+		if !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("Expected %v without an index but got %v", ErrInvalidEncoding, err)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			aggregation *query.Aggregation
+			err         error
+		}{
+			{"invalid table", db.Aggregate(ctx, ExampleStruct{}).Count(), ErrInvalidTableName},
+			{"invalid field", db.Aggregate(ctx, Person{}).Sum("Missing"), query.ErrInvalidField},
+			{"sum of strings", db.Aggregate(ctx, Person{}).Sum("City"), query.ErrInvalidAggregate},
+			{"invalid operator", db.Aggregate(ctx, Person{}).Where("Age", "like", 1).Count(), query.ErrInvalidOperator},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := tt.aggregation.Run(); !errors.Is(err, tt.err) {
+					t.Errorf("Expected %v but got %v", tt.err, err)
+				}
+			})
+		}
+	})
+}
+
+func BenchmarkDatabase_Aggregate(b *testing.B) {
+	ctx := context.Background()
+	db := newPeopleDatabase(b, 10000)
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = db.Aggregate(ctx, Person{}).GroupBy("Age").Count().Run()
+		}
+	})
+	if err := db.CreateIndex(ctx, Person{}, "Age"); err != nil {
+		b.Fatalf("Failed to create index: %v", err)
+	}
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = db.Aggregate(ctx, Person{}).GroupBy("Age").Count().Run()
+		}
+	})
+}
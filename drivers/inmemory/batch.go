@@ -0,0 +1,347 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/priyanshujain/go-storage/encoding"
+	"github.com/priyanshujain/go-storage/query"
+	"github.com/priyanshujain/go-storage/schema"
+)
+
+// start a batch of writes across the tables of the database
+func (db *Database) Batch(ctx context.Context) *query.Batch {
+	return query.NewBatch(ctx, db)
+}
+
+// insert records of one or more tables, either all of them are inserted or
+// none
+func (db *Database) InsertMany(ctx context.Context, records ...interface{}) error {
+	return db.Batch(ctx).Insert(records...).Commit()
+}
+
+// apply the writes of a batch, every write is checked before the first one
+// is applied so that a failing or cancelled batch leaves the tables
+// untouched. After hooks run once the batch is applied, their errors are
+// joined and wrap schema.ErrAfterHook.
+func (db *Database) ExecuteBatch(ctx context.Context, ops []query.Op) error {
+	writes, err := db.executeBatch(ctx, ops)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, w := range writes {
+		if w.kind != query.DeleteOp {
+			setRecord(w.target, w.record)
+		}
+		if err := db.afterHook(ctx, w); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (db *Database) executeBatch(ctx context.Context, ops []query.Op) ([]write, error) {
+	if err := db.lock.Lock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.lock.Unlock()
+
+	if err := db.track(); err != nil {
+		return nil, err
+	}
+	writes, err := db.prepare(ctx, ops)
+	if err != nil {
+		return nil, err
+	}
+	db.apply(writes)
+	db.evict()
+	return writes, nil
+}
+
+// afterHook runs the after hooks of an applied write, their error wraps
+// schema.ErrAfterHook
+func (db *Database) afterHook(ctx context.Context, w write) error {
+	var err error
+	switch {
+	case w.kind == query.InsertOp:
+		_, err = db.hookRecord(ctx, schema.AfterInsert, w.table, w.record)
+	case w.kind == query.UpdateOp:
+		_, err = db.hookRecord(ctx, schema.AfterUpdate, w.table, w.record)
+	case w.old != nil && db.hooked(schema.AfterDelete, w.table):
+		_, err = db.hookRecord(ctx, schema.AfterDelete, w.table, reflect.Indirect(reflect.ValueOf(w.old)).Interface())
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", schema.ErrAfterHook, err)
+	}
+	return nil
+}
+
+// write is a checked write of a batch. Inserts and updates hold the record
+// and its encoding, updates and deletes hold the record they replace when
+// the table has indexes. Target is the record as passed to the batch.
+type write struct {
+	kind   string
+	table  *Table
+	pk     string
+	record interface{}
+	value  string
+	old    interface{}
+	target interface{}
+	// expires is the expiry of an insert
+	expires int64
+}
+
+// stagedRecord is a record as left by the earlier writes of a batch, record
+// is nil until it is needed to update the indexes
+type stagedRecord struct {
+	exists bool
+	record interface{}
+}
+
+// staging holds the writes of a batch checked so far and the records they
+// leave by table and primary key
+type staging struct {
+	db     *Database
+	staged map[*Table]map[string]stagedRecord
+	writes []write
+}
+
+// current returns a record as left by the staged writes
+func (s *staging) current(t *Table, pk string) (stagedRecord, error) {
+	if staged, ok := s.staged[t][pk]; ok {
+		return staged, nil
+	}
+	r, found := t.find(pk)
+	if !found || len(t.Indexes) == 0 {
+		return stagedRecord{exists: found}, nil
+	}
+	record := reflect.New(t.Fields).Interface()
+	if err := encoding.Decode(r.Value, record); err != nil {
+		return stagedRecord{}, fmt.Errorf("error decoding record: %v %w", err, ErrInvalidEncoding)
+	}
+	return stagedRecord{exists: true, record: record}, nil
+}
+
+// add checks and stages a write, followed by the writes of the delete
+// actions of the records referencing a deleted record
+func (s *staging) add(ctx context.Context, op query.Op) error {
+	w, err := s.db.prepareWrite(ctx, op, s.current)
+	if err != nil {
+		return err
+	}
+	if s.staged[w.table] == nil {
+		s.staged[w.table] = make(map[string]stagedRecord)
+	}
+	s.staged[w.table][w.pk] = stagedRecord{exists: w.kind != query.DeleteOp, record: w.record}
+	s.writes = append(s.writes, w)
+	if w.kind == query.DeleteOp && len(w.table.referencedBy) > 0 {
+		return s.cascade(ctx, w)
+	}
+	return nil
+}
+
+// prepare checks the writes of a batch against the tables and the earlier
+// writes of the batch, the error lists every invalid write
+func (db *Database) prepare(ctx context.Context, ops []query.Op) ([]write, error) {
+	s := &staging{
+		db:     db,
+		staged: make(map[*Table]map[string]stagedRecord),
+		writes: make([]write, 0, len(ops)),
+	}
+	var errs []query.OpError
+	for i, op := range ops {
+		if err := interrupted(ctx, i); err != nil {
+			return nil, err
+		}
+		if err := s.add(ctx, op); err != nil {
+			errs = append(errs, query.OpError{Index: i, Op: op, Err: err})
+		}
+	}
+	if errs != nil {
+		return nil, &query.BatchError{Errors: errs}
+	}
+	return s.writes, nil
+}
+
+// prepareWrite checks a single write of a batch
+func (db *Database) prepareWrite(ctx context.Context, op query.Op, current func(*Table, string) (stagedRecord, error)) (write, error) {
+	w := write{kind: op.Kind, target: op.Record}
+	switch op.Kind {
+	case query.InsertOp, query.UpdateOp:
+		if op.Record == nil {
+			return write{}, fmt.Errorf("%s without a record: %w", op.Kind, query.ErrInvalidOp)
+		}
+		w.record = recordValue(op.Record)
+		tableName := reflect.TypeOf(w.record).Name()
+		table, ok := db.Tables[tableName]
+		if !ok {
+			return write{}, ErrInvalidTableName
+		}
+		w.table = table
+		event := schema.BeforeUpdate
+		if op.Kind == query.InsertOp {
+			record, err := table.generateKey(w.record)
+			if err != nil {
+				return write{}, err
+			}
+			w.record, event = record, schema.BeforeInsert
+		}
+		record, err := db.hookRecord(ctx, event, table, w.record)
+		if err != nil {
+			return write{}, err
+		}
+		w.record = record
+		if op.Kind == query.InsertOp {
+			w.record, w.expires = table.expires(w.record, 0)
+		}
+		w.pk = table.recordKey(w.record)
+	case query.DeleteOp:
+		if op.TableType == nil {
+			return write{}, fmt.Errorf("%s without a table: %w", op.Kind, query.ErrInvalidOp)
+		}
+		table, ok := db.Tables[reflect.TypeOf(op.TableType).Name()]
+		if !ok {
+			return write{}, ErrInvalidTableName
+		}
+		key, err := table.key(op.Pk)
+		if err != nil {
+			return write{}, err
+		}
+		w.table, w.pk = table, key
+	default:
+		return write{}, fmt.Errorf("%q: %w", op.Kind, query.ErrInvalidOp)
+	}
+
+	s, err := current(w.table, w.pk)
+	if err != nil {
+		return write{}, err
+	}
+	switch {
+	case w.kind == query.InsertOp && s.exists:
+		return write{}, ErrDuplicateRecord
+	case w.kind != query.InsertOp && !s.exists:
+		return write{}, ErrRecordNotFound
+	}
+	w.old = s.record
+	if w.kind == query.DeleteOp && w.old == nil && len(w.table.referencedBy) > 0 {
+		// cascades look for the records referencing the deleted key
+		r, _ := w.table.find(w.pk)
+		if w.old, err = w.table.decode(r); err != nil {
+			return write{}, err
+		}
+	}
+	if w.kind == query.DeleteOp && (db.hooked(schema.BeforeDelete, w.table) || db.hooked(schema.AfterDelete, w.table)) {
+		if w.old == nil {
+			r, _ := w.table.find(w.pk)
+			if w.old, err = w.table.decode(r); err != nil {
+				return write{}, err
+			}
+		}
+		// the hooks get a copy, the old record is used to update the indexes
+		deleted := reflect.New(w.table.Fields)
+		deleted.Elem().Set(reflect.Indirect(reflect.ValueOf(w.old)))
+		if err := db.runHooks(ctx, schema.BeforeDelete, w.table, deleted.Interface()); err != nil {
+			return write{}, err
+		}
+	}
+	if w.kind != query.DeleteOp {
+		if err := w.table.check(w.record); err != nil {
+			return write{}, err
+		}
+		if err := w.table.checkReferences(w.record, w.pk, current); err != nil {
+			return write{}, err
+		}
+		if w.value, err = encoding.Encode(w.record); err != nil {
+			return write{}, fmt.Errorf("error encoding record: %v %w", err, ErrInvalidEncoding)
+		}
+	}
+	return w, nil
+}
+
+// apply the checked writes of a batch in order. Inserts are collected and
+// merged into the records and indexes of their table together, they are
+// merged before a later update or delete of the table.
+func (db *Database) apply(writes []write) {
+	flush := func(t *Table, pending []write) {
+		for _, r := range t.insertAll(pending) {
+			db.cache.added(t, r)
+		}
+	}
+	inserts := make(map[*Table][]write)
+	for _, w := range writes {
+		if w.kind == query.InsertOp {
+			db.dropExpired(w.table, w.pk)
+			inserts[w.table] = append(inserts[w.table], w)
+			db.publish(w, nil)
+			continue
+		}
+		if pending, ok := inserts[w.table]; ok {
+			flush(w.table, pending)
+			delete(inserts, w.table)
+		}
+
+		i, _ := w.table.search(w.pk)
+		r := w.table.Records[i]
+		db.publish(w, r)
+		w.table.removeEntries(w.old, r)
+		if w.kind == query.UpdateOp {
+			r.Value = w.value
+			w.table.setExpiry(r, w.record, r.Expires)
+			w.table.addEntries(w.record, r)
+			db.cache.updated(r)
+		} else {
+			db.cache.removed(r)
+			w.table.Records = append(w.table.Records[:i], w.table.Records[i+1:]...)
+		}
+	}
+	for table, pending := range inserts {
+		flush(table, pending)
+	}
+}
+
+// insertAll merges the records of inserts with distinct new primary keys into
+// the records and indexes of the table, it returns the records inserted
+func (t *Table) insertAll(inserts []write) []*Record {
+	sort.Slice(inserts, func(i, j int) bool {
+		return inserts[i].pk < inserts[j].pk
+	})
+	records := make([]*Record, len(inserts))
+	for i, w := range inserts {
+		records[i] = &Record{Key: w.pk, Value: w.value}
+		t.setExpiry(records[i], w.record, w.expires)
+	}
+	t.Records = merge(t.Records, records, func(a, b *Record) bool {
+		return a.Key < b.Key
+	})
+
+	for field, index := range t.Indexes {
+		entries := make([]indexEntry, len(inserts))
+		for i, w := range inserts {
+			value, _ := query.Field(w.record, field)
+			entries[i] = indexEntry{value: value, record: records[i]}
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return index.less(entries[i], entries[j])
+		})
+		index.entries = merge(index.entries, entries, index.less)
+	}
+	return records
+}
+
+// merge returns the elements of a and b, both sorted by less, in order
+func merge[T any](a, b []T, less func(x, y T) bool) []T {
+	merged := make([]T, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if less(b[0], a[0]) {
+			merged, b = append(merged, b[0]), b[1:]
+		} else {
+			merged, a = append(merged, a[0]), a[1:]
+		}
+	}
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
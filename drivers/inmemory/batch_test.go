@@ -0,0 +1,198 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/priyanshujain/go-storage/query"
+)
+
+func TestDatabase_InsertMany(t *testing.T) {
+	ctx := context.Background()
+	db := newPeopleDatabase(t, 0)
+	if err := db.CreateIndex(ctx, Person{}, "City"); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	var people []interface{}
+	for _, i := range []int{3, 0, 5, 1, 4, 2} {
+		person := newPerson(i, 6)
+		people = append(people, &person)
+	}
+	if err := db.InsertMany(ctx, people...); err != nil {
+		t.Fatalf("Failed to insert records: %v", err)
+	}
+
+	records, err := db.Query(ctx, Person{}).All()
+	if err != nil {
+		t.Fatalf("Failed to run query: %v", err)
+	}
+	expected := []string{"p000", "p001", "p002", "p003", "p004", "p005"}
+	if !reflect.DeepEqual(ids(records), expected) {
+		t.Errorf("Expected %v but got %v", expected, ids(records))
+	}
+	records, err = db.Query(ctx, Person{}).Where("City", "=", "Delhi").All()
+	if err != nil {
+		t.Fatalf("Failed to run query: %v", err)
+	}
+	if !reflect.DeepEqual(ids(records), []string{"p001", "p004"}) {
+		t.Errorf("Expected the indexed records of Delhi but got %v", ids(records))
+	}
+
+	t.Run("all or nothing", func(t *testing.T) {
+		err := db.InsertMany(ctx, newPerson(6, 10), newPerson(2, 6), ExampleStruct{ID: "e1"}, newPerson(7, 10))
+		var batchErr *query.BatchError
+		if !errors.As(err, &batchErr) {
+			t.Fatalf("Expected a batch error but got %v", err)
+		}
+		if len(batchErr.Errors) != 2 || batchErr.Errors[0].Index != 1 || batchErr.Errors[1].Index != 2 {
+			t.Errorf("Expected the errors of writes 1 and 2 but got %v", err)
+		}
+		if !errors.Is(err, ErrDuplicateRecord) || !errors.Is(err, ErrInvalidTableName) || !errors.Is(err, query.ErrBatch) {
+			t.Errorf("Expected duplicate record and invalid table errors but got %v", err)
+		}
+		if _, err := db.Get(ctx, Person{}, "p006"); err != ErrRecordNotFound {
+			t.Errorf("Expected %v but got %v", ErrRecordNotFound, err)
+		}
+	})
+
+	t.Run("duplicates within the batch", func(t *testing.T) {
+		err := db.InsertMany(ctx, newPerson(8, 10), newPerson(8, 10))
+		if !errors.Is(err, ErrDuplicateRecord) {
+			t.Errorf("Expected %v but got %v", ErrDuplicateRecord, err)
+		}
+		if _, err := db.Get(ctx, Person{}, "p008"); err != ErrRecordNotFound {
+			t.Errorf("Expected %v but got %v", ErrRecordNotFound, err)
+		}
+	})
+}
+
+func TestDatabase_Batch(t *testing.T) {
+	ctx := context.Background()
+	db := newPeopleDatabase(t, 4)
+	if err := db.CreateIndex(ctx, Person{}, "Age"); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	err := db.Batch(ctx).
+		Insert(Person{Id: "p010", Name: "new", Age: 30}).
+		Update(Person{Id: "p010", Name: "updated", Age: 31}, Person{Id: "p000", Name: "first", Age: 31}).
+		Delete(Person{}, "p001", "p010").
+		Insert(Person{Id: "p001", Name: "again", Age: 31}).
+		Commit()
+	if err != nil {
+		t.Fatalf("Failed to commit batch: %v", err)
+	}
+
+	records, err := db.Query(ctx, Person{}).All()
+	if err != nil {
+		t.Fatalf("Failed to run query: %v", err)
+	}
+	expected := []string{"p000", "p001", "p002", "p003"}
+	if !reflect.DeepEqual(ids(records), expected) {
+		t.Errorf("Expected %v but got %v", expected, ids(records))
+	}
+	for _, tt := range []struct {
+		age      int
+		expected []string
+	}{
+		{20, []string{}},
+		{21, []string{}},
+		{30, []string{}},
+		{31, []string{"p000", "p001"}},
+	} {
+		records, err := db.Query(ctx, Person{}).Where("Age", "=", tt.age).All()
+		if err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		if !reflect.DeepEqual(ids(records), tt.expected) {
+			t.Errorf("Expected %v of age %d but got %v", tt.expected, tt.age, ids(records))
+		}
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			batch *query.Batch
+			err   error
+		}{
+			{"missing update", db.Batch(ctx).Update(Person{Id: "p009"}), ErrRecordNotFound},
+			{"missing delete", db.Batch(ctx).Delete(Person{}, "p009"), ErrRecordNotFound},
+			{"deleted twice", db.Batch(ctx).Delete(Person{}, "p002", "p002"), ErrRecordNotFound},
+			{"invalid table", db.Batch(ctx).Delete(ExampleStruct{}, "p002"), ErrInvalidTableName},
+			{"nil record", db.Batch(ctx).Insert(nil), query.ErrInvalidOp},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if err := tt.batch.Commit(); !errors.Is(err, tt.err) {
+					t.Errorf("Expected %v but got %v", tt.err, err)
+				}
+			})
+		}
+		if err := db.ExecuteBatch(ctx, []query.Op{{Kind: "upsert"}}); !errors.Is(err, query.ErrInvalidOp) {
+			t.Errorf("Expected %v but got %v", query.ErrInvalidOp, err)
+		}
+		if _, err := db.Get(ctx, Person{}, "p002"); err != nil {
+			t.Errorf("Expected the record of failed batches to remain but got %v", err)
+		}
+	})
+}
+
+func TestDatabase_Batch_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	db := newPeopleDatabase(t, 0)
+	const batches, size = 20, 50
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for b := 0; b < batches; b++ {
+			var people []interface{}
+			for i := 0; i < size; i++ {
+				people = append(people, newPerson(b*size+i, batches*size))
+			}
+			if err := db.InsertMany(ctx, people...); err != nil {
+				t.Errorf("Failed to insert records: %v", err)
+			}
+		}
+	}()
+	for done := false; !done; {
+		records, err := db.Query(ctx, Person{}).All()
+		if err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		if len(records)%size != 0 {
+			t.Fatalf("Expected whole batches but got %d records", len(records))
+		}
+		done = len(records) == batches*size
+	}
+	wg.Wait()
+}
+
+func BenchmarkDatabase_InsertMany(b *testing.B) {
+	ctx := context.Background()
+	people := make([]interface{}, 10000)
+	for i := range people {
+		// insert in reverse key order, the worst case for single inserts
+		people[i] = newPerson(len(people)-1-i, len(people))
+	}
+	b.Run("insert", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			db := newPeopleDatabase(b, 0)
+			_ = db.CreateIndex(ctx, Person{}, "Age")
+			for _, person := range people {
+				_, _ = db.Insert(ctx, person)
+			}
+		}
+	})
+	b.Run("insert many", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			db := newPeopleDatabase(b, 0)
+			_ = db.CreateIndex(ctx, Person{}, "Age")
+			_ = db.InsertMany(ctx, people...)
+		}
+	})
+}
@@ -0,0 +1,320 @@
+package inmemory
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/priyanshujain/go-storage/encoding"
+	"github.com/priyanshujain/go-storage/query"
+)
+
+// A database with an Eviction policy is a cache. Once a write leaves more
+// records than MaxRecords or more bytes than MaxBytes, counted as the length
+// of the encoded keys and values of the records, the write evicts records
+// chosen by the policy until they fit. Get and GetFields use a record,
+// inserts and updates write it. Evicting a record sends a delete change
+// event but does not run hooks or the delete actions of foreign keys.
+
+// CacheStats are the counters of a database used as a cache. Hits and
+// Misses count the records found and not found by Get and GetFields.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Records and Bytes are the records tracked by the eviction policy
+	Records int
+	Bytes   int
+}
+
+// cache tracks the records of a database for its eviction policy, it has
+// its own lock as reads use records under the read lock of the database
+type cache struct {
+	mu       sync.Mutex
+	eviction Eviction
+	policy   policy
+	entries  map[*Record]*cached
+	stats    CacheStats
+}
+
+// cached is a record tracked by the eviction policy, the other fields are
+// kept by the policy
+type cached struct {
+	table  *Table
+	record *Record
+	size   int
+
+	// lru and arc
+	elem     *list.Element
+	frequent bool
+	// lfu
+	uses, used uint64
+	index      int
+}
+
+// ghostKey identifies the record of a table once it is evicted
+func (e *cached) ghostKey() string {
+	return e.table.Name + "\x00" + e.record.Key
+}
+
+// recordSize is the approximate size of a record
+func recordSize(r *Record) int {
+	return len(r.Key) + len(r.Value)
+}
+
+// get the counters of the database used as a cache
+func (db *Database) CacheStats() CacheStats {
+	db.cache.mu.Lock()
+	defer db.cache.mu.Unlock()
+	return db.cache.stats
+}
+
+// track starts tracking the records of the database when its eviction
+// policy is set or changed, and stops when it is unset. It is called under
+// the lock of the database before a write.
+func (db *Database) track() error {
+	c := &db.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if db.Eviction == c.eviction {
+		return nil
+	}
+	c.eviction, c.policy, c.entries = "", nil, nil
+	c.stats.Records, c.stats.Bytes = 0, 0
+	if db.Eviction == "" {
+		return nil
+	}
+	p, err := newPolicy(db.Eviction)
+	if err != nil {
+		return err
+	}
+	c.eviction, c.policy, c.entries = db.Eviction, p, make(map[*Record]*cached)
+	for _, t := range db.Tables {
+		for _, r := range t.Records {
+			c.add(t, r)
+		}
+	}
+	return nil
+}
+
+// reset stops tracking the records of the tables dropped by Init, they are
+// tracked again by the next write
+func (c *cache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.eviction, c.policy, c.entries = "", nil, nil
+	c.stats = CacheStats{}
+}
+
+// added tracks a record written to a table
+func (c *cache) added(t *Table, r *Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(t, r)
+}
+
+func (c *cache) add(t *Table, r *Record) {
+	if c.policy == nil {
+		return
+	}
+	e := &cached{table: t, record: r, size: recordSize(r)}
+	c.entries[r] = e
+	c.policy.add(e)
+	c.stats.Records++
+	c.stats.Bytes += e.size
+}
+
+// updated tracks the new value of an updated record
+func (c *cache) updated(r *Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[r]
+	if !ok {
+		return
+	}
+	size := recordSize(r)
+	c.stats.Bytes += size - e.size
+	e.size = size
+	c.policy.touch(e)
+}
+
+// removed stops tracking a record removed from its table
+func (c *cache) removed(r *Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[r]
+	if !ok {
+		return
+	}
+	c.policy.remove(e)
+	c.forget(e)
+}
+
+func (c *cache) forget(e *cached) {
+	delete(c.entries, e.record)
+	c.stats.Records--
+	c.stats.Bytes -= e.size
+}
+
+// used counts a read of a record, r is nil when it was not found
+func (c *cache) used(r *Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r == nil {
+		c.stats.Misses++
+		return
+	}
+	c.stats.Hits++
+	if e, ok := c.entries[r]; ok {
+		c.policy.touch(e)
+	}
+}
+
+// victim returns the next record to evict while the records exceed the
+// limits, nil once they fit
+func (c *cache) victim(maxRecords, maxBytes int) *cached {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.policy == nil {
+		return nil
+	}
+	if (maxRecords <= 0 || c.stats.Records <= maxRecords) && (maxBytes <= 0 || c.stats.Bytes <= maxBytes) {
+		return nil
+	}
+	e := c.policy.victim()
+	if e != nil {
+		c.forget(e)
+		c.stats.Evictions++
+	}
+	return e
+}
+
+// evict the records chosen by the eviction policy until the records fit
+// the limits of the database. It is called under the lock of the database
+// after a write.
+func (db *Database) evict() {
+	for e := db.cache.victim(db.MaxRecords, db.MaxBytes); e != nil; e = db.cache.victim(db.MaxRecords, db.MaxBytes) {
+		t := e.table
+		i, found := t.search(e.record.Key)
+		if !found || t.Records[i] != e.record {
+			continue
+		}
+		db.discard(t, e.record)
+		t.Records = append(t.Records[:i], t.Records[i+1:]...)
+	}
+}
+
+// Put writes a copy of a record kept by another engine, replacing the record
+// with its primary key. The record is written as it is: hooks are not run
+// and constraints and foreign keys are not checked. It expires at expires,
+// or when set by its expiry field when expires is zero.
+func (db *Database) Put(ctx context.Context, record interface{}, expires time.Time) error {
+	if err := db.lock.Lock(ctx); err != nil {
+		return err
+	}
+	defer db.lock.Unlock()
+
+	record = recordValue(record)
+	table, ok := db.Tables[reflect.TypeOf(record).Name()]
+	if !ok {
+		return ErrInvalidTableName
+	}
+	if err := db.track(); err != nil {
+		return err
+	}
+	// the sequence moves past the key of the copy
+	record, err := table.generateKey(record)
+	if err != nil {
+		return err
+	}
+	value, err := encoding.Encode(record)
+	if err != nil {
+		return fmt.Errorf("error encoding record: %v %w", err, ErrInvalidEncoding)
+	}
+	var at int64
+	if !expires.IsZero() {
+		at = expires.UnixMilli()
+	}
+
+	pk := table.recordKey(record)
+	db.dropExpired(table, pk)
+	i, found := table.search(pk)
+	if found {
+		r := table.Records[i]
+		old, err := table.decode(r)
+		if err != nil {
+			return err
+		}
+		db.publish(write{kind: query.UpdateOp, table: table, record: record, old: old}, r)
+		table.removeEntries(old, r)
+		r.Value = value
+		table.setExpiry(r, record, at)
+		table.addEntries(record, r)
+		db.cache.updated(r)
+	} else {
+		r := &Record{Key: pk, Value: value}
+		table.setExpiry(r, record, at)
+		table.Records = append(table.Records, nil)
+		copy(table.Records[i+1:], table.Records[i:])
+		table.Records[i] = r
+		table.addEntries(record, r)
+		db.publish(write{kind: query.InsertOp, table: table, record: record}, nil)
+		db.cache.added(table, r)
+	}
+	db.evict()
+	return nil
+}
+
+// Drop removes a record and the records referencing it through foreign
+// keys, whatever their delete action, without running hooks. A missing
+// record is not an error.
+func (db *Database) Drop(ctx context.Context, tableType interface{}, pk interface{}) error {
+	if err := db.lock.Lock(ctx); err != nil {
+		return err
+	}
+	defer db.lock.Unlock()
+
+	table, ok := db.Tables[reflect.TypeOf(tableType).Name()]
+	if !ok {
+		return ErrInvalidTableName
+	}
+	key, err := table.key(pk)
+	if err != nil {
+		return err
+	}
+	return db.drop(ctx, table, key)
+}
+
+// drop removes the record with the encoded primary key pk and the records
+// referencing it
+func (db *Database) drop(ctx context.Context, t *Table, pk string) error {
+	i, found := t.search(pk)
+	if !found {
+		return nil
+	}
+	r := t.Records[i]
+	record, err := t.decode(r)
+	if err != nil {
+		return err
+	}
+	db.discard(t, r)
+	t.Records = append(t.Records[:i], t.Records[i+1:]...)
+
+	for _, ref := range t.referencedBy {
+		value := reflect.ValueOf(record).Elem().FieldByIndex(t.keys[0].Index)
+		children, err := (&staging{db: db}).referencing(ctx, ref, pk, value.Interface())
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := db.drop(ctx, ref.table, ref.table.recordKey(child.Interface())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/priyanshujain/go-storage/query"
+	"github.com/priyanshujain/go-storage/schema"
+)
+
+// accountIds returns the primary keys of the accounts
+func accountIds(t *testing.T, db *Database) []int {
+	records, err := db.Query(context.Background(), Account{}).All()
+	if err != nil {
+		t.Fatalf("Failed to query accounts: %v", err)
+	}
+	var ids []int
+	for _, record := range records {
+		ids = append(ids, record.(*Account).Id)
+	}
+	return ids
+}
+
+func TestDatabase_Eviction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("record limit", func(t *testing.T) {
+		db := newBankDatabase(t)
+		db.Eviction, db.MaxRecords = LRU, 2
+		_, _ = db.Insert(ctx, Account{Id: 1})
+		_, _ = db.Insert(ctx, Account{Id: 2})
+		events, cancel := db.Watch(ctx, Account{}, query.LatestSeq)
+
+		_, _ = db.Get(ctx, Account{}, 1)
+		_, _ = db.Insert(ctx, Account{Id: 3})
+		_, _ = db.Get(ctx, Account{}, 2)
+		cancel()
+
+		if ids, expected := accountIds(t, db), []int{1, 3}; !reflect.DeepEqual(ids, expected) {
+			t.Errorf("Expected %v but got %v", expected, ids)
+		}
+		if got := drain(events); len(got) != 2 || got[1].Op != query.DeleteOp || got[1].Pk != 2 {
+			t.Errorf("Expected the insert of 3 and the delete of 2 but got %+v", got)
+		}
+		expected := CacheStats{Hits: 1, Misses: 1, Evictions: 1, Records: 2, Bytes: db.CacheStats().Bytes}
+		if stats := db.CacheStats(); stats != expected || stats.Bytes == 0 {
+			t.Errorf("Expected %+v but got %+v", expected, stats)
+		}
+	})
+
+	t.Run("byte limit", func(t *testing.T) {
+		db := newBankDatabase(t)
+		_, _ = db.Insert(ctx, Account{Id: 1})
+		size := recordSize(db.Tables["Account"].Records[0])
+		db.Eviction, db.MaxBytes = LFU, 3*size
+
+		var accounts []interface{}
+		for id := 2; id <= 5; id++ {
+			accounts = append(accounts, Account{Id: id})
+		}
+		if err := db.InsertMany(ctx, accounts...); err != nil {
+			t.Fatalf("Failed to insert accounts: %v", err)
+		}
+		if stats := db.CacheStats(); stats.Records != 3 || stats.Bytes > db.MaxBytes || stats.Evictions != 2 {
+			t.Errorf("Expected 3 records within %d bytes but got %+v", db.MaxBytes, stats)
+		}
+		// an update growing a record evicts another one
+		_, _ = db.Get(ctx, Account{}, 3)
+		if err := db.Update(ctx, Account{Id: 3, Balance: 1 << 40}); err != nil {
+			t.Fatalf("Failed to update account: %v", err)
+		}
+		if ids := accountIds(t, db); len(ids) > 2 || ids[0] != 3 {
+			t.Errorf("Expected account 3 to be kept alone or with one more but got %v", ids)
+		}
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		db := newBankDatabase(t)
+		db.Eviction, db.MaxRecords = ARC, 1
+		_ = db.InsertMany(ctx, Account{Id: 1}, Account{Id: 2})
+		db.Eviction = ""
+		_ = db.InsertMany(ctx, Account{Id: 3}, Account{Id: 4})
+		if ids, expected := accountIds(t, db), []int{2, 3, 4}; !reflect.DeepEqual(ids, expected) {
+			t.Errorf("Expected %v but got %v", expected, ids)
+		}
+		if stats := db.CacheStats(); stats.Records != 0 || stats.Evictions != 1 {
+			t.Errorf("Expected no records tracked but got %+v", stats)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		db := newBankDatabase(t)
+		db.Eviction = "fifo"
+		if _, err := db.Insert(ctx, Account{Id: 1}); !errors.Is(err, ErrInvalidEviction) {
+			t.Errorf("Expected %v but got %v", ErrInvalidEviction, err)
+		}
+		if err := db.InsertMany(ctx, Account{Id: 1}); !errors.Is(err, ErrInvalidEviction) {
+			t.Errorf("Expected %v but got %v", ErrInvalidEviction, err)
+		}
+	})
+}
+
+func TestDatabase_Put(t *testing.T) {
+	ctx := context.Background()
+	db := newLibraryDatabase(t)
+	hooks := 0
+	for _, event := range []schema.Event{schema.BeforeInsert, schema.AfterInsert, schema.BeforeUpdate, schema.AfterUpdate} {
+		_ = db.AddHook(event, func(ctx context.Context, table string, record interface{}) error {
+			hooks++
+			return nil
+		})
+	}
+
+	// the author of the book is not checked
+	expires := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+	if err := db.Put(ctx, &Book{Id: "b1", AuthorId: 1}, expires); err != nil {
+		t.Fatalf("Failed to put book: %v", err)
+	}
+	if err := db.Put(ctx, Book{Id: "b1", AuthorId: 2}, time.Time{}); err != nil {
+		t.Fatalf("Failed to put book: %v", err)
+	}
+	record, err := db.Get(ctx, Book{}, "b1")
+	if err != nil || *record.(*Book) != (Book{Id: "b1", AuthorId: 2}) {
+		t.Errorf("Expected the book to be replaced but got %v, %v", record, err)
+	}
+	if at, err := db.Expires(ctx, Book{}, "b1"); err != nil || !at.IsZero() {
+		t.Errorf("Expected the replaced book not to expire but got %v, %v", at, err)
+	}
+	if hooks != 0 {
+		t.Errorf("Expected no hooks to run but got %d", hooks)
+	}
+
+	if err := db.Put(ctx, Author{Id: 1}, expires); err != nil {
+		t.Fatalf("Failed to put author: %v", err)
+	}
+	if at, err := db.Expires(ctx, Author{}, 1); err != nil || !at.Equal(expires) {
+		t.Errorf("Expected %v but got %v, %v", expires, at, err)
+	}
+	if err := db.Put(ctx, Review{Id: 1}, time.Time{}); err != nil {
+		t.Fatalf("Failed to put review: %v", err)
+	}
+	if err := db.Put(ctx, Account{Id: 1}, time.Time{}); err != ErrInvalidTableName {
+		t.Errorf("Expected %v but got %v", ErrInvalidTableName, err)
+	}
+}
+
+func TestDatabase_Drop(t *testing.T) {
+	ctx := context.Background()
+	db := newLibraryDatabase(t)
+	_, _ = db.Insert(ctx, Author{Id: 1})
+	_, _ = db.Insert(ctx, Book{Id: "b1", AuthorId: 1})
+	_, _ = db.Insert(ctx, Chapter{BookId: "b1", Number: 1})
+	_, _ = db.Insert(ctx, Review{Id: 1, BookId: "b1"})
+	_, _ = db.Insert(ctx, Review{Id: 2})
+	hooks := 0
+	for _, event := range []schema.Event{schema.BeforeDelete, schema.AfterDelete} {
+		_ = db.AddHook(event, func(ctx context.Context, table string, record interface{}) error {
+			hooks++
+			return nil
+		})
+	}
+
+	// a restricted delete is dropped with the records referencing it
+	if err := db.Drop(ctx, Author{}, 1); err != nil {
+		t.Fatalf("Failed to drop author: %v", err)
+	}
+	for _, tt := range []struct {
+		tableType interface{}
+		pk        interface{}
+	}{
+		{Author{}, 1},
+		{Book{}, "b1"},
+		{Chapter{}, []interface{}{"b1", 1}},
+		{Review{}, 1},
+	} {
+		if _, err := db.Get(ctx, tt.tableType, tt.pk); err != ErrRecordNotFound {
+			t.Errorf("Expected %T %v to be dropped but got %v", tt.tableType, tt.pk, err)
+		}
+	}
+	if _, err := db.Get(ctx, Review{}, 2); err != nil {
+		t.Errorf("Expected review 2 to be kept but got %v", err)
+	}
+	if hooks != 0 {
+		t.Errorf("Expected no hooks to run but got %d", hooks)
+	}
+	if err := db.Drop(ctx, Author{}, 1); err != nil {
+		t.Errorf("Expected a missing record to be dropped but got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/priyanshujain/go-storage/query"
+	"github.com/priyanshujain/go-storage/schema"
+)
+
+// A foreign key is a field referencing the single field primary key of a
+// table created before, or of its own table. Inserts and updates fail when
+// a field references a missing record. Deletes fail, cascade or set the
+// referencing fields to their zero value, the writes of the delete actions
+// are checked and applied together with the delete like the writes of a
+// batch. Records referencing a deleted record are found through an index on
+// their field when there is one, otherwise by a scan of their table.
+
+// reference is a foreign key of table to the primary key of parent
+type reference struct {
+	schema.ForeignKey
+	field  reflect.StructField
+	table  *Table
+	parent *Table
+}
+
+// references resolves the foreign keys of a table being created
+func (db *Database) references(t *Table, keys []schema.ForeignKey) ([]*reference, error) {
+	var refs []*reference
+	for _, fk := range keys {
+		if err := fk.Check(); err != nil {
+			return nil, err
+		}
+		invalid := func(reason string) error {
+			return fmt.Errorf("%s.%s %s: %w", t.Name, fk.Field, reason, schema.ErrInvalidForeignKey)
+		}
+		f, ok := t.Fields.FieldByName(fk.Field)
+		if !ok || !f.IsExported() || !keyType(f.Type) {
+			return nil, invalid("is not a field that can reference a key")
+		}
+		for _, ref := range refs {
+			if ref.Field == fk.Field {
+				return nil, invalid("references more than one table")
+			}
+		}
+		parent, ok := db.Tables[fk.Table]
+		if fk.Table == t.Name {
+			parent, ok = t, true
+		}
+		if !ok {
+			return nil, invalid(fmt.Sprintf("references the unknown table %q", fk.Table))
+		}
+		if len(parent.keys) != 1 || keyKind(parent.keys[0].Type) != keyKind(f.Type) {
+			return nil, invalid(fmt.Sprintf("does not match the primary key of %s", parent.Name))
+		}
+		refs = append(refs, &reference{ForeignKey: fk, field: f, table: t, parent: parent})
+	}
+	return refs, nil
+}
+
+// keyKind groups the types of key fields whose values convert to each other
+func keyKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Array:
+		return fmt.Sprintf("[%d]byte", t.Len())
+	}
+	return "integer"
+}
+
+// committed returns a record of the table as it is, for a write outside of
+// a batch
+func committed(t *Table, pk string) (stagedRecord, error) {
+	_, found := t.find(pk)
+	return stagedRecord{exists: found}, nil
+}
+
+// checkReferences returns an error when a field of a record with the
+// encoded primary key pk references a missing record
+func (t *Table) checkReferences(record interface{}, pk string, current func(*Table, string) (stagedRecord, error)) error {
+	v := reflect.Indirect(reflect.ValueOf(record))
+	for _, ref := range t.references {
+		value := v.FieldByIndex(ref.field.Index)
+		if value.IsZero() {
+			continue
+		}
+		missing := fmt.Errorf("%s.%s references a missing %s %v: %w", t.Name, ref.Field, ref.parent.Name, value, ErrForeignKey)
+		key, err := ref.parent.key(value.Interface())
+		if err != nil {
+			return missing
+		}
+		if ref.parent == t && key == pk {
+			continue
+		}
+		s, err := current(ref.parent, key)
+		if err != nil {
+			return err
+		}
+		if !s.exists {
+			return missing
+		}
+	}
+	return nil
+}
+
+// cascade stages the delete actions of the records referencing a deleted
+// record
+func (s *staging) cascade(ctx context.Context, w write) error {
+	deleted := reflect.Indirect(reflect.ValueOf(w.old))
+	value := deleted.FieldByIndex(w.table.keys[0].Index)
+	for _, ref := range w.table.referencedBy {
+		children, err := s.referencing(ctx, ref, w.pk, value.Interface())
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			var op query.Op
+			switch ref.OnDelete {
+			case schema.Cascade:
+				op = query.Op{Kind: query.DeleteOp, TableType: reflect.Zero(ref.table.Fields).Interface(), Pk: ref.table.pkValue(child)}
+			case schema.SetNull:
+				updated := reflect.New(ref.table.Fields).Elem()
+				updated.Set(child)
+				updated.FieldByIndex(ref.field.Index).SetZero()
+				op = query.Op{Kind: query.UpdateOp, Record: updated.Interface()}
+			default:
+				return fmt.Errorf("%s %v is referenced by %s.%s: %w", w.table.Name, value, ref.table.Name, ref.Field, ErrForeignKey)
+			}
+			if err := s.add(ctx, op); err != nil {
+				return fmt.Errorf("%s of %s.%s: %w", ref.OnDelete, ref.table.Name, ref.Field, err)
+			}
+		}
+	}
+	return nil
+}
+
+// referencing returns the records, as left by the staged writes, whose
+// field of a foreign key references the encoded primary key pk holding
+// value
+func (s *staging) referencing(ctx context.Context, ref *reference, pk string, value interface{}) ([]reflect.Value, error) {
+	matches := func(record reflect.Value) bool {
+		field := record.FieldByIndex(ref.field.Index)
+		if field.IsZero() {
+			return false
+		}
+		key, err := ref.parent.key(field.Interface())
+		return err == nil && key == pk
+	}
+
+	records := ref.table.live(ref.table.Records)
+	if v, err := keyValue(ref.field.Type, value); err == nil {
+		records, _ = ref.table.candidates([]query.Condition{{Field: ref.Field, Operator: query.Eq, Value: v.Interface()}})
+	}
+	var children []reflect.Value
+	for i, r := range records {
+		if err := interrupted(ctx, i); err != nil {
+			return nil, err
+		}
+		if _, ok := s.staged[ref.table][r.Key]; ok {
+			continue
+		}
+		record, err := ref.table.decode(r)
+		if err != nil {
+			return nil, err
+		}
+		if v := reflect.ValueOf(record).Elem(); matches(v) {
+			children = append(children, v)
+		}
+	}
+
+	// records written by the batch, in primary key order
+	keys := make([]string, 0, len(s.staged[ref.table]))
+	for key, staged := range s.staged[ref.table] {
+		if staged.exists && staged.record != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if v := reflect.Indirect(reflect.ValueOf(s.staged[ref.table][key].record)); matches(v) {
+			children = append(children, v)
+		}
+	}
+	return children, nil
+}
+
+// pkValue returns the value of the primary key of a record as passed to
+// Get and Delete
+func (t *Table) pkValue(record reflect.Value) interface{} {
+	if len(t.keys) == 1 {
+		return record.FieldByIndex(t.keys[0].Index).Interface()
+	}
+	values := make([]interface{}, len(t.keys))
+	for i, f := range t.keys {
+		values[i] = record.FieldByIndex(f.Index).Interface()
+	}
+	return values
+}
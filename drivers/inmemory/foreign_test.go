@@ -0,0 +1,201 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/priyanshujain/go-storage/schema"
+)
+
+type Author struct {
+	Id   int
+	Name string
+}
+
+type Book struct {
+	Id       string
+	AuthorId int64 `references:"Author"`
+}
+
+type Chapter struct {
+	BookId string `references:"Book,cascade"`
+	Number int
+}
+
+type Review struct {
+	Id     int
+	BookId string
+}
+
+type Folder struct {
+	Id       int
+	ParentId int `references:"Folder,cascade"`
+}
+
+func newLibraryDatabase(t *testing.T) *Database {
+	ctx := context.Background()
+	db := New()
+	for _, table := range []struct {
+		tableType interface{}
+		pk        string
+		opts      []schema.Option
+	}{
+		{Author{}, "Id", nil},
+		{Book{}, "Id", nil},
+		{Chapter{}, "BookId,Number", nil},
+		{Review{}, "Id", []schema.Option{schema.References("BookId", "Book", schema.SetNull)}},
+		{Folder{}, "Id", nil},
+	} {
+		if err := db.CreateTable(ctx, table.tableType, table.pk, table.opts...); err != nil {
+			t.Fatalf("Failed to create table %T: %v", table.tableType, err)
+		}
+	}
+	return db
+}
+
+func TestDatabase_ForeignKeys(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("insert and update", func(t *testing.T) {
+		db := newLibraryDatabase(t)
+		if _, err := db.Insert(ctx, Book{Id: "b1", AuthorId: 1}); !errors.Is(err, ErrForeignKey) {
+			t.Errorf("Expected %v but got %v", ErrForeignKey, err)
+		}
+		// a zero field references nothing
+		if _, err := db.Insert(ctx, Book{Id: "b1"}); err != nil {
+			t.Fatalf("Failed to insert book: %v", err)
+		}
+		if err := db.Update(ctx, Book{Id: "b1", AuthorId: 1}); !errors.Is(err, ErrForeignKey) {
+			t.Errorf("Expected %v but got %v", ErrForeignKey, err)
+		}
+		// a batch sees the records written before in the batch
+		err := db.Batch(ctx).Insert(Author{Id: 1, Name: "ann"}).Update(Book{Id: "b1", AuthorId: 1}).Commit()
+		if err != nil {
+			t.Fatalf("Failed to commit batch: %v", err)
+		}
+		err = db.Batch(ctx).Insert(Author{Id: 2}).Delete(Author{}, 2).Insert(Book{Id: "b2", AuthorId: 2}).Commit()
+		if !errors.Is(err, ErrForeignKey) {
+			t.Errorf("Expected %v but got %v", ErrForeignKey, err)
+		}
+		if _, err := db.Insert(ctx, Folder{Id: 1, ParentId: 1}); err != nil {
+			t.Errorf("Expected a record to reference itself but got %v", err)
+		}
+	})
+
+	t.Run("restrict", func(t *testing.T) {
+		db := newLibraryDatabase(t)
+		if err := db.InsertMany(ctx, Author{Id: 1}, Book{Id: "b1", AuthorId: 1}); err != nil {
+			t.Fatalf("Failed to insert records: %v", err)
+		}
+		err := db.Delete(ctx, Author{}, 1)
+		if !errors.Is(err, ErrForeignKey) {
+			t.Fatalf("Expected %v but got %v", ErrForeignKey, err)
+		}
+		if expected := "Author 1 is referenced by Book.AuthorId: foreign key violated"; err.Error() != expected {
+			t.Errorf("Expected %q but got %q", expected, err.Error())
+		}
+		if err := db.Batch(ctx).Delete(Book{}, "b1").Delete(Author{}, 1).Commit(); err != nil {
+			t.Errorf("Expected the author to be deleted once unreferenced but got %v", err)
+		}
+	})
+
+	t.Run("cascade and set null", func(t *testing.T) {
+		db := newLibraryDatabase(t)
+		_ = db.CreateIndex(ctx, Review{}, "BookId")
+		records := []interface{}{
+			Book{Id: "b1"}, Book{Id: "b2"},
+			Chapter{BookId: "b1", Number: 1}, Chapter{BookId: "b1", Number: 2}, Chapter{BookId: "b2", Number: 1},
+			Review{Id: 1, BookId: "b1"}, Review{Id: 2, BookId: "b2"}, Review{Id: 3, BookId: "b1"},
+		}
+		if err := db.InsertMany(ctx, records...); err != nil {
+			t.Fatalf("Failed to insert records: %v", err)
+		}
+		if err := db.Delete(ctx, Book{}, "b1"); err != nil {
+			t.Fatalf("Failed to delete book: %v", err)
+		}
+		chapters, _ := db.Query(ctx, Chapter{}).All()
+		if len(chapters) != 1 || chapters[0].(*Chapter).BookId != "b2" {
+			t.Errorf("Expected the chapters of b1 to be deleted but got %v", chapters)
+		}
+		reviews, err := db.Query(ctx, Review{}).Where("BookId", "=", "").All()
+		if err != nil || len(reviews) != 2 {
+			t.Errorf("Expected the reviews of b1 to reference nothing but got %v, %v", reviews, err)
+		}
+	})
+
+	t.Run("atomic", func(t *testing.T) {
+		db := newLibraryDatabase(t)
+		records := []interface{}{
+			Author{Id: 1}, Book{Id: "b1"}, Book{Id: "b2", AuthorId: 1},
+			Chapter{BookId: "b1", Number: 1}, Chapter{BookId: "b2", Number: 1},
+		}
+		if err := db.InsertMany(ctx, records...); err != nil {
+			t.Fatalf("Failed to insert records: %v", err)
+		}
+		// the delete of b1 cascades but the delete of the author is restricted
+		err := db.Batch(ctx).Delete(Book{}, "b1").Delete(Author{}, 1).Commit()
+		if !errors.Is(err, ErrForeignKey) {
+			t.Fatalf("Expected %v but got %v", ErrForeignKey, err)
+		}
+		chapters, _ := db.Query(ctx, Chapter{}).All()
+		if len(chapters) != 2 {
+			t.Errorf("Expected a failed batch to keep the chapters but got %v", chapters)
+		}
+	})
+
+	t.Run("nested cascade", func(t *testing.T) {
+		db := newLibraryDatabase(t)
+		var folders []interface{}
+		for id := 1; id <= 6; id++ {
+			// folders 2 and 3 are in 1, 4 is in 2, 5 in 4 and 6 in none
+			parent := map[int]int{2: 1, 3: 1, 4: 2, 5: 4}[id]
+			folders = append(folders, Folder{Id: id, ParentId: parent})
+		}
+		if err := db.InsertMany(ctx, folders...); err != nil {
+			t.Fatalf("Failed to insert folders: %v", err)
+		}
+		if err := db.Delete(ctx, Folder{}, 1); err != nil {
+			t.Fatalf("Failed to delete folder: %v", err)
+		}
+		records, _ := db.Query(ctx, Folder{}).All()
+		var ids []int
+		for _, record := range records {
+			ids = append(ids, record.(*Folder).Id)
+		}
+		if expected := []int{6}; !reflect.DeepEqual(ids, expected) {
+			t.Errorf("Expected %v but got %v", expected, ids)
+		}
+	})
+
+	t.Run("invalid declarations", func(t *testing.T) {
+		type Note struct {
+			Id       int
+			AuthorId string
+			Flag     bool
+		}
+		tests := []struct {
+			name string
+			opt  schema.Option
+		}{
+			{"unknown table", schema.References("AuthorId", "Writer", schema.Restrict)},
+			{"unknown field", schema.References("WriterId", "Author", schema.Restrict)},
+			{"type mismatch", schema.References("AuthorId", "Author", schema.Restrict)},
+			{"not a key type", schema.References("Flag", "Note", schema.Restrict)},
+			{"composite key", schema.References("Id", "Chapter", schema.Restrict)},
+			{"unknown action", schema.References("Id", "Author", "ignore")},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				db := newLibraryDatabase(t)
+				if err := db.CreateTable(ctx, Note{}, "Id", tt.opt); !errors.Is(err, schema.ErrInvalidForeignKey) {
+					t.Errorf("Expected %v but got %v", schema.ErrInvalidForeignKey, err)
+				}
+				if refs := db.Tables["Author"].referencedBy; len(refs) != 1 || refs[0].table.Name != "Book" {
+					t.Errorf("Expected a failed declaration to leave the referenced table but got %v", refs)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/priyanshujain/go-storage/schema"
+)
+
+// generateKey returns the record with its primary key generated when the
+// table generates keys and the key of the record is zero. A sequence value
+// is taken from the table before the write is checked so that it is never
+// issued again, an explicit key larger than the sequence moves it forward.
+func (t *Table) generateKey(record interface{}) (interface{}, error) {
+	if t.Generator == "" {
+		return record, nil
+	}
+	f := t.keys[0]
+	v := reflect.New(t.Fields).Elem()
+	v.Set(reflect.ValueOf(record))
+	field := v.FieldByIndex(f.Index)
+
+	if t.Generator != schema.Sequence {
+		if !field.IsZero() {
+			return record, nil
+		}
+		generated, err := t.Generator.Value(f.Type, t.now())
+		if err != nil {
+			return nil, err
+		}
+		field.Set(generated)
+		return v.Interface(), nil
+	}
+
+	if !field.IsZero() {
+		if n, ok := uintValue(field); ok && n > t.Sequence {
+			t.Sequence = n
+		}
+		return record, nil
+	}
+	next := t.Sequence + 1
+	if next == 0 || !setSequence(field, next) {
+		return nil, fmt.Errorf("sequence of %q exhausted: %w", t.Name, ErrInvalidPk)
+	}
+	t.Sequence = next
+	return v.Interface(), nil
+}
+
+// get the last value issued by the sequence of a table, a persistent engine
+// saves it to resume the sequence with schema.ResumeSequence
+func (db *Database) Sequence(ctx context.Context, tableType interface{}) (uint64, error) {
+	if err := db.lock.RLock(ctx); err != nil {
+		return 0, err
+	}
+	defer db.lock.RUnlock()
+
+	table, ok := db.Tables[reflect.TypeOf(tableType).Name()]
+	if !ok {
+		return 0, ErrInvalidTableName
+	}
+	return table.Sequence, nil
+}
+
+// setSequence sets an integer field to a sequence value, unless the field
+// can not hold it
+func setSequence(field reflect.Value, n uint64) bool {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n > 1<<63-1 || field.OverflowInt(int64(n)) {
+			return false
+		}
+		field.SetInt(int64(n))
+	default:
+		if field.OverflowUint(n) {
+			return false
+		}
+		field.SetUint(n)
+	}
+	return true
+}
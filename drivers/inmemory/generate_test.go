@@ -0,0 +1,202 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/priyanshujain/go-storage/query"
+	"github.com/priyanshujain/go-storage/schema"
+)
+
+type Purchase struct {
+	Id   int64
+	Item string
+}
+
+type Ticket struct {
+	Id    string
+	Title string
+}
+
+type Upload struct {
+	Id   [16]byte
+	Name string
+}
+
+func TestDatabase_GeneratedKeys(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sequence", func(t *testing.T) {
+		db := New()
+		if err := db.CreateTable(ctx, Purchase{}, "Id", schema.GeneratedKey(schema.Sequence)); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		var ids []int64
+		for _, item := range []string{"a", "b", "c"} {
+			purchase := &Purchase{Item: item}
+			if _, err := db.Insert(ctx, purchase); err != nil {
+				t.Fatalf("Failed to insert purchase: %v", err)
+			}
+			ids = append(ids, purchase.Id)
+		}
+		if expected := []int64{1, 2, 3}; !reflect.DeepEqual(ids, expected) {
+			t.Errorf("Expected %v but got %v", expected, ids)
+		}
+
+		// values of deleted records and failed batches are not issued again
+		if err := db.Delete(ctx, Purchase{}, 3); err != nil {
+			t.Fatalf("Failed to delete purchase: %v", err)
+		}
+		failed := &Purchase{Item: "d"}
+		err := db.Batch(ctx).Insert(failed).Delete(Purchase{}, 100).Commit()
+		if !errors.Is(err, query.ErrBatch) {
+			t.Fatalf("Expected %v but got %v", query.ErrBatch, err)
+		}
+		if failed.Id != 0 {
+			t.Errorf("Expected no key on a record of a failed batch but got %d", failed.Id)
+		}
+		purchase := &Purchase{Item: "e"}
+		id, err := db.Insert(ctx, purchase)
+		if err != nil {
+			t.Fatalf("Failed to insert purchase: %v", err)
+		}
+		if purchase.Id != 5 || id != int64(5) {
+			t.Errorf("Expected 5 but got %d and %v", purchase.Id, id)
+		}
+
+		// an explicit key moves the sequence forward
+		if _, err := db.Insert(ctx, Purchase{Id: 10, Item: "f"}); err != nil {
+			t.Fatalf("Failed to insert purchase: %v", err)
+		}
+		purchases := []interface{}{&Purchase{Item: "g"}, &Purchase{Item: "h"}}
+		if err := db.InsertMany(ctx, purchases...); err != nil {
+			t.Fatalf("Failed to insert purchases: %v", err)
+		}
+		if a, b := purchases[0].(*Purchase).Id, purchases[1].(*Purchase).Id; a != 11 || b != 12 {
+			t.Errorf("Expected 11 and 12 but got %d and %d", a, b)
+		}
+		if seq := db.Tables["Purchase"].Sequence; seq != 12 {
+			t.Errorf("Expected the sequence at 12 but got %d", seq)
+		}
+		record, err := db.Get(ctx, Purchase{}, 12)
+		if err != nil || record.(*Purchase).Item != "h" {
+			t.Errorf("Expected purchase h but got %v, %v", record, err)
+		}
+	})
+
+	t.Run("resumed sequence", func(t *testing.T) {
+		db := New()
+		_ = db.CreateTable(ctx, Purchase{}, "Id", schema.GeneratedKey(schema.Sequence))
+		_ = db.InsertMany(ctx, Purchase{Item: "a"}, Purchase{Item: "b"}, Purchase{Item: "c"})
+		_ = db.Delete(ctx, Purchase{}, int64(3))
+		last, err := db.Sequence(ctx, Purchase{})
+		if err != nil || last != 3 {
+			t.Fatalf("Expected the sequence at 3 but got %d, %v", last, err)
+		}
+
+		// a restarted database reloads the records and resumes the sequence
+		restarted := New()
+		if err := restarted.CreateTable(ctx, Purchase{}, "Id", schema.GeneratedKey(schema.Sequence), schema.ResumeSequence(last)); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		_ = restarted.InsertMany(ctx, Purchase{Id: 1, Item: "a"}, Purchase{Id: 2, Item: "b"})
+		if id, err := restarted.Insert(ctx, Purchase{Item: "d"}); err != nil || id != int64(4) {
+			t.Errorf("Expected 4 but got %v, %v", id, err)
+		}
+
+		err = New().CreateTable(ctx, Purchase{}, "Id", schema.ResumeSequence(last))
+		if !errors.Is(err, schema.ErrInvalidGenerator) {
+			t.Errorf("Expected %v but got %v", schema.ErrInvalidGenerator, err)
+		}
+		if _, err := db.Sequence(ctx, Ticket{}); err != ErrInvalidTableName {
+			t.Errorf("Expected %v but got %v", ErrInvalidTableName, err)
+		}
+	})
+
+	t.Run("exhausted sequence", func(t *testing.T) {
+		type Small struct {
+			Id   int8
+			Name string
+		}
+		db := New()
+		_ = db.CreateTable(ctx, Small{}, "Id", schema.GeneratedKey(schema.Sequence))
+		if _, err := db.Insert(ctx, Small{Id: math.MaxInt8}); err != nil {
+			t.Fatalf("Failed to insert record: %v", err)
+		}
+		if _, err := db.Insert(ctx, &Small{Name: "next"}); !errors.Is(err, ErrInvalidPk) {
+			t.Errorf("Expected %v but got %v", ErrInvalidPk, err)
+		}
+	})
+
+	t.Run("identifiers", func(t *testing.T) {
+		tests := []struct {
+			generator schema.Generator
+			length    int
+		}{
+			{schema.UUIDv4, 36},
+			{schema.UUIDv7, 36},
+			{schema.ULID, 26},
+		}
+		for _, tt := range tests {
+			t.Run(string(tt.generator), func(t *testing.T) {
+				db := New()
+				if err := db.CreateTable(ctx, Ticket{}, "Id", schema.GeneratedKey(tt.generator)); err != nil {
+					t.Fatalf("Failed to create table: %v", err)
+				}
+				if err := db.CreateTable(ctx, Upload{}, "Id", schema.GeneratedKey(tt.generator)); err != nil {
+					t.Fatalf("Failed to create table: %v", err)
+				}
+				a, b := &Ticket{Title: "a"}, &Ticket{Title: "b"}
+				if err := db.InsertMany(ctx, a, b); err != nil {
+					t.Fatalf("Failed to insert tickets: %v", err)
+				}
+				if len(a.Id) != tt.length || a.Id == b.Id {
+					t.Errorf("Expected two distinct keys of %d characters but got %q and %q", tt.length, a.Id, b.Id)
+				}
+				if _, err := db.Insert(ctx, Ticket{Id: "mine", Title: "c"}); err != nil {
+					t.Fatalf("Failed to insert ticket: %v", err)
+				}
+				if _, err := db.Get(ctx, Ticket{}, "mine"); err != nil {
+					t.Errorf("Expected an explicit key to be kept but got %v", err)
+				}
+
+				upload := &Upload{Name: "file"}
+				if _, err := db.Insert(ctx, upload); err != nil {
+					t.Fatalf("Failed to insert upload: %v", err)
+				}
+				if upload.Id == ([16]byte{}) {
+					t.Errorf("Expected a generated key")
+				}
+				if _, err := db.Get(ctx, Upload{}, upload.Id); err != nil {
+					t.Errorf("Failed to get upload: %v", err)
+				}
+			})
+		}
+	})
+
+	t.Run("invalid declarations", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			tableType interface{}
+			pk        string
+			generator schema.Generator
+			err       error
+		}{
+			{"sequence of strings", Ticket{}, "Id", schema.Sequence, schema.ErrInvalidGenerator},
+			{"uuid of integers", Purchase{}, "Id", schema.UUIDv4, schema.ErrInvalidGenerator},
+			{"unknown", Purchase{}, "Id", "counter", schema.ErrInvalidGenerator},
+			{"composite", Member{}, "TenantId,UserId", schema.Sequence, ErrInvalidPk},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				db := New()
+				if err := db.CreateTable(ctx, tt.tableType, tt.pk, schema.GeneratedKey(tt.generator)); !errors.Is(err, tt.err) {
+					t.Errorf("Expected %v but got %v", tt.err, err)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/priyanshujain/go-storage/schema"
+)
+
+// Before hooks run under the lock of the database as part of the write, a
+// call to the database with the context of a before hook fails with
+// ErrReentrant rather than waiting for the lock forever. After hooks run
+// once the lock is released and may write through the database, as to
+// record audit entries.
+
+// hooks are the hooks registered on a database, they have their own lock
+// as after hooks are read once the lock of the database is released
+type hooks struct {
+	mu     sync.RWMutex
+	events map[schema.Event][]schema.Hook
+}
+
+func (h *hooks) get(event schema.Event) []schema.Hook {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.events[event]
+}
+
+// register a hook run on an event for the records of every table, hooks run
+// in the order they were registered
+func (db *Database) AddHook(event schema.Event, hook schema.Hook) error {
+	if err := event.Check(); err != nil {
+		return err
+	}
+	db.hooks.mu.Lock()
+	defer db.hooks.mu.Unlock()
+	if db.hooks.events == nil {
+		db.hooks.events = make(map[schema.Event][]schema.Hook)
+	}
+	db.hooks.events[event] = append(db.hooks.events[event], hook)
+	return nil
+}
+
+// hooked reports whether hooks run on an event for the records of a table
+func (db *Database) hooked(event schema.Event, t *Table) bool {
+	if _, ok := schema.RecordHook(event, reflect.Zero(reflect.PointerTo(t.Fields)).Interface()); ok {
+		return true
+	}
+	return len(db.hooks.get(event)) > 0
+}
+
+// runHooks runs the hook of the record type then the registered hooks of an
+// event on a pointer to a record
+func (db *Database) runHooks(ctx context.Context, event schema.Event, t *Table, record interface{}) error {
+	switch event {
+	case schema.BeforeInsert, schema.BeforeUpdate, schema.BeforeDelete:
+		ctx = db.lock.hold(ctx)
+	}
+	if hook, ok := schema.RecordHook(event, record); ok {
+		if err := hook(ctx); err != nil {
+			return fmt.Errorf("%s hook of %q: %w", event, t.Name, err)
+		}
+	}
+	for _, hook := range db.hooks.get(event) {
+		if err := hook(ctx, t.Name, record); err != nil {
+			return fmt.Errorf("%s hook of %q: %w", event, t.Name, err)
+		}
+	}
+	return nil
+}
+
+// hookRecord runs the hooks of an event on a copy of a record value and
+// returns the copy as changed by the hooks
+func (db *Database) hookRecord(ctx context.Context, event schema.Event, t *Table, record interface{}) (interface{}, error) {
+	if !db.hooked(event, t) {
+		return record, nil
+	}
+	p := reflect.New(t.Fields)
+	p.Elem().Set(reflect.ValueOf(record))
+	if err := db.runHooks(ctx, event, t, p.Interface()); err != nil {
+		return nil, err
+	}
+	return p.Elem().Interface(), nil
+}
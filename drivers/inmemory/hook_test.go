@@ -0,0 +1,270 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/priyanshujain/go-storage/schema"
+)
+
+type Profile struct {
+	Id        string
+	Email     string `validate:"required"`
+	CreatedAt int64
+	UpdatedAt int64
+	Version   int
+}
+
+var errLocked = errors.New("locked profile")
+
+func (p *Profile) BeforeInsert(ctx context.Context) error {
+	p.Email = strings.ToLower(strings.TrimSpace(p.Email))
+	return nil
+}
+
+func (p *Profile) BeforeUpdate(ctx context.Context) error {
+	p.Version++
+	return nil
+}
+
+func (p *Profile) BeforeDelete(ctx context.Context) error {
+	if p.Id == "locked" {
+		return errLocked
+	}
+	return nil
+}
+
+type AuditEntry struct {
+	Id     int64
+	Event  schema.Event
+	Record string
+}
+
+func TestDatabase_Hooks(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+	if err := db.CreateTable(ctx, Profile{}, "Id"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if err := db.CreateTable(ctx, AuditEntry{}, "Id", schema.GeneratedKey(schema.Sequence)); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	clock := int64(100)
+	stamp := func(ctx context.Context, table string, record interface{}) error {
+		if p, ok := record.(*Profile); ok {
+			clock++
+			if p.CreatedAt == 0 {
+				p.CreatedAt = clock
+			}
+			p.UpdatedAt = clock
+		}
+		return nil
+	}
+	_ = db.AddHook(schema.BeforeInsert, stamp)
+	_ = db.AddHook(schema.BeforeUpdate, stamp)
+	// after hooks write through the database
+	for _, event := range []schema.Event{schema.AfterInsert, schema.AfterUpdate, schema.AfterDelete} {
+		event := event
+		_ = db.AddHook(event, func(ctx context.Context, table string, record interface{}) error {
+			if table == "AuditEntry" {
+				return nil
+			}
+			_, err := db.Insert(ctx, AuditEntry{Event: event, Record: record.(*Profile).Id})
+			return err
+		})
+	}
+	audit := func() []string {
+		records, err := db.Query(ctx, AuditEntry{}).All()
+		if err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		var entries []string
+		for _, record := range records {
+			entry := record.(*AuditEntry)
+			entries = append(entries, string(entry.Event)+" "+entry.Record)
+		}
+		return entries
+	}
+
+	t.Run("insert", func(t *testing.T) {
+		profile := &Profile{Id: "ann", Email: " Ann@Example.com "}
+		if _, err := db.Insert(ctx, profile); err != nil {
+			t.Fatalf("Failed to insert profile: %v", err)
+		}
+		expected := Profile{Id: "ann", Email: "ann@example.com", CreatedAt: 101, UpdatedAt: 101}
+		if *profile != expected {
+			t.Errorf("Expected %+v but got %+v", expected, *profile)
+		}
+		record, err := db.Get(ctx, Profile{}, "ann")
+		if err != nil || *record.(*Profile) != expected {
+			t.Errorf("Expected %+v but got %v, %v", expected, record, err)
+		}
+
+		// before hooks run before the constraints are checked
+		_, err = db.Insert(ctx, &Profile{Id: "blank", Email: "  "})
+		if !errors.Is(err, schema.ErrConstraint) {
+			t.Errorf("Expected %v but got %v", schema.ErrConstraint, err)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		profile := &Profile{Id: "ann", Email: "ann@example.org", CreatedAt: 101}
+		if err := db.Update(ctx, profile); err != nil {
+			t.Fatalf("Failed to update profile: %v", err)
+		}
+		if profile.Version != 1 || profile.UpdatedAt != 103 || profile.CreatedAt != 101 {
+			t.Errorf("Expected version 1 updated at 103 but got %+v", *profile)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if _, err := db.Insert(ctx, Profile{Id: "locked", Email: "x@example.com"}); err != nil {
+			t.Fatalf("Failed to insert profile: %v", err)
+		}
+		if err := db.Delete(ctx, Profile{}, "locked"); !errors.Is(err, errLocked) {
+			t.Errorf("Expected %v but got %v", errLocked, err)
+		}
+		if _, err := db.Get(ctx, Profile{}, "locked"); err != nil {
+			t.Errorf("Expected an aborted delete to keep the record but got %v", err)
+		}
+		if err := db.Delete(ctx, Profile{}, "ann"); err != nil {
+			t.Fatalf("Failed to delete profile: %v", err)
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		before := len(audit())
+		batch := db.Batch(ctx).Insert(&Profile{Id: "bob", Email: "bob@example.com"}).Delete(Profile{}, "locked")
+		if err := batch.Commit(); !errors.Is(err, errLocked) {
+			t.Fatalf("Expected %v but got %v", errLocked, err)
+		}
+		if _, err := db.Get(ctx, Profile{}, "bob"); err != ErrRecordNotFound {
+			t.Errorf("Expected an aborted batch to insert nothing but got %v", err)
+		}
+		if after := len(audit()); after != before {
+			t.Errorf("Expected no audit entries for an aborted batch but got %d", after-before)
+		}
+
+		profiles := []interface{}{&Profile{Id: "bob", Email: "BOB@example.com"}, &Profile{Id: "cid", Email: "cid@example.com"}}
+		if err := db.InsertMany(ctx, profiles...); err != nil {
+			t.Fatalf("Failed to insert profiles: %v", err)
+		}
+		if bob := profiles[0].(*Profile); bob.Email != "bob@example.com" || bob.CreatedAt == 0 {
+			t.Errorf("Expected the hooks to change bob but got %+v", *bob)
+		}
+		if err := db.Batch(ctx).Update(Profile{Id: "cid", Email: "cid@example.com"}).Delete(Profile{}, "bob").Commit(); err != nil {
+			t.Fatalf("Failed to commit batch: %v", err)
+		}
+		record, err := db.Get(ctx, Profile{}, "cid")
+		if err != nil || record.(*Profile).Version != 1 {
+			t.Errorf("Expected version 1 of cid but got %v, %v", record, err)
+		}
+	})
+
+	expected := []string{
+		"after insert ann", "after update ann", "after insert locked", "after delete ann",
+		"after insert bob", "after insert cid", "after update cid", "after delete bob",
+	}
+	if entries := audit(); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v but got %v", expected, entries)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		errHidden := errors.New("hidden")
+		_ = db.AddHook(schema.AfterGet, func(ctx context.Context, table string, record interface{}) error {
+			if p, ok := record.(*Profile); ok && p.Id == "locked" {
+				return errHidden
+			}
+			record.(*Profile).Email = "redacted"
+			return nil
+		})
+		if _, err := db.Get(ctx, Profile{}, "locked"); !errors.Is(err, errHidden) {
+			t.Errorf("Expected %v but got %v", errHidden, err)
+		}
+		record, err := db.GetFields(ctx, Profile{}, "cid", "Email")
+		if err != nil || record.(*Profile).Email != "redacted" {
+			t.Errorf("Expected a redacted email but got %v, %v", record, err)
+		}
+	})
+
+	if err := db.AddHook("before get", nil); !errors.Is(err, schema.ErrInvalidEvent) {
+		t.Errorf("Expected %v but got %v", schema.ErrInvalidEvent, err)
+	}
+}
+
+func TestDatabase_AfterHookError(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+	if err := db.CreateTable(ctx, Profile{}, "Id"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	errAudit := errors.New("audit failed")
+	for _, event := range []schema.Event{schema.AfterInsert, schema.AfterUpdate, schema.AfterDelete} {
+		_ = db.AddHook(event, func(ctx context.Context, table string, record interface{}) error {
+			return errAudit
+		})
+	}
+	// the write is applied whatever the after hooks return
+	email := func(id string) string {
+		record, err := db.Get(ctx, Profile{}, id)
+		if err != nil {
+			return ""
+		}
+		return record.(*Profile).Email
+	}
+
+	tests := []struct {
+		name  string
+		write func() error
+		id    string
+		email string
+	}{
+		{"insert", func() error { _, err := db.Insert(ctx, Profile{Id: "ann", Email: "ann@example.com"}); return err }, "ann", "ann@example.com"},
+		{"update", func() error { return db.Update(ctx, Profile{Id: "ann", Email: "ann@example.org"}) }, "ann", "ann@example.org"},
+		{"batch", func() error {
+			return db.InsertMany(ctx, Profile{Id: "bob", Email: "bob@example.com"}, Profile{Id: "cid", Email: "cid@example.com"})
+		}, "cid", "cid@example.com"},
+		{"delete", func() error { return db.Delete(ctx, Profile{}, "bob") }, "bob", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.write()
+			if !errors.Is(err, schema.ErrAfterHook) || !errors.Is(err, errAudit) {
+				t.Errorf("Expected %v and %v but got %v", schema.ErrAfterHook, errAudit, err)
+			}
+			if got := email(tt.id); got != tt.email {
+				t.Errorf("Expected %q but got %q", tt.email, got)
+			}
+		})
+	}
+}
+
+func TestDatabase_ReentrantHook(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+	if err := db.CreateTable(ctx, Profile{}, "Id"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	_ = db.AddHook(schema.BeforeInsert, func(ctx context.Context, table string, record interface{}) error {
+		_, err := db.Get(ctx, Profile{}, "ann")
+		return err
+	})
+	done := make(chan error)
+	go func() {
+		_, err := db.Insert(ctx, Profile{Id: "bob", Email: "bob@example.com"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrReentrant) {
+			t.Errorf("Expected %v but got %v", ErrReentrant, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a before hook calling the database to fail but it is waiting for the lock")
+	}
+}
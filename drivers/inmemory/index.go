@@ -0,0 +1,188 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/priyanshujain/go-storage/encoding"
+	"github.com/priyanshujain/go-storage/query"
+)
+
+var ErrInvalidIndex = errors.New("invalid index")
+var ErrIndexExists = errors.New("index already exists")
+
+// An Index keeps the records of a table sorted by the value of a field so
+// that queries on the field do not have to decode every record.
+type Index struct {
+	Field   string
+	entries []indexEntry
+}
+
+type indexEntry struct {
+	value  interface{}
+	record *Record
+}
+
+// create a secondary index on a field of the table
+func (db *Database) CreateIndex(ctx context.Context, tableType interface{}, field string) error {
+	if err := db.lock.Lock(ctx); err != nil {
+		return err
+	}
+	defer db.lock.Unlock()
+
+	tableName := reflect.TypeOf(tableType).Name()
+	table, ok := db.Tables[tableName]
+	if !ok {
+		return ErrInvalidTableName
+	}
+	if _, ok := table.Indexes[field]; ok {
+		return ErrIndexExists
+	}
+	f, ok := table.Fields.FieldByName(field)
+	if !ok || !f.IsExported() || !query.Comparable(f.Type) {
+		return ErrInvalidIndex
+	}
+
+	index := &Index{Field: field}
+	for i, r := range table.Records {
+		if err := interrupted(ctx, i); err != nil {
+			return err
+		}
+		record := reflect.New(table.Fields)
+		if err := encoding.Decode(r.Value, record.Interface()); err != nil {
+			return fmt.Errorf("error decoding record: %v %w", err, ErrInvalidEncoding)
+		}
+		value, _ := query.Field(record.Interface(), field)
+		index.entries = append(index.entries, indexEntry{value: value, record: r})
+	}
+	sort.SliceStable(index.entries, func(i, j int) bool {
+		return index.less(index.entries[i], index.entries[j])
+	})
+
+	if table.Indexes == nil {
+		table.Indexes = make(map[string]*Index)
+	}
+	table.Indexes[field] = index
+	return nil
+}
+
+// less orders entries by value and then by primary key
+func (idx *Index) less(a, b indexEntry) bool {
+	// values of a field always compare, the field was checked on creation
+	result, _ := query.Compare(a.value, b.value)
+	if result != 0 {
+		return result < 0
+	}
+	return a.record.Key < b.record.Key
+}
+
+func (idx *Index) insert(value interface{}, record *Record) {
+	entry := indexEntry{value: value, record: record}
+	i := sort.Search(len(idx.entries), func(i int) bool {
+		return idx.less(entry, idx.entries[i])
+	})
+	idx.entries = append(idx.entries, indexEntry{})
+	copy(idx.entries[i+1:], idx.entries[i:])
+	idx.entries[i] = entry
+}
+
+// remove deletes the entry of a record whose field had the given value
+func (idx *Index) remove(value interface{}, record *Record) {
+	lower, upper := idx.bounds(value)
+	for i := lower; i < upper; i++ {
+		if idx.entries[i].record == record {
+			idx.entries = append(idx.entries[:i], idx.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// unindex removes a record from the indexes of the table, its current value
+// is decoded to find the entries
+func (t *Table) unindex(r *Record) error {
+	if len(t.Indexes) == 0 {
+		return nil
+	}
+	record := reflect.New(t.Fields).Interface()
+	if err := encoding.Decode(r.Value, record); err != nil {
+		return fmt.Errorf("error decoding record: %v %w", err, ErrInvalidEncoding)
+	}
+	t.removeEntries(record, r)
+	return nil
+}
+
+// addEntries adds the entries of a record to the indexes of the table
+func (t *Table) addEntries(record interface{}, r *Record) {
+	for field, index := range t.Indexes {
+		value, _ := query.Field(record, field)
+		index.insert(value, r)
+	}
+}
+
+// removeEntries removes the entries of a record from the indexes of the
+// table, record is the decoded value of r
+func (t *Table) removeEntries(record interface{}, r *Record) {
+	for field, index := range t.Indexes {
+		value, _ := query.Field(record, field)
+		index.remove(value, r)
+	}
+}
+
+// bounds returns the range of entries equal to value
+func (idx *Index) bounds(value interface{}) (int, int) {
+	lower := sort.Search(len(idx.entries), func(i int) bool {
+		result, _ := query.Compare(idx.entries[i].value, value)
+		return result >= 0
+	})
+	upper := sort.Search(len(idx.entries), func(i int) bool {
+		result, _ := query.Compare(idx.entries[i].value, value)
+		return result > 0
+	})
+	return lower, upper
+}
+
+// scan returns the records that can satisfy the condition, or false when
+// the operator cannot use the index
+func (idx *Index) scan(c query.Condition) ([]*Record, bool) {
+	var ranges [][2]int
+	switch c.Operator {
+	case query.Eq:
+		lower, upper := idx.bounds(c.Value)
+		ranges = append(ranges, [2]int{lower, upper})
+	case query.In:
+		values := reflect.ValueOf(c.Value)
+		for i := 0; i < values.Len(); i++ {
+			lower, upper := idx.bounds(values.Index(i).Interface())
+			ranges = append(ranges, [2]int{lower, upper})
+		}
+	case query.Lt, query.Le:
+		lower, upper := idx.bounds(c.Value)
+		if c.Operator == query.Le {
+			lower = upper
+		}
+		ranges = append(ranges, [2]int{0, lower})
+	case query.Gt, query.Ge:
+		lower, upper := idx.bounds(c.Value)
+		if c.Operator == query.Gt {
+			lower = upper
+		}
+		ranges = append(ranges, [2]int{lower, len(idx.entries)})
+	default:
+		return nil, false
+	}
+
+	var records []*Record
+	seen := make(map[*Record]bool)
+	for _, r := range ranges {
+		for _, entry := range idx.entries[r[0]:r[1]] {
+			if !seen[entry.record] {
+				seen[entry.record] = true
+				records = append(records, entry.record)
+			}
+		}
+	}
+	return records, true
+}
@@ -0,0 +1,108 @@
+package inmemory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/priyanshujain/go-storage/query"
+)
+
+func TestDatabase_CreateIndex(t *testing.T) {
+	ctx := context.Background()
+	t.Run("errors", func(t *testing.T) {
+		type Record struct {
+			Id     string
+			Tags   []string
+			hidden int
+		}
+		db := New()
+		_ = db.CreateTable(ctx, Record{}, "Id")
+
+		tests := []struct {
+			name      string
+			tableType interface{}
+			field     string
+			err       error
+		}{
+			{"invalid table", ExampleStruct{}, "ID", ErrInvalidTableName},
+			{"missing field", Record{}, "Missing", ErrInvalidIndex},
+			{"unexported field", Record{}, "hidden", ErrInvalidIndex},
+			{"unordered field", Record{}, "Tags", ErrInvalidIndex},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if err := db.CreateIndex(ctx, tt.tableType, tt.field); err != tt.err {
+					t.Errorf("Expected %v but got %v", tt.err, err)
+				}
+			})
+		}
+
+		if err := db.CreateIndex(ctx, Record{}, "Id"); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+		if err := db.CreateIndex(ctx, Record{}, "Id"); err != ErrIndexExists {
+			t.Errorf("Expected ErrIndexExists but got %v", err)
+		}
+	})
+
+	t.Run("maintained on insert", func(t *testing.T) {
+		db := newPeopleDatabase(t, 30)
+		if err := db.CreateIndex(ctx, Person{}, "Age"); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+		table := db.Tables["Person"]
+		// insert in reverse so that records land before existing ones
+		for i := 59; i >= 30; i-- {
+			if _, err := db.Insert(ctx, newPerson(i, 60)); err != nil {
+				t.Fatalf("Failed to insert record: %v", err)
+			}
+		}
+		index := table.Indexes["Age"]
+		if len(index.entries) != len(table.Records) {
+			t.Fatalf("Expected %d index entries but got %d", len(table.Records), len(index.entries))
+		}
+		for i := 1; i < len(index.entries); i++ {
+			if !index.less(index.entries[i-1], index.entries[i]) {
+				t.Fatalf("Expected index entries to be sorted at %d", i)
+			}
+		}
+		for i := 1; i < len(table.Records); i++ {
+			if table.Records[i-1].Key >= table.Records[i].Key {
+				t.Fatalf("Expected records to be sorted by key at %d", i)
+			}
+		}
+	})
+
+	t.Run("candidates", func(t *testing.T) {
+		db := newPeopleDatabase(t, 100)
+		_ = db.CreateIndex(ctx, Person{}, "Age")
+		_ = db.CreateIndex(ctx, Person{}, "City")
+		table := db.Tables["Person"]
+
+		tests := []struct {
+			name       string
+			conditions []query.Condition
+			count      int
+			indexed    bool
+		}{
+			{"no conditions", nil, 100, false},
+			{"not indexed", []query.Condition{{Field: "Name", Operator: "=", Value: "a"}}, 100, false},
+			{"not equal", []query.Condition{{Field: "Age", Operator: "!=", Value: 20}}, 100, false},
+			{"primary key", []query.Condition{{Field: "City", Operator: "=", Value: "Pune"}, {Field: "Id", Operator: "=", Value: "p001"}}, 1, true},
+			{"equality before range", []query.Condition{{Field: "Age", Operator: "<", Value: 59}, {Field: "Age", Operator: "=", Value: 20}}, 3, true},
+			{"in", []query.Condition{{Field: "Age", Operator: "in", Value: []int{20, 21, 20}}}, 6, true},
+			{"less than", []query.Condition{{Field: "Age", Operator: "<", Value: 22}}, 6, true},
+			{"at most", []query.Condition{{Field: "Age", Operator: "<=", Value: 22}}, 9, true},
+			{"greater than", []query.Condition{{Field: "Age", Operator: ">", Value: 57}}, 4, true},
+			{"at least", []query.Condition{{Field: "Age", Operator: ">=", Value: 57}}, 6, true},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				records, indexed := table.candidates(tt.conditions)
+				if len(records) != tt.count || indexed != tt.indexed {
+					t.Errorf("Expected %d records, indexed %v but got %d, %v", tt.count, tt.indexed, len(records), indexed)
+				}
+			})
+		}
+	})
+}
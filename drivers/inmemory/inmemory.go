@@ -1,16 +1,25 @@
 package inmemory
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/priyanshujain/go-storage/encoding"
+	"github.com/priyanshujain/go-storage/query"
+	"github.com/priyanshujain/go-storage/schema"
 	"reflect"
+	"sort"
 	"sync"
+	"time"
 )
 
 type Record struct {
+	// Key is the encoded primary key, see appendKey
 	Key   string
 	Value string
+	// Expires is the time the record expires at in Unix milliseconds, zero
+	// when it does not expire
+	Expires int64
 }
 
 type InMemoryStorage struct {
@@ -71,20 +80,94 @@ func (s *InMemoryStorage) Delete(key string) error {
 }
 
 type Table struct {
-	Name    string
-	Pk      string
-	Fields  reflect.Type
+	Name string
+	// Pk names the fields of the primary key separated by commas
+	Pk     string
+	Fields reflect.Type
+	// Records are sorted by primary key
 	Records []*Record
+	Indexes map[string]*Index
+	// Generator generates the primary key of records inserted with a zero
+	// key, Sequence is the last value issued by a sequence
+	Generator schema.Generator
+	Sequence  uint64
+	keys      []reflect.StructField
+	// constraints are declared by the validate tags of the record type
+	constraints *schema.Constraints
+	// references are the foreign keys of the table and referencedBy the
+	// foreign keys of tables to it
+	references   []*reference
+	referencedBy []*reference
+	// TTL is the time records live for unless they set their expiry, expiry
+	// is the index of the field holding it. Expiring is set once a record
+	// of the table expires and clock is the clock of the database.
+	TTL      time.Duration
+	expiry   []int
+	expiring bool
+	clock    func() time.Time
+}
+
+// check returns the violated constraint of a record about to be written
+func (t *Table) check(record interface{}) error {
+	if t.constraints == nil {
+		return nil
+	}
+	return t.constraints.Check(record)
+}
+
+// search returns the position of the record with the encoded primary key,
+// or where it would be inserted
+func (t *Table) search(key string) (int, bool) {
+	i := sort.Search(len(t.Records), func(i int) bool {
+		return t.Records[i].Key >= key
+	})
+	return i, i < len(t.Records) && t.Records[i].Key == key
+}
+
+// find returns the record with the encoded primary key unless it expired
+func (t *Table) find(key string) (*Record, bool) {
+	i, found := t.search(key)
+	if !found || (t.expiring && expired(t.Records[i], t.now().UnixMilli())) {
+		return nil, false
+	}
+	return t.Records[i], true
 }
 
 type Database struct {
 	Tables  map[string]*Table
 	Storage *InMemoryStorage
+	// ChangeLog is the number of change events retained to replay to
+	// watchers resuming from an earlier sequence, WatchBuffer the number of
+	// events buffered for a watcher before it overflows
+	ChangeLog   int
+	WatchBuffer int
+	// Now is the clock records expire by, time.Now when nil
+	Now func() time.Time
+	// Eviction makes the database a cache evicting records once there are
+	// more than MaxRecords or their size is more than MaxBytes, a zero
+	// limit is no limit
+	Eviction   Eviction
+	MaxRecords int
+	MaxBytes   int
+	// lock guards the tables, writes of a batch are applied under a single
+	// lock so readers never see part of a batch
+	lock    rwLock
+	hooks   hooks
+	changes changes
+	cache   cache
 }
 
+// Init drops every table under the lock, with the records tracked by the
+// eviction policy and the expiry state of the tables, so that a running
+// sweeper or reader never sees the tables being replaced
 func (db *Database) Init() {
+	// a background context can not be done
+	_ = db.lock.Lock(context.Background())
+	defer db.lock.Unlock()
+
 	db.Tables = make(map[string]*Table)
 	db.Storage = &InMemoryStorage{data: make(map[string]string)}
+	db.cache.reset()
 }
 
 func New() *Database {
@@ -100,9 +183,19 @@ var ErrInvalidEncoding = errors.New("invalid encoding")
 var ErrRecordNotFound = errors.New("record not found")
 var ErrTableExists = errors.New("table already exists")
 var ErrDuplicateRecord = errors.New("duplicate record")
+var ErrForeignKey = errors.New("foreign key violated")
+var ErrInvalidEviction = errors.New("invalid eviction policy")
+var ErrReentrant = errors.New("database called while it is locked")
+var ErrInvalidInterval = errors.New("invalid sweep interval")
+
+// create a new table in the database, pk names the fields of the primary
+// key separated by commas
+func (db *Database) CreateTable(ctx context.Context, tType interface{}, pk string, opts ...schema.Option) error {
+	if err := db.lock.Lock(ctx); err != nil {
+		return err
+	}
+	defer db.lock.Unlock()
 
-// create a new table in the database
-func (db *Database) CreateTable(tType interface{}, pk string) error {
 	// get the name of the struct using reflection
 	tableType := reflect.TypeOf(tType)
 	name := reflect.TypeOf(tType).Name()
@@ -111,69 +204,341 @@ func (db *Database) CreateTable(tType interface{}, pk string) error {
 		return ErrTableExists
 	}
 
-	_, found := tableType.FieldByName(pk)
-
-	if !found {
-		return ErrInvalidPk
+	keys, err := keyFields(tableType, pk)
+	if err != nil {
+		return err
+	}
+	options := schema.Options(opts...)
+	if options.Generator != "" {
+		if len(keys) > 1 {
+			return fmt.Errorf("%q can not be generated: %w", pk, ErrInvalidPk)
+		}
+		if err := options.Generator.Check(keys[0].Type); err != nil {
+			return err
+		}
 	}
-	db.Tables[name] = &Table{Name: name, Fields: tableType, Pk: pk}
+	if options.Sequence != 0 && options.Generator != schema.Sequence {
+		return fmt.Errorf("sequence of %q resumed without a sequence: %w", name, schema.ErrInvalidGenerator)
+	}
+	constraints, err := schema.ParseConstraints(tableType)
+	if err != nil {
+		return err
+	}
+	foreignKeys, err := schema.ParseForeignKeys(tableType)
+	if err != nil {
+		return err
+	}
+	if options.TTL < 0 {
+		return fmt.Errorf("%v: %w", options.TTL, schema.ErrInvalidTTL)
+	}
+	expiry, err := schema.ExpiryField(tableType)
+	if err != nil {
+		return err
+	}
+	table := &Table{Name: name, Fields: tableType, Pk: pk, Generator: options.Generator, Sequence: options.Sequence, keys: keys, constraints: constraints, TTL: options.TTL, clock: db.now}
+	if expiry != "" {
+		f, _ := tableType.FieldByName(expiry)
+		table.expiry = f.Index
+	}
+	if table.references, err = db.references(table, append(foreignKeys, options.ForeignKeys...)); err != nil {
+		return err
+	}
+	for _, ref := range table.references {
+		ref.parent.referencedBy = append(ref.parent.referencedBy, ref)
+	}
+	db.Tables[name] = table
 	return nil
 }
 
-// insert a record into the table
-func (db *Database) Insert(record interface{}) error {
+// setRecord sets a record passed through a pointer to the record written
+func setRecord(target, record interface{}) {
+	if v := reflect.ValueOf(target); v.Kind() == reflect.Ptr {
+		v.Elem().Set(reflect.ValueOf(record))
+	}
+}
+
+// dereference a pointer to a record
+func recordValue(record interface{}) interface{} {
 	if reflect.TypeOf(record).Kind() == reflect.Ptr {
 		value := reflect.ValueOf(record).Elem()
 		newValue := reflect.New(value.Type()).Elem()
 		newValue.Set(value)
 		record = newValue.Interface()
 	}
+	return record
+}
+
+// insert a record into the table. Records are checked against the
+// constraints of the table before they are written, a record inserted
+// through a pointer is set to the written record with its generated primary
+// key and the changes of hooks.
+func (db *Database) Insert(ctx context.Context, record interface{}) (interface{}, error) {
+	table, written, err := db.insert(ctx, record, 0)
+	if err != nil {
+		return nil, err
+	}
+	setRecord(record, written)
+	return table.pkValue(reflect.ValueOf(written)), db.afterHook(ctx, write{kind: query.InsertOp, table: table, record: written})
+}
+
+// insert a record that expires after ttl, or by its table when ttl is zero,
+// under the lock, returns its table and the written record
+func (db *Database) insert(ctx context.Context, record interface{}, ttl time.Duration) (*Table, interface{}, error) {
+	if err := db.lock.Lock(ctx); err != nil {
+		return nil, nil, err
+	}
+	defer db.lock.Unlock()
+
+	record = recordValue(record)
 
 	tableName := reflect.TypeOf(record).Name()
 	table, ok := db.Tables[tableName]
 
 	if !ok {
-		return ErrInvalidTableName
+		return nil, nil, ErrInvalidTableName
+	}
+	if err := db.track(); err != nil {
+		return nil, nil, err
+	}
+
+	record, err := table.generateKey(record)
+	if err != nil {
+		return nil, nil, err
+	}
+	if record, err = db.hookRecord(ctx, schema.BeforeInsert, table, record); err != nil {
+		return nil, nil, err
+	}
+	record, expires := table.expires(record, ttl)
+	if err := table.check(record); err != nil {
+		return nil, nil, err
 	}
 
 	// get the value of the primary key
-	pk := reflect.ValueOf(record).FieldByName(table.Pk).String()
+	pk := table.recordKey(record)
 
 	// check if the record already exists
-	for _, r := range table.Records {
-		if r.Key == pk {
-			return ErrDuplicateRecord
-		}
+	if _, found := table.find(pk); found {
+		return nil, nil, ErrDuplicateRecord
+	}
+	db.dropExpired(table, pk)
+	i, _ := table.search(pk)
+	if err := table.checkReferences(record, pk, committed); err != nil {
+		return nil, nil, err
 	}
 
 	value, err := encoding.Encode(record)
 	if err != nil {
-		return fmt.Errorf("error encoding record: %v %w", err, ErrInvalidEncoding)
+		return nil, nil, fmt.Errorf("error encoding record: %v %w", err, ErrInvalidEncoding)
 	}
 
 	// insert the record
-	table.Records = append(table.Records, &Record{Key: pk, Value: value})
-	return nil
+	r := &Record{Key: pk, Value: value}
+	table.setExpiry(r, record, expires)
+	table.Records = append(table.Records, nil)
+	copy(table.Records[i+1:], table.Records[i:])
+	table.Records[i] = r
+	table.addEntries(record, r)
+	db.publish(write{kind: query.InsertOp, table: table, record: record}, nil)
+	db.cache.added(table, r)
+	db.evict()
+	return table, record, nil
+}
+
+// get a record from the table by the value of its primary key, the values
+// of a composite key are passed as a []interface{}
+func (db *Database) Get(ctx context.Context, tableType interface{}, pk interface{}) (interface{}, error) {
+	table, record, err := db.get(ctx, tableType, pk)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.runHooks(ctx, schema.AfterGet, table, record); err != nil {
+		return nil, err
+	}
+	return record, nil
 }
 
-// get a record from the table
-func (db *Database) Get(tableType interface{}, pk string) (interface{}, error) {
+func (db *Database) get(ctx context.Context, tableType interface{}, pk interface{}) (*Table, interface{}, error) {
+	if err := db.lock.RLock(ctx); err != nil {
+		return nil, nil, err
+	}
+	defer db.lock.RUnlock()
+
 	tableName := reflect.TypeOf(tableType).Name()
 	table, ok := db.Tables[tableName]
 
 	if !ok {
-		return nil, ErrInvalidTableName
+		return nil, nil, ErrInvalidTableName
 	}
 
 	// get the record
-	for _, r := range table.Records {
-		if r.Key == pk {
-			record := reflect.New(table.Fields).Interface()
-			// decoding failure can not happen until we change the table fields and we are not doing it as of now
-			_ = encoding.Decode(r.Value, record)
-			return record, nil
+	key, err := table.key(pk)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, found := table.find(key)
+	db.cache.used(r)
+	if !found {
+		return nil, nil, ErrRecordNotFound
+	}
+	record := reflect.New(table.Fields).Interface()
+	// decoding failure can not happen until we change the table fields and we are not doing it as of now
+	_ = encoding.Decode(r.Value, record)
+	return table, record, nil
+}
+
+// get the named fields of a record from the table, the other fields are not
+// decoded and keep their zero value
+func (db *Database) GetFields(ctx context.Context, tableType interface{}, pk interface{}, fields ...string) (interface{}, error) {
+	table, record, err := db.getFields(ctx, tableType, pk, fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.runHooks(ctx, schema.AfterGet, table, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (db *Database) getFields(ctx context.Context, tableType interface{}, pk interface{}, fields []string) (*Table, interface{}, error) {
+	if err := db.lock.RLock(ctx); err != nil {
+		return nil, nil, err
+	}
+	defer db.lock.RUnlock()
+
+	tableName := reflect.TypeOf(tableType).Name()
+	table, ok := db.Tables[tableName]
+
+	if !ok {
+		return nil, nil, ErrInvalidTableName
+	}
+	if err := (query.Spec{Fields: fields}).Check(table.Fields); err != nil {
+		return nil, nil, err
+	}
+
+	key, err := table.key(pk)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, found := table.find(key)
+	db.cache.used(r)
+	if !found {
+		return nil, nil, ErrRecordNotFound
+	}
+	record := reflect.New(table.Fields).Interface()
+	if err := encoding.Decode(r.Value, record, encoding.Fields(fields...)); err != nil {
+		return nil, nil, fmt.Errorf("error decoding record: %v %w", err, ErrInvalidEncoding)
+	}
+	return table, record, nil
+}
+
+// update a record in the table, the record to replace is found by its
+// primary key. A record updated through a pointer is set to the written
+// record with the changes of hooks.
+func (db *Database) Update(ctx context.Context, record interface{}) error {
+	table, written, err := db.update(ctx, record)
+	if err != nil {
+		return err
+	}
+	setRecord(record, written)
+	return db.afterHook(ctx, write{kind: query.UpdateOp, table: table, record: written})
+}
+
+func (db *Database) update(ctx context.Context, record interface{}) (*Table, interface{}, error) {
+	if err := db.lock.Lock(ctx); err != nil {
+		return nil, nil, err
+	}
+	defer db.lock.Unlock()
+
+	record = recordValue(record)
+
+	tableName := reflect.TypeOf(record).Name()
+	table, ok := db.Tables[tableName]
+
+	if !ok {
+		return nil, nil, ErrInvalidTableName
+	}
+	if err := db.track(); err != nil {
+		return nil, nil, err
+	}
+
+	record, err := db.hookRecord(ctx, schema.BeforeUpdate, table, record)
+	if err != nil {
+		return nil, nil, err
+	}
+	pk := table.recordKey(record)
+	r, found := table.find(pk)
+	if !found {
+		return nil, nil, ErrRecordNotFound
+	}
+	if err := table.check(record); err != nil {
+		return nil, nil, err
+	}
+	if err := table.checkReferences(record, pk, committed); err != nil {
+		return nil, nil, err
+	}
+
+	value, err := encoding.Encode(record)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error encoding record: %v %w", err, ErrInvalidEncoding)
+	}
+	if err := table.unindex(r); err != nil {
+		return nil, nil, err
+	}
+	db.publish(write{kind: query.UpdateOp, table: table, record: record}, r)
+	r.Value = value
+	table.setExpiry(r, record, r.Expires)
+	table.addEntries(record, r)
+	db.cache.updated(r)
+	db.evict()
+	return table, record, nil
+}
+
+// delete a record from the table, with the records referencing it when
+// their foreign keys cascade
+func (db *Database) Delete(ctx context.Context, tableType interface{}, pk interface{}) error {
+	writes, err := db.delete(ctx, tableType, pk)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, w := range writes {
+		if err := db.afterHook(ctx, w); err != nil {
+			errs = append(errs, err)
 		}
 	}
+	return errors.Join(errs...)
+}
+
+// delete a record under the lock, the writes of the delete actions of the
+// records referencing it are checked and applied like a batch
+func (db *Database) delete(ctx context.Context, tableType interface{}, pk interface{}) ([]write, error) {
+	if err := db.lock.Lock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.lock.Unlock()
 
-	return nil, ErrRecordNotFound
+	writes, err := db.prepare(ctx, []query.Op{{Kind: query.DeleteOp, TableType: tableType, Pk: pk}})
+	var batchErr *query.BatchError
+	if errors.As(err, &batchErr) {
+		return nil, batchErr.Errors[0].Err
+	}
+	if err != nil {
+		return nil, err
+	}
+	db.apply(writes)
+	return writes, nil
+}
+
+// get the record type and the primary key fields of a table by its name
+func (db *Database) TableSchema(ctx context.Context, name string) (reflect.Type, string, error) {
+	if err := db.lock.RLock(ctx); err != nil {
+		return nil, "", err
+	}
+	defer db.lock.RUnlock()
+
+	table, ok := db.Tables[name]
+	if !ok {
+		return nil, "", ErrInvalidTableName
+	}
+	return table.Fields, table.Pk, nil
 }
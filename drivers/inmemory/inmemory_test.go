@@ -1,10 +1,15 @@
 package inmemory
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"sync"
 	"testing"
+
+	"github.com/priyanshujain/go-storage/query"
+	"github.com/priyanshujain/go-storage/schema"
 )
 
 func TestInMemoryStorage(t *testing.T) {
@@ -244,16 +249,17 @@ type ExampleStruct struct {
 }
 
 func TestDatabase_CreateTable(t *testing.T) {
+	ctx := context.Background()
 	db := New()
 
 	// Test creating a new table
-	err := db.CreateTable(ExampleStruct{}, "ID")
+	err := db.CreateTable(ctx, ExampleStruct{}, "ID")
 	if err != nil {
 		t.Errorf("Failed to create table: %v", err)
 	}
 
 	// Test creating duplicate table
-	err = db.CreateTable(ExampleStruct{}, "ID")
+	err = db.CreateTable(ctx, ExampleStruct{}, "ID")
 	if err != ErrTableExists {
 		t.Errorf("Expected ErrTableExists, but got: %v", err)
 	}
@@ -261,35 +267,36 @@ func TestDatabase_CreateTable(t *testing.T) {
 	db = New()
 
 	// Test creating a table with an invalid primary key
-	err = db.CreateTable(ExampleStruct{}, "InvalidKey")
+	err = db.CreateTable(ctx, ExampleStruct{}, "InvalidKey")
 	if err != ErrInvalidPk {
 		t.Errorf("Expected ErrInvalidPk, but got: %v", err)
 	}
 }
 
 func TestDatabase_Insert(t *testing.T) {
+	ctx := context.Background()
 	db := New()
 
 	// Create a table and insert a record
-	err := db.CreateTable(ExampleStruct{}, "ID")
+	err := db.CreateTable(ctx, ExampleStruct{}, "ID")
 	if err != nil {
 		t.Errorf("Failed to create table: %v", err)
 	}
 
 	record := ExampleStruct{ID: "1", Name: "John Doe"}
-	err = db.Insert(record)
+	_, err = db.Insert(ctx, record)
 	if err != nil {
 		t.Errorf("Failed to insert record: %v", err)
 	}
 
 	// Try inserting a record into a non-existing table
-	err = db.Insert("invalid record")
+	_, err = db.Insert(ctx, "invalid record")
 	if err != ErrInvalidTableName {
 		t.Errorf("Expected ErrInvalidTableName, but got: %v", err)
 	}
 
 	// Try inserting a duplicate record
-	err = db.Insert(record)
+	_, err = db.Insert(ctx, record)
 	if err == nil {
 		t.Error("Expected an error when inserting duplicate record, but got nil")
 	}
@@ -303,10 +310,10 @@ func TestDatabase_Insert(t *testing.T) {
 			Name chan int
 		}
 		// Create a table and insert a record
-		_ = db.CreateTable(ExampleStruct{}, "ID")
+		_ = db.CreateTable(ctx, ExampleStruct{}, "ID")
 
 		record := ExampleStruct{ID: "1", Name: make(chan int)}
-		err = db.Insert(record)
+		_, err = db.Insert(ctx, record)
 		if !errors.Is(err, ErrInvalidEncoding) {
 			t.Errorf("Expected ErrInvalidEncoding, but got: %v", err)
 		}
@@ -321,10 +328,10 @@ func TestDatabase_Insert(t *testing.T) {
 			Name string
 		}
 		// Create a table and insert a record
-		_ = db.CreateTable(ExampleStruct{}, "ID")
+		_ = db.CreateTable(ctx, ExampleStruct{}, "ID")
 
 		record := &ExampleStruct{ID: "1", Name: "John Doe"}
-		err = db.Insert(record)
+		_, err = db.Insert(ctx, record)
 		if err != nil {
 			t.Errorf("Failed to insert record: %v", err)
 		}
@@ -332,22 +339,23 @@ func TestDatabase_Insert(t *testing.T) {
 }
 
 func TestDatabase_Get(t *testing.T) {
+	ctx := context.Background()
 	db := New()
 
 	// Create a table and insert a record
-	err := db.CreateTable(ExampleStruct{}, "ID")
+	err := db.CreateTable(ctx, ExampleStruct{}, "ID")
 	if err != nil {
 		t.Errorf("Failed to create table: %v", err)
 	}
 
 	record := ExampleStruct{ID: "1", Name: "John Doe"}
-	err = db.Insert(record)
+	_, err = db.Insert(ctx, record)
 	if err != nil {
 		t.Errorf("Failed to insert record: %v", err)
 	}
 
 	// Get an existing record
-	result, err := db.Get(ExampleStruct{}, "1")
+	result, err := db.Get(ctx, ExampleStruct{}, "1")
 	if err != nil {
 		t.Errorf("Failed to get record: %v", err)
 	}
@@ -362,14 +370,224 @@ func TestDatabase_Get(t *testing.T) {
 	}
 
 	// Try getting a record from a non-existing table
-	_, err = db.Get("InvalidTable", "1")
+	_, err = db.Get(ctx, "InvalidTable", "1")
 	if err != ErrInvalidTableName {
 		t.Errorf("Expected ErrInvalidTableName, but got: %v", err)
 	}
 
 	// Try getting a non-existing record
-	_, err = db.Get(ExampleStruct{}, "2")
+	_, err = db.Get(ctx, ExampleStruct{}, "2")
+	if err != ErrRecordNotFound {
+		t.Errorf("Expected ErrRecordNotFound, but got: %v", err)
+	}
+}
+
+func TestDatabase_GetFields(t *testing.T) {
+	ctx := context.Background()
+	db := newPeopleDatabase(t, 3)
+
+	result, err := db.GetFields(ctx, Person{}, "p001", "Name", "City")
+	if err != nil {
+		t.Fatalf("Failed to get record: %v", err)
+	}
+	expected := &Person{Name: "name002", City: "Delhi"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, result)
+	}
+
+	if _, err := db.GetFields(ctx, Person{}, "p001", "Missing"); !errors.Is(err, query.ErrInvalidField) {
+		t.Errorf("Expected %v but got %v", query.ErrInvalidField, err)
+	}
+	if _, err := db.GetFields(ctx, Person{}, "p009", "Name"); err != ErrRecordNotFound {
+		t.Errorf("Expected %v but got %v", ErrRecordNotFound, err)
+	}
+	if _, err := db.GetFields(ctx, ExampleStruct{}, "p001", "Name"); err != ErrInvalidTableName {
+		t.Errorf("Expected %v but got %v", ErrInvalidTableName, err)
+	}
+}
+
+func TestDatabase_Update(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+
+	// Create a table and insert a record
+	err := db.CreateTable(ctx, ExampleStruct{}, "ID")
+	if err != nil {
+		t.Errorf("Failed to create table: %v", err)
+	}
+	err = db.CreateIndex(ctx, ExampleStruct{}, "Name")
+	if err != nil {
+		t.Errorf("Failed to create index: %v", err)
+	}
+	_, err = db.Insert(ctx, ExampleStruct{ID: "1", Name: "John Doe"})
+	if err != nil {
+		t.Errorf("Failed to insert record: %v", err)
+	}
+
+	// Update the record
+	err = db.Update(ctx, &ExampleStruct{ID: "1", Name: "Jane Doe"})
+	if err != nil {
+		t.Errorf("Failed to update record: %v", err)
+	}
+	result, err := db.Get(ctx, ExampleStruct{}, "1")
+	if err != nil {
+		t.Errorf("Failed to get record: %v", err)
+	}
+	if result.(*ExampleStruct).Name != "Jane Doe" {
+		t.Errorf("Expected updated name, but got: %+v", result)
+	}
+
+	// The index follows the update
+	records, err := db.Query(ctx, ExampleStruct{}).Where("Name", "=", "John Doe").All()
+	if err != nil || len(records) != 0 {
+		t.Errorf("Expected no records for the old name, but got: %v %v", records, err)
+	}
+	records, err = db.Query(ctx, ExampleStruct{}).Where("Name", "=", "Jane Doe").All()
+	if err != nil || len(records) != 1 {
+		t.Errorf("Expected one record for the new name, but got: %v %v", records, err)
+	}
+
+	// Try updating a non-existing record
+	err = db.Update(ctx, ExampleStruct{ID: "2"})
 	if err != ErrRecordNotFound {
 		t.Errorf("Expected ErrRecordNotFound, but got: %v", err)
 	}
+
+	// Try updating a record of a non-existing table
+	err = db.Update(ctx, "invalid record")
+	if err != ErrInvalidTableName {
+		t.Errorf("Expected ErrInvalidTableName, but got: %v", err)
+	}
+}
+
+func TestDatabase_Delete(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+
+	// Create a table and insert records
+	err := db.CreateTable(ctx, ExampleStruct{}, "ID")
+	if err != nil {
+		t.Errorf("Failed to create table: %v", err)
+	}
+	err = db.CreateIndex(ctx, ExampleStruct{}, "Name")
+	if err != nil {
+		t.Errorf("Failed to create index: %v", err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		_, err = db.Insert(ctx, ExampleStruct{ID: id, Name: "John Doe"})
+		if err != nil {
+			t.Errorf("Failed to insert record: %v", err)
+		}
+	}
+
+	// Delete a record
+	err = db.Delete(ctx, ExampleStruct{}, "2")
+	if err != nil {
+		t.Errorf("Failed to delete record: %v", err)
+	}
+	_, err = db.Get(ctx, ExampleStruct{}, "2")
+	if err != ErrRecordNotFound {
+		t.Errorf("Expected ErrRecordNotFound, but got: %v", err)
+	}
+	records, err := db.Query(ctx, ExampleStruct{}).Where("Name", "=", "John Doe").All()
+	if err != nil || len(records) != 2 {
+		t.Errorf("Expected two records left in the index, but got: %v %v", records, err)
+	}
+
+	// Try deleting it again
+	err = db.Delete(ctx, ExampleStruct{}, "2")
+	if err != ErrRecordNotFound {
+		t.Errorf("Expected ErrRecordNotFound, but got: %v", err)
+	}
+
+	// Try deleting from a non-existing table
+	err = db.Delete(ctx, "InvalidTable", "1")
+	if err != ErrInvalidTableName {
+		t.Errorf("Expected ErrInvalidTableName, but got: %v", err)
+	}
+}
+
+func TestDatabase_TableSchema(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+	_ = db.CreateTable(ctx, ExampleStruct{}, "ID")
+
+	recordType, pk, err := db.TableSchema(ctx, "ExampleStruct")
+	if err != nil {
+		t.Errorf("Failed to get table schema: %v", err)
+	}
+	if recordType != reflect.TypeOf(ExampleStruct{}) || pk != "ID" {
+		t.Errorf("Unexpected schema: %v %v", recordType, pk)
+	}
+
+	_, _, err = db.TableSchema(ctx, "InvalidTable")
+	if err != ErrInvalidTableName {
+		t.Errorf("Expected ErrInvalidTableName, but got: %v", err)
+	}
+}
+
+type Signup struct {
+	Email string `validate:"required,match=^[^@]+@[^@]+$"`
+	Age   int    `validate:"min=13"`
+	Plan  string `validate:"oneof=free pro"`
+}
+
+var errReserved = errors.New("reserved address")
+
+func (s Signup) Validate() error {
+	if s.Email == "root@localhost" {
+		return errReserved
+	}
+	return nil
+}
+
+func TestDatabase_Constraints(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+	if err := db.CreateTable(ctx, Signup{}, "Email"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	valid := Signup{Email: "ann@example.com", Age: 20, Plan: "free"}
+	if _, err := db.Insert(ctx, valid); err != nil {
+		t.Fatalf("Failed to insert record: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		write  func(record Signup) error
+		record Signup
+		err    string
+	}{
+		{"insert", func(r Signup) error { _, err := db.Insert(ctx, r); return err }, Signup{Email: "bob", Age: 20, Plan: "free"}, "Signup.Email: match=^[^@]+@[^@]+$: constraint violated"},
+		{"update", func(r Signup) error { return db.Update(ctx, &r) }, Signup{Email: "ann@example.com", Age: 12, Plan: "free"}, "Signup.Age: min=13: constraint violated"},
+		{"batch", func(r Signup) error { return db.Batch(ctx).Insert(r).Commit() }, Signup{Email: "cid@example.com", Age: 20, Plan: "gold"}, "batch failed: insert 0: Signup.Plan: oneof=free pro: constraint violated"},
+		{"validate", func(r Signup) error { _, err := db.Insert(ctx, r); return err }, Signup{Email: "root@localhost", Age: 20, Plan: "pro"}, "Signup: Validate: reserved address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.write(tt.record)
+			if !errors.Is(err, schema.ErrConstraint) {
+				t.Fatalf("Expected %v but got %v", schema.ErrConstraint, err)
+			}
+			if err.Error() != tt.err {
+				t.Errorf("Expected %q but got %q", tt.err, err.Error())
+			}
+		})
+	}
+
+	record, err := db.Get(ctx, Signup{}, "ann@example.com")
+	if err != nil || *record.(*Signup) != valid {
+		t.Errorf("Expected %+v unchanged but got %v, %v", valid, record, err)
+	}
+	if _, err := db.Get(ctx, Signup{}, "root@localhost"); err != ErrRecordNotFound {
+		t.Errorf("Expected %v but got %v", ErrRecordNotFound, err)
+	}
+
+	type Broken struct {
+		Id  string
+		Age int `validate:"len=2"`
+	}
+	if err := db.CreateTable(ctx, Broken{}, "Id"); !errors.Is(err, schema.ErrInvalidConstraint) {
+		t.Errorf("Expected %v but got %v", schema.ErrInvalidConstraint, err)
+	}
 }
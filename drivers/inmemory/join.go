@@ -0,0 +1,194 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/priyanshujain/go-storage/encoding"
+	"github.com/priyanshujain/go-storage/query"
+)
+
+// strategies to find the right records of a join
+const (
+	// look up the right records of each left record by primary key or index
+	indexNestedLoop = "index nested loop"
+	// build a hash table of the right records on the join field
+	hashJoin = "hash join"
+)
+
+// start an inner join between the tables of two table types
+func (db *Database) Join(ctx context.Context, left, right interface{}) *query.Join {
+	return query.NewJoin(ctx, db, query.InnerJoin, left, right)
+}
+
+// start a left join between the tables of two table types
+func (db *Database) LeftJoin(ctx context.Context, left, right interface{}) *query.Join {
+	return query.NewJoin(ctx, db, query.LeftJoin, left, right)
+}
+
+// run a join, the right records are looked up when the right field is the
+// primary key or indexed and hashed otherwise
+func (db *Database) ExecuteJoin(ctx context.Context, spec query.JoinSpec) ([]query.Row, error) {
+	if err := db.lock.RLock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.lock.RUnlock()
+
+	left, ok := db.Tables[reflect.TypeOf(spec.Left).Name()]
+	if !ok {
+		return nil, ErrInvalidTableName
+	}
+	right, ok := db.Tables[reflect.TypeOf(spec.Right).Name()]
+	if !ok {
+		return nil, ErrInvalidTableName
+	}
+	if err := spec.Check(left.Fields, right.Fields); err != nil {
+		return nil, err
+	}
+
+	leftRecords, err := left.matching(ctx, spec.LeftConditions)
+	if err != nil {
+		return nil, err
+	}
+	lookup, err := right.joiner(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []query.Row
+	for i, record := range leftRecords {
+		if err := interrupted(ctx, i); err != nil {
+			return nil, err
+		}
+		value, _ := query.Field(record, spec.LeftField)
+		matches, err := lookup(value)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			rows = append(rows, query.Row{Left: record, Right: match})
+		}
+		if len(matches) == 0 && spec.Kind == query.LeftJoin {
+			rows = append(rows, query.Row{Left: record})
+		}
+	}
+	return rows, nil
+}
+
+// joinStrategy returns how the right records of a join are found
+func (t *Table) joinStrategy(field string) string {
+	if _, ok := t.Indexes[field]; ok {
+		return indexNestedLoop
+	}
+	if len(t.keys) == 1 && field == t.keys[0].Name {
+		return indexNestedLoop
+	}
+	return hashJoin
+}
+
+// joiner returns a function finding the records of the right table of a
+// join matching a value of the left field, in primary key order
+func (t *Table) joiner(ctx context.Context, spec query.JoinSpec) (func(value interface{}) ([]interface{}, error), error) {
+	if t.joinStrategy(spec.RightField) == hashJoin {
+		records, err := t.matching(ctx, spec.RightConditions)
+		if err != nil {
+			return nil, err
+		}
+		hash := make(map[interface{}][]interface{})
+		for _, record := range records {
+			value, _ := query.Field(record, spec.RightField)
+			if key, ok := query.HashKey(value); ok {
+				hash[key] = append(hash[key], record)
+			}
+		}
+		return func(value interface{}) ([]interface{}, error) {
+			key, ok := query.HashKey(value)
+			if !ok {
+				return nil, nil
+			}
+			return hash[key], nil
+		}, nil
+	}
+
+	decoded := make(map[*Record]interface{})
+	return func(value interface{}) ([]interface{}, error) {
+		if _, ok := query.HashKey(value); !ok {
+			return nil, nil
+		}
+		var candidates []*Record
+		if index, ok := t.Indexes[spec.RightField]; ok {
+			lower, upper := index.bounds(value)
+			for _, entry := range index.entries[lower:upper] {
+				candidates = append(candidates, entry.record)
+			}
+			candidates = t.live(candidates)
+		} else if key, err := t.key(reflect.Indirect(reflect.ValueOf(value)).Interface()); err == nil {
+			// values that do not convert to the key type match no key
+			if r, ok := t.find(key); ok {
+				candidates = append(candidates, r)
+			}
+		}
+
+		var records []interface{}
+		for _, r := range candidates {
+			record, ok := decoded[r]
+			if !ok {
+				var err error
+				if record, err = t.decode(r); err != nil {
+					return nil, err
+				}
+				if ok, err = (query.Spec{Conditions: spec.RightConditions}).Matches(record); err != nil {
+					return nil, err
+				}
+				if !ok {
+					record = nil
+				}
+				decoded[r] = record
+			}
+			if record != nil {
+				records = append(records, record)
+			}
+		}
+		return records, nil
+	}, nil
+}
+
+// matching returns the decoded records matching the conditions in primary
+// key order
+func (t *Table) matching(ctx context.Context, conditions []query.Condition) ([]interface{}, error) {
+	candidates, indexed := t.candidates(conditions)
+	if indexed {
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].Key < candidates[j].Key
+		})
+	}
+	spec := query.Spec{Conditions: conditions}
+	var records []interface{}
+	for i, r := range candidates {
+		if err := interrupted(ctx, i); err != nil {
+			return nil, err
+		}
+		record, err := t.decode(r)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := spec.Matches(record)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (t *Table) decode(r *Record) (interface{}, error) {
+	record := reflect.New(t.Fields).Interface()
+	if err := encoding.Decode(r.Value, record); err != nil {
+		return nil, fmt.Errorf("error decoding record: %v %w", err, ErrInvalidEncoding)
+	}
+	return record, nil
+}
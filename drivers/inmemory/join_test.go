@@ -0,0 +1,186 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/priyanshujain/go-storage/query"
+)
+
+type Customer struct {
+	Id   string
+	Name string
+	Tier int
+}
+
+type Order struct {
+	Id         string
+	CustomerId *string
+	Amount     float64
+	Tier       int64
+}
+
+func newShopDatabase(t *testing.T) *Database {
+	ctx := context.Background()
+	db := New()
+	if err := db.CreateTable(ctx, Customer{}, "Id"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if err := db.CreateTable(ctx, Order{}, "Id"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	customers := []Customer{{"c1", "Ann", 1}, {"c2", "Bob", 2}, {"c3", "Cid", 1}}
+	for _, c := range customers {
+		if _, err := db.Insert(ctx, c); err != nil {
+			t.Fatalf("Failed to insert record: %v", err)
+		}
+	}
+	customer := func(id string) *string { return &id }
+	orders := []Order{
+		{"o1", customer("c1"), 10, 1},
+		{"o2", customer("c2"), 20, 2},
+		{"o3", customer("c1"), 30, 3},
+		{"o4", customer("c9"), 40, 1},
+		{"o5", nil, 50, 2},
+	}
+	for _, o := range orders {
+		if _, err := db.Insert(ctx, o); err != nil {
+			t.Fatalf("Failed to insert record: %v", err)
+		}
+	}
+	return db
+}
+
+// pairs returns the ids of the records of each row
+func pairs(rows []query.Row) [][2]string {
+	pairs := [][2]string{}
+	for _, row := range rows {
+		var pair [2]string
+		for i, record := range []interface{}{row.Left, row.Right} {
+			if record != nil {
+				id, _ := query.Field(record, "Id")
+				pair[i] = id.(string)
+			}
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+func TestDatabase_Join(t *testing.T) {
+	ctx := context.Background()
+	db := newShopDatabase(t)
+
+	tests := []struct {
+		name     string
+		join     func() *query.Join
+		strategy string
+		expected [][2]string
+	}{
+		{
+			name:     "inner on primary key",
+			join:     func() *query.Join { return db.Join(ctx, Order{}, Customer{}).On("CustomerId", "Id") },
+			strategy: indexNestedLoop,
+			expected: [][2]string{{"o1", "c1"}, {"o2", "c2"}, {"o3", "c1"}},
+		},
+		{
+			name:     "left on primary key",
+			join:     func() *query.Join { return db.LeftJoin(ctx, Order{}, Customer{}).On("CustomerId", "Id") },
+			strategy: indexNestedLoop,
+			expected: [][2]string{{"o1", "c1"}, {"o2", "c2"}, {"o3", "c1"}, {"o4", ""}, {"o5", ""}},
+		},
+		{
+			name:     "one to many",
+			join:     func() *query.Join { return db.LeftJoin(ctx, Customer{}, Order{}).On("Id", "CustomerId") },
+			strategy: hashJoin,
+			expected: [][2]string{{"c1", "o1"}, {"c1", "o3"}, {"c2", "o2"}, {"c3", ""}},
+		},
+		{
+			name:     "numbers of different kinds",
+			join:     func() *query.Join { return db.Join(ctx, Customer{}, Order{}).On("Tier", "Tier") },
+			strategy: hashJoin,
+			expected: [][2]string{{"c1", "o1"}, {"c1", "o4"}, {"c2", "o2"}, {"c2", "o5"}, {"c3", "o1"}, {"c3", "o4"}},
+		},
+		{
+			name: "conditions",
+			join: func() *query.Join {
+				return db.LeftJoin(ctx, Customer{}, Order{}).On("Id", "CustomerId").
+					Where("Customer.Name", "!=", "Bob").Where("Order.Amount", ">", 15)
+			},
+			strategy: hashJoin,
+			expected: [][2]string{{"c1", "o3"}, {"c3", ""}},
+		},
+	}
+
+	planned := func(t *testing.T) {
+		for _, tt := range tests {
+			spec := tt.join().Spec()
+			right := db.Tables[reflect.TypeOf(spec.Right).Name()]
+			if strategy := right.joinStrategy(spec.RightField); strategy != tt.strategy {
+				t.Errorf("Expected %s for %s but got %s", tt.strategy, tt.name, strategy)
+			}
+		}
+	}
+	run := func(t *testing.T) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rows, err := tt.join().All()
+				if err != nil {
+					t.Fatalf("Failed to run join: %v", err)
+				}
+				if got := pairs(rows); !reflect.DeepEqual(got, tt.expected) {
+					t.Errorf("Expected %v but got %v", tt.expected, got)
+				}
+			})
+		}
+	}
+
+	t.Run("planned", planned)
+	t.Run("without indexes", run)
+	for _, table := range []interface{}{Order{}, Customer{}} {
+		if err := db.CreateIndex(ctx, table, "Tier"); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+	}
+	if err := db.CreateIndex(ctx, Order{}, "CustomerId"); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	// the right fields of the other joins are indexed now
+	for i := 2; i < len(tests); i++ {
+		tests[i].strategy = indexNestedLoop
+	}
+	t.Run("planned with indexes", planned)
+	t.Run("with indexes", run)
+
+	t.Run("records", func(t *testing.T) {
+		rows, err := db.Join(ctx, Order{}, Customer{}).On("CustomerId", "Id").Where("Id", "=", "o2").All()
+		if err != nil {
+			t.Fatalf("Failed to run join: %v", err)
+		}
+		if len(rows) != 1 || rows[0].Left.(*Order).Amount != 20 || rows[0].Right.(*Customer).Name != "Bob" {
+			t.Errorf("Expected order o2 of Bob but got %+v", rows)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name string
+			join *query.Join
+			err  error
+		}{
+			{"invalid left table", db.Join(ctx, ExampleStruct{}, Customer{}).On("Id", "Id"), ErrInvalidTableName},
+			{"invalid right table", db.Join(ctx, Order{}, ExampleStruct{}).On("Id", "Id"), ErrInvalidTableName},
+			{"missing on", db.Join(ctx, Order{}, Customer{}), query.ErrInvalidField},
+			{"invalid field", db.Join(ctx, Order{}, Customer{}).On("CustomerId", "Missing"), query.ErrInvalidField},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := tt.join.All(); !errors.Is(err, tt.err) {
+					t.Errorf("Expected %v but got %v", tt.err, err)
+				}
+			})
+		}
+	})
+}
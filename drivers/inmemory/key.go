@@ -0,0 +1,178 @@
+package inmemory
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// A primary key is made of one or more fields of the record type, declared
+// to CreateTable as their names separated by commas, as in "TenantId,UserId".
+// Fields of a key are signed or unsigned integers, strings and byte arrays
+// such as UUIDs stored as [16]byte.
+//
+// The key of a record is encoded into a string whose byte order is the
+// natural order of the key, integers by value, strings and byte arrays
+// lexicographically and composite keys field by field, so that records
+// sorted by Key are in primary key order.
+
+// keyFields returns the fields of a primary key declaration
+func keyFields(recordType reflect.Type, pk string) ([]reflect.StructField, error) {
+	var fields []reflect.StructField
+	for _, name := range strings.Split(pk, ",") {
+		name = strings.TrimSpace(name)
+		f, ok := recordType.FieldByName(name)
+		if !ok || !f.IsExported() || !keyType(f.Type) {
+			return nil, ErrInvalidPk
+		}
+		for _, field := range fields {
+			if field.Name == f.Name {
+				return nil, ErrInvalidPk
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// keyType reports whether a field of type t can be part of a primary key
+func keyType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.String:
+		return true
+	case reflect.Array:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// recordKey returns the encoded primary key of a record value
+func (t *Table) recordKey(record interface{}) string {
+	v := reflect.ValueOf(record)
+	var key []byte
+	for _, f := range t.keys {
+		key = appendKey(key, v.FieldByIndex(f.Index))
+	}
+	return string(key)
+}
+
+// key encodes a primary key value, the values of the fields of a composite
+// key are passed in a []interface{} in the order of the declaration. Values
+// are converted to the type of their field when they can hold them.
+func (t *Table) key(pk interface{}) (string, error) {
+	values := []interface{}{pk}
+	if len(t.keys) > 1 {
+		var ok bool
+		if values, ok = pk.([]interface{}); !ok || len(values) != len(t.keys) {
+			return "", fmt.Errorf("%v for a key of %d fields: %w", pk, len(t.keys), ErrInvalidPk)
+		}
+	}
+	var key []byte
+	for i, f := range t.keys {
+		v, err := keyValue(f.Type, values[i])
+		if err != nil {
+			return "", fmt.Errorf("%q: %w", f.Name, err)
+		}
+		key = appendKey(key, v)
+	}
+	return string(key), nil
+}
+
+// keyValue converts a value to the type of a key field
+func keyValue(t reflect.Type, value interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(value)
+	invalid := fmt.Errorf("%v of type %T for %v: %w", value, value, t, ErrInvalidPk)
+	if !v.IsValid() {
+		return reflect.Value{}, invalid
+	}
+	if v.Type() == t {
+		return v, nil
+	}
+	converted := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := intValue(v)
+		if !ok || converted.OverflowInt(n) {
+			return reflect.Value{}, invalid
+		}
+		converted.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := uintValue(v)
+		if !ok || converted.OverflowUint(n) {
+			return reflect.Value{}, invalid
+		}
+		converted.SetUint(n)
+	case reflect.String:
+		if v.Kind() != reflect.String {
+			return reflect.Value{}, invalid
+		}
+		converted.SetString(v.String())
+	case reflect.Array:
+		if (v.Kind() != reflect.Array && v.Kind() != reflect.Slice) || v.Type().Elem().Kind() != reflect.Uint8 || v.Len() != t.Len() {
+			return reflect.Value{}, invalid
+		}
+		reflect.Copy(converted, v)
+	}
+	return converted, nil
+}
+
+// intValue returns an integer, or a float without fraction, as an int64
+func intValue(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(v.Uint()), v.Uint() <= math.MaxInt64
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		return int64(f), f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64
+	}
+	return 0, false
+}
+
+// uintValue returns a non negative integer, or float without fraction, as
+// an uint64
+func uintValue(v reflect.Value) (uint64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(v.Int()), v.Int() >= 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		return uint64(f), f == math.Trunc(f) && f >= 0 && f < math.MaxUint64
+	}
+	return 0, false
+}
+
+// appendKey appends the order preserving encoding of a key field. Integers
+// take 8 big endian bytes with the sign bit of signed integers flipped, byte
+// arrays are copied and strings end with 0x00 0x01 with 0x00 escaped as
+// 0x00 0xFF, so that no encoded field is a prefix of another.
+func appendKey(key []byte, v reflect.Value) []byte {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.BigEndian.AppendUint64(key, uint64(v.Int())^(1<<63))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return binary.BigEndian.AppendUint64(key, v.Uint())
+	case reflect.String:
+		s := v.String()
+		for i := 0; i < len(s); i++ {
+			if s[i] == 0 {
+				key = append(key, 0, 0xFF)
+			} else {
+				key = append(key, s[i])
+			}
+		}
+		return append(key, 0, 1)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			key = append(key, byte(v.Index(i).Uint()))
+		}
+	}
+	return key
+}
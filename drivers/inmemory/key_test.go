@@ -0,0 +1,221 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/priyanshujain/go-storage/query"
+)
+
+type Account struct {
+	Id      int
+	Balance int
+}
+
+type Device struct {
+	Serial uint16
+	Model  string
+}
+
+type Session struct {
+	Id   [16]byte
+	User string
+}
+
+type Member struct {
+	TenantId string
+	UserId   int64
+	Role     string
+}
+
+func TestDatabase_TypedKeys(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+	for _, table := range []struct {
+		tableType interface{}
+		pk        string
+	}{
+		{Account{}, "Id"},
+		{Device{}, "Serial"},
+		{Session{}, "Id"},
+		{Member{}, "TenantId, UserId"},
+	} {
+		if err := db.CreateTable(ctx, table.tableType, table.pk); err != nil {
+			t.Fatalf("Failed to create table %T: %v", table.tableType, err)
+		}
+	}
+
+	t.Run("int", func(t *testing.T) {
+		for _, id := range []int{10, -3, 2, 0, -20, 300} {
+			if _, err := db.Insert(ctx, Account{Id: id, Balance: id * 10}); err != nil {
+				t.Fatalf("Failed to insert account %d: %v", id, err)
+			}
+		}
+		if _, err := db.Insert(ctx, Account{Id: 2}); err != ErrDuplicateRecord {
+			t.Errorf("Expected %v but got %v", ErrDuplicateRecord, err)
+		}
+
+		records, err := db.Query(ctx, Account{}).All()
+		if err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		var got []int
+		for _, record := range records {
+			got = append(got, record.(*Account).Id)
+		}
+		if expected := []int{-20, -3, 0, 2, 10, 300}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v but got %v", expected, got)
+		}
+
+		for _, pk := range []interface{}{-3, int64(-3), int8(-3), -3.0} {
+			record, err := db.Get(ctx, Account{}, pk)
+			if err != nil {
+				t.Fatalf("Failed to get account %v of type %T: %v", pk, pk, err)
+			}
+			if record.(*Account).Balance != -30 {
+				t.Errorf("Expected a balance of -30 but got %v", record.(*Account).Balance)
+			}
+		}
+		for _, pk := range []interface{}{"-3", 2.5, nil, []interface{}{2}} {
+			if _, err := db.Get(ctx, Account{}, pk); !errors.Is(err, ErrInvalidPk) {
+				t.Errorf("Expected %v for %v but got %v", ErrInvalidPk, pk, err)
+			}
+		}
+
+		records, err = db.Query(ctx, Account{}).Where("Id", "=", 300).All()
+		if err != nil || len(records) != 1 || records[0].(*Account).Balance != 3000 {
+			t.Errorf("Expected account 300 but got %v, %v", records, err)
+		}
+	})
+
+	t.Run("uint", func(t *testing.T) {
+		if err := db.InsertMany(ctx, Device{Serial: 65535, Model: "max"}, Device{Serial: 256, Model: "mid"}, Device{Serial: 1, Model: "min"}); err != nil {
+			t.Fatalf("Failed to insert devices: %v", err)
+		}
+		page, err := db.List(ctx, Device{}, query.ListOptions{Limit: 2})
+		if err != nil {
+			t.Fatalf("Failed to list devices: %v", err)
+		}
+		page, err = db.List(ctx, Device{}, query.ListOptions{Limit: 2, After: page.Next})
+		if err != nil {
+			t.Fatalf("Failed to list devices: %v", err)
+		}
+		if len(page.Records) != 1 || page.Records[0].(*Device).Model != "max" {
+			t.Errorf("Expected the last device on the second page but got %+v", page.Records)
+		}
+		if _, err := db.Get(ctx, Device{}, -1); !errors.Is(err, ErrInvalidPk) {
+			t.Errorf("Expected %v but got %v", ErrInvalidPk, err)
+		}
+		if _, err := db.Get(ctx, Device{}, 65536); !errors.Is(err, ErrInvalidPk) {
+			t.Errorf("Expected %v but got %v", ErrInvalidPk, err)
+		}
+	})
+
+	t.Run("uuid", func(t *testing.T) {
+		id := [16]byte{0x8f, 0x14, 0xe4, 0x5f, 0xce, 0xea, 0x46, 0x7f, 0xa0, 0xe6, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+		if _, err := db.Insert(ctx, Session{Id: id, User: "ann"}); err != nil {
+			t.Fatalf("Failed to insert session: %v", err)
+		}
+		for _, pk := range []interface{}{id, id[:]} {
+			record, err := db.Get(ctx, Session{}, pk)
+			if err != nil || record.(*Session).User != "ann" {
+				t.Errorf("Expected the session of ann but got %v, %v", record, err)
+			}
+		}
+		if _, err := db.Get(ctx, Session{}, id[:8]); !errors.Is(err, ErrInvalidPk) {
+			t.Errorf("Expected %v but got %v", ErrInvalidPk, err)
+		}
+	})
+
+	t.Run("composite", func(t *testing.T) {
+		members := []interface{}{
+			Member{TenantId: "b", UserId: 1, Role: "owner"},
+			Member{TenantId: "a", UserId: 10, Role: "admin"},
+			Member{TenantId: "a", UserId: 2, Role: "user"},
+			Member{TenantId: "ab", UserId: -1, Role: "guest"},
+			Member{TenantId: "a\x00", UserId: 0, Role: "odd"},
+		}
+		if err := db.InsertMany(ctx, members...); err != nil {
+			t.Fatalf("Failed to insert members: %v", err)
+		}
+		if _, err := db.Insert(ctx, Member{TenantId: "a", UserId: 2}); err != ErrDuplicateRecord {
+			t.Errorf("Expected %v but got %v", ErrDuplicateRecord, err)
+		}
+
+		records, err := db.Query(ctx, Member{}).All()
+		if err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		var roles []string
+		for _, record := range records {
+			roles = append(roles, record.(*Member).Role)
+		}
+		if expected := []string{"user", "admin", "odd", "guest", "owner"}; !reflect.DeepEqual(roles, expected) {
+			t.Errorf("Expected %v but got %v", expected, roles)
+		}
+
+		record, err := db.Get(ctx, Member{}, []interface{}{"a", 10})
+		if err != nil || record.(*Member).Role != "admin" {
+			t.Errorf("Expected the admin but got %v, %v", record, err)
+		}
+		if _, err := db.Get(ctx, Member{}, "a"); !errors.Is(err, ErrInvalidPk) {
+			t.Errorf("Expected %v but got %v", ErrInvalidPk, err)
+		}
+		if err := db.Delete(ctx, Member{}, []interface{}{"a", 10}); err != nil {
+			t.Fatalf("Failed to delete member: %v", err)
+		}
+		if _, err := db.Get(ctx, Member{}, []interface{}{"a", 10}); err != ErrRecordNotFound {
+			t.Errorf("Expected %v but got %v", ErrRecordNotFound, err)
+		}
+		pk, err := db.Insert(ctx, Member{TenantId: "a", UserId: 10})
+		if expected := []interface{}{"a", int64(10)}; err != nil || !reflect.DeepEqual(pk, expected) {
+			t.Errorf("Expected the key %v but got %v, %v", expected, pk, err)
+		}
+		if err := db.Update(ctx, Member{TenantId: "b", UserId: 1, Role: "admin"}); err != nil {
+			t.Fatalf("Failed to update member: %v", err)
+		}
+		if err := db.Batch(ctx).Delete(Member{}, []interface{}{"b", 1}).Commit(); err != nil {
+			t.Fatalf("Failed to delete member: %v", err)
+		}
+	})
+
+	t.Run("invalid declarations", func(t *testing.T) {
+		type Reading struct {
+			Value float64
+			At    int
+			tag   string
+		}
+		for _, pk := range []string{"Value", "At,At", "tag", "At,Missing", ""} {
+			if err := New().CreateTable(ctx, Reading{}, pk); err != ErrInvalidPk {
+				t.Errorf("Expected %v for %q but got %v", ErrInvalidPk, pk, err)
+			}
+		}
+	})
+}
+
+func TestDatabase_TypedKeys_Join(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+	type Transfer struct {
+		Id        string
+		AccountId int64
+	}
+	if err := db.CreateTable(ctx, Account{}, "Id"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if err := db.CreateTable(ctx, Transfer{}, "Id"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if err := db.InsertMany(ctx, Account{Id: 1, Balance: 5}, Transfer{Id: "t1", AccountId: 1}, Transfer{Id: "t2", AccountId: 2}); err != nil {
+		t.Fatalf("Failed to insert records: %v", err)
+	}
+	rows, err := db.LeftJoin(ctx, Transfer{}, Account{}).On("AccountId", "Id").All()
+	if err != nil {
+		t.Fatalf("Failed to run join: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Right.(*Account).Balance != 5 || rows[1].Right != nil {
+		t.Errorf("Expected t1 to match account 1 and t2 no account but got %+v", rows)
+	}
+}
@@ -0,0 +1,241 @@
+package inmemory
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/priyanshujain/go-storage/encoding"
+	"github.com/priyanshujain/go-storage/query"
+)
+
+// cursor is the position of the last record of a page
+type cursor struct {
+	key   string
+	value interface{}
+}
+
+// cursorType returns the record type of the cursors of an order, the value
+// has the type of the order field
+func (t *Table) cursorType(opts query.ListOptions) reflect.Type {
+	valueType := reflect.TypeOf("")
+	if opts.OrderBy != "" {
+		f, _ := t.Fields.FieldByName(opts.OrderBy)
+		valueType = f.Type
+	}
+	return reflect.StructOf([]reflect.StructField{
+		{Name: "Table", Type: reflect.TypeOf("")},
+		{Name: "OrderBy", Type: reflect.TypeOf("")},
+		{Name: "Desc", Type: reflect.TypeOf(false)},
+		{Name: "Key", Type: reflect.TypeOf("")},
+		{Name: "Value", Type: valueType},
+	})
+}
+
+// encode a cursor together with the table and order it belongs to, the
+// encoded record is turned into URL safe base64
+func (t *Table) encodeCursor(opts query.ListOptions, c cursor) (string, error) {
+	v := reflect.New(t.cursorType(opts)).Elem()
+	v.Field(0).SetString(t.Name)
+	v.Field(1).SetString(opts.OrderBy)
+	v.Field(2).SetBool(opts.Desc)
+	v.Field(3).SetString(c.key)
+	if opts.OrderBy != "" {
+		if value := reflect.ValueOf(c.value); value.IsValid() {
+			v.Field(4).Set(value)
+		}
+	}
+	record, err := encoding.Encode(v.Interface())
+	if err != nil {
+		return "", fmt.Errorf("error encoding cursor: %v %w", err, ErrInvalidEncoding)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(record)
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func (t *Table) decodeCursor(opts query.ListOptions) (cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(opts.After)
+	if err != nil {
+		return cursor{}, fmt.Errorf("%v: %w", err, query.ErrInvalidCursor)
+	}
+	v := reflect.New(t.cursorType(opts))
+	if err := encoding.Decode(base64.StdEncoding.EncodeToString(raw), v.Interface()); err != nil {
+		return cursor{}, fmt.Errorf("%v: %w", err, query.ErrInvalidCursor)
+	}
+	v = v.Elem()
+	if v.Field(0).String() != t.Name || v.Field(1).String() != opts.OrderBy || v.Field(2).Bool() != opts.Desc {
+		return cursor{}, fmt.Errorf("cursor of another table or order: %w", query.ErrInvalidCursor)
+	}
+	return cursor{key: v.Field(3).String(), value: v.Field(4).Interface()}, nil
+}
+
+// list a page of the records of a table, pages continue after the primary
+// key and order value of the last record of the previous page
+func (db *Database) List(ctx context.Context, tableType interface{}, opts query.ListOptions) (query.Page, error) {
+	if err := db.lock.RLock(ctx); err != nil {
+		return query.Page{}, err
+	}
+	defer db.lock.RUnlock()
+
+	tableName := reflect.TypeOf(tableType).Name()
+	table, ok := db.Tables[tableName]
+	if !ok {
+		return query.Page{}, ErrInvalidTableName
+	}
+	if opts.OrderBy != "" {
+		if err := (query.Spec{Orders: []query.Order{{Field: opts.OrderBy}}}).Check(table.Fields); err != nil {
+			return query.Page{}, err
+		}
+	}
+	if opts.Limit < 0 {
+		return query.Page{}, fmt.Errorf("limit %d: %w", opts.Limit, query.ErrInvalidLimit)
+	}
+	var after *cursor
+	if opts.After != "" {
+		c, err := table.decodeCursor(opts)
+		if err != nil {
+			return query.Page{}, err
+		}
+		after = &c
+	}
+
+	var positions []position
+	var more bool
+	var err error
+	if _, indexed := table.Indexes[opts.OrderBy]; opts.OrderBy == "" || indexed {
+		positions, more = table.seek(opts, after)
+	} else if positions, more, err = table.scanPage(ctx, opts, after); err != nil {
+		return query.Page{}, err
+	}
+
+	page := query.Page{Records: make([]interface{}, 0, len(positions))}
+	for _, p := range positions {
+		record := p.decoded
+		if record == nil {
+			if record, err = table.decode(p.record); err != nil {
+				return query.Page{}, err
+			}
+		}
+		page.Records = append(page.Records, record)
+	}
+	if more && len(positions) > 0 {
+		last := positions[len(positions)-1]
+		if page.Next, err = table.encodeCursor(opts, cursor{key: last.record.Key, value: last.value}); err != nil {
+			return query.Page{}, err
+		}
+	}
+	return page, nil
+}
+
+// position is a record of a page with its order value, decoded is set when
+// the record was decoded to find it
+type position struct {
+	record  *Record
+	value   interface{}
+	decoded interface{}
+}
+
+// seek walks the records in primary key order or the entries of the index of
+// the order field from the cursor, it reports whether records remain after
+// the page
+func (t *Table) seek(opts query.ListOptions, after *cursor) ([]position, bool) {
+	var n int
+	var at func(i int) position
+	var start int
+	if opts.OrderBy == "" {
+		n = len(t.Records)
+		at = func(i int) position { return position{record: t.Records[i]} }
+		if after != nil {
+			i, found := t.search(after.key)
+			if found && !opts.Desc {
+				i++
+			}
+			start = i
+		}
+	} else {
+		index := t.Indexes[opts.OrderBy]
+		n = len(index.entries)
+		at = func(i int) position {
+			return position{record: index.entries[i].record, value: index.entries[i].value}
+		}
+		if after != nil {
+			c := indexEntry{value: after.value, record: &Record{Key: after.key}}
+			// the first entry after the cursor
+			start = sort.Search(n, func(i int) bool { return index.less(c, index.entries[i]) })
+			if opts.Desc {
+				// the first entry before the cursor, counted from the end
+				start = sort.Search(n, func(i int) bool { return !index.less(index.entries[i], c) })
+			}
+		}
+	}
+	if opts.Desc {
+		if after == nil {
+			start = n
+		}
+		// walk downwards from the entry before start
+		top, ascending := start, at
+		at = func(i int) position { return ascending(top - 1 - i) }
+		n, start = top, 0
+	}
+
+	// expired records are skipped
+	now := t.now().UnixMilli()
+	var positions []position
+	for i := start; i < n; i++ {
+		p := at(i)
+		if expired(p.record, now) {
+			continue
+		}
+		if opts.Limit > 0 && len(positions) == opts.Limit {
+			return positions, true
+		}
+		positions = append(positions, p)
+	}
+	return positions, false
+}
+
+// scanPage decodes every record to find a page ordered by a field without an
+// index
+func (t *Table) scanPage(ctx context.Context, opts query.ListOptions, after *cursor) ([]position, bool, error) {
+	compare := func(a, b position) int {
+		result, _ := query.Compare(a.value, b.value)
+		if result == 0 {
+			switch {
+			case a.record.Key < b.record.Key:
+				result = -1
+			case a.record.Key > b.record.Key:
+				result = 1
+			}
+		}
+		if opts.Desc {
+			return -result
+		}
+		return result
+	}
+
+	var positions []position
+	for i, r := range t.live(t.Records) {
+		if err := interrupted(ctx, i); err != nil {
+			return nil, false, err
+		}
+		record, err := t.decode(r)
+		if err != nil {
+			return nil, false, err
+		}
+		value, _ := query.Field(record, opts.OrderBy)
+		p := position{record: r, value: value, decoded: record}
+		if after != nil && compare(p, position{record: &Record{Key: after.key}, value: after.value}) <= 0 {
+			continue
+		}
+		positions = append(positions, p)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return compare(positions[i], positions[j]) < 0
+	})
+	if opts.Limit > 0 && opts.Limit < len(positions) {
+		return positions[:opts.Limit], true, nil
+	}
+	return positions, false, nil
+}
@@ -0,0 +1,161 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/priyanshujain/go-storage/query"
+)
+
+// listAll follows the cursors of a listing to the end
+func listAll(t *testing.T, db *Database, opts query.ListOptions) ([]string, int) {
+	ctx := context.Background()
+	var all []interface{}
+	pages := 0
+	for {
+		page, err := db.List(ctx, Person{}, opts)
+		if err != nil {
+			t.Fatalf("Failed to list records: %v", err)
+		}
+		pages++
+		if opts.Limit > 0 && len(page.Records) > opts.Limit {
+			t.Fatalf("Expected at most %d records but got %d", opts.Limit, len(page.Records))
+		}
+		all = append(all, page.Records...)
+		if page.Next == "" {
+			return ids(all), pages
+		}
+		opts.After = page.Next
+	}
+}
+
+func TestDatabase_List(t *testing.T) {
+	ctx := context.Background()
+	db := newPeopleDatabase(t, 100)
+
+	tests := []struct {
+		name  string
+		opts  query.ListOptions
+		pages int
+		query *query.Query
+	}{
+		{"primary key", query.ListOptions{Limit: 30}, 4, db.Query(ctx, Person{})},
+		{"primary key descending", query.ListOptions{Limit: 30, Desc: true}, 4, db.Query(ctx, Person{}).OrderByDesc("Id")},
+		{"exact pages", query.ListOptions{Limit: 50}, 2, db.Query(ctx, Person{})},
+		{"single page", query.ListOptions{}, 1, db.Query(ctx, Person{})},
+		{"field with ties", query.ListOptions{Limit: 7, OrderBy: "Age"}, 15, db.Query(ctx, Person{}).OrderBy("Age").OrderBy("Id")},
+		{"field descending", query.ListOptions{Limit: 7, OrderBy: "Age", Desc: true}, 15, db.Query(ctx, Person{}).OrderByDesc("Age").OrderByDesc("Id")},
+		{"nil values", query.ListOptions{Limit: 40, OrderBy: "Score"}, 3, db.Query(ctx, Person{}).OrderBy("Score").OrderBy("Id")},
+		{"strings", query.ListOptions{Limit: 33, OrderBy: "City", Desc: true}, 4, db.Query(ctx, Person{}).OrderByDesc("City").OrderByDesc("Id")},
+	}
+
+	run := func(t *testing.T) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				records, err := tt.query.All()
+				if err != nil {
+					t.Fatalf("Failed to run query: %v", err)
+				}
+				expected := ids(records)
+				got, pages := listAll(t, db, tt.opts)
+				if !reflect.DeepEqual(got, expected) {
+					t.Errorf("Expected %v but got %v", expected, got)
+				}
+				if pages != tt.pages {
+					t.Errorf("Expected %d pages but got %d", tt.pages, pages)
+				}
+			})
+		}
+	}
+
+	t.Run("without indexes", run)
+	for _, field := range []string{"Age", "Score", "City"} {
+		if err := db.CreateIndex(ctx, Person{}, field); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+	}
+	t.Run("with indexes", run)
+
+	t.Run("errors", func(t *testing.T) {
+		page, err := db.List(ctx, Person{}, query.ListOptions{Limit: 10, OrderBy: "Age"})
+		if err != nil {
+			t.Fatalf("Failed to list records: %v", err)
+		}
+		tests := []struct {
+			name      string
+			tableType interface{}
+			opts      query.ListOptions
+			err       error
+		}{
+			{"invalid table", ExampleStruct{}, query.ListOptions{}, ErrInvalidTableName},
+			{"invalid field", Person{}, query.ListOptions{OrderBy: "Missing"}, query.ErrInvalidField},
+			{"negative limit", Person{}, query.ListOptions{Limit: -1}, query.ErrInvalidLimit},
+			{"malformed cursor", Person{}, query.ListOptions{After: "not a cursor!"}, query.ErrInvalidCursor},
+			{"cursor of another order", Person{}, query.ListOptions{After: page.Next, OrderBy: "Age", Desc: true}, query.ErrInvalidCursor},
+			{"cursor of another field", Person{}, query.ListOptions{After: page.Next, OrderBy: "City"}, query.ErrInvalidCursor},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := db.List(ctx, tt.tableType, tt.opts); !errors.Is(err, tt.err) {
+					t.Errorf("Expected %v but got %v", tt.err, err)
+				}
+			})
+		}
+	})
+}
+
+func TestDatabase_List_Stable(t *testing.T) {
+	ctx := context.Background()
+	for _, indexed := range []bool{false, true} {
+		name := "without index"
+		if indexed {
+			name = "with index"
+		}
+		t.Run(name, func(t *testing.T) {
+			db := newPeopleDatabase(t, 20)
+			if indexed {
+				if err := db.CreateIndex(ctx, Person{}, "Age"); err != nil {
+					t.Fatalf("Failed to create index: %v", err)
+				}
+			}
+			opts := query.ListOptions{Limit: 5, OrderBy: "Age"}
+			page, err := db.List(ctx, Person{}, opts)
+			if err != nil {
+				t.Fatalf("Failed to list records: %v", err)
+			}
+			last := page.Records[len(page.Records)-1].(*Person)
+
+			// delete the last record of the page and insert records before
+			// and after it
+			if err := db.Delete(ctx, Person{}, last.Id); err != nil {
+				t.Fatalf("Failed to delete record: %v", err)
+			}
+			for _, p := range []Person{
+				{Id: "a-before", Age: last.Age - 1},
+				{Id: "z-tie-after", Age: last.Age},
+				{Id: "a-tie-before", Age: last.Age},
+				{Id: "after", Age: last.Age + 1},
+			} {
+				if _, err := db.Insert(ctx, p); err != nil {
+					t.Fatalf("Failed to insert record: %v", err)
+				}
+			}
+
+			opts.After = page.Next
+			got, _ := listAll(t, db, opts)
+			var expected []string
+			records, _ := db.Query(ctx, Person{}).OrderBy("Age").OrderBy("Id").All()
+			for _, record := range records {
+				p := record.(*Person)
+				if p.Age > last.Age || p.Age == last.Age && p.Id > last.Id {
+					expected = append(expected, p.Id)
+				}
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("Expected %v but got %v", expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package inmemory
+
+import (
+	"context"
+	"sync"
+)
+
+// checkEvery is the number of records a loop handles between two checks of
+// its context
+const checkEvery = 256
+
+// interrupted returns the error of a done context on every checkEvery-th
+// iteration of a loop
+func interrupted(ctx context.Context, i int) error {
+	if i%checkEvery != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// rwLock is a readers-writer lock whose acquisition gives up when a context
+// is done. Readers share the lock, a writer waits until the last reader has
+// left. A waiting writer stops new readers from taking the lock, like
+// sync.RWMutex, so that overlapping readers can not keep it waiting. The
+// zero value is an unlocked lock.
+type rwLock struct {
+	once sync.Once
+	// turnstile is held by a writer from the time it waits for the lock
+	// until it unlocks, readers pass through it
+	turnstile chan struct{}
+	// write is held by a writer or by the readers together
+	write chan struct{}
+	// readers guards the number of readers
+	readers chan struct{}
+	count   int
+}
+
+// held is the context key marking a lock as held by the caller
+type held struct {
+	l *rwLock
+}
+
+// hold returns a context marking the lock as held, the lock fails with
+// ErrReentrant for the context instead of waiting for itself
+func (l *rwLock) hold(ctx context.Context) context.Context {
+	return context.WithValue(ctx, held{l}, true)
+}
+
+// check returns the error of a done context or of a context holding the
+// lock
+func (l *rwLock) check(ctx context.Context) error {
+	if ctx.Value(held{l}) != nil {
+		return ErrReentrant
+	}
+	return ctx.Err()
+}
+
+func (l *rwLock) init() {
+	l.once.Do(func() {
+		l.turnstile = make(chan struct{}, 1)
+		l.write = make(chan struct{}, 1)
+		l.readers = make(chan struct{}, 1)
+	})
+}
+
+// lock the lock for writing
+func (l *rwLock) Lock(ctx context.Context) error {
+	l.init()
+	if err := l.check(ctx); err != nil {
+		return err
+	}
+	select {
+	case l.turnstile <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case l.write <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		<-l.turnstile
+		return ctx.Err()
+	}
+}
+
+func (l *rwLock) Unlock() {
+	<-l.write
+	<-l.turnstile
+}
+
+// lock the lock for reading, the first reader takes the lock from writers
+func (l *rwLock) RLock(ctx context.Context) error {
+	l.init()
+	if err := l.check(ctx); err != nil {
+		return err
+	}
+	// wait for a writer ahead of the reader
+	select {
+	case l.turnstile <- struct{}{}:
+		<-l.turnstile
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case l.readers <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-l.readers }()
+	if l.count == 0 {
+		select {
+		case l.write <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	l.count++
+	return nil
+}
+
+// unlock the lock for reading, the last reader gives it back to writers
+func (l *rwLock) RUnlock() {
+	l.readers <- struct{}{}
+	l.count--
+	if l.count == 0 {
+		<-l.write
+	}
+	<-l.readers
+}
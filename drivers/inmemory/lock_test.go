@@ -0,0 +1,190 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/priyanshujain/go-storage/query"
+)
+
+// countdown is a context that is cancelled once its error was checked n times
+type countdown struct {
+	context.Context
+	n int
+}
+
+func (c *countdown) Err() error {
+	c.n--
+	if c.n < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestRWLock(t *testing.T) {
+	ctx := context.Background()
+	var l rwLock
+
+	if err := l.RLock(ctx); err != nil {
+		t.Fatalf("Failed to lock for reading: %v", err)
+	}
+	if err := l.RLock(ctx); err != nil {
+		t.Fatalf("Expected readers to share the lock but got %v", err)
+	}
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.Lock(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a writer to wait for readers but got %v", err)
+	}
+	l.RUnlock()
+	l.RUnlock()
+
+	if err := l.Lock(ctx); err != nil {
+		t.Fatalf("Failed to lock for writing: %v", err)
+	}
+	timeout, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.RLock(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a reader to wait for the writer but got %v", err)
+	}
+	released := make(chan error)
+	go func() {
+		released <- l.RLock(ctx)
+	}()
+	l.Unlock()
+	if err := <-released; err != nil {
+		t.Errorf("Expected the reader to get the lock once released but got %v", err)
+	}
+	l.RUnlock()
+
+	// a waiting writer stops new readers until it is done
+	if err := l.RLock(ctx); err != nil {
+		t.Fatalf("Failed to lock for reading: %v", err)
+	}
+	locked := make(chan error)
+	go func() {
+		locked <- l.Lock(ctx)
+	}()
+	for len(l.turnstile) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	timeout, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.RLock(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a reader to wait for the waiting writer but got %v", err)
+	}
+	l.RUnlock()
+	if err := <-locked; err != nil {
+		t.Errorf("Expected the writer to get the lock once the reader left but got %v", err)
+	}
+	l.Unlock()
+
+	// a writer giving up lets readers in again
+	if err := l.RLock(ctx); err != nil {
+		t.Fatalf("Failed to lock for reading: %v", err)
+	}
+	timeout, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.Lock(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a writer to wait for readers but got %v", err)
+	}
+	if err := l.RLock(ctx); err != nil {
+		t.Errorf("Expected a reader to get the lock after the writer gave up but got %v", err)
+	}
+	l.RUnlock()
+	l.RUnlock()
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := l.Lock(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v but got %v", context.Canceled, err)
+	}
+
+	// a context holding the lock fails instead of waiting for itself
+	if err := l.Lock(ctx); err != nil {
+		t.Fatalf("Failed to lock for writing: %v", err)
+	}
+	if err := l.RLock(l.hold(ctx)); err != ErrReentrant {
+		t.Errorf("Expected %v but got %v", ErrReentrant, err)
+	}
+	if err := l.Lock(l.hold(ctx)); err != ErrReentrant {
+		t.Errorf("Expected %v but got %v", ErrReentrant, err)
+	}
+	var other rwLock
+	if err := other.Lock(l.hold(ctx)); err != nil {
+		t.Errorf("Expected another lock to be taken but got %v", err)
+	}
+	l.Unlock()
+}
+
+func TestDatabase_Context(t *testing.T) {
+	ctx := context.Background()
+	db := newPeopleDatabase(t, 1000)
+
+	t.Run("cancelled", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := db.Get(cancelled, Person{}, "p001"); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v but got %v", context.Canceled, err)
+		}
+		if _, err := db.Insert(cancelled, newPerson(1000, 1000)); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v but got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("lock wait", func(t *testing.T) {
+		if err := db.lock.Lock(ctx); err != nil {
+			t.Fatalf("Failed to lock: %v", err)
+		}
+		defer db.lock.Unlock()
+		timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := db.Query(timeout, Person{}).All(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected %v but got %v", context.DeadlineExceeded, err)
+		}
+	})
+
+	t.Run("scans", func(t *testing.T) {
+		tests := []struct {
+			name string
+			run  func(ctx context.Context) error
+		}{
+			{"query", func(ctx context.Context) error {
+				_, err := db.Query(ctx, Person{}).Where("Age", ">", 100).All()
+				return err
+			}},
+			{"aggregate", func(ctx context.Context) error {
+				_, err := db.Aggregate(ctx, Person{}).GroupBy("City").Sum("Age").Run()
+				return err
+			}},
+			{"join", func(ctx context.Context) error {
+				_, err := db.Join(ctx, Person{}, Person{}).On("Name", "Id").All()
+				return err
+			}},
+			{"list", func(ctx context.Context) error {
+				_, err := db.List(ctx, Person{}, query.ListOptions{OrderBy: "City", Limit: 1})
+				return err
+			}},
+			{"batch", func(ctx context.Context) error {
+				var people []interface{}
+				for i := 1000; i < 2000; i++ {
+					people = append(people, newPerson(i, 2000))
+				}
+				return db.InsertMany(ctx, people...)
+			}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// the lock and the first record pass, the scan stops later
+				if err := tt.run(&countdown{Context: ctx, n: 2}); !errors.Is(err, context.Canceled) {
+					t.Errorf("Expected %v but got %v", context.Canceled, err)
+				}
+			})
+		}
+		if _, err := db.Get(ctx, Person{}, "p1000"); err != ErrRecordNotFound {
+			t.Errorf("Expected a cancelled batch to insert nothing but got %v", err)
+		}
+	})
+}
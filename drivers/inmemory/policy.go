@@ -0,0 +1,253 @@
+package inmemory
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+)
+
+// Eviction is a policy choosing the record to evict when the records of a
+// database exceed its limits.
+type Eviction string
+
+const (
+	// LRU evicts the least recently used record
+	LRU Eviction = "lru"
+	// LFU evicts the least frequently used record, the least recently used
+	// of them on a tie
+	LFU Eviction = "lfu"
+	// ARC balances recency and frequency by the records evicted before,
+	// see Megiddo and Modha, "ARC: A Self-Tuning, Low Overhead Replacement
+	// Cache"
+	ARC Eviction = "arc"
+)
+
+// policy orders the cached records for eviction
+type policy interface {
+	// add a record written to the cache
+	add(e *cached)
+	// touch a record read or updated
+	touch(e *cached)
+	// remove a record deleted from the cache
+	remove(e *cached)
+	// victim removes the next record to evict, nil when there is none
+	victim() *cached
+}
+
+// newPolicy returns the policy of an eviction
+func newPolicy(eviction Eviction) (policy, error) {
+	switch eviction {
+	case LRU:
+		return &lru{order: list.New()}, nil
+	case LFU:
+		return &lfu{}, nil
+	case ARC:
+		return newARC(), nil
+	}
+	return nil, fmt.Errorf("%q: %w", eviction, ErrInvalidEviction)
+}
+
+// lru keeps the records from the most to the least recently used
+type lru struct {
+	order *list.List
+}
+
+func (p *lru) add(e *cached) {
+	e.elem = p.order.PushFront(e)
+}
+
+func (p *lru) touch(e *cached) {
+	p.order.MoveToFront(e.elem)
+}
+
+func (p *lru) remove(e *cached) {
+	p.order.Remove(e.elem)
+}
+
+func (p *lru) victim() *cached {
+	back := p.order.Back()
+	if back == nil {
+		return nil
+	}
+	return p.order.Remove(back).(*cached)
+}
+
+// lfu is a heap of the records by use count and then by last use
+type lfu struct {
+	entries []*cached
+	tick    uint64
+}
+
+func (p *lfu) Len() int { return len(p.entries) }
+
+func (p *lfu) Less(i, j int) bool {
+	a, b := p.entries[i], p.entries[j]
+	if a.uses != b.uses {
+		return a.uses < b.uses
+	}
+	return a.used < b.used
+}
+
+func (p *lfu) Swap(i, j int) {
+	p.entries[i], p.entries[j] = p.entries[j], p.entries[i]
+	p.entries[i].index = i
+	p.entries[j].index = j
+}
+
+func (p *lfu) Push(x interface{}) {
+	e := x.(*cached)
+	e.index = len(p.entries)
+	p.entries = append(p.entries, e)
+}
+
+func (p *lfu) Pop() interface{} {
+	last := len(p.entries) - 1
+	e := p.entries[last]
+	p.entries[last] = nil
+	p.entries = p.entries[:last]
+	return e
+}
+
+func (p *lfu) add(e *cached) {
+	p.tick++
+	e.uses, e.used = 1, p.tick
+	heap.Push(p, e)
+}
+
+func (p *lfu) touch(e *cached) {
+	p.tick++
+	e.uses, e.used = e.uses+1, p.tick
+	heap.Fix(p, e.index)
+}
+
+func (p *lfu) remove(e *cached) {
+	heap.Remove(p, e.index)
+}
+
+func (p *lfu) victim() *cached {
+	if len(p.entries) == 0 {
+		return nil
+	}
+	return heap.Pop(p).(*cached)
+}
+
+// arc keeps the records used once in recent and the records used again in
+// frequent, from the most to the least recently used. The ghosts are the
+// keys of the records evicted from each list. A new record whose key is a
+// ghost of recent moves target, the number of records recent is kept to,
+// up and a ghost of frequent moves it down.
+type arc struct {
+	recent, frequent             *list.List
+	recentGhosts, frequentGhosts *ghosts
+	target                       int
+}
+
+func newARC() *arc {
+	return &arc{
+		recent:         list.New(),
+		frequent:       list.New(),
+		recentGhosts:   newGhosts(),
+		frequentGhosts: newGhosts(),
+	}
+}
+
+// size is the number of records cached, the capacity of the lists
+func (p *arc) size() int {
+	return p.recent.Len() + p.frequent.Len()
+}
+
+func (p *arc) add(e *cached) {
+	key := e.ghostKey()
+	switch {
+	case p.recentGhosts.remove(key):
+		p.target += ratio(p.frequentGhosts, p.recentGhosts)
+		if p.target > p.size()+1 {
+			p.target = p.size() + 1
+		}
+	case p.frequentGhosts.remove(key):
+		p.target -= ratio(p.recentGhosts, p.frequentGhosts)
+		if p.target < 0 {
+			p.target = 0
+		}
+	default:
+		e.frequent = false
+		e.elem = p.recent.PushFront(e)
+		return
+	}
+	e.frequent = true
+	e.elem = p.frequent.PushFront(e)
+}
+
+func (p *arc) touch(e *cached) {
+	p.list(e).Remove(e.elem)
+	e.frequent = true
+	e.elem = p.frequent.PushFront(e)
+}
+
+func (p *arc) remove(e *cached) {
+	p.list(e).Remove(e.elem)
+}
+
+func (p *arc) victim() *cached {
+	from, ghosts := p.frequent, p.frequentGhosts
+	if p.recent.Len() > 0 && (p.recent.Len() > p.target || p.frequent.Len() == 0) {
+		from, ghosts = p.recent, p.recentGhosts
+	}
+	back := from.Back()
+	if back == nil {
+		return nil
+	}
+	e := from.Remove(back).(*cached)
+	ghosts.add(e.ghostKey(), p.size()+1)
+	return e
+}
+
+func (p *arc) list(e *cached) *list.List {
+	if e.frequent {
+		return p.frequent
+	}
+	return p.recent
+}
+
+// ratio returns the step target moves by on a ghost hit, the number of
+// ghosts of the other list per ghost of the list hit and at least one. The
+// key hit is already removed from hit.
+func ratio(other, hit *ghosts) int {
+	n := other.order.Len() / (hit.order.Len() + 1)
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// ghosts are the keys of evicted records from the most to the least
+// recently evicted
+type ghosts struct {
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+func newGhosts() *ghosts {
+	return &ghosts{order: list.New(), keys: make(map[string]*list.Element)}
+}
+
+// add a key keeping at most limit keys
+func (g *ghosts) add(key string, limit int) {
+	if elem, ok := g.keys[key]; ok {
+		g.order.Remove(elem)
+	}
+	g.keys[key] = g.order.PushFront(key)
+	for g.order.Len() > limit {
+		delete(g.keys, g.order.Remove(g.order.Back()).(string))
+	}
+}
+
+// remove reports whether the key was a ghost
+func (g *ghosts) remove(key string) bool {
+	elem, ok := g.keys[key]
+	if ok {
+		g.order.Remove(elem)
+		delete(g.keys, key)
+	}
+	return ok
+}
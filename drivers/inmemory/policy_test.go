@@ -0,0 +1,65 @@
+package inmemory
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// replay runs accesses against a policy limited to size records and returns
+// the keys evicted. An access is "+key" to add a record and "key" to use it,
+// a record used before it is added or once evicted is added.
+func replay(t *testing.T, eviction Eviction, size int, accesses []string) []string {
+	p, err := newPolicy(eviction)
+	if err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	table := &Table{Name: "T"}
+	entries := make(map[string]*cached)
+	var evicted []string
+	for _, key := range accesses {
+		if e, ok := entries[key]; ok {
+			p.touch(e)
+			continue
+		}
+		e := &cached{table: table, record: &Record{Key: key}}
+		entries[key] = e
+		p.add(e)
+		if len(entries) > size {
+			victim := p.victim()
+			delete(entries, victim.record.Key)
+			evicted = append(evicted, victim.record.Key)
+		}
+	}
+	return evicted
+}
+
+func TestPolicies(t *testing.T) {
+	tests := []struct {
+		eviction Eviction
+		accesses []string
+		evicted  []string
+	}{
+		{LRU, []string{"a", "b", "c", "a", "d", "e"}, []string{"b", "c"}},
+		// a key added again starts over
+		{LFU, []string{"a", "a", "b", "b", "b", "c", "d", "c", "e"}, []string{"c", "d", "c"}},
+		// a scan of keys used once does not evict the keys used twice
+		{ARC, []string{"a", "a", "b", "b", "c", "d", "e", "f"}, []string{"c", "d", "e"}},
+		{LRU, []string{"a", "a", "b", "b", "c", "d", "e", "f"}, []string{"a", "b", "c"}},
+		// an evicted key used again comes back as frequent and grows the
+		// share of the recent keys
+		{ARC, []string{"a", "a", "b", "b", "c", "d", "c", "e"}, []string{"c", "a", "d"}},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d", tt.eviction, i), func(t *testing.T) {
+			if evicted := replay(t, tt.eviction, 3, tt.accesses); !reflect.DeepEqual(evicted, tt.evicted) {
+				t.Errorf("Expected %v but got %v", tt.evicted, evicted)
+			}
+		})
+	}
+
+	if _, err := newPolicy("fifo"); !errors.Is(err, ErrInvalidEviction) {
+		t.Errorf("Expected %v but got %v", ErrInvalidEviction, err)
+	}
+}
@@ -0,0 +1,124 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/priyanshujain/go-storage/encoding"
+	"github.com/priyanshujain/go-storage/query"
+)
+
+// start a query over the table of tableType
+func (db *Database) Query(ctx context.Context, tableType interface{}) *query.Query {
+	return query.New(ctx, db, tableType)
+}
+
+// run a query, the primary key or an index is used to find the candidate
+// records when a condition allows it
+func (db *Database) Execute(ctx context.Context, spec query.Spec) ([]interface{}, error) {
+	if err := db.lock.RLock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.lock.RUnlock()
+
+	tableName := reflect.TypeOf(spec.TableType).Name()
+	table, ok := db.Tables[tableName]
+	if !ok {
+		return nil, ErrInvalidTableName
+	}
+	if err := spec.Check(table.Fields); err != nil {
+		return nil, err
+	}
+
+	candidates, indexed := table.candidates(spec.Conditions)
+	if indexed {
+		// keep the primary key order of a full scan
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].Key < candidates[j].Key
+		})
+	}
+
+	// without an order the scan can stop once the window is full
+	wanted := -1
+	if len(spec.Orders) == 0 && spec.Limited() {
+		wanted = spec.Offset + spec.Limit
+	}
+	var opts []encoding.Option
+	if fields := spec.Decoded(); fields != nil {
+		opts = append(opts, encoding.Fields(fields...))
+	}
+	var records []interface{}
+	for i, r := range candidates {
+		if len(records) == wanted {
+			break
+		}
+		if err := interrupted(ctx, i); err != nil {
+			return nil, err
+		}
+		record := reflect.New(table.Fields).Interface()
+		if err := encoding.Decode(r.Value, record, opts...); err != nil {
+			return nil, fmt.Errorf("error decoding record: %v %w", err, ErrInvalidEncoding)
+		}
+		ok, err := spec.Matches(record)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			records = append(records, record)
+		}
+	}
+
+	if err := spec.Sort(records); err != nil {
+		return nil, err
+	}
+	records = spec.Window(records)
+	if len(spec.Decoded()) > len(spec.Fields) {
+		// clear the fields decoded only to filter and sort
+		for i, record := range records {
+			records[i] = spec.Project(record)
+		}
+	}
+	return records, nil
+}
+
+// candidates returns the records that can match the conditions. A primary
+// key lookup is preferred, then an equality on an index and then a range
+// over an index. The records are all records when no condition can use an
+// index.
+func (t *Table) candidates(conditions []query.Condition) ([]*Record, bool) {
+	for _, c := range conditions {
+		if len(t.keys) != 1 || c.Field != t.keys[0].Name || c.Operator != query.Eq {
+			continue
+		}
+		// values that do not convert to the key type are compared in a scan
+		if key, err := t.key(c.Value); err == nil {
+			if r, ok := t.find(key); ok {
+				return []*Record{r}, true
+			}
+			return nil, true
+		}
+	}
+	for _, operators := range [][]string{{query.Eq, query.In}, {query.Lt, query.Le, query.Gt, query.Ge}} {
+		for _, c := range conditions {
+			index, ok := t.Indexes[c.Field]
+			if !ok || !contains(operators, c.Operator) {
+				continue
+			}
+			if records, ok := index.scan(c); ok {
+				return t.live(records), true
+			}
+		}
+	}
+	return t.live(t.Records), false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/priyanshujain/go-storage/query"
+)
+
+type Person struct {
+	Id    string
+	Name  string
+	Age   int
+	City  string
+	Score *float64
+}
+
+func newPeopleDatabase(t testing.TB, count int) *Database {
+	ctx := context.Background()
+	db := New()
+	if err := db.CreateTable(ctx, Person{}, "Id"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	for i := 0; i < count; i++ {
+		if _, err := db.Insert(ctx, newPerson(i, count)); err != nil {
+			t.Fatalf("Failed to insert record: %v", err)
+		}
+	}
+	return db
+}
+
+// newPerson returns the i-th of count people, names sort in reverse order
+func newPerson(i, count int) Person {
+	cities := []string{"Pune", "Delhi", "Mumbai"}
+	person := Person{
+		Id:   fmt.Sprintf("p%03d", i),
+		Name: fmt.Sprintf("name%03d", count-i),
+		Age:  20 + i%40,
+		City: cities[i%len(cities)],
+	}
+	if i%2 == 0 {
+		score := float64(i) / 2
+		person.Score = &score
+	}
+	return person
+}
+
+func ids(records []interface{}) []string {
+	ids := []string{}
+	for _, record := range records {
+		ids = append(ids, record.(*Person).Id)
+	}
+	return ids
+}
+
+func TestDatabase_Query(t *testing.T) {
+	ctx := context.Background()
+	db := newPeopleDatabase(t, 100)
+
+	tests := []struct {
+		name     string
+		query    *query.Query
+		expected func(p Person) bool
+		ordered  []string
+	}{
+		{
+			name:     "all records",
+			query:    db.Query(ctx, Person{}),
+			expected: func(p Person) bool { return true },
+		},
+		{
+			name:     "predicates",
+			query:    db.Query(ctx, Person{}).Where("Age", ">", 30).Where("City", "=", "Pune"),
+			expected: func(p Person) bool { return p.Age > 30 && p.City == "Pune" },
+		},
+		{
+			name:     "typed comparison",
+			query:    db.Query(ctx, Person{}).Where("Age", "<=", 21.5).Where("Score", ">=", uint8(10)),
+			expected: func(p Person) bool { return p.Age <= 21 && p.Score != nil && *p.Score >= 10 },
+		},
+		{
+			name:     "nil pointers",
+			query:    db.Query(ctx, Person{}).Where("Score", "=", nil).Where("Age", "in", []int{21, 23}),
+			expected: func(p Person) bool { return p.Score == nil && (p.Age == 21 || p.Age == 23) },
+		},
+		{
+			name:     "primary key",
+			query:    db.Query(ctx, Person{}).Where("Id", "=", "p042"),
+			expected: func(p Person) bool { return p.Id == "p042" },
+		},
+		{
+			name:     "missing primary key",
+			query:    db.Query(ctx, Person{}).Where("Id", "=", "missing"),
+			expected: func(p Person) bool { return false },
+		},
+		{
+			name:    "order limit and offset",
+			query:   db.Query(ctx, Person{}).Where("Age", ">", 30).Where("City", "=", "Pune").OrderBy("Name").Limit(3).Offset(2),
+			ordered: []string{"p093", "p078", "p075"},
+		},
+		{
+			name:    "descending with ties",
+			query:   db.Query(ctx, Person{}).Where("Age", ">=", 58).OrderByDesc("Age").OrderBy("Id"),
+			ordered: []string{"p039", "p079", "p038", "p078"},
+		},
+		{
+			name:    "limit without order",
+			query:   db.Query(ctx, Person{}).Where("City", "=", "Delhi").Limit(2).Offset(1),
+			ordered: []string{"p004", "p007"},
+		},
+		{
+			name:    "offset past the end",
+			query:   db.Query(ctx, Person{}).Offset(100),
+			ordered: []string{},
+		},
+		{
+			name:    "zero limit",
+			query:   db.Query(ctx, Person{}).Limit(0),
+			ordered: []string{},
+		},
+		{
+			name:    "zero limit with order",
+			query:   db.Query(ctx, Person{}).OrderBy("Name").Limit(0),
+			ordered: []string{},
+		},
+	}
+
+	run := func(t *testing.T) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				records, err := tt.query.All()
+				if err != nil {
+					t.Fatalf("Failed to run query: %v", err)
+				}
+				expected := tt.ordered
+				if tt.expected != nil {
+					expected = []string{}
+					for _, r := range db.Tables["Person"].Records {
+						record, _ := db.Tables["Person"].decode(r)
+						if person := record.(*Person); tt.expected(*person) {
+							expected = append(expected, person.Id)
+						}
+					}
+				}
+				if got := ids(records); !reflect.DeepEqual(got, expected) {
+					t.Errorf("Expected %v but got %v", expected, got)
+				}
+			})
+		}
+	}
+
+	t.Run("without indexes", run)
+	for _, field := range []string{"Age", "City", "Score", "Name"} {
+		if err := db.CreateIndex(ctx, Person{}, field); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+	}
+	t.Run("with indexes", run)
+
+	t.Run("first", func(t *testing.T) {
+		record, err := db.Query(ctx, Person{}).Where("Age", "=", 59).OrderByDesc("Id").First()
+		if err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		if record.(*Person).Id != "p079" {
+			t.Errorf("Expected p079 but got %v", record.(*Person).Id)
+		}
+		_, err = db.Query(ctx, Person{}).Where("Age", ">", 100).First()
+		if err != query.ErrNoRecords {
+			t.Errorf("Expected ErrNoRecords but got %v", err)
+		}
+	})
+
+	t.Run("select", func(t *testing.T) {
+		records, err := db.Query(ctx, Person{}).Select("Name").Where("Age", "=", 59).OrderByDesc("Id").All()
+		if err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		expected := []interface{}{&Person{Name: "name021"}, &Person{Name: "name061"}}
+		if !reflect.DeepEqual(records, expected) {
+			t.Errorf("Expected %v but got %v", expected, records)
+		}
+
+		maps, err := db.Query(ctx, Person{}).Select("Id", "City").Where("Age", "=", 59).AllMaps()
+		if err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		expectedMaps := []map[string]interface{}{{"Id": "p039", "City": "Pune"}, {"Id": "p079", "City": "Delhi"}}
+		if !reflect.DeepEqual(maps, expectedMaps) {
+			t.Errorf("Expected %v but got %v", expectedMaps, maps)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			query *query.Query
+			err   error
+		}{
+			{"invalid table", db.Query(ctx, ExampleStruct{}), ErrInvalidTableName},
+			{"invalid field", db.Query(ctx, Person{}).Where("Missing", "=", 1), query.ErrInvalidField},
+			{"invalid order", db.Query(ctx, Person{}).OrderBy("Missing"), query.ErrInvalidField},
+			{"invalid selection", db.Query(ctx, Person{}).Select("Missing"), query.ErrInvalidField},
+			{"incomparable value", db.Query(ctx, Person{}).Where("Age", ">", "thirty"), query.ErrIncomparable},
+			{"invalid operator", db.Query(ctx, Person{}).Where("Age", "like", 30), query.ErrInvalidOperator},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := tt.query.All(); !errors.Is(err, tt.err) {
+					t.Errorf("Expected %v but got %v", tt.err, err)
+				}
+			})
+		}
+	})
+}
+
+func BenchmarkDatabase_Query(b *testing.B) {
+	ctx := context.Background()
+	db := newPeopleDatabase(b, 10000)
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = db.Query(ctx, Person{}).Where("Age", "=", 30).All()
+		}
+	})
+	if err := db.CreateIndex(ctx, Person{}, "Age"); err != nil {
+		b.Fatalf("Failed to create index: %v", err)
+	}
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = db.Query(ctx, Person{}).Where("Age", "=", 30).All()
+		}
+	})
+}
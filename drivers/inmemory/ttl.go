@@ -0,0 +1,215 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/priyanshujain/go-storage/query"
+)
+
+// A record expires at the time set by InsertWithTTL, by its expiry field or
+// by the TTL of its table counted from its insert, in that order. An
+// expired record is invisible to reads and writes at once, it is removed by
+// the next sweep or by the insert of a record with its key. Removing an
+// expired record sends a delete change event but does not run hooks or the
+// delete actions of foreign keys.
+
+// now returns the time of the clock of the database of the table
+func (t *Table) now() time.Time {
+	if t.clock != nil {
+		return t.clock()
+	}
+	return time.Now()
+}
+
+// now returns the time of the clock of the database
+func (db *Database) now() time.Time {
+	if db.Now != nil {
+		return db.Now()
+	}
+	return time.Now()
+}
+
+// expired reports whether a record expired at now in Unix milliseconds
+func expired(r *Record, now int64) bool {
+	return r.Expires != 0 && r.Expires <= now
+}
+
+// live returns the records that did not expire, records is returned as is
+// when no record of the table expires
+func (t *Table) live(records []*Record) []*Record {
+	if !t.expiring {
+		return records
+	}
+	now := t.now().UnixMilli()
+	var alive []*Record
+	for _, r := range records {
+		if !expired(r, now) {
+			alive = append(alive, r)
+		}
+	}
+	return alive
+}
+
+// expires returns the record to insert with its expiry field set and the
+// time it expires at in Unix milliseconds, zero when it does not expire
+func (t *Table) expires(record interface{}, ttl time.Duration) (interface{}, int64) {
+	var field reflect.Value
+	v := reflect.ValueOf(record)
+	if t.expiry != nil {
+		field = v.FieldByIndex(t.expiry)
+		if ttl <= 0 && field.Int() != 0 {
+			return record, field.Int()
+		}
+	}
+	if ttl <= 0 {
+		ttl = t.TTL
+	}
+	if ttl <= 0 {
+		return record, 0
+	}
+	at := t.now().Add(ttl).UnixMilli()
+	if t.expiry != nil {
+		copied := reflect.New(t.Fields).Elem()
+		copied.Set(v)
+		copied.FieldByIndex(t.expiry).SetInt(at)
+		record = copied.Interface()
+	}
+	return record, at
+}
+
+// setExpiry sets the expiry of a stored record, an update keeps the expiry
+// of the record unless its expiry field is set
+func (t *Table) setExpiry(r *Record, record interface{}, expires int64) {
+	if t.expiry != nil {
+		if at := reflect.ValueOf(record).FieldByIndex(t.expiry).Int(); at != 0 {
+			expires = at
+		}
+	}
+	r.Expires = expires
+	if expires != 0 {
+		t.expiring = true
+	}
+}
+
+// insert a record that expires after ttl, it returns the key of the record
+// like Insert
+func (db *Database) InsertWithTTL(ctx context.Context, record interface{}, ttl time.Duration) (interface{}, error) {
+	table, written, err := db.insert(ctx, record, ttl)
+	if err != nil {
+		return nil, err
+	}
+	setRecord(record, written)
+	return table.pkValue(reflect.ValueOf(written)), db.afterHook(ctx, write{kind: query.InsertOp, table: table, record: written})
+}
+
+// get the time a record expires at, zero when it does not expire
+func (db *Database) Expires(ctx context.Context, tableType interface{}, pk interface{}) (time.Time, error) {
+	if err := db.lock.RLock(ctx); err != nil {
+		return time.Time{}, err
+	}
+	defer db.lock.RUnlock()
+
+	table, ok := db.Tables[reflect.TypeOf(tableType).Name()]
+	if !ok {
+		return time.Time{}, ErrInvalidTableName
+	}
+	key, err := table.key(pk)
+	if err != nil {
+		return time.Time{}, err
+	}
+	r, found := table.find(key)
+	if !found {
+		return time.Time{}, ErrRecordNotFound
+	}
+	if r.Expires == 0 {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(r.Expires), nil
+}
+
+// remove the expired records of every table, it returns the number of
+// records removed
+func (db *Database) Sweep(ctx context.Context) (int, error) {
+	if err := db.lock.Lock(ctx); err != nil {
+		return 0, err
+	}
+	defer db.lock.Unlock()
+
+	removed := 0
+	for _, table := range db.Tables {
+		if !table.expiring {
+			continue
+		}
+		now := table.now().UnixMilli()
+		records := table.Records[:0]
+		for _, r := range table.Records {
+			if !expired(r, now) {
+				records = append(records, r)
+				continue
+			}
+			db.discard(table, r)
+			removed++
+		}
+		for i := len(records); i < len(table.Records); i++ {
+			table.Records[i] = nil
+		}
+		table.Records = records
+	}
+	return removed, nil
+}
+
+// discard removes an expired or evicted record from the indexes and the
+// cache and sends its change event, the caller removes it from the records
+func (db *Database) discard(t *Table, r *Record) {
+	db.publish(write{kind: query.DeleteOp, table: t}, r)
+	db.cache.removed(r)
+	// decoding failure can not happen for a record of the table
+	_ = t.unindex(r)
+}
+
+// dropExpired removes the expired record with the encoded primary key pk
+// before a record with the key is inserted
+func (db *Database) dropExpired(t *Table, pk string) {
+	i, found := t.search(pk)
+	if !found || !expired(t.Records[i], t.now().UnixMilli()) {
+		return
+	}
+	db.discard(t, t.Records[i])
+	t.Records = append(t.Records[:i], t.Records[i+1:]...)
+}
+
+// start a sweep of the expired records every interval in the background,
+// stop ends it and waits for a running sweep to return. The interval must
+// be positive.
+func (db *Database) StartSweeper(interval time.Duration) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("%v: %w", interval, ErrInvalidInterval)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// a sweep is only cancelled by stop
+				_, _ = db.Sweep(ctx)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}, nil
+}
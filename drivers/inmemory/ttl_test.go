@@ -0,0 +1,236 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/priyanshujain/go-storage/query"
+	"github.com/priyanshujain/go-storage/schema"
+)
+
+type Token struct {
+	Id   string
+	User int
+}
+
+type Lease struct {
+	Id        int
+	Holder    string
+	ExpiresAt int64 `ttl:"expiry"`
+}
+
+// fakeClock is a clock moved forward by the tests
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newExpiringDatabase(t *testing.T) (*Database, *fakeClock) {
+	clock := &fakeClock{now: time.UnixMilli(1_000_000)}
+	db := New()
+	db.Now = clock.Now
+	if err := db.CreateTable(context.Background(), Token{}, "Id", schema.TTL(time.Minute)); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if err := db.CreateTable(context.Background(), Lease{}, "Id"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	return db, clock
+}
+
+func TestDatabase_TTL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("table ttl", func(t *testing.T) {
+		db, clock := newExpiringDatabase(t)
+		_, _ = db.Insert(ctx, Token{Id: "a", User: 1})
+		clock.now = clock.now.Add(30 * time.Second)
+		_, _ = db.Insert(ctx, Token{Id: "b", User: 2})
+		_, _ = db.InsertWithTTL(ctx, Token{Id: "c", User: 3}, time.Hour)
+
+		clock.now = clock.now.Add(45 * time.Second)
+		if _, err := db.Get(ctx, Token{}, "a"); err != ErrRecordNotFound {
+			t.Errorf("Expected %v but got %v", ErrRecordNotFound, err)
+		}
+		if _, err := db.Get(ctx, Token{}, "b"); err != nil {
+			t.Errorf("Expected b to be live but got %v", err)
+		}
+		records, err := db.Query(ctx, Token{}).All()
+		if err != nil || len(records) != 2 {
+			t.Errorf("Expected two live tokens but got %v, %v", records, err)
+		}
+		if err := db.Update(ctx, Token{Id: "a"}); err != ErrRecordNotFound {
+			t.Errorf("Expected %v but got %v", ErrRecordNotFound, err)
+		}
+
+		clock.now = clock.now.Add(time.Minute)
+		groups, err := db.Aggregate(ctx, Token{}).Count().Run()
+		if err != nil || !reflect.DeepEqual(groups[0].Values, []interface{}{1}) {
+			t.Errorf("Expected a count of 1 but got %v, %v", groups, err)
+		}
+	})
+
+	t.Run("expiry field", func(t *testing.T) {
+		db, clock := newExpiringDatabase(t)
+		now := clock.now.UnixMilli()
+		_, _ = db.Insert(ctx, Lease{Id: 1, ExpiresAt: now + 1000})
+		_, _ = db.Insert(ctx, Lease{Id: 2})
+		lease := &Lease{Id: 3}
+		_, _ = db.InsertWithTTL(ctx, lease, 2*time.Second)
+		if expected := now + 2000; lease.ExpiresAt != expected {
+			t.Errorf("Expected the expiry field to be set to %d but got %d", expected, lease.ExpiresAt)
+		}
+
+		clock.now = clock.now.Add(1500 * time.Millisecond)
+		page, err := db.List(ctx, Lease{}, query.ListOptions{Limit: 1})
+		if err != nil {
+			t.Fatalf("Failed to list records: %v", err)
+		}
+		if len(page.Records) != 1 || page.Records[0].(*Lease).Id != 2 || page.Next == "" {
+			t.Errorf("Expected lease 2 and a next page but got %+v", page)
+		}
+		// an update setting the expiry field extends the lease
+		if err := db.Update(ctx, Lease{Id: 3, ExpiresAt: now + 5000}); err != nil {
+			t.Fatalf("Failed to update lease: %v", err)
+		}
+		clock.now = clock.now.Add(time.Second)
+		if _, err := db.Get(ctx, Lease{}, 3); err != nil {
+			t.Errorf("Expected lease 3 to be live but got %v", err)
+		}
+	})
+
+	t.Run("insert over an expired record", func(t *testing.T) {
+		db, clock := newExpiringDatabase(t)
+		_, _ = db.Insert(ctx, Token{Id: "a", User: 1})
+		_, _ = db.Insert(ctx, Token{Id: "b", User: 1})
+		events, cancel := db.Watch(ctx, Token{}, query.LatestSeq)
+
+		clock.now = clock.now.Add(2 * time.Minute)
+		if _, err := db.Insert(ctx, Token{Id: "a", User: 2}); err != nil {
+			t.Fatalf("Failed to insert token: %v", err)
+		}
+		if err := db.InsertMany(ctx, Token{Id: "b", User: 2}); err != nil {
+			t.Fatalf("Failed to insert token: %v", err)
+		}
+		if _, err := db.Insert(ctx, Token{Id: "a"}); err != ErrDuplicateRecord {
+			t.Errorf("Expected %v but got %v", ErrDuplicateRecord, err)
+		}
+		cancel()
+
+		var ops []string
+		for _, event := range drain(events) {
+			ops = append(ops, event.Op)
+		}
+		expected := []string{query.DeleteOp, query.InsertOp, query.DeleteOp, query.InsertOp}
+		if !reflect.DeepEqual(ops, expected) {
+			t.Errorf("Expected %v but got %v", expected, ops)
+		}
+		if got := len(db.Tables["Token"].Records); got != 2 {
+			t.Errorf("Expected 2 records but got %d", got)
+		}
+	})
+
+	t.Run("sweep", func(t *testing.T) {
+		db, clock := newExpiringDatabase(t)
+		_ = db.CreateIndex(ctx, Token{}, "User")
+		_ = db.InsertMany(ctx, Token{Id: "a", User: 1}, Token{Id: "b", User: 1}, Lease{Id: 1})
+		_, _ = db.InsertWithTTL(ctx, Token{Id: "c", User: 1}, time.Hour)
+		events, cancel := db.Watch(ctx, Token{}, query.LatestSeq)
+
+		clock.now = clock.now.Add(2 * time.Minute)
+		removed, err := db.Sweep(ctx)
+		if err != nil || removed != 2 {
+			t.Errorf("Expected 2 records removed but got %d, %v", removed, err)
+		}
+		cancel()
+		if got := drain(events); len(got) != 2 || got[0].Op != query.DeleteOp || got[0].Old != (Token{Id: "a", User: 1}) {
+			t.Errorf("Expected two delete events but got %+v", got)
+		}
+		if got := len(db.Tables["Token"].Indexes["User"].entries); got != 1 {
+			t.Errorf("Expected 1 index entry but got %d", got)
+		}
+		if got := len(db.Tables["Lease"].Records); got != 1 {
+			t.Errorf("Expected the lease to be kept but got %d records", got)
+		}
+	})
+
+	t.Run("sweeper", func(t *testing.T) {
+		db, clock := newExpiringDatabase(t)
+		_, _ = db.Insert(ctx, Token{Id: "a"})
+		clock.now = clock.now.Add(2 * time.Minute)
+
+		stop, err := db.StartSweeper(time.Millisecond)
+		if err != nil {
+			t.Fatalf("Failed to start the sweeper: %v", err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for {
+			if err := db.lock.RLock(ctx); err != nil {
+				t.Fatalf("Failed to lock: %v", err)
+			}
+			n := len(db.Tables["Token"].Records)
+			db.lock.RUnlock()
+			if n == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the sweeper to remove the token")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		stop()
+		stop()
+
+		for _, interval := range []time.Duration{0, -time.Second} {
+			if _, err := db.StartSweeper(interval); !errors.Is(err, ErrInvalidInterval) {
+				t.Errorf("Expected %v for %v but got %v", ErrInvalidInterval, interval, err)
+			}
+		}
+	})
+
+	t.Run("init", func(t *testing.T) {
+		db, clock := newExpiringDatabase(t)
+		db.Eviction = LRU
+		_, _ = db.Insert(ctx, Token{Id: "a"})
+		clock.now = clock.now.Add(2 * time.Minute)
+		stop, _ := db.StartSweeper(time.Millisecond)
+		defer stop()
+
+		// the sweeper runs while the tables are dropped
+		db.Init()
+		if err := db.CreateTable(ctx, Token{}, "Id"); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		if err := db.lock.RLock(ctx); err != nil {
+			t.Fatalf("Failed to lock: %v", err)
+		}
+		expiring := db.Tables["Token"].expiring
+		db.lock.RUnlock()
+		if expiring {
+			t.Errorf("Expected the expiry state to be reset")
+		}
+		if stats := db.CacheStats(); stats != (CacheStats{}) {
+			t.Errorf("Expected the cache to be reset but got %+v", stats)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		type Grant struct {
+			Id      int
+			Expires string `ttl:"expiry"`
+		}
+		db := New()
+		if err := db.CreateTable(ctx, Grant{}, "Id"); !errors.Is(err, schema.ErrInvalidTTL) {
+			t.Errorf("Expected %v but got %v", schema.ErrInvalidTTL, err)
+		}
+		if err := db.CreateTable(ctx, Token{}, "Id", schema.TTL(-time.Second)); !errors.Is(err, schema.ErrInvalidTTL) {
+			t.Errorf("Expected %v but got %v", schema.ErrInvalidTTL, err)
+		}
+	})
+}
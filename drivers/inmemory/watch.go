@@ -0,0 +1,194 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/priyanshujain/go-storage/query"
+)
+
+// defaultWatchBuffer is the number of events buffered for a watcher when
+// the database does not set WatchBuffer
+const defaultWatchBuffer = 256
+
+// changes numbers the writes of a database, retains the last events and
+// sends them to the watchers of their table
+type changes struct {
+	mu       sync.Mutex
+	seq      uint64
+	log      []query.ChangeEvent
+	watchers map[*watcher]bool
+}
+
+// watcher receives the events of a table. Its channel holds one more event
+// than limit so that the overflow error always fits.
+type watcher struct {
+	table  string
+	events chan query.ChangeEvent
+	limit  int
+	stop   chan struct{}
+}
+
+// watch the writes of a table from the write after fromSeq, the events
+// retained after fromSeq are replayed first. The watch ends when the
+// context is done or cancel is called, its channel is then closed.
+func (db *Database) Watch(ctx context.Context, tableType interface{}, fromSeq uint64) (<-chan query.ChangeEvent, func()) {
+	if err := db.lock.RLock(ctx); err != nil {
+		return failedWatch(err)
+	}
+	// writes wait for the lock so no event is missed between the replay
+	// and the first event sent
+	defer db.lock.RUnlock()
+
+	name := reflect.TypeOf(tableType).Name()
+	if _, ok := db.Tables[name]; !ok {
+		return failedWatch(ErrInvalidTableName)
+	}
+	limit := db.WatchBuffer
+	if limit <= 0 {
+		limit = defaultWatchBuffer
+	}
+	return db.changes.watch(ctx, name, fromSeq, limit)
+}
+
+func failedWatch(err error) (<-chan query.ChangeEvent, func()) {
+	events := make(chan query.ChangeEvent, 1)
+	events <- query.ChangeEvent{Err: err}
+	close(events)
+	return events, func() {}
+}
+
+func (c *changes) watch(ctx context.Context, table string, fromSeq uint64, limit int) (<-chan query.ChangeEvent, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if fromSeq == query.LatestSeq {
+		fromSeq = c.seq
+	}
+	if fromSeq > c.seq {
+		return failedWatch(fmt.Errorf("%d is after the last sequence %d: %w", fromSeq, c.seq, query.ErrSeqNotRetained))
+	}
+	first := c.seq + 1
+	if len(c.log) > 0 {
+		first = c.log[0].Seq
+	}
+	if fromSeq+1 < first {
+		return failedWatch(fmt.Errorf("events after %d: %w", fromSeq, query.ErrSeqNotRetained))
+	}
+	var replay []query.ChangeEvent
+	for _, event := range c.log {
+		if event.Seq > fromSeq && event.Table == table {
+			replay = append(replay, event)
+		}
+	}
+
+	if len(replay) > limit {
+		limit = len(replay)
+	}
+	w := &watcher{
+		table:  table,
+		events: make(chan query.ChangeEvent, limit+1),
+		limit:  limit,
+		stop:   make(chan struct{}),
+	}
+	for _, event := range replay {
+		w.events <- event
+	}
+	if c.watchers == nil {
+		c.watchers = make(map[*watcher]bool)
+	}
+	c.watchers[w] = true
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.end(w, &query.ChangeEvent{Table: table, Err: ctx.Err()})
+		case <-w.stop:
+		}
+	}()
+	cancel := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.end(w, nil)
+	}
+	return w.events, cancel
+}
+
+// end removes a watcher and closes its channel after the last event
+func (c *changes) end(w *watcher, last *query.ChangeEvent) {
+	if !c.watchers[w] {
+		return
+	}
+	delete(c.watchers, w)
+	if last != nil {
+		w.events <- *last
+	}
+	close(w.events)
+	close(w.stop)
+}
+
+// active reports whether events are needed, by watchers or to be retained
+func (c *changes) active(retain int) bool {
+	return retain > 0 || len(c.watchers) > 0
+}
+
+// publish numbers a write and sends its event, made by event only when the
+// event is needed
+func (c *changes) publish(retain int, event func() query.ChangeEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	if !c.active(retain) {
+		c.log = nil
+		return
+	}
+	e := event()
+	e.Seq = c.seq
+	if retain > 0 {
+		c.log = append(c.log, e)
+		if len(c.log) > retain {
+			c.log = c.log[len(c.log)-retain:]
+		}
+	} else {
+		c.log = nil
+	}
+	for w := range c.watchers {
+		if w.table != e.Table {
+			continue
+		}
+		if len(w.events) < w.limit {
+			w.events <- e
+			continue
+		}
+		c.end(w, &query.ChangeEvent{Seq: e.Seq, Table: e.Table, Err: fmt.Errorf("%d events behind: %w", w.limit, query.ErrWatchOverflow)})
+	}
+}
+
+// publish numbers an applied write and sends its change event, r is the
+// record replaced by an update or delete which is decoded unless the write
+// holds it
+func (db *Database) publish(w write, r *Record) {
+	db.changes.publish(db.ChangeLog, func() query.ChangeEvent {
+		event := query.ChangeEvent{Table: w.table.Name, Op: w.kind}
+		if w.kind != query.DeleteOp {
+			event.New = w.record
+			event.Pk = w.table.pkValue(reflect.ValueOf(w.record))
+		}
+		if w.kind != query.InsertOp {
+			old := w.old
+			if old == nil {
+				// decoding failure can not happen for a record of the table
+				old, _ = w.table.decode(r)
+			}
+			v := reflect.Indirect(reflect.ValueOf(old))
+			event.Old = v.Interface()
+			event.Pk = w.table.pkValue(v)
+		}
+		return event
+	})
+}
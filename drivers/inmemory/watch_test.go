@@ -0,0 +1,140 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/priyanshujain/go-storage/query"
+)
+
+// drain returns the events of a closed watch
+func drain(events <-chan query.ChangeEvent) []query.ChangeEvent {
+	var all []query.ChangeEvent
+	for event := range events {
+		all = append(all, event)
+	}
+	return all
+}
+
+func newBankDatabase(t *testing.T) *Database {
+	db := New()
+	if err := db.CreateTable(context.Background(), Account{}, "Id"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if err := db.CreateTable(context.Background(), Device{}, "Serial"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	return db
+}
+
+func TestDatabase_Watch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("live", func(t *testing.T) {
+		db := newBankDatabase(t)
+		_, _ = db.Insert(ctx, Account{Id: 1, Balance: 10})
+		events, cancel := db.Watch(ctx, Account{}, query.LatestSeq)
+
+		_, _ = db.Insert(ctx, Account{Id: 2, Balance: 20})
+		_, _ = db.Insert(ctx, Device{Serial: 1})
+		_ = db.Update(ctx, Account{Id: 1, Balance: 15})
+		_ = db.Batch(ctx).Insert(Account{Id: 3}).Delete(Account{}, 2).Commit()
+		cancel()
+		cancel()
+
+		expected := []query.ChangeEvent{
+			{Seq: 2, Table: "Account", Pk: 2, Op: query.InsertOp, New: Account{Id: 2, Balance: 20}},
+			{Seq: 4, Table: "Account", Pk: 1, Op: query.UpdateOp, Old: Account{Id: 1, Balance: 10}, New: Account{Id: 1, Balance: 15}},
+			{Seq: 5, Table: "Account", Pk: 3, Op: query.InsertOp, New: Account{Id: 3}},
+			{Seq: 6, Table: "Account", Pk: 2, Op: query.DeleteOp, Old: Account{Id: 2, Balance: 20}},
+		}
+		if got := drain(events); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %+v but got %+v", expected, got)
+		}
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		db := newBankDatabase(t)
+		db.ChangeLog = 3
+		for id := 1; id <= 5; id++ {
+			_, _ = db.Insert(ctx, Account{Id: id})
+		}
+
+		events, cancel := db.Watch(ctx, Account{}, 3)
+		_, _ = db.Insert(ctx, Account{Id: 6})
+		cancel()
+		var seqs []uint64
+		for _, event := range drain(events) {
+			seqs = append(seqs, event.Seq)
+		}
+		if expected := []uint64{4, 5, 6}; !reflect.DeepEqual(seqs, expected) {
+			t.Errorf("Expected %v but got %v", expected, seqs)
+		}
+
+		// events 2 and 3 are no longer retained
+		for _, fromSeq := range []uint64{1, 7} {
+			events, _ := db.Watch(ctx, Account{}, fromSeq)
+			got := drain(events)
+			if len(got) != 1 || !errors.Is(got[0].Err, query.ErrSeqNotRetained) {
+				t.Errorf("Expected %v from %d but got %+v", query.ErrSeqNotRetained, fromSeq, got)
+			}
+		}
+		events, cancel = db.Watch(ctx, Account{}, 6)
+		cancel()
+		if got := drain(events); len(got) != 0 {
+			t.Errorf("Expected no events after the last write but got %+v", got)
+		}
+	})
+
+	t.Run("not retained", func(t *testing.T) {
+		db := newBankDatabase(t)
+		_, _ = db.Insert(ctx, Account{Id: 1})
+		events, _ := db.Watch(ctx, Account{}, 0)
+		if got := drain(events); len(got) != 1 || !errors.Is(got[0].Err, query.ErrSeqNotRetained) {
+			t.Errorf("Expected %v but got %+v", query.ErrSeqNotRetained, got)
+		}
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		db := newBankDatabase(t)
+		db.WatchBuffer = 2
+		slow, _ := db.Watch(ctx, Account{}, query.LatestSeq)
+		fast, cancel := db.Watch(ctx, Account{}, query.LatestSeq)
+		defer cancel()
+
+		for id := 1; id <= 5; id++ {
+			if _, err := db.Insert(ctx, Account{Id: id}); err != nil {
+				t.Fatalf("Failed to insert account: %v", err)
+			}
+			if event := <-fast; event.Seq != uint64(id) {
+				t.Errorf("Expected event %d but got %+v", id, event)
+			}
+		}
+		got := drain(slow)
+		if len(got) != 3 || got[1].Seq != 2 || !errors.Is(got[2].Err, query.ErrWatchOverflow) {
+			t.Errorf("Expected two events then %v but got %+v", query.ErrWatchOverflow, got)
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		db := newBankDatabase(t)
+		watchCtx, cancel := context.WithCancel(ctx)
+		events, _ := db.Watch(watchCtx, Account{}, query.LatestSeq)
+		_, _ = db.Insert(ctx, Account{Id: 1})
+		cancel()
+		got := drain(events)
+		if len(got) != 2 || got[0].Seq != 1 || !errors.Is(got[1].Err, context.Canceled) {
+			t.Errorf("Expected an event then %v but got %+v", context.Canceled, got)
+		}
+	})
+
+	t.Run("invalid table", func(t *testing.T) {
+		db := newBankDatabase(t)
+		events, _ := db.Watch(ctx, Member{}, query.LatestSeq)
+		if got := drain(events); len(got) != 1 || got[0].Err != ErrInvalidTableName {
+			t.Errorf("Expected %v but got %+v", ErrInvalidTableName, got)
+		}
+	})
+}
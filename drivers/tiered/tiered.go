@@ -0,0 +1,764 @@
+// Package tiered layers a fast storage engine as a cache in front of a
+// slower backing engine. Both engines hold the same tables, CreateTable
+// creates a table in both.
+//
+// Get looks up the cache first. A record missing from the cache is read
+// from the backing engine and copied to the cache. Misses of different
+// records read the backing engine in parallel, the misses of one record
+// share a single read. Query, List, Aggregate and joins read the backing
+// engine, GetFields and TableSchema the cache. Watch sends the writes of
+// the backing engine.
+//
+// Writes depend on the mode:
+//   - ReadThrough writes to the backing engine and then removes the written
+//     records from the cache, they are read again on their next Get.
+//   - WriteThrough writes to the backing engine and then copies the records
+//     as written, with their generated keys, to the cache.
+//   - WriteBack writes to the cache and queues the writes, Flush writes them
+//     to the backing engine in order. Reads of the backing engine flush the
+//     queue first.
+//
+// Copies are put in the cache without running hooks or checking
+// constraints and foreign keys, they expire with the records of the backing
+// engine when it implements Expirer. The hooks of a write run once, in the
+// engine written, except in write-back mode where they run in the cache and
+// again in the backing engine when the write is flushed.
+//
+// A write fails when the engine it is written to first rejects it, nothing
+// is written then. An error of the after hooks of an engine wraps
+// schema.ErrAfterHook and does not undo the write. A cache that can not be
+// updated after the backing engine was written is left without the record,
+// the write fails with ErrStaleCache only when the record can not be
+// removed either.
+package tiered
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	storage "github.com/priyanshujain/go-storage"
+	"github.com/priyanshujain/go-storage/encoding"
+	"github.com/priyanshujain/go-storage/query"
+	"github.com/priyanshujain/go-storage/schema"
+)
+
+var ErrInvalidMode = errors.New("invalid tiered mode")
+var ErrStaleCache = errors.New("stale cache")
+var ErrCacheFill = errors.New("record not copied to the cache")
+
+// Mode is how writes reach the cache and the backing engine.
+type Mode string
+
+const (
+	ReadThrough  Mode = "read-through"
+	WriteThrough Mode = "write-through"
+	WriteBack    Mode = "write-back"
+)
+
+// Cache is a storage engine that can be the cache of a Storage. Put writes
+// a copy of a record, replacing the record with its primary key, without
+// running hooks or checking constraints and foreign keys. The copy expires
+// at expires, it does not when expires is zero. Drop removes a record and
+// the records referencing it without running hooks, a missing record is not
+// an error.
+type Cache interface {
+	storage.Storage
+	Put(ctx context.Context, record interface{}, expires time.Time) error
+	Drop(ctx context.Context, tableType interface{}, pk interface{}) error
+}
+
+// Expirer is a backing engine that tells when its records expire, the zero
+// time when they do not.
+type Expirer interface {
+	Expires(ctx context.Context, tableType interface{}, pk interface{}) (time.Time, error)
+}
+
+// Storage is a storage engine made of a cache and a backing engine.
+type Storage struct {
+	Cache   Cache
+	Backing storage.Storage
+	mode    Mode
+
+	// mu orders the writes with the records read into the cache, it guards
+	// the queue of write-back mode
+	mu     sync.Mutex
+	queue  []queued
+	latest map[string]queued
+	seq    uint64
+	// gen counts the writes, a record read from the backing engine is only
+	// added to the cache when no write happened during the read. loads are
+	// the reads of the backing engine in progress by key.
+	gen   uint64
+	loads map[string]*loading
+	// rejected are the writes dropped by flushes since the last Flush
+	rejected []query.OpError
+	// flushing lets one flush run at a time
+	flushing sync.Mutex
+	// stop stops the background flush every interval, closed is set by
+	// Close, both are guarded by mu
+	interval time.Duration
+	stop     func()
+	closed   bool
+}
+
+// loading is a read of a record from the backing engine shared by the
+// misses of the record while it runs
+type loading struct {
+	done   chan struct{}
+	record interface{}
+	err    error
+}
+
+// queued is a write of write-back mode not yet written to the backing
+// engine, key identifies its record
+type queued struct {
+	seq uint64
+	key string
+	op  query.Op
+}
+
+// New returns a storage engine writing in mode. In write-back mode a
+// non-zero flushInterval flushes the queued writes in the background until
+// Close is called.
+func New(cache Cache, backing storage.Storage, mode Mode, flushInterval time.Duration) (*Storage, error) {
+	switch mode {
+	case ReadThrough, WriteThrough, WriteBack:
+	default:
+		return nil, fmt.Errorf("%q: %w", mode, ErrInvalidMode)
+	}
+	if flushInterval < 0 || (flushInterval > 0 && mode != WriteBack) {
+		return nil, fmt.Errorf("flush interval %v in %s mode: %w", flushInterval, mode, ErrInvalidMode)
+	}
+	s := &Storage{Cache: cache, Backing: backing, mode: mode, interval: flushInterval}
+	if flushInterval > 0 {
+		s.stop = s.startFlusher(flushInterval)
+	}
+	return s, nil
+}
+
+// Mode returns the mode of the storage engine.
+func (s *Storage) Mode() Mode {
+	return s.mode
+}
+
+// Init initializes both engines and drops the queued writes. The background
+// flush is stopped and a running flush waited for so that no write reaches
+// the engines once they are initialized.
+func (s *Storage) Init() {
+	s.mu.Lock()
+	stop := s.stop
+	s.stop = nil
+	s.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+
+	s.flushing.Lock()
+	defer s.flushing.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Cache.Init()
+	s.Backing.Init()
+	s.queue, s.latest, s.rejected = nil, nil, nil
+	s.gen++
+	if stop != nil && !s.closed {
+		s.stop = s.startFlusher(s.interval)
+	}
+}
+
+// create a new table in the backing engine and then in the cache
+func (s *Storage) CreateTable(ctx context.Context, tableType interface{}, pk string, opts ...schema.Option) error {
+	if err := s.Backing.CreateTable(ctx, tableType, pk, opts...); err != nil {
+		return err
+	}
+	return s.Cache.CreateTable(ctx, tableType, pk, opts...)
+}
+
+func (s *Storage) Insert(ctx context.Context, record interface{}) (interface{}, error) {
+	written := pointer(record)
+	err := single(s.ExecuteBatch(ctx, []query.Op{{Kind: query.InsertOp, Record: written}}))
+	if err != nil && !errors.Is(err, ErrStaleCache) && !errors.Is(err, schema.ErrAfterHook) {
+		return nil, err
+	}
+	if v := reflect.ValueOf(record); v.Kind() == reflect.Ptr {
+		v.Elem().Set(reflect.ValueOf(written).Elem())
+	}
+	_, pk, keyErr := s.opKey(ctx, query.Op{Kind: query.InsertOp, Record: written})
+	if keyErr != nil {
+		return nil, errors.Join(err, keyErr)
+	}
+	return pk, err
+}
+
+func (s *Storage) InsertMany(ctx context.Context, records ...interface{}) error {
+	return s.Batch(ctx).Insert(records...).Commit()
+}
+
+func (s *Storage) Update(ctx context.Context, record interface{}) error {
+	return single(s.ExecuteBatch(ctx, []query.Op{{Kind: query.UpdateOp, Record: record}}))
+}
+
+func (s *Storage) Delete(ctx context.Context, tableType interface{}, pk interface{}) error {
+	return single(s.ExecuteBatch(ctx, []query.Op{{Kind: query.DeleteOp, TableType: tableType, Pk: pk}}))
+}
+
+// single returns the error of a batch of a single write as the error of the
+// write
+func single(err error) error {
+	var batchErr *query.BatchError
+	if errors.As(err, &batchErr) && len(batchErr.Errors) == 1 {
+		return batchErr.Errors[0].Err
+	}
+	return err
+}
+
+// get a record from the cache, or from the backing engine when the cache
+// misses. A record read that can not be copied to the cache is returned
+// with an error wrapping ErrCacheFill.
+func (s *Storage) Get(ctx context.Context, tableType interface{}, pk interface{}) (interface{}, error) {
+	record, err := s.Cache.Get(ctx, tableType, pk)
+	if err == nil {
+		return record, nil
+	}
+	return s.fetch(ctx, tableType, pk, err)
+}
+
+// get fields of a record from the cache, a record missing from the cache is
+// added to it first
+func (s *Storage) GetFields(ctx context.Context, tableType interface{}, pk interface{}, fields ...string) (interface{}, error) {
+	record, err := s.Cache.GetFields(ctx, tableType, pk, fields...)
+	if err == nil {
+		return record, nil
+	}
+	if err := (query.Spec{Fields: fields}).Check(reflect.TypeOf(tableType)); err != nil {
+		return nil, err
+	}
+	record, fillErr := s.fetch(ctx, tableType, pk, err)
+	if fillErr != nil && !errors.Is(fillErr, ErrCacheFill) {
+		return nil, fillErr
+	}
+	// only the named fields are kept, like a read of the cache
+	value, err := encoding.Encode(reflect.Indirect(reflect.ValueOf(record)).Interface())
+	if err != nil {
+		return nil, err
+	}
+	selected := reflect.New(reflect.TypeOf(tableType)).Interface()
+	if err := encoding.Decode(value, selected, encoding.Fields(fields...)); err != nil {
+		return nil, err
+	}
+	return selected, fillErr
+}
+
+// fetch reads a record missing from the cache, miss is the error of the
+// cache. A queued write of the record is read under mu. The backing engine
+// is read without holding mu, so misses of different records do not wait
+// for each other and the misses of the same record share one read.
+func (s *Storage) fetch(ctx context.Context, tableType interface{}, pk interface{}, miss error) (interface{}, error) {
+	key := queueKey(tableType, pk)
+	s.mu.Lock()
+	if _, ok := s.latest[key]; ok {
+		defer s.mu.Unlock()
+		return s.load(ctx, tableType, pk, miss)
+	}
+	if l, ok := s.loads[key]; ok {
+		s.mu.Unlock()
+		select {
+		case <-l.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if errors.Is(l.err, context.Canceled) || errors.Is(l.err, context.DeadlineExceeded) {
+			// the read was given up by the miss that started it
+			return s.fetch(ctx, tableType, pk, miss)
+		}
+		if l.err != nil {
+			return nil, l.err
+		}
+		return clone(l.record)
+	}
+	l := &loading{done: make(chan struct{})}
+	if s.loads == nil {
+		s.loads = make(map[string]*loading)
+	}
+	s.loads[key] = l
+	gen := s.gen
+	s.mu.Unlock()
+
+	l.record, l.err = s.Backing.Get(ctx, tableType, pk)
+	var expires time.Time
+	var fillErr error
+	if l.err == nil {
+		expires, fillErr = s.expires(ctx, tableType, pk)
+	}
+
+	s.mu.Lock()
+	delete(s.loads, key)
+	if l.err == nil && fillErr == nil && s.gen == gen {
+		fillErr = s.put(ctx, l.record, expires)
+	}
+	s.mu.Unlock()
+	close(l.done)
+	if l.err != nil {
+		return nil, l.err
+	}
+	return l.record, fillErr
+}
+
+// expires returns the time a record of the backing engine expires at, zero
+// when it does not or the backing engine does not tell
+func (s *Storage) expires(ctx context.Context, tableType interface{}, pk interface{}) (time.Time, error) {
+	e, ok := s.Backing.(Expirer)
+	if !ok {
+		return time.Time{}, nil
+	}
+	expires, err := e.Expires(ctx, tableType, pk)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %w", err, ErrCacheFill)
+	}
+	return expires, nil
+}
+
+// put copies a record to the cache
+func (s *Storage) put(ctx context.Context, record interface{}, expires time.Time) error {
+	if err := s.Cache.Put(ctx, reflect.Indirect(reflect.ValueOf(record)).Interface(), expires); err != nil {
+		return fmt.Errorf("%w: %w", err, ErrCacheFill)
+	}
+	return nil
+}
+
+// clone returns a deep copy of a record read by another miss
+func clone(record interface{}) (interface{}, error) {
+	v := reflect.Indirect(reflect.ValueOf(record))
+	value, err := encoding.Encode(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	copied := reflect.New(v.Type()).Interface()
+	if err := encoding.Decode(value, copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
+
+// load reads a record missing from the cache while no write can happen,
+// miss is the error of the cache. A queued write of the record is read
+// before the backing engine, a queued delete fails with miss. The record is
+// copied to the cache, it is returned with an error wrapping ErrCacheFill
+// when the copy fails. It is called with mu held.
+func (s *Storage) load(ctx context.Context, tableType interface{}, pk interface{}, miss error) (interface{}, error) {
+	var record interface{}
+	var expires time.Time
+	if q, ok := s.latest[queueKey(tableType, pk)]; ok {
+		if q.op.Kind == query.DeleteOp {
+			return nil, miss
+		}
+		record = pointer(q.op.Record)
+	} else {
+		var err error
+		if record, err = s.Backing.Get(ctx, tableType, pk); err != nil {
+			return nil, err
+		}
+		if expires, err = s.expires(ctx, tableType, pk); err != nil {
+			return record, err
+		}
+	}
+	return record, s.put(ctx, record, expires)
+}
+
+func (s *Storage) Batch(ctx context.Context) *query.Batch {
+	return query.NewBatch(ctx, s)
+}
+
+// apply the writes of a batch to the engine written first and then bring
+// the other engine up to date as set by the mode. Records passed through a
+// pointer are set to the records written.
+func (s *Storage) ExecuteBatch(ctx context.Context, ops []query.Op) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gen++
+
+	// the engines write copies so that the records written are known
+	// whether or not they were passed through a pointer
+	written := make([]query.Op, len(ops))
+	for i, op := range ops {
+		written[i] = op
+		if op.Record != nil {
+			written[i].Record = pointer(op.Record)
+		}
+	}
+
+	var err error
+	if s.mode == WriteBack {
+		err = s.writeBack(ctx, written)
+	} else {
+		err = s.writeThrough(ctx, written)
+	}
+	if err != nil && !errors.Is(err, ErrStaleCache) && !errors.Is(err, schema.ErrAfterHook) {
+		return err
+	}
+	for i, op := range ops {
+		if v := reflect.ValueOf(op.Record); v.Kind() == reflect.Ptr {
+			v.Elem().Set(reflect.ValueOf(written[i].Record).Elem())
+		}
+	}
+	return err
+}
+
+// writeThrough writes to the backing engine, then copies the records to
+// the cache in write-through mode or removes them from it
+func (s *Storage) writeThrough(ctx context.Context, ops []query.Op) error {
+	err := commit(ctx, s.Backing, ops)
+	if err != nil && !errors.Is(err, schema.ErrAfterHook) {
+		return err
+	}
+	errs := []error{err}
+	for _, op := range ops {
+		tableType, pk, err := s.opKey(ctx, op)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %w", err, ErrStaleCache))
+			continue
+		}
+		if s.mode == WriteThrough && op.Kind != query.DeleteOp {
+			expires, err := s.expires(ctx, tableType, pk)
+			if err == nil {
+				err = s.put(ctx, tableType, expires)
+			}
+			if err == nil {
+				continue
+			}
+		}
+		if err := s.invalidate(ctx, tableType, pk); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// invalidate removes a record from the cache, it fails when the record
+// stays in the cache
+func (s *Storage) invalidate(ctx context.Context, tableType interface{}, pk interface{}) error {
+	err := s.Cache.Drop(ctx, tableType, pk)
+	if err == nil {
+		return nil
+	}
+	if _, getErr := s.Cache.Get(ctx, tableType, pk); getErr != nil {
+		return nil
+	}
+	return fmt.Errorf("%s %v: %v: %w", reflect.TypeOf(tableType).Name(), pk, err, ErrStaleCache)
+}
+
+// writeBack writes to the cache and queues the writes. Records missing from
+// the cache are loaded first so that the cache checks the writes against
+// the records of the backing engine.
+func (s *Storage) writeBack(ctx context.Context, ops []query.Op) error {
+	for _, op := range ops {
+		tableType, pk, err := s.opKey(ctx, op)
+		if err != nil {
+			continue
+		}
+		if _, err := s.Cache.Get(ctx, tableType, pk); err != nil {
+			// a record missing from both engines is inserted by the batch
+			if _, err := s.load(ctx, tableType, pk, err); errors.Is(err, ErrCacheFill) {
+				return err
+			}
+		}
+	}
+	hookErr := commit(ctx, s.Cache, ops)
+	if hookErr != nil && !errors.Is(hookErr, schema.ErrAfterHook) {
+		return hookErr
+	}
+	for _, op := range ops {
+		tableType, pk, err := s.opKey(ctx, op)
+		if err != nil {
+			return err
+		}
+		if op.Kind != query.DeleteOp {
+			op.Record = tableType
+		}
+		s.seq++
+		q := queued{seq: s.seq, key: queueKey(tableType, pk), op: op}
+		s.queue = append(s.queue, q)
+		if s.latest == nil {
+			s.latest = make(map[string]queued)
+		}
+		s.latest[q.key] = q
+	}
+	return hookErr
+}
+
+// Flush writes the queued writes of write-back mode to the backing engine
+// in order, as one batch. The backing engine is left as it was when the
+// batch fails, except for the writes it rejects: they are dropped and their
+// records removed from the cache so that both engines agree. The rejected
+// writes of every flush since the last Flush are returned in a
+// *query.BatchError, indexed by their order in their flush.
+func (s *Storage) Flush(ctx context.Context) error {
+	err := s.flush(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rejected error
+	if len(s.rejected) > 0 {
+		rejected = &query.BatchError{Errors: s.rejected}
+		s.rejected = nil
+	}
+	return errors.Join(rejected, err)
+}
+
+// flush the queued writes, the rejected writes are kept for Flush
+func (s *Storage) flush(ctx context.Context) error {
+	s.flushing.Lock()
+	defer s.flushing.Unlock()
+
+	s.mu.Lock()
+	pending := append([]queued(nil), s.queue...)
+	s.mu.Unlock()
+
+	// a rejected write is dropped and the others retried, a batch is
+	// written whole or not at all
+	rejected := make(map[int]error)
+	remaining := make([]int, len(pending))
+	for i := range pending {
+		remaining[i] = i
+	}
+	var err error
+	for len(remaining) > 0 {
+		ops := make([]query.Op, len(remaining))
+		for i, at := range remaining {
+			ops[i] = pending[at].op
+		}
+		err = commit(ctx, s.Backing, ops)
+		var batchErr *query.BatchError
+		if !errors.As(err, &batchErr) {
+			break
+		}
+		err = nil
+		dropped := make(map[int]bool)
+		for _, opErr := range batchErr.Errors {
+			dropped[opErr.Index] = true
+			rejected[remaining[opErr.Index]] = opErr.Err
+		}
+		kept := remaining[:0]
+		for i, at := range remaining {
+			if !dropped[i] {
+				kept = append(kept, at)
+			}
+		}
+		remaining = kept
+	}
+
+	// the writes are applied when only the after hooks failed
+	applied := err == nil || errors.Is(err, schema.ErrAfterHook)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	done := make(map[uint64]bool)
+	for i, q := range pending {
+		if _, ok := rejected[i]; ok || applied {
+			done[q.seq] = true
+		}
+	}
+	queue := s.queue[:0]
+	for _, q := range s.queue {
+		if !done[q.seq] {
+			queue = append(queue, q)
+		}
+	}
+	s.queue = queue
+	var errs []error
+	for i, q := range pending {
+		if !done[q.seq] {
+			continue
+		}
+		if s.latest[q.key].seq == q.seq {
+			delete(s.latest, q.key)
+		}
+		opErr, ok := rejected[i]
+		if !ok {
+			continue
+		}
+		s.rejected = append(s.rejected, query.OpError{Index: i, Op: q.op, Err: opErr})
+		// a later queued write of the record is rejected by a later flush
+		// when it depends on this one
+		if _, ok := s.latest[q.key]; !ok {
+			tableType, pk, _ := s.opKey(ctx, q.op)
+			if invalidateErr := s.invalidate(ctx, tableType, pk); invalidateErr != nil {
+				errs = append(errs, invalidateErr)
+			}
+		}
+	}
+	return errors.Join(append(errs, err)...)
+}
+
+// startFlusher flushes the queue every interval until stop is called
+func (s *Storage) startFlusher(interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// writes left queued by a failure are retried on the next
+				// tick, rejected writes are returned by Flush
+				_ = s.flush(ctx)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+}
+
+// Close stops the background flush of write-back mode and flushes the
+// queued writes.
+func (s *Storage) Close(ctx context.Context) error {
+	s.mu.Lock()
+	stop := s.stop
+	s.stop, s.closed = nil, true
+	s.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+	if s.mode != WriteBack {
+		return nil
+	}
+	return s.Flush(ctx)
+}
+
+// settle flushes the queue before a read of the backing engine in
+// write-back mode
+func (s *Storage) settle(ctx context.Context) error {
+	if s.mode != WriteBack {
+		return nil
+	}
+	return s.flush(ctx)
+}
+
+func (s *Storage) Query(ctx context.Context, tableType interface{}) *query.Query {
+	if err := s.settle(ctx); err != nil {
+		return query.New(ctx, failed{err}, tableType)
+	}
+	return s.Backing.Query(ctx, tableType)
+}
+
+func (s *Storage) List(ctx context.Context, tableType interface{}, opts query.ListOptions) (query.Page, error) {
+	if err := s.settle(ctx); err != nil {
+		return query.Page{}, err
+	}
+	return s.Backing.List(ctx, tableType, opts)
+}
+
+func (s *Storage) Aggregate(ctx context.Context, tableType interface{}) *query.Aggregation {
+	if err := s.settle(ctx); err != nil {
+		return query.NewAggregation(ctx, failed{err}, tableType)
+	}
+	return s.Backing.Aggregate(ctx, tableType)
+}
+
+func (s *Storage) Join(ctx context.Context, left, right interface{}) *query.Join {
+	if err := s.settle(ctx); err != nil {
+		return query.NewJoin(ctx, failed{err}, query.InnerJoin, left, right)
+	}
+	return s.Backing.Join(ctx, left, right)
+}
+
+func (s *Storage) LeftJoin(ctx context.Context, left, right interface{}) *query.Join {
+	if err := s.settle(ctx); err != nil {
+		return query.NewJoin(ctx, failed{err}, query.LeftJoin, left, right)
+	}
+	return s.Backing.LeftJoin(ctx, left, right)
+}
+
+func (s *Storage) TableSchema(ctx context.Context, name string) (reflect.Type, string, error) {
+	return s.Cache.TableSchema(ctx, name)
+}
+
+func (s *Storage) Watch(ctx context.Context, tableType interface{}, fromSeq uint64) (<-chan query.ChangeEvent, func()) {
+	return s.Backing.Watch(ctx, tableType, fromSeq)
+}
+
+// failed runs queries that fail with the error of the flush before them
+type failed struct {
+	err error
+}
+
+func (f failed) Execute(context.Context, query.Spec) ([]interface{}, error) {
+	return nil, f.err
+}
+
+func (f failed) ExecuteAggregate(context.Context, query.AggregateSpec) ([]query.Group, error) {
+	return nil, f.err
+}
+
+func (f failed) ExecuteJoin(context.Context, query.JoinSpec) ([]query.Row, error) {
+	return nil, f.err
+}
+
+// commit applies writes as a batch of an engine
+func commit(ctx context.Context, st storage.Storage, ops []query.Op) error {
+	b := st.Batch(ctx)
+	for _, op := range ops {
+		switch op.Kind {
+		case query.InsertOp:
+			b.Insert(op.Record)
+		case query.UpdateOp:
+			b.Update(op.Record)
+		case query.DeleteOp:
+			b.Delete(op.TableType, op.Pk)
+		default:
+			return fmt.Errorf("%q: %w", op.Kind, query.ErrInvalidOp)
+		}
+	}
+	return b.Commit()
+}
+
+// opKey returns the table type, as the record of an insert or update, and
+// the primary key value of the record of a write
+func (s *Storage) opKey(ctx context.Context, op query.Op) (interface{}, interface{}, error) {
+	if op.Kind == query.DeleteOp {
+		return op.TableType, op.Pk, nil
+	}
+	if op.Record == nil {
+		return nil, nil, fmt.Errorf("%s without a record: %w", op.Kind, query.ErrInvalidOp)
+	}
+	v := reflect.Indirect(reflect.ValueOf(op.Record))
+	_, pk, err := s.Cache.TableSchema(ctx, v.Type().Name())
+	if err != nil {
+		return nil, nil, err
+	}
+	names := strings.Split(pk, ",")
+	values := make([]interface{}, len(names))
+	for i, name := range names {
+		values[i] = v.FieldByName(strings.TrimSpace(name)).Interface()
+	}
+	if len(values) == 1 {
+		return v.Interface(), values[0], nil
+	}
+	return v.Interface(), values, nil
+}
+
+// queueKey identifies a record by its table and primary key value, values
+// converting to the same key print the same
+func queueKey(tableType interface{}, pk interface{}) string {
+	return fmt.Sprintf("%s %v", reflect.TypeOf(tableType).Name(), pk)
+}
+
+// pointer returns a pointer to a copy of a record
+func pointer(record interface{}) interface{} {
+	v := reflect.Indirect(reflect.ValueOf(record))
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p.Interface()
+}
@@ -0,0 +1,506 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	storage "github.com/priyanshujain/go-storage"
+	"github.com/priyanshujain/go-storage/drivers/inmemory"
+	"github.com/priyanshujain/go-storage/query"
+	"github.com/priyanshujain/go-storage/schema"
+)
+
+var _ storage.Storage = (*Storage)(nil)
+
+type Account struct {
+	Id      int
+	Balance int
+}
+
+type Transfer struct {
+	Id     uint64
+	Amount int
+}
+
+func newStorage(t *testing.T, mode Mode, flushInterval time.Duration) (*Storage, *inmemory.Database, *inmemory.Database) {
+	cache, backing := inmemory.New(), inmemory.New()
+	s, err := New(cache, backing, mode, flushInterval)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := s.CreateTable(context.Background(), Account{}, "Id"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if err := s.CreateTable(context.Background(), Transfer{}, "Id", schema.GeneratedKey(schema.Sequence)); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	return s, cache, backing
+}
+
+// balance returns the balance of an account in an engine, -1 when it is
+// missing
+func balance(st storage.Storage, id int) int {
+	record, err := st.Get(context.Background(), Account{}, id)
+	if err != nil {
+		return -1
+	}
+	return record.(*Account).Balance
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		mode          Mode
+		flushInterval time.Duration
+	}{
+		{"write-around", 0},
+		{WriteBack, -time.Second},
+		{WriteThrough, time.Second},
+	}
+	for _, tt := range tests {
+		if _, err := New(inmemory.New(), inmemory.New(), tt.mode, tt.flushInterval); !errors.Is(err, ErrInvalidMode) {
+			t.Errorf("Expected %v for %s every %v but got %v", ErrInvalidMode, tt.mode, tt.flushInterval, err)
+		}
+	}
+}
+
+func TestStorage_ReadThrough(t *testing.T) {
+	ctx := context.Background()
+	s, cache, backing := newStorage(t, ReadThrough, 0)
+
+	if _, err := s.Insert(ctx, Account{Id: 1, Balance: 10}); err != nil {
+		t.Fatalf("Failed to insert account: %v", err)
+	}
+	if got := balance(cache, 1); got != -1 {
+		t.Errorf("Expected the insert to skip the cache but got %d", got)
+	}
+	if got := balance(s, 1); got != 10 {
+		t.Errorf("Expected 10 but got %d", got)
+	}
+	if got := balance(cache, 1); got != 10 {
+		t.Errorf("Expected the read to fill the cache but got %d", got)
+	}
+
+	if err := s.Update(ctx, Account{Id: 1, Balance: 20}); err != nil {
+		t.Fatalf("Failed to update account: %v", err)
+	}
+	if got := balance(cache, 1); got != -1 {
+		t.Errorf("Expected the update to invalidate the cache but got %d", got)
+	}
+	if got := balance(s, 1); got != 20 {
+		t.Errorf("Expected 20 but got %d", got)
+	}
+	if err := s.Delete(ctx, Account{}, 1); err != nil {
+		t.Fatalf("Failed to delete account: %v", err)
+	}
+	if _, err := s.Get(ctx, Account{}, 1); err != inmemory.ErrRecordNotFound {
+		t.Errorf("Expected %v but got %v", inmemory.ErrRecordNotFound, err)
+	}
+
+	// a write the backing engine rejects leaves both engines untouched
+	_, _ = s.Insert(ctx, Account{Id: 2, Balance: 5})
+	_, _ = s.Get(ctx, Account{}, 2)
+	if _, err := s.Insert(ctx, Account{Id: 2}); err != inmemory.ErrDuplicateRecord {
+		t.Errorf("Expected %v but got %v", inmemory.ErrDuplicateRecord, err)
+	}
+	if got := balance(cache, 2); got != 5 {
+		t.Errorf("Expected the cache to keep 5 but got %d", got)
+	}
+	if got := balance(backing, 2); got != 5 {
+		t.Errorf("Expected the backing engine to keep 5 but got %d", got)
+	}
+}
+
+func TestStorage_Misses(t *testing.T) {
+	ctx := context.Background()
+	s, cache, backing := newStorage(t, ReadThrough, 0)
+	_ = backing.InsertMany(ctx, Account{Id: 1, Balance: 10}, Account{Id: 2, Balance: 20})
+	// reads of account 1 wait for release
+	var release chan struct{}
+	reads := make(chan int, 10)
+	_ = backing.AddHook(schema.AfterGet, func(ctx context.Context, table string, record interface{}) error {
+		if account, ok := record.(*Account); ok && account.Id == 1 {
+			reads <- 1
+			<-release
+		}
+		return nil
+	})
+	read := func(results chan<- int) {
+		go func() {
+			results <- balance(s, 1)
+		}()
+	}
+
+	t.Run("shared", func(t *testing.T) {
+		release = make(chan struct{})
+		results := make(chan int, 2)
+		read(results)
+		<-reads
+		read(results)
+		if got := balance(s, 2); got != 20 {
+			t.Errorf("Expected a miss of another account to be read meanwhile but got %d", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		for i := 0; i < 2; i++ {
+			if got := <-results; got != 10 {
+				t.Errorf("Expected 10 but got %d", got)
+			}
+		}
+		if got := len(reads); got != 0 {
+			t.Errorf("Expected the misses of account 1 to share a read but got %d more", got)
+		}
+	})
+
+	t.Run("written during the read", func(t *testing.T) {
+		_ = cache.Delete(ctx, Account{}, 1)
+		release = make(chan struct{})
+		results := make(chan int, 1)
+		read(results)
+		<-reads
+		if err := s.Update(ctx, Account{Id: 1, Balance: 15}); err != nil {
+			t.Fatalf("Failed to update account: %v", err)
+		}
+		close(release)
+		if got := <-results; got != 10 {
+			t.Errorf("Expected the balance read before the update but got %d", got)
+		}
+		if got := balance(cache, 1); got != -1 {
+			t.Errorf("Expected the record read before the update to be left out of the cache but got %d", got)
+		}
+		if got := balance(s, 1); got != 15 {
+			t.Errorf("Expected 15 but got %d", got)
+		}
+	})
+
+	// a miss of GetFields keeps the named fields only
+	_ = cache.Delete(ctx, Account{}, 2)
+	record, err := s.GetFields(ctx, Account{}, 2, "Balance")
+	if err != nil || *record.(*Account) != (Account{Balance: 20}) {
+		t.Errorf("Expected {Balance: 20} but got %v, %v", record, err)
+	}
+	if _, err := s.GetFields(ctx, Account{}, 3, "Missing"); !errors.Is(err, query.ErrInvalidField) {
+		t.Errorf("Expected %v but got %v", query.ErrInvalidField, err)
+	}
+}
+
+func TestStorage_WriteThrough(t *testing.T) {
+	ctx := context.Background()
+	s, cache, backing := newStorage(t, WriteThrough, 0)
+
+	transfer := &Transfer{Amount: 10}
+	if err := s.InsertMany(ctx, transfer, Transfer{Amount: 20}, Account{Id: 1, Balance: 10}); err != nil {
+		t.Fatalf("Failed to insert records: %v", err)
+	}
+	if transfer.Id != 1 {
+		t.Errorf("Expected the generated key 1 but got %d", transfer.Id)
+	}
+	transfer = &Transfer{Amount: 30}
+	if id, err := s.Insert(ctx, transfer); err != nil || id != uint64(3) || transfer.Id != 3 {
+		t.Errorf("Expected the generated key 3 but got %v, %d, %v", id, transfer.Id, err)
+	}
+	// the cache holds the keys generated by the backing engine
+	for _, st := range []storage.Storage{cache, backing} {
+		record, err := st.Get(ctx, Transfer{}, uint64(2))
+		if err != nil || record.(*Transfer).Amount != 20 {
+			t.Errorf("Expected transfer 2 in both engines but got %v, %v", record, err)
+		}
+	}
+
+	if err := s.Update(ctx, &Account{Id: 1, Balance: 30}); err != nil {
+		t.Fatalf("Failed to update account: %v", err)
+	}
+	if got := balance(cache, 1); got != 30 {
+		t.Errorf("Expected the update to be written to the cache but got %d", got)
+	}
+
+	// a cache failing writes is left without the record
+	cache.Eviction = "fifo"
+	if err := s.Update(ctx, Account{Id: 1, Balance: 40}); err != nil {
+		t.Fatalf("Expected a failing cache to be invalidated but got %v", err)
+	}
+	cache.Eviction = ""
+	if got := balance(cache, 1); got != -1 {
+		t.Errorf("Expected the account to be removed from the cache but got %d", got)
+	}
+	if got := balance(s, 1); got != 40 {
+		t.Errorf("Expected 40 but got %d", got)
+	}
+}
+
+func TestStorage_AfterHookError(t *testing.T) {
+	ctx := context.Background()
+	errAudit := errors.New("audit failed")
+	audit := func(ctx context.Context, table string, record interface{}) error {
+		return errAudit
+	}
+
+	t.Run("write-through", func(t *testing.T) {
+		s, cache, backing := newStorage(t, WriteThrough, 0)
+		_ = backing.AddHook(schema.AfterInsert, audit)
+		if _, err := s.Insert(ctx, Account{Id: 1, Balance: 10}); !errors.Is(err, schema.ErrAfterHook) || !errors.Is(err, errAudit) {
+			t.Errorf("Expected %v but got %v", schema.ErrAfterHook, err)
+		}
+		if got := balance(cache, 1); got != 10 {
+			t.Errorf("Expected the applied insert to be written to the cache but got %d", got)
+		}
+	})
+
+	t.Run("write-back", func(t *testing.T) {
+		s, _, backing := newStorage(t, WriteBack, 0)
+		_ = backing.AddHook(schema.AfterInsert, audit)
+		_, _ = s.Insert(ctx, Account{Id: 1, Balance: 10})
+		if err := s.Flush(ctx); !errors.Is(err, schema.ErrAfterHook) {
+			t.Errorf("Expected %v but got %v", schema.ErrAfterHook, err)
+		}
+		if got := balance(backing, 1); got != 10 {
+			t.Errorf("Expected 10 but got %d", got)
+		}
+		if err := s.Flush(ctx); err != nil {
+			t.Errorf("Expected the applied writes to leave the queue but got %v", err)
+		}
+	})
+}
+
+func TestStorage_WriteBack(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("flush", func(t *testing.T) {
+		s, cache, backing := newStorage(t, WriteBack, 0)
+		_, _ = backing.Insert(ctx, Account{Id: 1, Balance: 10})
+
+		if err := s.Update(ctx, Account{Id: 1, Balance: 15}); err != nil {
+			t.Fatalf("Failed to update account: %v", err)
+		}
+		if _, err := s.Insert(ctx, Account{Id: 2, Balance: 20}); err != nil {
+			t.Fatalf("Failed to insert account: %v", err)
+		}
+		if _, err := s.Insert(ctx, Account{Id: 1}); err != inmemory.ErrDuplicateRecord {
+			t.Errorf("Expected a record of the backing engine to be checked but got %v", err)
+		}
+		_ = s.Delete(ctx, Account{}, 2)
+		if got := balance(backing, 1); got != 10 {
+			t.Errorf("Expected the backing engine to wait for a flush but got %d", got)
+		}
+		if got := balance(s, 1); got != 15 {
+			t.Errorf("Expected 15 but got %d", got)
+		}
+
+		records, err := s.Query(ctx, Account{}).All()
+		if err != nil || len(records) != 1 || records[0].(*Account).Balance != 15 {
+			t.Errorf("Expected a query to see the queued writes but got %v, %v", records, err)
+		}
+		if got := balance(cache, 1); got != 15 {
+			t.Errorf("Expected the cache to keep 15 but got %d", got)
+		}
+		if err := s.Flush(ctx); err != nil {
+			t.Errorf("Expected an empty flush but got %v", err)
+		}
+	})
+
+	t.Run("evicted", func(t *testing.T) {
+		s, cache, backing := newStorage(t, WriteBack, 0)
+		cache.Eviction, cache.MaxRecords = inmemory.LRU, 1
+		_, _ = backing.Insert(ctx, Account{Id: 3, Balance: 30})
+		_, _ = s.Insert(ctx, Account{Id: 1, Balance: 10})
+		_, _ = s.Insert(ctx, Account{Id: 2, Balance: 20})
+		_ = s.Delete(ctx, Account{}, 3)
+
+		// queued writes are read before the backing engine
+		if got := balance(s, 1); got != 10 {
+			t.Errorf("Expected 10 but got %d", got)
+		}
+		if got := balance(s, 3); got != -1 {
+			t.Errorf("Expected account 3 to be deleted but got %d", got)
+		}
+		if err := s.Close(ctx); err != nil {
+			t.Fatalf("Failed to close storage: %v", err)
+		}
+		for id, expected := range map[int]int{1: 10, 2: 20, 3: -1} {
+			if got := balance(backing, id); got != expected {
+				t.Errorf("Expected %d for account %d but got %d", expected, id, got)
+			}
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		s, cache, backing := newStorage(t, WriteBack, 0)
+		rejected := errors.New("rejected")
+		_ = backing.AddHook(schema.BeforeInsert, func(ctx context.Context, table string, record interface{}) error {
+			if account, ok := record.(*Account); ok && account.Balance < 0 {
+				return rejected
+			}
+			return nil
+		})
+		_, _ = s.Insert(ctx, Account{Id: 1, Balance: 10})
+		_, _ = s.Insert(ctx, Account{Id: 2, Balance: -5})
+		_ = s.Update(ctx, Account{Id: 1, Balance: 15})
+
+		err := s.Flush(ctx)
+		var batchErr *query.BatchError
+		if !errors.As(err, &batchErr) || len(batchErr.Errors) != 1 || batchErr.Errors[0].Index != 1 || !errors.Is(err, rejected) {
+			t.Fatalf("Expected the insert of account 2 to be rejected but got %v", err)
+		}
+		if got := balance(backing, 1); got != 15 {
+			t.Errorf("Expected the other writes to be flushed but got %d", got)
+		}
+		if got := balance(cache, 2); got != -1 {
+			t.Errorf("Expected the rejected account to be removed from the cache but got %d", got)
+		}
+		if err := s.Flush(ctx); err != nil {
+			t.Errorf("Expected the rejected write to be dropped but got %v", err)
+		}
+	})
+
+	t.Run("flush interval", func(t *testing.T) {
+		s, _, backing := newStorage(t, WriteBack, time.Millisecond)
+		_, _ = s.Insert(ctx, Account{Id: 1, Balance: 10})
+		deadline := time.Now().Add(time.Second)
+		for balance(backing, 1) != 10 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the background flush to write the account")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if err := s.Close(ctx); err != nil {
+			t.Errorf("Failed to close storage: %v", err)
+		}
+	})
+
+	t.Run("init", func(t *testing.T) {
+		s, _, backing := newStorage(t, WriteBack, time.Millisecond)
+		for id := 1; id <= 100; id++ {
+			_, _ = s.Insert(ctx, Account{Id: id, Balance: id})
+		}
+		s.Init()
+		if err := backing.CreateTable(ctx, Account{}, "Id"); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		_ = s.Cache.CreateTable(ctx, Account{}, "Id")
+		time.Sleep(5 * time.Millisecond)
+		if records, _ := backing.Query(ctx, Account{}).All(); len(records) != 0 {
+			t.Errorf("Expected the queued writes to be dropped but got %d records", len(records))
+		}
+		// the background flush goes on after Init
+		_, _ = s.Insert(ctx, Account{Id: 1, Balance: 10})
+		deadline := time.Now().Add(time.Second)
+		for balance(backing, 1) != 10 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the background flush to write the account")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if err := s.Close(ctx); err != nil {
+			t.Errorf("Failed to close storage: %v", err)
+		}
+	})
+
+	t.Run("cancelled flush", func(t *testing.T) {
+		s, _, backing := newStorage(t, WriteBack, 0)
+		_, _ = s.Insert(ctx, Account{Id: 1, Balance: 10})
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := s.Aggregate(cancelled, Account{}).Count().Run(); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v but got %v", context.Canceled, err)
+		}
+		if err := s.Flush(ctx); err != nil || balance(backing, 1) != 10 {
+			t.Errorf("Expected the writes to stay queued until flushed but got %v", err)
+		}
+	})
+}
+
+func TestStorage_Hooks(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		mode Mode
+		// inserts are the after insert hooks run by the cache and the
+		// backing engine
+		inserts [2]int
+	}{
+		{ReadThrough, [2]int{0, 1}},
+		{WriteThrough, [2]int{0, 1}},
+		{WriteBack, [2]int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			s, cache, backing := newStorage(t, tt.mode, 0)
+			var inserts [2]int
+			for i, st := range []*inmemory.Database{cache, backing} {
+				i := i
+				_ = st.AddHook(schema.AfterInsert, func(ctx context.Context, table string, record interface{}) error {
+					inserts[i]++
+					return nil
+				})
+			}
+
+			if _, err := s.Insert(ctx, Account{Id: 1, Balance: 10}); err != nil {
+				t.Fatalf("Failed to insert account: %v", err)
+			}
+			if err := s.Flush(ctx); err != nil {
+				t.Fatalf("Failed to flush: %v", err)
+			}
+			// a miss copies the account to the cache, a hit reads it there
+			_ = cache.Drop(ctx, Account{}, 1)
+			for i := 0; i < 2; i++ {
+				if got := balance(s, 1); got != 10 {
+					t.Errorf("Expected 10 but got %d", got)
+				}
+			}
+			if inserts != tt.inserts {
+				t.Errorf("Expected %v but got %v", tt.inserts, inserts)
+			}
+		})
+	}
+}
+
+func TestStorage_Fill(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("expiry", func(t *testing.T) {
+		s, cache, backing := newStorage(t, ReadThrough, 0)
+		_, _ = backing.InsertWithTTL(ctx, Account{Id: 1, Balance: 10}, time.Hour)
+		if got := balance(s, 1); got != 10 {
+			t.Errorf("Expected 10 but got %d", got)
+		}
+		expected, _ := backing.Expires(ctx, Account{}, 1)
+		if at, err := cache.Expires(ctx, Account{}, 1); err != nil || at.IsZero() || !at.Equal(expected) {
+			t.Errorf("Expected the copy to expire at %v but got %v, %v", expected, at, err)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		s, cache, backing := newStorage(t, ReadThrough, 0)
+		_, _ = backing.Insert(ctx, Account{Id: 1, Balance: 10})
+		cache.Eviction = "fifo"
+		record, err := s.Get(ctx, Account{}, 1)
+		if !errors.Is(err, ErrCacheFill) {
+			t.Errorf("Expected %v but got %v", ErrCacheFill, err)
+		}
+		if record == nil || record.(*Account).Balance != 10 {
+			t.Errorf("Expected the account read to be returned but got %v", record)
+		}
+		if _, err := s.GetFields(ctx, Account{}, 1, "Balance"); !errors.Is(err, ErrCacheFill) {
+			t.Errorf("Expected %v but got %v", ErrCacheFill, err)
+		}
+	})
+
+	t.Run("foreign key", func(t *testing.T) {
+		type Entry struct {
+			Id        int
+			AccountId int `references:"Account"`
+		}
+		s, cache, _ := newStorage(t, WriteThrough, 0)
+		if err := s.CreateTable(ctx, Entry{}, "Id"); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		_, _ = s.Insert(ctx, Account{Id: 1})
+		_, _ = s.Insert(ctx, Entry{Id: 1, AccountId: 1})
+		// the entry is copied without its account in the cache
+		_ = cache.Drop(ctx, Account{}, 1)
+		_ = s.Update(ctx, Entry{Id: 1, AccountId: 1})
+		if _, err := cache.Get(ctx, Entry{}, 1); err != nil {
+			t.Errorf("Expected the entry to be copied to the cache but got %v", err)
+		}
+	})
+}
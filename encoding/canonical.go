@@ -0,0 +1,141 @@
+package encoding
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// canonical normalizes values that are equal but have more than one
+// representation, see EncodeCanonical.
+func canonical() Option {
+	return func(o *options) {
+		o.canonical = true
+	}
+}
+
+// EncodeCanonical encodes data like Encode and guarantees that values which
+// compare equal produce byte for byte identical output, so the result can be
+// used for content hashing and change detection. On top of the deterministic
+// map ordering Encode already provides, negative zero is written as zero.
+// NaN is always written the same way regardless of its payload bits.
+func EncodeCanonical(data interface{}, opts ...Option) (string, error) {
+	return Encode(data, append(opts, canonical())...)
+}
+
+// Hash returns the hex encoded SHA-256 digest of the canonical encoding of
+// record. Equal records hash equally across processes and runs.
+func Hash(record interface{}, opts ...Option) (string, error) {
+	encodedRecord, err := EncodeCanonical(record, opts...)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(encodedRecord))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalFloat maps negative zero to zero, every other value including
+// NaN already has a single text representation
+func canonicalFloat(f float64) float64 {
+	if f == 0 {
+		return 0
+	}
+	return f
+}
+
+// mapEntry is an encoded map entry along with its original key used for
+// ordering
+type mapEntry struct {
+	key        reflect.Value
+	keyValue   string
+	fieldValue string
+}
+
+// sortMapEntries orders map entries by key. Keys of the same basic kind are
+// compared by value (numbers numerically, strings lexically), any other key
+// falls back to comparing its encoding. Ties, which only happen for keys
+// such as NaN, are broken by the encoded entry so the order is total.
+func sortMapEntries(entries []mapEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if c := compareKeys(entries[i].key, entries[j].key); c != 0 {
+			return c < 0
+		}
+		if c := strings.Compare(entries[i].keyValue, entries[j].keyValue); c != 0 {
+			return c < 0
+		}
+		return entries[i].fieldValue < entries[j].fieldValue
+	})
+}
+
+func compareKeys(a, b reflect.Value) int {
+	if a.Kind() == reflect.Interface {
+		a = a.Elem()
+	}
+	if b.Kind() == reflect.Interface {
+		b = b.Elem()
+	}
+	if !a.IsValid() || !b.IsValid() || a.Type() != b.Type() {
+		return compareTypes(a, b)
+	}
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return compareOrdered(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareFloats(a.Float(), b.Float())
+	case reflect.Bool:
+		return compareOrdered(boolToInt(a.Bool()), boolToInt(b.Bool()))
+	}
+	// composite keys are ordered by their encoding by the caller
+	return 0
+}
+
+// compareTypes orders values of different dynamic types, which only occurs
+// for interface keys, by type name with invalid (nil) values first
+func compareTypes(a, b reflect.Value) int {
+	switch {
+	case !a.IsValid() && !b.IsValid():
+		return 0
+	case !a.IsValid():
+		return -1
+	case !b.IsValid():
+		return 1
+	}
+	return strings.Compare(a.Type().String(), b.Type().String())
+}
+
+// compareFloats orders NaN before every other value
+func compareFloats(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	case math.IsNaN(b):
+		return 1
+	}
+	return compareOrdered(a, b)
+}
+
+func compareOrdered[T int64 | uint64 | float64 | int](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,183 @@
+package encoding
+
+import (
+	"encoding/base64"
+	"math"
+	"testing"
+)
+
+func TestDeterministicEncoding(t *testing.T) {
+	t.Run("same map encodes identically", func(t *testing.T) {
+		type Record struct {
+			Strings map[string]int
+			Nested  map[string]map[int]bool
+			Mixed   map[interface{}]string
+		}
+		data := Record{
+			Strings: map[string]int{},
+			Nested:  map[string]map[int]bool{},
+			Mixed:   map[interface{}]string{1: "int", "1": "string", 2: "int", "a": "string"},
+		}
+		for i := 0; i < 50; i++ {
+			data.Strings[string(rune('a'+i%26))+string(rune('a'+i/26))] = i
+			data.Nested[string(rune('a'+i%26))] = map[int]bool{i: true, -i: false}
+		}
+		first, err := Encode(data)
+		if err != nil {
+			t.Fatalf("Failed to encode data: %v", err)
+		}
+		for i := 0; i < 20; i++ {
+			encodedData, err := Encode(data)
+			if err != nil {
+				t.Fatalf("Failed to encode data: %v", err)
+			}
+			if encodedData != first {
+				t.Fatalf("Expected identical encodings, got %q and %q", first, encodedData)
+			}
+		}
+	})
+
+	t.Run("keys are ordered by value", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			data     interface{}
+			expected string
+		}{
+			{
+				name:     "int keys",
+				data:     struct{ M map[int]string }{M: map[int]string{10: "c", -1: "a", 9: "b"}},
+				expected: "-1:a,9:b,10:c",
+			},
+			{
+				name:     "uint keys",
+				data:     struct{ M map[uint8]bool }{M: map[uint8]bool{200: true, 3: false, 20: true}},
+				expected: "3:false,20:true,200:true",
+			},
+			{
+				name:     "float keys",
+				data:     struct{ M map[float64]int }{M: map[float64]int{2.5: 1, -1: 2, math.NaN(): 3, 10: 4}},
+				expected: "NaN:3,-1:2,2.5:1,10:4",
+			},
+			{
+				name:     "string keys",
+				data:     struct{ M map[string]int }{M: map[string]int{"b": 1, "a": 2, "B": 3}},
+				expected: "B:3,a:2,b:1",
+			},
+			{
+				name:     "bool keys",
+				data:     struct{ M map[bool]int }{M: map[bool]int{true: 1, false: 0}},
+				expected: "false:0,true:1",
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				encodedData, err := Encode(tt.data)
+				if err != nil {
+					t.Fatalf("Failed to encode data: %v", err)
+				}
+				expected := base64.StdEncoding.EncodeToString([]byte(
+					base64.StdEncoding.EncodeToString([]byte(tt.expected)),
+				))
+				if encodedData != expected {
+					t.Errorf("Expected %q but got %q", expected, encodedData)
+				}
+			})
+		}
+	})
+}
+
+func TestEncodeCanonical(t *testing.T) {
+	type Record struct {
+		Float  float64
+		Floats map[string]float32
+		NaN    float64
+	}
+	negativeZero := math.Copysign(0, -1)
+
+	t.Run("negative zero", func(t *testing.T) {
+		a := Record{Float: 0, Floats: map[string]float32{"x": 0}}
+		b := Record{Float: negativeZero, Floats: map[string]float32{"x": float32(negativeZero)}}
+
+		encodedA, _ := Encode(a)
+		encodedB, _ := Encode(b)
+		if encodedA == encodedB {
+			t.Errorf("Expected Encode to preserve negative zero")
+		}
+
+		canonicalA, err := EncodeCanonical(a)
+		if err != nil {
+			t.Fatalf("Failed to encode data: %v", err)
+		}
+		canonicalB, err := EncodeCanonical(b)
+		if err != nil {
+			t.Fatalf("Failed to encode data: %v", err)
+		}
+		if canonicalA != canonicalB {
+			t.Errorf("Expected canonical encodings to match, got %q and %q", canonicalA, canonicalB)
+		}
+	})
+
+	t.Run("NaN payloads", func(t *testing.T) {
+		a := Record{NaN: math.NaN()}
+		b := Record{NaN: math.Float64frombits(0x7ff8000000000001)}
+		canonicalA, _ := EncodeCanonical(a)
+		canonicalB, _ := EncodeCanonical(b)
+		if canonicalA != canonicalB {
+			t.Errorf("Expected canonical encodings to match, got %q and %q", canonicalA, canonicalB)
+		}
+	})
+
+	t.Run("decodes like Encode", func(t *testing.T) {
+		data := MyStruct{
+			StringField: "Hello",
+			FloatField:  3.14,
+			ArrayField:  [3]int{1, 2, 3},
+			SliceField:  []string{"a", "b"},
+			MapField:    map[string]int{"a": 1, "b": 2},
+			StructField: NestedStruct{Field1: "test", Field2: 2},
+		}
+		encodedData, err := EncodeCanonical(data)
+		if err != nil {
+			t.Fatalf("Failed to encode data: %v", err)
+		}
+		var decodedData MyStruct
+		if err := Decode(encodedData, &decodedData); err != nil {
+			t.Fatalf("Failed to decode data: %v", err)
+		}
+	})
+}
+
+func TestHash(t *testing.T) {
+	newRecord := func() MyStruct {
+		return MyStruct{
+			StringField: "Hello",
+			MapField:    map[string]int{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5},
+		}
+	}
+
+	first, err := Hash(newRecord())
+	if err != nil {
+		t.Fatalf("Failed to hash record: %v", err)
+	}
+	if len(first) != 64 {
+		t.Errorf("Expected a hex encoded sha256 digest, got %q", first)
+	}
+	for i := 0; i < 20; i++ {
+		h, _ := Hash(newRecord())
+		if h != first {
+			t.Fatalf("Expected equal records to hash equally, got %q and %q", first, h)
+		}
+	}
+
+	changed := newRecord()
+	changed.MapField["a"] = 0
+	h, _ := Hash(changed)
+	if h == first {
+		t.Errorf("Expected different records to hash differently")
+	}
+
+	_, err = Hash(struct{ C chan int }{})
+	if err == nil {
+		t.Errorf("Expected error for unsupported type")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -51,7 +52,7 @@ func TestDecode(t *testing.T) {
 						StringField string
 						IntField    int
 						FloatField  float64
-						BoolField   interface{}
+						BoolField   chan bool
 						ArrayField  [3]int
 						SliceField  []string
 						MapField    map[string]int
@@ -600,3 +601,530 @@ func TestEncodingDecoding(t *testing.T) {
 		})
 	})
 }
+
+type Base struct {
+	Id      string
+	Version int
+}
+
+type audit struct {
+	CreatedBy string
+	note      string
+}
+
+type Embedded struct {
+	Base
+	audit
+	Name string
+	*NestedStruct
+}
+
+type Flat struct {
+	Id           string
+	Version      int
+	CreatedBy    string
+	Name         string
+	NestedStruct *NestedStruct
+}
+
+type WithUnexported struct {
+	Name   string
+	secret string
+	count  int
+	inner  audit
+	Nested map[string]audit
+}
+
+func TestUnexportedFields(t *testing.T) {
+	data := WithUnexported{
+		Name:   "public",
+		secret: "hidden",
+		count:  3,
+		inner:  audit{CreatedBy: "a", note: "b"},
+		Nested: map[string]audit{"x": {CreatedBy: "c", note: "d"}},
+	}
+
+	t.Run("skipped by default", func(t *testing.T) {
+		encodedData, err := Encode(data)
+		if err != nil {
+			t.Fatalf("Failed to encode data: %v", err)
+		}
+		decodedData := WithUnexported{secret: "untouched"}
+		err = Decode(encodedData, &decodedData)
+		if err != nil {
+			t.Fatalf("Failed to decode data: %v", err)
+		}
+		expected := WithUnexported{
+			Name:   "public",
+			secret: "untouched",
+			Nested: map[string]audit{"x": {CreatedBy: "c"}},
+		}
+		if !reflect.DeepEqual(expected, decodedData) {
+			t.Errorf("Decoded data does not match.\nExpected: %+v\nGot: %+v", expected, decodedData)
+		}
+	})
+
+	t.Run("included with option", func(t *testing.T) {
+		encodedData, err := Encode(data, IncludeUnexported())
+		if err != nil {
+			t.Fatalf("Failed to encode data: %v", err)
+		}
+		var decodedData WithUnexported
+		err = Decode(encodedData, &decodedData, IncludeUnexported())
+		if err != nil {
+			t.Fatalf("Failed to decode data: %v", err)
+		}
+		if !reflect.DeepEqual(data, decodedData) {
+			t.Errorf("Decoded data does not match.\nExpected: %+v\nGot: %+v", data, decodedData)
+		}
+	})
+}
+
+func TestEmbeddedStructs(t *testing.T) {
+	t.Run("fields are promoted", func(t *testing.T) {
+		data := Embedded{
+			Base:         Base{Id: "1", Version: 2},
+			audit:        audit{CreatedBy: "me", note: "skipped"},
+			Name:         "name",
+			NestedStruct: &NestedStruct{Field1: "f", Field2: 1},
+		}
+		flat := Flat{
+			Id:           "1",
+			Version:      2,
+			CreatedBy:    "me",
+			Name:         "name",
+			NestedStruct: &NestedStruct{Field1: "f", Field2: 1},
+		}
+		encodedData, err := Encode(data)
+		if err != nil {
+			t.Fatalf("Failed to encode data: %v", err)
+		}
+		encodedFlat, err := Encode(flat)
+		if err != nil {
+			t.Fatalf("Failed to encode data: %v", err)
+		}
+		if encodedData != encodedFlat {
+			t.Errorf("Expected embedded fields to encode like flat fields, got %q and %q", encodedData, encodedFlat)
+		}
+
+		var decodedData Embedded
+		err = Decode(encodedFlat, &decodedData)
+		if err != nil {
+			t.Fatalf("Failed to decode data: %v", err)
+		}
+		data.audit.note = ""
+		if !reflect.DeepEqual(data, decodedData) {
+			t.Errorf("Decoded data does not match.\nExpected: %+v\nGot: %+v", data, decodedData)
+		}
+	})
+
+	t.Run("nil embedded pointer", func(t *testing.T) {
+		data := Embedded{Base: Base{Id: "1"}}
+		encodedData, err := Encode(data)
+		if err != nil {
+			t.Fatalf("Failed to encode data: %v", err)
+		}
+		var decodedData Embedded
+		err = Decode(encodedData, &decodedData)
+		if err != nil {
+			t.Fatalf("Failed to decode data: %v", err)
+		}
+		if !reflect.DeepEqual(data, decodedData) {
+			t.Errorf("Decoded data does not match.\nExpected: %+v\nGot: %+v", data, decodedData)
+		}
+	})
+
+	t.Run("nested embedding", func(t *testing.T) {
+		type Inner struct {
+			Base
+			Inner string
+		}
+		type Outer struct {
+			Inner
+			Outer string
+		}
+		data := Outer{Inner: Inner{Base: Base{Id: "1", Version: 1}, Inner: "in"}, Outer: "out"}
+		encodedData, err := Encode(data)
+		if err != nil {
+			t.Fatalf("Failed to encode data: %v", err)
+		}
+		var decodedData Outer
+		err = Decode(encodedData, &decodedData)
+		if err != nil {
+			t.Fatalf("Failed to decode data: %v", err)
+		}
+		if !reflect.DeepEqual(data, decodedData) {
+			t.Errorf("Decoded data does not match.\nExpected: %+v\nGot: %+v", data, decodedData)
+		}
+	})
+
+	t.Run("shadowed fields", func(t *testing.T) {
+		type Inner struct {
+			A int
+			B int
+		}
+		type Left struct {
+			C string
+		}
+		type Right struct {
+			C string
+			D string
+		}
+		type Shadowing struct {
+			Inner
+			A string
+			Left
+			Right
+		}
+		type Visible struct {
+			B int
+			A string
+			D string
+		}
+		data := Shadowing{Inner: Inner{A: 1, B: 2}, A: "a", Left: Left{C: "left"}, Right: Right{C: "right", D: "d"}}
+		encodedData, err := Encode(data)
+		if err != nil {
+			t.Fatalf("Failed to encode data: %v", err)
+		}
+		encodedVisible, err := Encode(Visible{B: 2, A: "a", D: "d"})
+		if err != nil {
+			t.Fatalf("Failed to encode data: %v", err)
+		}
+		// the shadowed Inner.A and the ambiguous C are left out
+		if encodedData != encodedVisible {
+			t.Errorf("Expected only the visible fields to be encoded, got %q and %q", encodedData, encodedVisible)
+		}
+	})
+}
+
+func TestPointers(t *testing.T) {
+	zero := 0
+	one := 1
+	empty := ""
+	zeroPtr := &zero
+	var nilPtr *int
+
+	type Pointers struct {
+		Int          *int
+		PtrPtr       **int
+		String       *string
+		Struct       *NestedStruct
+		Slice        []*int
+		Map          map[string]*string
+		Array        [2]*bool
+		PtrToSlice   *[]string
+		NestedStruct struct {
+			Ptr    *int
+			PtrPtr **int
+		}
+	}
+
+	tests := []struct {
+		name string
+		data Pointers
+	}{
+		{name: "all nil", data: Pointers{}},
+		{name: "pointers to zero values", data: Pointers{
+			Int:        &zero,
+			PtrPtr:     &zeroPtr,
+			String:     &empty,
+			Struct:     &NestedStruct{},
+			PtrToSlice: &[]string{""},
+		}},
+		{name: "pointer to nil pointer", data: Pointers{PtrPtr: &nilPtr}},
+		{name: "pointers to values", data: Pointers{Int: &one, String: &[]string{"s"}[0], Struct: &NestedStruct{Field1: "a", Field2: 1}}},
+		{name: "pointers inside collections", data: Pointers{
+			Slice: []*int{&one, nil, &zero},
+			Map:   map[string]*string{"nil": nil, "empty": &empty},
+			Array: [2]*bool{nil, new(bool)},
+		}},
+		{name: "pointers inside nested struct", data: func() Pointers {
+			var p Pointers
+			p.NestedStruct.Ptr = &zero
+			p.NestedStruct.PtrPtr = &nilPtr
+			return p
+		}()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encodedData, err := Encode(tt.data)
+			if err != nil {
+				t.Fatalf("Failed to encode data: %v", err)
+			}
+			var decodedData Pointers
+			err = Decode(encodedData, &decodedData)
+			if err != nil {
+				t.Fatalf("Failed to decode data: %v", err)
+			}
+			if !reflect.DeepEqual(tt.data, decodedData) {
+				t.Errorf("Decoded data does not match.\nExpected: %+v\nGot: %+v", tt.data, decodedData)
+			}
+		})
+	}
+
+	t.Run("missing pointer marker", func(t *testing.T) {
+		type Source struct {
+			Int int
+		}
+		type Target struct {
+			Int *int
+		}
+		encodedData, _ := Encode(Source{Int: 1})
+		var decodedData Target
+		err := Decode(encodedData, &decodedData)
+		if !errors.Is(err, ErrParsePtr) {
+			t.Errorf("Expected error %v but got %v", ErrParsePtr, err)
+		}
+	})
+}
+
+type Collections[T any] struct {
+	Slice []T
+	Map   map[string]T
+}
+
+type collectionCase struct {
+	name    string
+	data    interface{}
+	decoded func() interface{}
+}
+
+// collectionCases covers nil, empty and populated slices and maps holding
+// zero and non-zero values of T
+func collectionCases[T any](kind string, zero, value T) []collectionCase {
+	cases := []struct {
+		name string
+		data Collections[T]
+	}{
+		{"nil", Collections[T]{}},
+		{"empty", Collections[T]{Slice: []T{}, Map: map[string]T{}}},
+		{"single zero", Collections[T]{Slice: []T{zero}, Map: map[string]T{"": zero}}},
+		{"zero first", Collections[T]{Slice: []T{zero, value}, Map: map[string]T{"a": zero, "b": value}}},
+		{"zero last", Collections[T]{Slice: []T{value, zero}}},
+		{"only zeros", Collections[T]{Slice: []T{zero, zero, zero}}},
+		{"populated", Collections[T]{Slice: []T{value}, Map: map[string]T{"a": value}}},
+	}
+	var collectionCases []collectionCase
+	for _, c := range cases {
+		collectionCases = append(collectionCases, collectionCase{
+			name:    kind + "/" + c.name,
+			data:    c.data,
+			decoded: func() interface{} { return &Collections[T]{} },
+		})
+	}
+	return collectionCases
+}
+
+func TestCollections(t *testing.T) {
+	one := 1
+	var tests []collectionCase
+	tests = append(tests, collectionCases("string", "", "a")...)
+	tests = append(tests, collectionCases("int", 0, -1)...)
+	tests = append(tests, collectionCases("int8", int8(0), int8(-128))...)
+	tests = append(tests, collectionCases("uint", uint(0), uint(1))...)
+	tests = append(tests, collectionCases("float", 0.0, 1.5)...)
+	tests = append(tests, collectionCases("bool", false, true)...)
+	tests = append(tests, collectionCases("struct", NestedStruct{}, NestedStruct{Field1: "a", Field2: 1})...)
+	tests = append(tests, collectionCases("pointer", (*int)(nil), &one)...)
+	tests = append(tests, collectionCases("pointer to zero", new(int), &one)...)
+	tests = append(tests, collectionCases("slice", []int(nil), []int{1})...)
+	tests = append(tests, collectionCases("empty slice", []int{}, []int{0})...)
+	tests = append(tests, collectionCases("array", [2]int{}, [2]int{1, 2})...)
+	tests = append(tests, collectionCases("map", map[string]int(nil), map[string]int{"a": 0})...)
+	tests = append(tests, collectionCases("empty map", map[string]int{}, map[string]int{"": 1})...)
+	tests = append(tests, collectionCases[interface{}]("interface", nil, "a")...)
+	tests = append(tests, collectionCases[interface{}]("interface zero", 0, "")...)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encodedData, err := Encode(tt.data)
+			if err != nil {
+				t.Fatalf("Failed to encode data: %v", err)
+			}
+			decodedData := tt.decoded()
+			if err := Decode(encodedData, decodedData); err != nil {
+				t.Fatalf("Failed to decode data: %v", err)
+			}
+			if !reflect.DeepEqual(tt.data, reflect.ValueOf(decodedData).Elem().Interface()) {
+				t.Errorf("Decoded data does not match.\nExpected: %#v\nGot: %#v", tt.data, decodedData)
+			}
+		})
+	}
+
+	t.Run("distinct encodings", func(t *testing.T) {
+		values := []Collections[string]{
+			{},
+			{Slice: []string{}},
+			{Slice: []string{""}},
+			{Slice: []string{"", ""}},
+			{Map: map[string]string{}},
+			{Map: map[string]string{"": ""}},
+		}
+		seen := make(map[string]int)
+		for i, value := range values {
+			encodedData, err := Encode(value)
+			if err != nil {
+				t.Fatalf("Failed to encode data: %v", err)
+			}
+			if j, ok := seen[encodedData]; ok {
+				t.Errorf("Expected %#v and %#v to be encoded differently", values[j], value)
+			}
+			seen[encodedData] = i
+		}
+	})
+
+	t.Run("invalid empty list", func(t *testing.T) {
+		type Target struct {
+			Map map[string]int
+		}
+		encodedData, _ := Encode(struct{ Map string }{"&1"})
+		var decodedData Target
+		if err := Decode(encodedData, &decodedData); !errors.Is(err, ErrParseMap) {
+			t.Errorf("Expected error %v but got %v", ErrParseMap, err)
+		}
+	})
+}
+
+type TreeNode struct {
+	Value    int
+	Children []*TreeNode
+	Parent   *TreeNode
+	Index    map[string]*TreeNode
+}
+
+func TestCodecCache(t *testing.T) {
+	t.Run("recursive types", func(t *testing.T) {
+		leaf := &TreeNode{Value: 3}
+		data := TreeNode{
+			Value:    1,
+			Children: []*TreeNode{{Value: 2, Children: []*TreeNode{leaf}}, leaf},
+			Index:    map[string]*TreeNode{"leaf": leaf},
+		}
+		encodedData, err := Encode(data)
+		if err != nil {
+			t.Fatalf("Failed to encode data: %v", err)
+		}
+		var decodedData TreeNode
+		err = Decode(encodedData, &decodedData)
+		if err != nil {
+			t.Fatalf("Failed to decode data: %v", err)
+		}
+		if !reflect.DeepEqual(data, decodedData) {
+			t.Errorf("Decoded data does not match.\nExpected: %+v\nGot: %+v", data, decodedData)
+		}
+	})
+
+	t.Run("codecs are compiled once per type and options", func(t *testing.T) {
+		o := newOptions(nil)
+		c := codecFor(reflect.TypeOf(MyStruct{}), o)
+		if codecFor(reflect.TypeOf(MyStruct{}), o) != c {
+			t.Errorf("Expected the cached codec to be reused")
+		}
+		if codecFor(reflect.TypeOf(MyStruct{}), newOptions([]Option{IncludeUnexported()})) == c {
+			t.Errorf("Expected a separate codec when unexported fields are included")
+		}
+	})
+
+	t.Run("caching reduces allocations", func(t *testing.T) {
+		data := benchmarkRecord()
+		encodedData, _ := Encode(data)
+		uncached := testing.AllocsPerRun(20, func() {
+			resetCodecs()
+			_, _ = Encode(data)
+			var decodedData MyStruct
+			_ = Decode(encodedData, &decodedData)
+		})
+		cached := testing.AllocsPerRun(20, func() {
+			_, _ = Encode(data)
+			var decodedData MyStruct
+			_ = Decode(encodedData, &decodedData)
+		})
+		if cached >= uncached {
+			t.Errorf("Expected fewer allocations with cached codecs, got %v cached and %v uncached", cached, uncached)
+		}
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		resetCodecs()
+		data := benchmarkRecord()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					encodedData, err := Encode(data)
+					if err != nil {
+						t.Errorf("Failed to encode data: %v", err)
+						return
+					}
+					var decodedData MyStruct
+					if err := Decode(encodedData, &decodedData); err != nil {
+						t.Errorf("Failed to decode data: %v", err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+// resetCodecs drops every compiled codec so the next operation has to
+// discover its types again
+func resetCodecs() {
+	codecs.Range(func(key, _ interface{}) bool {
+		codecs.Delete(key)
+		return true
+	})
+}
+
+func benchmarkRecord() MyStruct {
+	return MyStruct{
+		StringField: "Hello",
+		IntField:    42,
+		FloatField:  3.14,
+		BoolField:   true,
+		ArrayField:  [3]int{1, 2, 3},
+		SliceField:  []string{"a", "b", "c"},
+		MapField:    map[string]int{"a": 1, "b": 2, "c": 3},
+		StructField: NestedStruct{Field1: "test", Field2: 2},
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	data := benchmarkRecord()
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = Encode(data)
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			resetCodecs()
+			_, _ = Encode(data)
+		}
+	})
+}
+
+func BenchmarkDecode(b *testing.B) {
+	encodedData, _ := Encode(benchmarkRecord())
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var decodedData MyStruct
+			_ = Decode(encodedData, &decodedData)
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			resetCodecs()
+			var decodedData MyStruct
+			_ = Decode(encodedData, &decodedData)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package encoding
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Option configures how records are encoded and decoded. A record must be
+// decoded with the same options it was encoded with.
+type Option func(*options)
+
+type options struct {
+	includeUnexported bool
+	canonical         bool
+	ignoreMarshalers  bool
+	// fields are the names of the fields to decode, nil decodes all
+	fields []string
+}
+
+// IncludeUnexported encodes and decodes unexported struct fields as well.
+// By default unexported fields are skipped and left untouched on decode.
+func IncludeUnexported() Option {
+	return func(o *options) {
+		o.includeUnexported = true
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// structFields returns the index paths of the fields of a struct type in
+// encoding order. Fields of embedded structs are promoted in place of the
+// embedded field, the same way Go promotes them: a field is shadowed by a
+// field of the same name at a shallower depth, and fields of the same name
+// at the same depth hide each other. Only the promoted fields are names, an
+// embedded struct itself shadows nothing. Embedded pointers are kept as a single
+// field so that a nil embedded pointer survives a round trip.
+func structFields(structType reflect.Type, o *options) [][]int {
+	candidates := promotedFields(structType, nil)
+	// depth is the depth of the shallowest fields of each name and count
+	// their number
+	depth := make(map[string]int)
+	count := make(map[string]int)
+	for _, c := range candidates {
+		if c.expanded {
+			// an embedded struct is encoded as its fields and shadows none
+			continue
+		}
+		d, ok := depth[c.field.Name]
+		switch {
+		case !ok || len(c.index) < d:
+			depth[c.field.Name], count[c.field.Name] = len(c.index), 1
+		case len(c.index) == d:
+			count[c.field.Name]++
+		}
+	}
+	var fields [][]int
+	for _, c := range candidates {
+		if c.expanded || len(c.index) != depth[c.field.Name] || count[c.field.Name] > 1 {
+			continue
+		}
+		if !c.field.IsExported() && !o.includeUnexported {
+			continue
+		}
+		fields = append(fields, c.index)
+	}
+	return fields
+}
+
+// candidate is a field of a struct or of the structs it embeds, expanded is
+// set for an embedded struct whose fields are promoted in its place
+type candidate struct {
+	field    reflect.StructField
+	index    []int
+	expanded bool
+}
+
+// promotedFields lists the fields of a struct type and of the structs it
+// embeds in declaration order, index is the path to the struct type
+func promotedFields(structType reflect.Type, index []int) []candidate {
+	var candidates []candidate
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		c := candidate{field: field, index: append(append([]int(nil), index...), i)}
+		c.expanded = field.Anonymous && field.Type.Kind() == reflect.Struct
+		candidates = append(candidates, c)
+		if c.expanded {
+			candidates = append(candidates, promotedFields(field.Type, c.index)...)
+		}
+	}
+	return candidates
+}
+
+// fieldByIndex returns the nested field of v at index. Unexported fields are
+// only reachable through reflection when v is addressable, in which case they
+// are made readable and settable.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	field := v.FieldByIndex(index)
+	if !field.CanInterface() && field.CanAddr() {
+		field = reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+	}
+	return field
+}
@@ -0,0 +1,220 @@
+package encoding
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fuzzChild struct {
+	Label  string
+	Values []int32
+	Next   *fuzzChild
+}
+
+// fuzzList is registered so that interface map keys can hold a value that
+// cannot be hashed
+type fuzzList []string
+
+func init() {
+	RegisterName("encoding.fuzzList", fuzzList(nil))
+}
+
+type fuzzRecord struct {
+	Name    string
+	Count   int8
+	Size    uint16
+	Ratio   float32
+	Value   float64
+	Ok      bool
+	Nested  NestedStruct
+	Child   *fuzzChild
+	Names   []string
+	Bytes   []byte
+	Grid    [2][2]int
+	Lookup  map[string]*fuzzChild
+	Flags   map[int]bool
+	Any     interface{}
+	Keys    map[interface{}]string
+	Pointer **string
+	Self    *fuzzRecord
+}
+
+func fuzzSeeds() []fuzzRecord {
+	name := "ptr"
+	namePtr := &name
+	return []fuzzRecord{
+		{},
+		{
+			Name:   "a,b:c%d",
+			Count:  -128,
+			Size:   65535,
+			Ratio:  1.5,
+			Value:  math.Inf(-1),
+			Ok:     true,
+			Nested: NestedStruct{Field1: "nested", Field2: -1},
+			Child: &fuzzChild{Label: "child", Values: []int32{1, 2},
+				Next: &fuzzChild{Label: "next"}},
+			Names:   []string{"x", "", "y,z"},
+			Bytes:   []byte("bytes"),
+			Grid:    [2][2]int{{1, 2}, {3, 4}},
+			Lookup:  map[string]*fuzzChild{"": nil, "k:v": {Label: "l"}},
+			Flags:   map[int]bool{-1: true, 1: false},
+			Any:     OrderPlaced{OrderId: "o", Amount: 2},
+			Keys:    map[interface{}]string{"s": "string", 0: "int"},
+			Pointer: &namePtr,
+			Self:    &fuzzRecord{Name: "self", Any: "any"},
+		},
+	}
+}
+
+// checkRoundTrip decodes a record and checks that encoding the result again
+// is stable
+func checkRoundTrip(t *testing.T, record string) {
+	var decoded fuzzRecord
+	if err := Decode(record, &decoded); err != nil {
+		return
+	}
+	encoded, err := Encode(decoded)
+	if err != nil {
+		t.Fatalf("Failed to encode a decoded record: %v", err)
+	}
+	var again fuzzRecord
+	if err := Decode(encoded, &again); err != nil {
+		t.Fatalf("Failed to decode %q: %v", encoded, err)
+	}
+	reencoded, err := Encode(again)
+	if err != nil {
+		t.Fatalf("Failed to encode a decoded record: %v", err)
+	}
+	if reencoded != encoded {
+		t.Fatalf("Expected %q but got %q", encoded, reencoded)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		record, err := Encode(seed)
+		if err != nil {
+			f.Fatalf("Failed to encode seed: %v", err)
+		}
+		f.Add(record)
+	}
+	f.Fuzz(func(t *testing.T, record string) {
+		checkRoundTrip(t, record)
+		// the fields of a valid record, which reach deeper than random base64
+		checkRoundTrip(t, base64.StdEncoding.EncodeToString([]byte(record)))
+	})
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add("name", int8(1), uint16(2), float32(0.5), 1.25, true, []byte{1, 2, 3}, "key")
+	f.Add("a,b:c%", int8(-128), uint16(0), float32(math.MaxFloat32), math.NaN(), false, []byte{}, "")
+	f.Add("", int8(0), uint16(65535), float32(-0.0), math.Copysign(0, -1), true, []byte(nil), "%2C")
+	f.Fuzz(func(t *testing.T, s string, i int8, u uint16, r float32, v float64, b bool, data []byte, key string) {
+		values := make([]int32, len(data))
+		for n, d := range data {
+			values[n] = int32(d) - 128
+		}
+		child := &fuzzChild{Label: key, Values: values, Next: &fuzzChild{Label: s}}
+		namePtr := &s
+		record := fuzzRecord{
+			Name:    s,
+			Count:   i,
+			Size:    u,
+			Ratio:   r,
+			Value:   v,
+			Ok:      b,
+			Nested:  NestedStruct{Field1: key, Field2: int64(i) * int64(u)},
+			Child:   child,
+			Names:   []string{s, key},
+			Bytes:   data,
+			Grid:    [2][2]int{{int(i), int(u)}, {len(s), len(key)}},
+			Lookup:  map[string]*fuzzChild{s: child, key: nil},
+			Flags:   map[int]bool{int(i): b, int(u): !b},
+			Any:     s,
+			Keys:    map[interface{}]string{s: key, int(i): s},
+			Pointer: &namePtr,
+			Self:    &fuzzRecord{Name: key, Ratio: r, Bytes: data},
+		}
+
+		encoded, err := Encode(record)
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		var decoded fuzzRecord
+		if err := Decode(encoded, &decoded); err != nil {
+			t.Fatalf("Failed to decode %q: %v", encoded, err)
+		}
+		reencoded, err := Encode(decoded)
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		if reencoded != encoded {
+			t.Fatalf("Expected %q but got %q", encoded, reencoded)
+		}
+		if math.IsNaN(v) || math.IsNaN(float64(r)) {
+			// NaN is not equal to itself
+			return
+		}
+		if !reflect.DeepEqual(decoded, record) {
+			t.Fatalf("Expected %+v but got %+v", record, decoded)
+		}
+	})
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	b64 := func(fields ...string) string {
+		return base64.StdEncoding.EncodeToString([]byte(strings.Join(fields, ",")))
+	}
+	valid, _ := Encode(fuzzRecord{})
+	decodedValid, _ := base64.StdEncoding.DecodeString(valid)
+	fields := strings.Split(string(decodedValid), ",")
+	withField := func(i int, value string) string {
+		replaced := append([]string(nil), fields...)
+		replaced[i] = value
+		return b64(replaced...)
+	}
+
+	tests := []struct {
+		name   string
+		record string
+		err    error
+	}{
+		{"invalid base64", "!", ErrBase64Decoding},
+		{"truncated", b64("name", "1"), ErrInvalidFieldValues},
+		{"int overflow", withField(1, "128"), ErrParseInt},
+		{"uint overflow", withField(2, "65536"), ErrParseUint},
+		{"float overflow", withField(3, "1e39"), ErrParseFloat},
+		{"invalid escape", withField(0, "%41"), ErrParseString},
+		{"truncated escape", withField(0, "%2"), ErrParseString},
+		{"truncated struct", withField(6, b64("nested")), ErrParseStruct},
+		{"pointer without marker", withField(7, b64("label", "", "")), ErrParsePtr},
+		{"short array", withField(10, b64("")), ErrParseArray},
+		{"map entry without value", withField(11, b64("key")), ErrParseMap},
+		{"unhashable map key", withField(14, b64(b64("encoding.fuzzList:"+b64("a"))+":v")), ErrParseMap},
+		{"unregistered interface", withField(13, b64("unknown:1")), ErrParseInterface},
+		{"interface without name", withField(13, b64("1")), ErrParseInterface},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var decoded fuzzRecord
+			if err := Decode(tt.record, &decoded); !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v but got %v", tt.err, err)
+			}
+		})
+	}
+
+	t.Run("not a pointer", func(t *testing.T) {
+		if err := Decode(valid, fuzzRecord{}); !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("Expected ErrUnsupportedType but got %v", err)
+		}
+		if err := Decode(valid, (*fuzzRecord)(nil)); !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("Expected ErrUnsupportedType but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,504 @@
+package encoding
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Marshaler is implemented by record types that encode themselves, usually
+// through methods generated by cmd/storagegen. MarshalStorage must return
+// exactly what Encode would return for the value.
+type Marshaler interface {
+	MarshalStorage() (string, error)
+}
+
+// Unmarshaler is implemented by record types that decode themselves from
+// the output of Encode or MarshalStorage.
+type Unmarshaler interface {
+	UnmarshalStorage(record string) error
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+)
+
+// IgnoreMarshalers makes Encode and Decode use reflection even for types
+// implementing Marshaler or Unmarshaler.
+func IgnoreMarshalers() Option {
+	return func(o *options) {
+		o.ignoreMarshalers = true
+	}
+}
+
+// useMarshalers reports whether Marshaler and Unmarshaler implementations
+// may replace reflection. Generated methods always use the default layout
+// and decode every field, so they are skipped when an option changes that.
+func (o *options) useMarshalers() bool {
+	return !o.ignoreMarshalers && !o.includeUnexported && !o.canonical && o.fields == nil
+}
+
+func encodeMarshaler(e *encodeState, v reflect.Value) error {
+	value, err := v.Interface().(Marshaler).MarshalStorage()
+	if err != nil {
+		return err
+	}
+	e.buf = append(e.buf, value...)
+	return nil
+}
+
+func decodeUnmarshaler(v reflect.Value, value string, o *options) error {
+	if err := v.Addr().Interface().(Unmarshaler).UnmarshalStorage(value); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrParseStruct)
+	}
+	return nil
+}
+
+// A Writer builds a record in the format of Encode one value at a time. It
+// is the runtime used by code generated by cmd/storagegen; values have to be
+// written in the order of the fields of the record. The zero value is ready
+// to use.
+type Writer struct {
+	e        encodeState
+	levels   []writerLevel
+	afterPtr bool
+}
+
+var writerPool = sync.Pool{
+	New: func() interface{} { return &Writer{} },
+}
+
+// NewWriter returns an empty Writer from a pool, it should be handed back
+// with Release once the record is built.
+func NewWriter() *Writer {
+	return writerPool.Get().(*Writer)
+}
+
+// Release resets the Writer and returns it to the pool.
+func (w *Writer) Release() {
+	w.e.buf = w.e.buf[:0]
+	w.levels = w.levels[:0]
+	w.afterPtr = false
+	writerPool.Put(w)
+}
+
+// writerOptions are the options of values a Writer encodes through
+// reflection
+var writerOptions options
+
+type writerLevel struct {
+	start int
+	count int
+	isMap bool
+	// list is set for non-nil slices and maps, which are written as an empty
+	// list when their elements join to an empty string
+	list bool
+}
+
+// separate writes the separator that precedes the next value
+func (w *Writer) separate() {
+	if w.e.o == nil {
+		w.e.o = &writerOptions
+	}
+	if w.afterPtr {
+		// the value belongs to the pointer marker already written
+		w.afterPtr = false
+		return
+	}
+	if len(w.levels) == 0 {
+		w.levels = append(w.levels, writerLevel{})
+	}
+	level := &w.levels[len(w.levels)-1]
+	if level.count > 0 {
+		if level.isMap && level.count%2 == 1 {
+			w.e.buf = append(w.e.buf, ':')
+		} else {
+			w.e.buf = append(w.e.buf, ',')
+		}
+	}
+	level.count++
+}
+
+func (w *Writer) WriteString(s string) {
+	w.separate()
+	w.e.buf = appendEscaped(w.e.buf, s)
+}
+
+func (w *Writer) WriteInt(i int64) {
+	w.separate()
+	w.e.buf = strconv.AppendInt(w.e.buf, i, 10)
+}
+
+func (w *Writer) WriteUint(u uint64) {
+	w.separate()
+	w.e.buf = strconv.AppendUint(w.e.buf, u, 10)
+}
+
+// WriteFloat writes a float of the given bit size, 32 or 64.
+func (w *Writer) WriteFloat(f float64, bitSize int) {
+	w.separate()
+	w.e.buf = strconv.AppendFloat(w.e.buf, f, 'g', -1, bitSize)
+}
+
+func (w *Writer) WriteBool(b bool) {
+	w.separate()
+	w.e.buf = strconv.AppendBool(w.e.buf, b)
+}
+
+// WriteNil writes a nil pointer.
+func (w *Writer) WriteNil() {
+	w.separate()
+}
+
+// WritePtr writes the marker of a non-nil pointer, the pointed value must
+// be written next.
+func (w *Writer) WritePtr() {
+	w.separate()
+	w.e.buf = append(w.e.buf, ptrMarker...)
+	w.afterPtr = true
+}
+
+// WriteMarshaler writes a nested record that encodes itself.
+func (w *Writer) WriteMarshaler(m Marshaler) error {
+	value, err := m.MarshalStorage()
+	if err != nil {
+		return err
+	}
+	w.separate()
+	w.e.buf = append(w.e.buf, value...)
+	return nil
+}
+
+// WriteValue writes the value ptr points to using reflection. It is the
+// fallback for types the generator has no specialised code for.
+func (w *Writer) WriteValue(ptr interface{}) error {
+	w.separate()
+	v := reflect.ValueOf(ptr).Elem()
+	return codecFor(v.Type(), w.e.o).encode(&w.e, v)
+}
+
+// BeginStruct starts a nested struct whose fields are written next, it is
+// closed with EndStruct.
+func (w *Writer) BeginStruct() {
+	w.begin(false, false)
+}
+
+func (w *Writer) EndStruct() {
+	w.end()
+}
+
+// BeginList starts a slice, its elements are written next and the list is
+// closed with EndList. A nil slice has no elements.
+func (w *Writer) BeginList(isNil bool) {
+	w.begin(false, !isNil)
+}
+
+// BeginArray starts an array, its elements are written next and the array
+// is closed with EndList.
+func (w *Writer) BeginArray() {
+	w.begin(false, false)
+}
+
+func (w *Writer) EndList() {
+	w.end()
+}
+
+// BeginMap starts a map whose keys and values are written next, alternating
+// and in the key order Encode uses. The map is closed with EndMap.
+func (w *Writer) BeginMap(isNil bool) {
+	w.begin(true, !isNil)
+}
+
+func (w *Writer) EndMap() {
+	w.end()
+}
+
+func (w *Writer) begin(isMap, list bool) {
+	w.separate()
+	w.levels = append(w.levels, writerLevel{start: len(w.e.buf), isMap: isMap, list: list})
+}
+
+func (w *Writer) end() {
+	level := w.levels[len(w.levels)-1]
+	w.levels = w.levels[:len(w.levels)-1]
+	if level.list && len(w.e.buf) == level.start {
+		w.e.buf = appendEmptyList(w.e.buf, level.count)
+		return
+	}
+	w.e.base64From(level.start)
+}
+
+// Record returns the encoded record.
+func (w *Writer) Record() string {
+	w.e.base64From(0)
+	return string(w.e.buf)
+}
+
+// A Reader reads the values of a record encoded by Encode or a Writer in
+// field order. It is the runtime used by code generated by cmd/storagegen.
+type Reader struct {
+	levels     []readerLevel
+	pending    string
+	hasPending bool
+	o          options
+}
+
+type readerLevel struct {
+	values []string
+	pos    int
+}
+
+var readerPool = sync.Pool{
+	New: func() interface{} { return &Reader{} },
+}
+
+// NewReader returns a Reader over record, which must hold at least the
+// given number of fields. The Reader comes from a pool and should be handed
+// back with Release once the record is read.
+func NewReader(record string, fields int) (*Reader, error) {
+	r := readerPool.Get().(*Reader)
+	if err := r.begin(record, fields); err != nil {
+		r.Release()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Release resets the Reader and returns it to the pool.
+func (r *Reader) Release() {
+	r.levels = r.levels[:0]
+	r.pending, r.hasPending = "", false
+	readerPool.Put(r)
+}
+
+// begin decodes a base64 encoded struct and makes its fields the values
+// read next
+func (r *Reader) begin(value string, fields int) error {
+	decodedValue, err := decodeBase64(value)
+	if err != nil {
+		return err
+	}
+	fieldValues := strings.Split(decodedValue, ",")
+	if len(fieldValues) < fields {
+		return ErrInvalidFieldValues
+	}
+	r.levels = append(r.levels, readerLevel{values: fieldValues})
+	return nil
+}
+
+func (r *Reader) next() (string, error) {
+	if r.hasPending {
+		r.hasPending = false
+		return r.pending, nil
+	}
+	level := &r.levels[len(r.levels)-1]
+	if level.pos >= len(level.values) {
+		return "", ErrInvalidFieldValues
+	}
+	value := level.values[level.pos]
+	level.pos++
+	return value, nil
+}
+
+func (r *Reader) ReadString() (string, error) {
+	value, err := r.next()
+	if err != nil {
+		return "", err
+	}
+	return unescape(value)
+}
+
+// ReadInt reads an integer that must fit in the given bit size.
+func (r *Reader) ReadInt(bitSize int) (int64, error) {
+	value, err := r.next()
+	if err != nil {
+		return 0, err
+	}
+	intValue, err := strconv.ParseInt(value, 10, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", err, ErrParseInt)
+	}
+	return intValue, nil
+}
+
+// ReadUint reads an unsigned integer that must fit in the given bit size.
+func (r *Reader) ReadUint(bitSize int) (uint64, error) {
+	value, err := r.next()
+	if err != nil {
+		return 0, err
+	}
+	uintValue, err := strconv.ParseUint(value, 10, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", err, ErrParseUint)
+	}
+	return uintValue, nil
+}
+
+// ReadFloat reads a float of the given bit size, 32 or 64.
+func (r *Reader) ReadFloat(bitSize int) (float64, error) {
+	value, err := r.next()
+	if err != nil {
+		return 0, err
+	}
+	floatValue, err := strconv.ParseFloat(value, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", err, ErrParseFloat)
+	}
+	return floatValue, nil
+}
+
+func (r *Reader) ReadBool() (bool, error) {
+	value, err := r.next()
+	if err != nil {
+		return false, err
+	}
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", err, ErrParseBool)
+	}
+	return boolValue, nil
+}
+
+// ReadPtr reads a pointer marker and reports whether the pointer is nil.
+// For a non-nil pointer the pointed value must be read next.
+func (r *Reader) ReadPtr() (bool, error) {
+	value, err := r.next()
+	if err != nil {
+		return false, err
+	}
+	if value == "" {
+		return true, nil
+	}
+	value, found := strings.CutPrefix(value, ptrMarker)
+	if !found {
+		return false, fmt.Errorf("%v: %w", ErrInvalidFieldValues, ErrParsePtr)
+	}
+	r.pending, r.hasPending = value, true
+	return false, nil
+}
+
+// ReadUnmarshaler reads a nested record that decodes itself.
+func (r *Reader) ReadUnmarshaler(u Unmarshaler) error {
+	value, err := r.next()
+	if err != nil {
+		return err
+	}
+	if err := u.UnmarshalStorage(value); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrParseStruct)
+	}
+	return nil
+}
+
+// BeginStruct starts reading a nested struct with the given number of
+// fields, it is closed with EndStruct.
+func (r *Reader) BeginStruct(fields int) error {
+	value, err := r.next()
+	if err != nil {
+		return err
+	}
+	if err := r.begin(value, fields); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrParseStruct)
+	}
+	return nil
+}
+
+func (r *Reader) EndStruct() {
+	r.levels = r.levels[:len(r.levels)-1]
+}
+
+// ReadValue reads into the value ptr points to using reflection, it is the
+// counterpart of Writer.WriteValue.
+func (r *Reader) ReadValue(ptr interface{}) error {
+	value, err := r.next()
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(ptr).Elem()
+	return codecFor(v.Type(), &r.o).decode(v, value, &r.o)
+}
+
+// BeginList starts reading a slice and returns its length and whether it is
+// nil. The list is closed with EndList.
+func (r *Reader) BeginList() (int, bool, error) {
+	value, err := r.next()
+	if err != nil {
+		return 0, false, err
+	}
+	if value == "" {
+		r.levels = append(r.levels, readerLevel{})
+		return 0, true, nil
+	}
+	if n, ok := parseEmptyList(value); ok {
+		r.levels = append(r.levels, readerLevel{values: make([]string, n)})
+		return n, false, nil
+	}
+	decodedValue, err := decodeBase64(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("%v: %w", err, ErrParseSlice)
+	}
+	values := strings.Split(decodedValue, ",")
+	r.levels = append(r.levels, readerLevel{values: values})
+	return len(values), false, nil
+}
+
+// BeginArray starts reading an array of the given length. The array is
+// closed with EndList.
+func (r *Reader) BeginArray(length int) error {
+	value, err := r.next()
+	if err != nil {
+		return err
+	}
+	decodedValue, err := decodeBase64(value)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, ErrParseArray)
+	}
+	values := strings.Split(decodedValue, ",")
+	if len(values) < length {
+		return fmt.Errorf("%v: %w", ErrInvalidFieldValues, ErrParseArray)
+	}
+	r.levels = append(r.levels, readerLevel{values: values})
+	return nil
+}
+
+func (r *Reader) EndList() {
+	r.levels = r.levels[:len(r.levels)-1]
+}
+
+// BeginMap starts reading a map and returns its number of entries and
+// whether it is nil. Keys and values are read alternating and the map is
+// closed with EndMap.
+func (r *Reader) BeginMap() (int, bool, error) {
+	value, err := r.next()
+	if err != nil {
+		return 0, false, err
+	}
+	if value == "" {
+		r.levels = append(r.levels, readerLevel{})
+		return 0, true, nil
+	}
+	if n, ok := parseEmptyList(value); ok && n == 0 {
+		r.levels = append(r.levels, readerLevel{})
+		return 0, false, nil
+	}
+	decodedValue, err := decodeBase64(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("%v: %w", err, ErrParseMap)
+	}
+	var values []string
+	for _, entry := range strings.Split(decodedValue, ",") {
+		keyValue, elemValue, found := strings.Cut(entry, ":")
+		if !found {
+			return 0, false, fmt.Errorf("%v: %w", ErrInvalidFieldValues, ErrParseMap)
+		}
+		values = append(values, keyValue, elemValue)
+	}
+	r.levels = append(r.levels, readerLevel{values: values})
+	return len(values) / 2, false, nil
+}
+
+func (r *Reader) EndMap() {
+	r.levels = r.levels[:len(r.levels)-1]
+}
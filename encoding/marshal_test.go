@@ -0,0 +1,312 @@
+package encoding
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Money is a hand written Marshaler and Unmarshaler
+type Money struct {
+	Currency string
+	Amount   float64
+}
+
+func (m Money) MarshalStorage() (string, error) {
+	if m.Currency == "invalid" {
+		return "", errors.New("invalid currency")
+	}
+	w := NewWriter()
+	defer w.Release()
+	w.WriteString(m.Currency)
+	w.WriteFloat(m.Amount, 64)
+	return w.Record(), nil
+}
+
+func (m *Money) UnmarshalStorage(record string) error {
+	r, err := NewReader(record, 2)
+	if err != nil {
+		return err
+	}
+	defer r.Release()
+	if m.Currency, err = r.ReadString(); err != nil {
+		return err
+	}
+	m.Amount, err = r.ReadFloat(64)
+	return err
+}
+
+// Invoice embeds Money in a record encoded through reflection
+type Invoice struct {
+	Id    string
+	Total Money
+	Lines []Money
+}
+
+func TestMarshalers(t *testing.T) {
+	money := Money{Currency: "INR", Amount: 10.5}
+	reflective, err := Encode(money, IgnoreMarshalers())
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	t.Run("marshaler is used", func(t *testing.T) {
+		encoded, err := Encode(Money{Currency: "invalid"})
+		if err == nil || err.Error() != "invalid currency" {
+			t.Errorf("Expected the error of MarshalStorage but got %v", err)
+		}
+		encoded, err = Encode(money)
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		if encoded != reflective {
+			t.Errorf("Expected %q but got %q", reflective, encoded)
+		}
+	})
+
+	t.Run("ignore marshalers", func(t *testing.T) {
+		if _, err := Encode(Money{Currency: "invalid"}, IgnoreMarshalers()); err != nil {
+			t.Errorf("Expected reflection to be used but got %v", err)
+		}
+		var decoded Money
+		if err := Decode(reflective, &decoded, IgnoreMarshalers()); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if decoded != money {
+			t.Errorf("Expected %+v but got %+v", money, decoded)
+		}
+	})
+
+	t.Run("nested marshalers", func(t *testing.T) {
+		invoice := Invoice{Id: "i-1", Total: money, Lines: []Money{money, {Currency: "USD"}}}
+		encoded, err := Encode(invoice)
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		expected, err := Encode(invoice, IgnoreMarshalers())
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		if encoded != expected {
+			t.Errorf("Expected %q but got %q", expected, encoded)
+		}
+
+		var decoded Invoice
+		if err := Decode(encoded, &decoded); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if !reflect.DeepEqual(decoded, invoice) {
+			t.Errorf("Expected %+v but got %+v", invoice, decoded)
+		}
+
+		_, err = Encode(Invoice{Total: Money{Currency: "invalid"}})
+		if err == nil {
+			t.Error("Expected an error from a nested MarshalStorage but got nil")
+		}
+	})
+
+	t.Run("unmarshaler errors", func(t *testing.T) {
+		record, _ := Encode(struct{ Total string }{"x"})
+		var decoded Invoice
+		err := Decode(record, &decoded)
+		if !errors.Is(err, ErrInvalidFieldValues) {
+			t.Errorf("Expected ErrInvalidFieldValues but got %v", err)
+		}
+		record, _ = Encode(struct{ Id, Total, Lines string }{"x", "not base64", ""})
+		err = Decode(record, &decoded)
+		if !errors.Is(err, ErrParseStruct) {
+			t.Errorf("Expected ErrParseStruct but got %v", err)
+		}
+	})
+}
+
+func TestWriterReader(t *testing.T) {
+	type Record struct {
+		Name    string
+		Count   int32
+		Size    uint8
+		Ratio   float32
+		Ok      bool
+		Parent  *string
+		Child   *NestedStruct
+		Values  []int
+		Pair    [2]bool
+		Lookup  map[string]uint
+		Missing []string
+		Empty   map[string]int
+	}
+	parent := "root"
+	record := Record{
+		Name:   "writer",
+		Count:  -3,
+		Size:   200,
+		Ratio:  0.25,
+		Ok:     true,
+		Parent: &parent,
+		Child:  &NestedStruct{Field1: "child", Field2: 9},
+		Values: []int{1, 2, 3},
+		Pair:   [2]bool{true, false},
+		Lookup: map[string]uint{"b": 2, "a": 1},
+		Empty:  map[string]int{},
+	}
+	expected, err := Encode(record)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	w := NewWriter()
+	defer w.Release()
+	w.WriteString(record.Name)
+	w.WriteInt(int64(record.Count))
+	w.WriteUint(uint64(record.Size))
+	w.WriteFloat(float64(record.Ratio), 32)
+	w.WriteBool(record.Ok)
+	w.WritePtr()
+	w.WriteString(*record.Parent)
+	w.WritePtr()
+	if err := w.WriteValue(record.Child); err != nil {
+		t.Fatalf("Failed to write value: %v", err)
+	}
+	w.BeginList(false)
+	for _, v := range record.Values {
+		w.WriteInt(int64(v))
+	}
+	w.EndList()
+	w.BeginList(false)
+	for _, v := range record.Pair {
+		w.WriteBool(v)
+	}
+	w.EndList()
+	w.BeginMap(false)
+	for _, k := range []string{"a", "b"} {
+		w.WriteString(k)
+		w.WriteUint(uint64(record.Lookup[k]))
+	}
+	w.EndMap()
+	w.BeginList(true)
+	w.EndList()
+	w.BeginMap(false)
+	w.EndMap()
+	if encoded := w.Record(); encoded != expected {
+		t.Fatalf("Expected %q but got %q", expected, encoded)
+	}
+
+	r, err := NewReader(expected, 12)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	defer r.Release()
+	var decoded Record
+	if decoded.Name, err = r.ReadString(); err != nil {
+		t.Fatalf("Failed to read string: %v", err)
+	}
+	count, _ := r.ReadInt(32)
+	decoded.Count = int32(count)
+	size, _ := r.ReadUint(8)
+	decoded.Size = uint8(size)
+	ratio, _ := r.ReadFloat(32)
+	decoded.Ratio = float32(ratio)
+	decoded.Ok, _ = r.ReadBool()
+	if isNil, _ := r.ReadPtr(); !isNil {
+		decoded.Parent = new(string)
+		*decoded.Parent, _ = r.ReadString()
+	}
+	if isNil, _ := r.ReadPtr(); !isNil {
+		decoded.Child = new(NestedStruct)
+		if err := r.ReadValue(decoded.Child); err != nil {
+			t.Fatalf("Failed to read value: %v", err)
+		}
+	}
+	n, _, err := r.BeginList()
+	if err != nil {
+		t.Fatalf("Failed to begin list: %v", err)
+	}
+	decoded.Values = make([]int, n)
+	for i := range decoded.Values {
+		v, _ := r.ReadInt(0)
+		decoded.Values[i] = int(v)
+	}
+	r.EndList()
+	if err := r.BeginArray(len(decoded.Pair)); err != nil {
+		t.Fatalf("Failed to begin array: %v", err)
+	}
+	for i := range decoded.Pair {
+		decoded.Pair[i], _ = r.ReadBool()
+	}
+	r.EndList()
+	n, _, err = r.BeginMap()
+	if err != nil {
+		t.Fatalf("Failed to begin map: %v", err)
+	}
+	decoded.Lookup = make(map[string]uint, n)
+	for i := 0; i < n; i++ {
+		k, _ := r.ReadString()
+		v, _ := r.ReadUint(0)
+		decoded.Lookup[k] = uint(v)
+	}
+	r.EndMap()
+	if _, isNil, _ := r.BeginList(); !isNil {
+		t.Error("Expected a nil list")
+	}
+	r.EndList()
+	if n, isNil, _ := r.BeginMap(); n != 0 || isNil {
+		t.Errorf("Expected an empty map but got %d entries, nil %v", n, isNil)
+	}
+	decoded.Empty = map[string]int{}
+	r.EndMap()
+	if !reflect.DeepEqual(decoded, record) {
+		t.Errorf("Expected %+v but got %+v", record, decoded)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	record, _ := Encode(struct {
+		A string
+		B string
+	}{"x", "-1"})
+
+	tests := []struct {
+		name string
+		read func(r *Reader) error
+		err  error
+	}{
+		{"int", func(r *Reader) error { _, err := r.ReadInt(0); return err }, ErrParseInt},
+		{"uint", func(r *Reader) error { _, err := r.ReadUint(0); return err }, ErrParseUint},
+		{"float", func(r *Reader) error { _, err := r.ReadFloat(64); return err }, ErrParseFloat},
+		{"bool", func(r *Reader) error { _, err := r.ReadBool(); return err }, ErrParseBool},
+		{"struct", func(r *Reader) error { return r.BeginStruct(1) }, ErrParseStruct},
+		{"array", func(r *Reader) error { return r.BeginArray(2) }, ErrParseArray},
+		{"past the end", func(r *Reader) error {
+			r.ReadString()
+			r.ReadString()
+			_, err := r.ReadString()
+			return err
+		}, ErrInvalidFieldValues},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(record, 2)
+			if err != nil {
+				t.Fatalf("Failed to create reader: %v", err)
+			}
+			defer r.Release()
+			if err := tt.read(r); !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v but got %v", tt.err, err)
+			}
+		})
+	}
+
+	t.Run("too few fields", func(t *testing.T) {
+		if _, err := NewReader(record, 3); !errors.Is(err, ErrInvalidFieldValues) {
+			t.Errorf("Expected ErrInvalidFieldValues but got %v", err)
+		}
+	})
+
+	t.Run("invalid base64", func(t *testing.T) {
+		if _, err := NewReader(strings.Repeat("!", 4), 1); err == nil {
+			t.Error("Expected an error but got nil")
+		}
+	})
+}
@@ -0,0 +1,600 @@
+package encoding
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A codec is the compiled plan to encode and decode values of one type. It
+// is built once per type by walking the type with reflection, after which
+// encoding and decoding only follow the precomputed field indices and kind
+// handlers.
+type codec struct {
+	encode encodeFunc
+	decode decodeFunc
+	// fields holds the plan of every encoded field of a struct type
+	fields []fieldCodec
+}
+
+// encodeFunc appends the encoding of v to the encode state
+type encodeFunc func(e *encodeState, v reflect.Value) error
+
+// decodeFunc decodes value into the settable v
+type decodeFunc func(v reflect.Value, value string, o *options) error
+
+type fieldCodec struct {
+	index []int
+	// name is the name of the field, promoted fields have their own name
+	name  string
+	codec *codec
+}
+
+// codecKey identifies a compiled codec. Options that change which fields a
+// type is made of are part of the key, options that only change how values
+// are written are read from the encode state instead.
+type codecKey struct {
+	t                 reflect.Type
+	includeUnexported bool
+	useMarshalers     bool
+}
+
+// codecs caches compiled codecs, it is safe for concurrent use
+var codecs sync.Map // map[codecKey]*codec
+
+// codecFor returns the cached codec of t, compiling it on first use
+func codecFor(t reflect.Type, o *options) *codec {
+	key := codecKey{t: t, includeUnexported: o.includeUnexported, useMarshalers: o.useMarshalers()}
+	if c, ok := codecs.Load(key); ok {
+		return c.(*codec)
+	}
+	c, _ := codecs.LoadOrStore(key, compile(t, o, make(map[reflect.Type]*codec)))
+	return c.(*codec)
+}
+
+// compile builds the codec of t. Codecs under construction are tracked in
+// seen so that recursive types resolve to the same codec instead of looping.
+func compile(t reflect.Type, o *options, seen map[reflect.Type]*codec) *codec {
+	if c, ok := seen[t]; ok {
+		return c
+	}
+	c := &codec{}
+	seen[t] = c
+
+	switch t.Kind() {
+	case reflect.String:
+		c.encode, c.decode = encodeString, decodeString
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		c.encode, c.decode = encodeInt, decodeInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		c.encode, c.decode = encodeUint, decodeUint
+	case reflect.Float32, reflect.Float64:
+		c.encode, c.decode = encodeFloat, decodeFloat
+	case reflect.Bool:
+		c.encode, c.decode = encodeBool, decodeBool
+	case reflect.Struct:
+		for _, index := range structFields(t, o) {
+			field := t.FieldByIndex(index)
+			c.fields = append(c.fields, fieldCodec{index: index, name: field.Name, codec: compile(field.Type, o, seen)})
+		}
+		c.encode, c.decode = c.encodeStruct, c.decodeStruct
+		if o.useMarshalers() && t.Implements(marshalerType) {
+			c.encode = encodeMarshaler
+		}
+		if o.useMarshalers() && reflect.PointerTo(t).Implements(unmarshalerType) {
+			c.decode = decodeUnmarshaler
+		}
+	case reflect.Array:
+		elem := compile(t.Elem(), o, seen)
+		c.encode, c.decode = elem.encodeElems, elem.decodeArray
+	case reflect.Slice:
+		elem := compile(t.Elem(), o, seen)
+		c.encode, c.decode = elem.encodeSlice, elem.decodeSlice
+	case reflect.Map:
+		c.encode = encodeMap(compile(t.Key(), o, seen), compile(t.Elem(), o, seen))
+		c.decode = decodeMap(compile(t.Key(), o, seen), compile(t.Elem(), o, seen))
+	case reflect.Ptr:
+		elem := compile(t.Elem(), o, seen)
+		c.encode, c.decode = elem.encodePtr, elem.decodePtr
+	case reflect.Interface:
+		c.encode, c.decode = encodeInterface, decodeInterface
+	default:
+		c.encode, c.decode = encodeUnsupported, decodeUnsupported
+	}
+	return c
+}
+
+// encodeState holds the output buffer of one Encode call, states are pooled
+// so their buffers are reused across calls
+type encodeState struct {
+	buf     []byte
+	scratch []byte
+	o       *options
+}
+
+var encodeStatePool = sync.Pool{
+	New: func() interface{} { return &encodeState{} },
+}
+
+func newEncodeState(o *options) *encodeState {
+	e := encodeStatePool.Get().(*encodeState)
+	e.buf = e.buf[:0]
+	e.o = o
+	return e
+}
+
+func (e *encodeState) release() {
+	e.o = nil
+	encodeStatePool.Put(e)
+}
+
+// base64From replaces everything written to the buffer since start with its
+// base64 encoding, which is how nested values are kept free of separators
+func (e *encodeState) base64From(start int) {
+	e.scratch = append(e.scratch[:0], e.buf[start:]...)
+	n := base64.StdEncoding.EncodedLen(len(e.scratch))
+	if cap(e.buf) < start+n {
+		buf := make([]byte, start, 2*cap(e.buf)+n)
+		copy(buf, e.buf[:start])
+		e.buf = buf
+	}
+	e.buf = e.buf[:start+n]
+	base64.StdEncoding.Encode(e.buf[start:], e.scratch)
+}
+
+func encodeString(e *encodeState, v reflect.Value) error {
+	e.buf = appendEscaped(e.buf, v.String())
+	return nil
+}
+
+// escapedBytes are the separators of the format, strings escape them as
+// %XX so that a value cannot split the record it is written in
+const escapedBytes = "%,:"
+
+const hexDigits = "0123456789ABCDEF"
+
+func appendEscaped(buf []byte, s string) []byte {
+	if !strings.ContainsAny(s, escapedBytes) {
+		return append(buf, s...)
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if strings.IndexByte(escapedBytes, c) < 0 {
+			buf = append(buf, c)
+			continue
+		}
+		buf = append(buf, '%', hexDigits[c>>4], hexDigits[c&0xF])
+	}
+	return buf
+}
+
+// unescape reverses appendEscaped, only the escapes it writes are valid
+func unescape(s string) (string, error) {
+	if strings.IndexByte(s, '%') < 0 {
+		return s, nil
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("truncated escape %q: %w", s[i:], ErrParseString)
+		}
+		hi, lo := strings.IndexByte(hexDigits, s[i+1]), strings.IndexByte(hexDigits, s[i+2])
+		if hi < 0 || lo < 0 || strings.IndexByte(escapedBytes, byte(hi<<4|lo)) < 0 {
+			return "", fmt.Errorf("invalid escape %q: %w", s[i:i+3], ErrParseString)
+		}
+		b.WriteByte(byte(hi<<4 | lo))
+		i += 2
+	}
+	return b.String(), nil
+}
+
+func encodeInt(e *encodeState, v reflect.Value) error {
+	e.buf = strconv.AppendInt(e.buf, v.Int(), 10)
+	return nil
+}
+
+func encodeUint(e *encodeState, v reflect.Value) error {
+	e.buf = strconv.AppendUint(e.buf, v.Uint(), 10)
+	return nil
+}
+
+func encodeFloat(e *encodeState, v reflect.Value) error {
+	floatValue := v.Float()
+	if e.o.canonical {
+		floatValue = canonicalFloat(floatValue)
+	}
+	e.buf = strconv.AppendFloat(e.buf, floatValue, 'g', -1, v.Type().Bits())
+	return nil
+}
+
+func encodeBool(e *encodeState, v reflect.Value) error {
+	e.buf = strconv.AppendBool(e.buf, v.Bool())
+	return nil
+}
+
+func encodeUnsupported(e *encodeState, v reflect.Value) error {
+	return ErrUnsupportedType
+}
+
+// encodeFields writes the fields of a struct separated by commas
+func (c *codec) encodeFields(e *encodeState, v reflect.Value) error {
+	if e.o.includeUnexported && !v.CanAddr() {
+		// unexported fields can only be read from an addressable struct
+		addressable := reflect.New(v.Type()).Elem()
+		addressable.Set(v)
+		v = addressable
+	}
+	for i, field := range c.fields {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		if err := field.codec.encode(e, fieldByIndex(v, field.index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *codec) encodeStruct(e *encodeState, v reflect.Value) error {
+	start := len(e.buf)
+	if err := c.encodeFields(e, v); err != nil {
+		return err
+	}
+	e.base64From(start)
+	return nil
+}
+
+// encodeElems encodes the elements of an array or slice, c is the codec of
+// the element type
+func (c *codec) encodeElems(e *encodeState, v reflect.Value) error {
+	start := len(e.buf)
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		if err := c.encode(e, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	e.base64From(start)
+	return nil
+}
+
+// encodeSlice encodes a slice, a nil slice is empty and a non-nil one whose
+// elements join to an empty string is written as an empty list
+func (c *codec) encodeSlice(e *encodeState, v reflect.Value) error {
+	if v.IsNil() {
+		return nil
+	}
+	start := len(e.buf)
+	if err := c.encodeElems(e, v); err != nil {
+		return err
+	}
+	if len(e.buf) == start {
+		e.buf = appendEmptyList(e.buf, v.Len())
+	}
+	return nil
+}
+
+// Non-nil slices and maps whose elements join to an empty string, that is
+// empty ones and slices of a single element encoded as "", cannot be told
+// apart from nil or from each other. They are written as the pointer marker
+// followed by their length instead.
+func appendEmptyList(buf []byte, n int) []byte {
+	buf = append(buf, ptrMarker...)
+	return strconv.AppendInt(buf, int64(n), 10)
+}
+
+// parseEmptyList returns the length of a list written by appendEmptyList
+func parseEmptyList(value string) (int, bool) {
+	switch value {
+	case ptrMarker + "0":
+		return 0, true
+	case ptrMarker + "1":
+		return 1, true
+	}
+	return 0, false
+}
+
+// encodeMap encodes map entries in key order so that encoding the same map
+// always yields the same output
+func encodeMap(key, elem *codec) encodeFunc {
+	return func(e *encodeState, v reflect.Value) error {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Len() == 0 {
+			e.buf = appendEmptyList(e.buf, 0)
+			return nil
+		}
+		entries := make([]mapEntry, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			start := len(e.buf)
+			if err := key.encode(e, iter.Key()); err != nil {
+				return err
+			}
+			keyValue := string(e.buf[start:])
+			e.buf = e.buf[:start]
+			if err := elem.encode(e, iter.Value()); err != nil {
+				return err
+			}
+			fieldValue := string(e.buf[start:])
+			e.buf = e.buf[:start]
+			entries = append(entries, mapEntry{key: iter.Key(), keyValue: keyValue, fieldValue: fieldValue})
+		}
+		sortMapEntries(entries)
+
+		start := len(e.buf)
+		for i, entry := range entries {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			e.buf = append(e.buf, entry.keyValue...)
+			e.buf = append(e.buf, ':')
+			e.buf = append(e.buf, entry.fieldValue...)
+		}
+		e.base64From(start)
+		return nil
+	}
+}
+
+// ptrMarker prefixes the value of every non-nil pointer so that a nil
+// pointer and a pointer to a zero value encode differently
+const ptrMarker = "&"
+
+// encodePtr encodes a pointer, c is the codec of the pointed type
+func (c *codec) encodePtr(e *encodeState, v reflect.Value) error {
+	if v.IsNil() {
+		return nil
+	}
+	e.buf = append(e.buf, ptrMarker...)
+	return c.encode(e, v.Elem())
+}
+
+// encodeInterface encodes the dynamic value of an interface prefixed with
+// the name its concrete type was registered under
+func encodeInterface(e *encodeState, v reflect.Value) error {
+	if v.IsNil() {
+		return nil
+	}
+	concrete := v.Elem()
+	name, ok := registeredName(concrete.Type())
+	if !ok {
+		return fmt.Errorf("%v: %w", concrete.Type(), ErrUnregisteredType)
+	}
+	start := len(e.buf)
+	e.buf = append(e.buf, name...)
+	e.buf = append(e.buf, ':')
+	if err := codecFor(concrete.Type(), e.o).encode(e, concrete); err != nil {
+		return err
+	}
+	e.base64From(start)
+	return nil
+}
+
+// decodeBase64 decodes the base64 encoded value of a nested type
+func decodeBase64(value string) (string, error) {
+	decodedValue, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("%v: %w", err, ErrBase64Decoding)
+	}
+	return string(decodedValue), nil
+}
+
+func decodeString(v reflect.Value, value string, o *options) error {
+	stringValue, err := unescape(value)
+	if err != nil {
+		return err
+	}
+	v.SetString(stringValue)
+	return nil
+}
+
+func decodeInt(v reflect.Value, value string, o *options) error {
+	intValue, err := strconv.ParseInt(value, 10, v.Type().Bits())
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, ErrParseInt)
+	}
+	v.SetInt(intValue)
+	return nil
+}
+
+func decodeUint(v reflect.Value, value string, o *options) error {
+	uintValue, err := strconv.ParseUint(value, 10, v.Type().Bits())
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, ErrParseUint)
+	}
+	v.SetUint(uintValue)
+	return nil
+}
+
+func decodeFloat(v reflect.Value, value string, o *options) error {
+	floatValue, err := strconv.ParseFloat(value, v.Type().Bits())
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, ErrParseFloat)
+	}
+	v.SetFloat(floatValue)
+	return nil
+}
+
+func decodeBool(v reflect.Value, value string, o *options) error {
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, ErrParseBool)
+	}
+	v.SetBool(boolValue)
+	return nil
+}
+
+func decodeUnsupported(v reflect.Value, value string, o *options) error {
+	return ErrUnsupportedType
+}
+
+// decodeFields decodes the comma separated fields of a struct into v
+func (c *codec) decodeFields(v reflect.Value, value string, o *options) error {
+	fieldValues := strings.Split(value, ",")
+	if len(fieldValues) < len(c.fields) {
+		return ErrInvalidFieldValues
+	}
+	for i, field := range c.fields {
+		if err := field.codec.decode(fieldByIndex(v, field.index), fieldValues[i], o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *codec) decodeStruct(v reflect.Value, value string, o *options) error {
+	decodedValue, err := decodeBase64(value)
+	if err == nil {
+		err = c.decodeFields(v, decodedValue, o)
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, ErrParseStruct)
+	}
+	return nil
+}
+
+// decodeArray decodes an array, c is the codec of the element type
+func (c *codec) decodeArray(v reflect.Value, value string, o *options) error {
+	decodedValue, err := decodeBase64(value)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, ErrParseArray)
+	}
+	fieldValues := strings.Split(decodedValue, ",")
+	if len(fieldValues) < v.Len() {
+		return fmt.Errorf("%v: %w", ErrInvalidFieldValues, ErrParseArray)
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := c.decode(v.Index(i), fieldValues[i], o); err != nil {
+			return fmt.Errorf("%v: %w", err, ErrParseArray)
+		}
+	}
+	return nil
+}
+
+// decodeSlice decodes a slice, c is the codec of the element type
+func (c *codec) decodeSlice(v reflect.Value, value string, o *options) error {
+	if value == "" {
+		// only nil slices are written empty
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	var fieldValues []string
+	if n, ok := parseEmptyList(value); ok {
+		fieldValues = make([]string, n)
+	} else {
+		decodedValue, err := decodeBase64(value)
+		if err != nil {
+			return fmt.Errorf("%v: %w", err, ErrParseSlice)
+		}
+		fieldValues = strings.Split(decodedValue, ",")
+	}
+	slice := reflect.MakeSlice(v.Type(), len(fieldValues), len(fieldValues))
+	for i, fieldValue := range fieldValues {
+		if err := c.decode(slice.Index(i), fieldValue, o); err != nil {
+			return fmt.Errorf("%v: %w", err, ErrParseSlice)
+		}
+	}
+	v.Set(slice)
+	return nil
+}
+
+// decodeMap decodes a map of key:value entries
+func decodeMap(key, elem *codec) decodeFunc {
+	return func(v reflect.Value, value string, o *options) error {
+		mapType := v.Type()
+		if value == "" {
+			v.Set(reflect.Zero(mapType))
+			return nil
+		}
+		if n, ok := parseEmptyList(value); ok && n == 0 {
+			v.Set(reflect.MakeMap(mapType))
+			return nil
+		}
+		decodedValue, err := decodeBase64(value)
+		if err != nil {
+			return fmt.Errorf("%v: %w", err, ErrParseMap)
+		}
+		m := reflect.MakeMap(mapType)
+		for _, fieldValue := range strings.Split(decodedValue, ",") {
+			// split the key and value
+			keyValue, elemValue, found := strings.Cut(fieldValue, ":")
+			if !found {
+				return fmt.Errorf("%v: %w", ErrInvalidFieldValues, ErrParseMap)
+			}
+			k := reflect.New(mapType.Key()).Elem()
+			if err := key.decode(k, keyValue, o); err != nil {
+				return fmt.Errorf("%v: %w", err, ErrParseMap)
+			}
+			if !k.Comparable() {
+				return fmt.Errorf("unhashable key %v: %w", k.Elem().Type(), ErrParseMap)
+			}
+			e := reflect.New(mapType.Elem()).Elem()
+			if err := elem.decode(e, elemValue, o); err != nil {
+				return fmt.Errorf("%v: %w", err, ErrParseMap)
+			}
+			m.SetMapIndex(k, e)
+		}
+		v.Set(m)
+		return nil
+	}
+}
+
+// decodePtr decodes a pointer, an empty value is a nil pointer and anything
+// else carries the pointer marker followed by the pointed value. c is the
+// codec of the pointed type.
+func (c *codec) decodePtr(v reflect.Value, value string, o *options) error {
+	if value == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	value, found := strings.CutPrefix(value, ptrMarker)
+	if !found {
+		return fmt.Errorf("%v: %w", ErrInvalidFieldValues, ErrParsePtr)
+	}
+	ptr := reflect.New(v.Type().Elem())
+	if err := c.decode(ptr.Elem(), value, o); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrParsePtr)
+	}
+	v.Set(ptr)
+	return nil
+}
+
+// decodeInterface decodes a registered concrete value into an interface
+func decodeInterface(v reflect.Value, value string, o *options) error {
+	if value == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	decodedValue, err := decodeBase64(value)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, ErrParseInterface)
+	}
+	name, concreteValue, found := strings.Cut(decodedValue, ":")
+	if !found {
+		return fmt.Errorf("%v: %w", ErrInvalidFieldValues, ErrParseInterface)
+	}
+	concreteType, ok := registeredType(name)
+	if !ok {
+		return fmt.Errorf("%q: %v: %w", name, ErrUnregisteredType, ErrParseInterface)
+	}
+	if !concreteType.AssignableTo(v.Type()) {
+		return fmt.Errorf("%v does not implement %v: %w", concreteType, v.Type(), ErrParseInterface)
+	}
+	concrete := reflect.New(concreteType).Elem()
+	if err := codecFor(concreteType, o).decode(concrete, concreteValue, o); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrParseInterface)
+	}
+	v.Set(concrete)
+	return nil
+}
@@ -0,0 +1,100 @@
+package encoding
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var ErrUnknownField = errors.New("unknown field")
+
+// Fields makes Decode decode only the named fields of a record. The other
+// fields are skipped without being decoded and keep the value they had, the
+// record is only read up to the last named field. Fields of embedded
+// structs are named by their promoted name.
+func Fields(names ...string) Option {
+	return func(o *options) {
+		o.fields = append([]string{}, names...)
+	}
+}
+
+// decodeProjected decodes the fields named by the options into v
+func (c *codec) decodeProjected(v reflect.Value, value string, o *options) error {
+	wanted := make([]bool, len(c.fields))
+	last := -1
+	for _, name := range o.fields {
+		found := false
+		for i, field := range c.fields {
+			if field.name == name {
+				wanted[i], found = true, true
+				if i > last {
+					last = i
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("%q: %w", name, ErrUnknownField)
+		}
+	}
+
+	rest := value
+	for i := 0; i <= last; i++ {
+		fieldValue := rest
+		if j := strings.IndexByte(rest, ','); j >= 0 {
+			fieldValue, rest = rest[:j], rest[j+1:]
+		} else if i < last {
+			return ErrInvalidFieldValues
+		}
+		if !wanted[i] {
+			continue
+		}
+		field := c.fields[i]
+		if err := field.codec.decode(fieldByIndex(v, field.index), fieldValue, o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeMap decodes a record of the struct type of recordType into a map
+// of field names to values. With the Fields option only the named fields
+// are decoded and present in the map.
+func DecodeMap(record string, recordType interface{}, opts ...Option) (map[string]interface{}, error) {
+	t := reflect.TypeOf(recordType)
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, ErrUnsupportedType
+	}
+	o := newOptions(opts)
+	// the map is built from the fields of the codec, generated methods are
+	// not used so that both agree on the fields
+	o.ignoreMarshalers = true
+	decodedRecord, err := base64.StdEncoding.DecodeString(record)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrBase64Decoding)
+	}
+	v := reflect.New(t)
+	if err := decodeRecord(string(decodedRecord), v.Interface(), o); err != nil {
+		return nil, err
+	}
+
+	c := codecFor(t, o)
+	values := make(map[string]interface{}, len(c.fields))
+	for _, field := range c.fields {
+		if o.fields != nil && !contains(o.fields, field.name) {
+			continue
+		}
+		values[field.name] = fieldByIndex(v.Elem(), field.index).Interface()
+	}
+	return values, nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package encoding
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Document struct {
+	Name string
+	Blob []byte
+	Size int
+	Tags map[string]string
+}
+
+func TestFields(t *testing.T) {
+	doc := Document{Name: "report", Blob: []byte(strings.Repeat("x", 64)), Size: 64, Tags: map[string]string{"kind": "pdf"}}
+	record, err := Encode(doc)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	t.Run("subset", func(t *testing.T) {
+		got := Document{Blob: []byte("kept")}
+		if err := Decode(record, &got, Fields("Size", "Name")); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		expected := Document{Name: "report", Blob: []byte("kept"), Size: 64}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %+v but got %+v", expected, got)
+		}
+	})
+
+	t.Run("skipped fields are not decoded", func(t *testing.T) {
+		// the blob and tags are not valid base64, only a full decode sees it
+		corrupt := base64.StdEncoding.EncodeToString([]byte("report,%%%,64,%%%"))
+		var got Document
+		if err := Decode(corrupt, &got); !errors.Is(err, ErrParseSlice) {
+			t.Fatalf("Expected %v but got %v", ErrParseSlice, err)
+		}
+		got = Document{}
+		if err := Decode(corrupt, &got, Fields("Name", "Size")); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if got.Name != "report" || got.Size != 64 {
+			t.Errorf("Expected report of size 64 but got %+v", got)
+		}
+	})
+
+	t.Run("record read up to the last field", func(t *testing.T) {
+		truncated := base64.StdEncoding.EncodeToString([]byte("report"))
+		var got Document
+		if err := Decode(truncated, &got, Fields("Name")); err != nil || got.Name != "report" {
+			t.Errorf("Expected report but got %+v, %v", got, err)
+		}
+		if err := Decode(truncated, &got, Fields("Size")); !errors.Is(err, ErrInvalidFieldValues) {
+			t.Errorf("Expected %v but got %v", ErrInvalidFieldValues, err)
+		}
+	})
+
+	t.Run("promoted fields", func(t *testing.T) {
+		data := Embedded{Base: Base{Id: "1", Version: 2}, Name: "embedded", NestedStruct: &NestedStruct{Field1: "nested"}}
+		record, err := Encode(data)
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		var got Embedded
+		if err := Decode(record, &got, Fields("Version", "NestedStruct")); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		expected := Embedded{Base: Base{Version: 2}, NestedStruct: &NestedStruct{Field1: "nested"}}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %+v but got %+v", expected, got)
+		}
+	})
+
+	t.Run("unmarshalers are skipped", func(t *testing.T) {
+		record, err := Encode(Money{Currency: "INR", Amount: 10.5})
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		var got Money
+		if err := Decode(record, &got, Fields("Amount")); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if got != (Money{Amount: 10.5}) {
+			t.Errorf("Expected only the amount but got %+v", got)
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		var got Document
+		if err := Decode(record, &got, Fields("Name", "Missing")); !errors.Is(err, ErrUnknownField) {
+			t.Errorf("Expected %v but got %v", ErrUnknownField, err)
+		}
+	})
+}
+
+func TestDecodeMap(t *testing.T) {
+	doc := Document{Name: "report", Size: 64}
+	record, err := Encode(doc)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected map[string]interface{}
+	}{
+		{"all fields", nil, map[string]interface{}{"Name": "report", "Blob": []byte(nil), "Size": 64, "Tags": map[string]string(nil)}},
+		{"subset", []Option{Fields("Size")}, map[string]interface{}{"Size": 64}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeMap(record, Document{}, tt.opts...)
+			if err != nil {
+				t.Fatalf("Failed to decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v but got %v", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("unexported fields", func(t *testing.T) {
+		record, err := Encode(WithUnexported{Name: "public", secret: "s"}, IncludeUnexported())
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		got, err := DecodeMap(record, WithUnexported{}, IncludeUnexported(), Fields("secret"))
+		if err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if !reflect.DeepEqual(got, map[string]interface{}{"secret": "s"}) {
+			t.Errorf("Expected the secret but got %v", got)
+		}
+	})
+
+	if _, err := DecodeMap(record, &Document{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected %v but got %v", ErrUnsupportedType, err)
+	}
+}
+
+func BenchmarkDecode_Fields(b *testing.B) {
+	record, err := Encode(Document{Name: "report", Blob: make([]byte, 1<<16), Size: 1 << 16})
+	if err != nil {
+		b.Fatalf("Failed to encode: %v", err)
+	}
+	b.Run("all", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var doc Document
+			_ = Decode(record, &doc)
+		}
+	})
+	b.Run("name", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var doc Document
+			_ = Decode(record, &doc, Fields("Name"))
+		}
+	})
+}
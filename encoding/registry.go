@@ -0,0 +1,73 @@
+package encoding
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// registry maps the names used as type discriminators for interface values
+// to their concrete types and back.
+var registry = struct {
+	sync.RWMutex
+	types map[string]reflect.Type
+	names map[reflect.Type]string
+}{
+	types: make(map[string]reflect.Type),
+	names: make(map[reflect.Type]string),
+}
+
+// RegisterName records a concrete type under the given name so that values
+// of that type can be stored in interface-typed fields. The name is written
+// next to the encoded value and used on decode to rebuild the concrete type.
+//
+// Like encoding/gob it panics when the name or the type is already registered
+// with a different counterpart, registrations are expected to happen in init.
+func RegisterName(name string, value interface{}) {
+	if name == "" || strings.ContainsAny(name, ":,") {
+		panic(fmt.Sprintf("encoding: invalid registration name %q", name))
+	}
+	if value == nil {
+		panic(errNilRegistration)
+	}
+	t := reflect.TypeOf(value)
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if registered, ok := registry.types[name]; ok && registered != t {
+		panic(fmt.Sprintf("encoding: registering duplicate types for %q: %s != %s", name, registered, t))
+	}
+	if registered, ok := registry.names[t]; ok && registered != name {
+		panic(fmt.Sprintf("encoding: registering duplicate names for %s: %q != %q", t, registered, name))
+	}
+	registry.types[name] = t
+	registry.names[t] = name
+}
+
+// errNilRegistration is the panic of registering a nil value
+const errNilRegistration = "encoding: cannot register nil value"
+
+// Register records a concrete type under its qualified type name,
+// e.g. "main.OrderPlaced" or "*main.OrderPlaced".
+func Register(value interface{}) {
+	if value == nil {
+		panic(errNilRegistration)
+	}
+	RegisterName(reflect.TypeOf(value).String(), value)
+}
+
+func registeredName(t reflect.Type) (string, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	name, ok := registry.names[t]
+	return name, ok
+}
+
+func registeredType(name string) (reflect.Type, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	t, ok := registry.types[name]
+	return t, ok
+}
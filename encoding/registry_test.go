@@ -0,0 +1,185 @@
+package encoding
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type Event interface {
+	EventName() string
+}
+
+type OrderPlaced struct {
+	OrderId string
+	Amount  float64
+}
+
+func (OrderPlaced) EventName() string { return "OrderPlaced" }
+
+type OrderShipped struct {
+	OrderId string
+	Carrier string
+	Tracked bool
+}
+
+func (*OrderShipped) EventName() string { return "OrderShipped" }
+
+type OrderFailed struct {
+	Reason string
+}
+
+func (e OrderFailed) Error() string { return e.Reason }
+
+type Unregistered struct {
+	Field string
+}
+
+func (Unregistered) EventName() string { return "Unregistered" }
+
+func init() {
+	RegisterName("OrderPlaced", OrderPlaced{})
+	RegisterName("OrderShipped", &OrderShipped{})
+	Register(OrderFailed{})
+	Register("")
+	Register(0)
+}
+
+type EventRecord struct {
+	Id      string
+	Payload Event
+	Err     error
+	Meta    interface{}
+	History []Event
+	Tags    map[string]interface{}
+}
+
+func TestInterfaceFields(t *testing.T) {
+	t.Run("successful encode and decode", func(t *testing.T) {
+		tests := []struct {
+			name string
+			data EventRecord
+		}{
+			{
+				name: "nil interfaces",
+				data: EventRecord{Id: "1"},
+			},
+			{
+				name: "value type",
+				data: EventRecord{Id: "2", Payload: OrderPlaced{OrderId: "o-1", Amount: 9.5}},
+			},
+			{
+				name: "pointer type",
+				data: EventRecord{Id: "3", Payload: &OrderShipped{OrderId: "o-1", Carrier: "ups", Tracked: true}},
+			},
+			{
+				name: "error and empty interface",
+				data: EventRecord{Id: "4", Err: OrderFailed{Reason: "out of stock"}, Meta: "note"},
+			},
+			{
+				name: "heterogeneous slice and map",
+				data: EventRecord{
+					Id: "5",
+					History: []Event{
+						OrderPlaced{OrderId: "o-2", Amount: 1},
+						&OrderShipped{OrderId: "o-2", Carrier: "dhl"},
+					},
+					Tags: map[string]interface{}{"a": 1, "b": "two", "c": OrderPlaced{OrderId: "o-3"}},
+				},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				encodedData, err := Encode(tt.data)
+				if err != nil {
+					t.Fatalf("Failed to encode data: %v", err)
+				}
+				var decodedData EventRecord
+				err = Decode(encodedData, &decodedData)
+				if err != nil {
+					t.Fatalf("Failed to decode data: %v", err)
+				}
+				if !reflect.DeepEqual(tt.data, decodedData) {
+					t.Errorf("Decoded data does not match original data.\nExpected: %+v\nGot: %+v", tt.data, decodedData)
+				}
+			})
+		}
+	})
+
+	t.Run("encode fails", func(t *testing.T) {
+		t.Run("unregistered concrete type", func(t *testing.T) {
+			_, err := Encode(EventRecord{Payload: Unregistered{Field: "x"}})
+			if !errors.Is(err, ErrUnregisteredType) {
+				t.Errorf("Expected error %v but got %v", ErrUnregisteredType, err)
+			}
+		})
+	})
+
+	t.Run("decode fails", func(t *testing.T) {
+		t.Run("concrete type does not implement field type", func(t *testing.T) {
+			type Source struct {
+				Payload interface{}
+			}
+			type Target struct {
+				Payload Event
+			}
+			encodedData, err := Encode(Source{Payload: OrderFailed{Reason: "x"}})
+			if err != nil {
+				t.Fatalf("Failed to encode data: %v", err)
+			}
+			var decodedData Target
+			err = Decode(encodedData, &decodedData)
+			if !errors.Is(err, ErrParseInterface) {
+				t.Errorf("Expected error %v but got %v", ErrParseInterface, err)
+			}
+		})
+		t.Run("unknown type name", func(t *testing.T) {
+			type Target struct {
+				Payload Event
+			}
+			// a single field holding "Missing:x"
+			encodedData, _ := Encode(struct{ Payload string }{Payload: "TWlzc2luZzp4"})
+			var decodedData Target
+			err := Decode(encodedData, &decodedData)
+			if !errors.Is(err, ErrParseInterface) {
+				t.Errorf("Expected error %v but got %v", ErrParseInterface, err)
+			}
+		})
+	})
+}
+
+func TestRegisterName(t *testing.T) {
+	expectPanic := func(t *testing.T, f func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected panic")
+			}
+		}()
+		f()
+	}
+
+	t.Run("same registration twice is allowed", func(t *testing.T) {
+		RegisterName("OrderPlaced", OrderPlaced{})
+	})
+	t.Run("duplicate name", func(t *testing.T) {
+		expectPanic(t, func() { RegisterName("OrderPlaced", OrderFailed{}) })
+	})
+	t.Run("duplicate type", func(t *testing.T) {
+		expectPanic(t, func() { RegisterName("Placed", OrderPlaced{}) })
+	})
+	t.Run("invalid name", func(t *testing.T) {
+		expectPanic(t, func() { RegisterName("Order:Placed", Unregistered{}) })
+	})
+	t.Run("nil value", func(t *testing.T) {
+		expectPanic(t, func() { RegisterName("Nil", nil) })
+	})
+	t.Run("nil value by type name", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r != errNilRegistration {
+				t.Errorf("Expected %q but got %v", errNilRegistration, r)
+			}
+		}()
+		Register(nil)
+	})
+}
@@ -0,0 +1,117 @@
+package encoding
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// maxFrameSize bounds the length read from a frame header so that a corrupt
+// stream cannot make the decoder allocate arbitrary amounts of memory.
+const maxFrameSize = 64 << 20
+
+// An Encoder writes a stream of records of one type to an io.Writer. Every
+// record is written as a frame holding its uvarint encoded length followed
+// by the same bytes Encode returns for it.
+//
+// Writes are buffered, Flush must be called once the last record is encoded.
+type Encoder struct {
+	w          *bufio.Writer
+	o          *options
+	recordType reflect.Type
+	header     [binary.MaxVarintLen64]byte
+}
+
+// NewEncoder returns an Encoder writing to w with the given options.
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w), o: newOptions(opts)}
+}
+
+// Encode writes record to the stream. The first record fixes the type of
+// the stream, records of any other type are rejected with ErrRecordType.
+func (e *Encoder) Encode(record interface{}) error {
+	recordType := reflect.TypeOf(record)
+	if recordType == nil || recordType.Kind() != reflect.Struct {
+		return ErrUnsupportedType
+	}
+	if e.recordType == nil {
+		e.recordType = recordType
+	} else if recordType != e.recordType {
+		return fmt.Errorf("%v is not %v: %w", recordType, e.recordType, ErrRecordType)
+	}
+
+	state := newEncodeState(e.o)
+	defer state.release()
+	if err := codecFor(recordType, e.o).encode(state, reflect.ValueOf(record)); err != nil {
+		return err
+	}
+	n := binary.PutUvarint(e.header[:], uint64(len(state.buf)))
+	if _, err := e.w.Write(e.header[:n]); err != nil {
+		return err
+	}
+	_, err := e.w.Write(state.buf)
+	return err
+}
+
+// Flush writes any buffered records to the underlying io.Writer.
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+// A Decoder reads a stream of records written by an Encoder. The frame and
+// base64 buffers are reused between records.
+type Decoder struct {
+	r       *bufio.Reader
+	o       *options
+	frame   []byte
+	decoded []byte
+}
+
+// NewDecoder returns a Decoder reading from r with the given options, they
+// must match the options the stream was encoded with.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), o: newOptions(opts)}
+}
+
+// Decode reads the next record from the stream into data, which must be a
+// pointer to the record type. It returns io.EOF when the stream ends cleanly
+// between records and io.ErrUnexpectedEOF when it ends inside one.
+func (d *Decoder) Decode(data interface{}) error {
+	size, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return err
+	}
+	if size > maxFrameSize {
+		return fmt.Errorf("frame of %d bytes: %w", size, ErrInvalidFrame)
+	}
+
+	d.frame = grow(d.frame, int(size))
+	if _, err := io.ReadFull(d.r, d.frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	if u, ok := data.(Unmarshaler); ok && d.o.useMarshalers() {
+		return u.UnmarshalStorage(string(d.frame))
+	}
+	d.decoded = grow(d.decoded, base64.StdEncoding.DecodedLen(len(d.frame)))
+	n, err := base64.StdEncoding.Decode(d.decoded, d.frame)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, ErrBase64Decoding)
+	}
+	return decodeRecord(string(d.decoded[:n]), data, d.o)
+}
+
+// grow returns buf resliced to n bytes, reallocating only when its capacity
+// is too small
+func grow(buf []byte, n int) []byte {
+	if cap(buf) < n {
+		return make([]byte, n)
+	}
+	return buf[:n]
+}
@@ -0,0 +1,140 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	newRecord := func(i int) MyStruct {
+		return MyStruct{
+			StringField: fmt.Sprintf("record %d", i),
+			IntField:    i,
+			FloatField:  float64(i) / 2,
+			BoolField:   i%2 == 0,
+			ArrayField:  [3]int{i, i + 1, i + 2},
+			SliceField:  []string{"a", "b"},
+			MapField:    map[string]int{"i": i},
+			StructField: NestedStruct{Field1: "nested", Field2: int64(i)},
+		}
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		var buf bytes.Buffer
+		encoder := NewEncoder(&buf)
+		const count = 1000
+		for i := 0; i < count; i++ {
+			if err := encoder.Encode(newRecord(i)); err != nil {
+				t.Fatalf("Failed to encode record %d: %v", i, err)
+			}
+		}
+		if err := encoder.Flush(); err != nil {
+			t.Fatalf("Failed to flush: %v", err)
+		}
+
+		decoder := NewDecoder(&buf)
+		for i := 0; i < count; i++ {
+			var record MyStruct
+			if err := decoder.Decode(&record); err != nil {
+				t.Fatalf("Failed to decode record %d: %v", i, err)
+			}
+			if !reflect.DeepEqual(newRecord(i), record) {
+				t.Fatalf("Decoded record does not match.\nExpected: %+v\nGot: %+v", newRecord(i), record)
+			}
+		}
+		var record MyStruct
+		if err := decoder.Decode(&record); err != io.EOF {
+			t.Errorf("Expected io.EOF at end of stream but got %v", err)
+		}
+	})
+
+	t.Run("frames hold the Encode output", func(t *testing.T) {
+		var buf bytes.Buffer
+		encoder := NewEncoder(&buf)
+		_ = encoder.Encode(newRecord(1))
+		_ = encoder.Flush()
+
+		expected, _ := Encode(newRecord(1))
+		size, err := binary.ReadUvarint(&buf)
+		if err != nil || int(size) != len(expected) {
+			t.Fatalf("Expected frame of %d bytes but got %d (%v)", len(expected), size, err)
+		}
+		if buf.String() != expected {
+			t.Errorf("Expected frame %q but got %q", expected, buf.String())
+		}
+	})
+
+	t.Run("empty stream", func(t *testing.T) {
+		var record MyStruct
+		if err := NewDecoder(&bytes.Buffer{}).Decode(&record); err != io.EOF {
+			t.Errorf("Expected io.EOF but got %v", err)
+		}
+	})
+
+	t.Run("truncated stream", func(t *testing.T) {
+		var buf bytes.Buffer
+		encoder := NewEncoder(&buf)
+		_ = encoder.Encode(newRecord(1))
+		_ = encoder.Flush()
+
+		truncated := bytes.NewReader(buf.Bytes()[:buf.Len()-3])
+		var record MyStruct
+		if err := NewDecoder(truncated).Decode(&record); err != io.ErrUnexpectedEOF {
+			t.Errorf("Expected io.ErrUnexpectedEOF but got %v", err)
+		}
+	})
+
+	t.Run("oversized frame", func(t *testing.T) {
+		header := binary.AppendUvarint(nil, maxFrameSize+1)
+		var record MyStruct
+		err := NewDecoder(bytes.NewReader(header)).Decode(&record)
+		if !errors.Is(err, ErrInvalidFrame) {
+			t.Errorf("Expected error %v but got %v", ErrInvalidFrame, err)
+		}
+	})
+
+	t.Run("corrupt frame", func(t *testing.T) {
+		frame := append(binary.AppendUvarint(nil, 3), "!!!"...)
+		var record MyStruct
+		err := NewDecoder(bytes.NewReader(frame)).Decode(&record)
+		if !errors.Is(err, ErrBase64Decoding) {
+			t.Errorf("Expected error %v but got %v", ErrBase64Decoding, err)
+		}
+	})
+
+	t.Run("mixed record types", func(t *testing.T) {
+		encoder := NewEncoder(io.Discard)
+		if err := encoder.Encode(newRecord(1)); err != nil {
+			t.Fatalf("Failed to encode record: %v", err)
+		}
+		err := encoder.Encode(NestedStruct{})
+		if !errors.Is(err, ErrRecordType) {
+			t.Errorf("Expected error %v but got %v", ErrRecordType, err)
+		}
+		err = encoder.Encode(1)
+		if !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("Expected error %v but got %v", ErrUnsupportedType, err)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		data := WithUnexported{Name: "n", secret: "s", count: 1}
+		var buf bytes.Buffer
+		encoder := NewEncoder(&buf, IncludeUnexported())
+		_ = encoder.Encode(data)
+		_ = encoder.Flush()
+
+		var decodedData WithUnexported
+		if err := NewDecoder(&buf, IncludeUnexported()).Decode(&decodedData); err != nil {
+			t.Fatalf("Failed to decode record: %v", err)
+		}
+		if !reflect.DeepEqual(data, decodedData) {
+			t.Errorf("Decoded record does not match.\nExpected: %+v\nGot: %+v", data, decodedData)
+		}
+	})
+}
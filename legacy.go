@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/priyanshujain/go-storage/query"
+	"github.com/priyanshujain/go-storage/schema"
+)
+
+// Legacy is the Storage API without contexts.
+type Legacy interface {
+	Init()
+	CreateTable(tableType interface{}, pk string, opts ...schema.Option) error
+	Insert(record interface{}) (interface{}, error)
+	InsertMany(records ...interface{}) error
+	Get(tableType interface{}, pk interface{}) (interface{}, error)
+	GetFields(tableType interface{}, pk interface{}, fields ...string) (interface{}, error)
+	Update(record interface{}) error
+	Delete(tableType interface{}, pk interface{}) error
+	Batch() *query.Batch
+	Query(tableType interface{}) *query.Query
+	List(tableType interface{}, opts query.ListOptions) (query.Page, error)
+	Aggregate(tableType interface{}) *query.Aggregation
+	Join(left, right interface{}) *query.Join
+	LeftJoin(left, right interface{}) *query.Join
+	TableSchema(name string) (reflect.Type, string, error)
+	Watch(tableType interface{}, fromSeq uint64) (<-chan query.ChangeEvent, func())
+}
+
+// WithoutContext adapts a Storage to the Legacy API, every call runs with
+// context.Background.
+func WithoutContext(s Storage) Legacy {
+	return legacy{s}
+}
+
+type legacy struct {
+	s Storage
+}
+
+func (l legacy) Init() {
+	l.s.Init()
+}
+
+func (l legacy) CreateTable(tableType interface{}, pk string, opts ...schema.Option) error {
+	return l.s.CreateTable(context.Background(), tableType, pk, opts...)
+}
+
+func (l legacy) Insert(record interface{}) (interface{}, error) {
+	return l.s.Insert(context.Background(), record)
+}
+
+func (l legacy) InsertMany(records ...interface{}) error {
+	return l.s.InsertMany(context.Background(), records...)
+}
+
+func (l legacy) Get(tableType interface{}, pk interface{}) (interface{}, error) {
+	return l.s.Get(context.Background(), tableType, pk)
+}
+
+func (l legacy) GetFields(tableType interface{}, pk interface{}, fields ...string) (interface{}, error) {
+	return l.s.GetFields(context.Background(), tableType, pk, fields...)
+}
+
+func (l legacy) Update(record interface{}) error {
+	return l.s.Update(context.Background(), record)
+}
+
+func (l legacy) Delete(tableType interface{}, pk interface{}) error {
+	return l.s.Delete(context.Background(), tableType, pk)
+}
+
+func (l legacy) Batch() *query.Batch {
+	return l.s.Batch(context.Background())
+}
+
+func (l legacy) Query(tableType interface{}) *query.Query {
+	return l.s.Query(context.Background(), tableType)
+}
+
+func (l legacy) List(tableType interface{}, opts query.ListOptions) (query.Page, error) {
+	return l.s.List(context.Background(), tableType, opts)
+}
+
+func (l legacy) Aggregate(tableType interface{}) *query.Aggregation {
+	return l.s.Aggregate(context.Background(), tableType)
+}
+
+func (l legacy) Join(left, right interface{}) *query.Join {
+	return l.s.Join(context.Background(), left, right)
+}
+
+func (l legacy) LeftJoin(left, right interface{}) *query.Join {
+	return l.s.LeftJoin(context.Background(), left, right)
+}
+
+func (l legacy) TableSchema(name string) (reflect.Type, string, error) {
+	return l.s.TableSchema(context.Background(), name)
+}
+
+func (l legacy) Watch(tableType interface{}, fromSeq uint64) (<-chan query.ChangeEvent, func()) {
+	return l.s.Watch(context.Background(), tableType, fromSeq)
+}
@@ -0,0 +1,449 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+var ErrInvalidAggregate = errors.New("invalid aggregate")
+
+// Aggregate functions. Count counts records, the others skip nil values:
+// CountDistinct counts the distinct values of a field, Sum and Avg add up
+// numbers and Min and Max return the least and greatest value by Compare.
+const (
+	Count         = "count"
+	CountDistinct = "count distinct"
+	Sum           = "sum"
+	Avg           = "avg"
+	Min           = "min"
+	Max           = "max"
+)
+
+// Aggregate is an aggregate function over a field, Count has no field.
+type Aggregate struct {
+	Func  string
+	Field string
+}
+
+// AggregateSpec describes an aggregation for an AggregateExecutor.
+type AggregateSpec struct {
+	TableType  interface{}
+	Conditions []Condition
+	GroupBy    []string
+	Aggregates []Aggregate
+}
+
+// Group is a result of an aggregation. Key holds the values of the group by
+// fields and Values the results of the aggregates, both in the order they
+// were added. Count and CountDistinct are ints, Sum is an int64, uint64 or
+// float64 like the field and Avg a float64. Avg, Min and Max are nil when
+// the group has no values.
+type Group struct {
+	Key    []interface{}
+	Values []interface{}
+}
+
+// AggregateExecutor runs aggregations against the tables of a storage
+// engine.
+type AggregateExecutor interface {
+	ExecuteAggregate(ctx context.Context, spec AggregateSpec) ([]Group, error)
+}
+
+// Aggregation builds an AggregateSpec and runs it on an AggregateExecutor.
+// Errors in the chain are kept and returned when the aggregation is run.
+type Aggregation struct {
+	ctx  context.Context
+	exec AggregateExecutor
+	spec AggregateSpec
+	err  error
+}
+
+// NewAggregation returns an aggregation over the table of tableType that
+// runs with ctx.
+func NewAggregation(ctx context.Context, exec AggregateExecutor, tableType interface{}) *Aggregation {
+	return &Aggregation{ctx: ctx, exec: exec, spec: AggregateSpec{TableType: tableType}}
+}
+
+// Where aggregates only the records whose field compares to value with the
+// operator.
+func (a *Aggregation) Where(field, operator string, value interface{}) *Aggregation {
+	if !operators[operator] && a.err == nil {
+		a.err = fmt.Errorf("%q: %w", operator, ErrInvalidOperator)
+	}
+	a.spec.Conditions = append(a.spec.Conditions, Condition{Field: field, Operator: operator, Value: value})
+	return a
+}
+
+// GroupBy aggregates the records with equal values of the fields together.
+// Without it all records form a single group.
+func (a *Aggregation) GroupBy(fields ...string) *Aggregation {
+	a.spec.GroupBy = append(a.spec.GroupBy, fields...)
+	return a
+}
+
+// Count counts the records of each group.
+func (a *Aggregation) Count() *Aggregation {
+	a.spec.Aggregates = append(a.spec.Aggregates, Aggregate{Func: Count})
+	return a
+}
+
+// CountDistinct counts the distinct values of a field.
+func (a *Aggregation) CountDistinct(field string) *Aggregation {
+	a.spec.Aggregates = append(a.spec.Aggregates, Aggregate{Func: CountDistinct, Field: field})
+	return a
+}
+
+// Sum adds up a numeric field.
+func (a *Aggregation) Sum(field string) *Aggregation {
+	a.spec.Aggregates = append(a.spec.Aggregates, Aggregate{Func: Sum, Field: field})
+	return a
+}
+
+// Avg averages a numeric field.
+func (a *Aggregation) Avg(field string) *Aggregation {
+	a.spec.Aggregates = append(a.spec.Aggregates, Aggregate{Func: Avg, Field: field})
+	return a
+}
+
+// Min returns the least value of a field.
+func (a *Aggregation) Min(field string) *Aggregation {
+	a.spec.Aggregates = append(a.spec.Aggregates, Aggregate{Func: Min, Field: field})
+	return a
+}
+
+// Max returns the greatest value of a field.
+func (a *Aggregation) Max(field string) *Aggregation {
+	a.spec.Aggregates = append(a.spec.Aggregates, Aggregate{Func: Max, Field: field})
+	return a
+}
+
+// Spec returns the description of the aggregation.
+func (a *Aggregation) Spec() AggregateSpec {
+	return a.spec
+}
+
+// Run runs the aggregation and returns the groups sorted by key. An
+// aggregation without GroupBy returns a single group, even when no record
+// matched.
+func (a *Aggregation) Run() ([]Group, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	return a.exec.ExecuteAggregate(a.ctx, a.spec)
+}
+
+// Check validates the fields of a spec against the record type of its table
+// and that the aggregate functions apply to them.
+func (s AggregateSpec) Check(recordType reflect.Type) error {
+	if err := (Spec{Conditions: s.Conditions}).Check(recordType); err != nil {
+		return err
+	}
+	field := func(name string) (reflect.Type, error) {
+		f, ok := recordType.FieldByName(name)
+		if !ok || !f.IsExported() {
+			return nil, fmt.Errorf("%q: %w", name, ErrInvalidField)
+		}
+		return f.Type, nil
+	}
+	for _, name := range s.GroupBy {
+		t, err := field(name)
+		if err != nil {
+			return err
+		}
+		if !Comparable(t) {
+			return fmt.Errorf("group by %q: %v: %w", name, t, ErrIncomparable)
+		}
+	}
+	for _, a := range s.Aggregates {
+		if a.Func == Count {
+			if a.Field != "" {
+				return fmt.Errorf("%s of %q: %w", a.Func, a.Field, ErrInvalidAggregate)
+			}
+			continue
+		}
+		t, err := field(a.Field)
+		if err != nil {
+			return err
+		}
+		switch a.Func {
+		case CountDistinct, Min, Max:
+			if !Comparable(t) {
+				return fmt.Errorf("%s of %q: %v: %w", a.Func, a.Field, t, ErrIncomparable)
+			}
+		case Sum, Avg:
+			if !isNumber(reflect.Zero(elemType(t))) {
+				return fmt.Errorf("%s of %q: %v: %w", a.Func, a.Field, t, ErrInvalidAggregate)
+			}
+		default:
+			return fmt.Errorf("%q: %w", a.Func, ErrInvalidAggregate)
+		}
+	}
+	return nil
+}
+
+// Fields returns the fields the spec refers to, without duplicates.
+func (s AggregateSpec) Fields() []string {
+	var fields []string
+	seen := make(map[string]bool)
+	add := func(field string) {
+		if field != "" && !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+	for _, c := range s.Conditions {
+		add(c.Field)
+	}
+	for _, field := range s.GroupBy {
+		add(field)
+	}
+	for _, a := range s.Aggregates {
+		add(a.Field)
+	}
+	return fields
+}
+
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Accumulator computes the groups of an aggregation from records streamed
+// into it, so that engines do not have to hold the records.
+type Accumulator struct {
+	spec AggregateSpec
+	// sums holds the zero sum of each aggregate
+	sums []interface{}
+	// groups are sorted by key
+	groups []*group
+}
+
+type group struct {
+	key    []interface{}
+	states []*aggregateState
+}
+
+type aggregateState struct {
+	count int
+	sum   interface{}
+	value interface{}
+	// distinct values sorted by Compare
+	distinct []interface{}
+}
+
+// NewAccumulator returns an accumulator for a checked spec over records of
+// recordType.
+func NewAccumulator(spec AggregateSpec, recordType reflect.Type) *Accumulator {
+	acc := &Accumulator{spec: spec, sums: make([]interface{}, len(spec.Aggregates))}
+	for i, a := range spec.Aggregates {
+		if a.Func != Sum && a.Func != Avg {
+			continue
+		}
+		f, _ := recordType.FieldByName(a.Field)
+		switch v := reflect.Zero(elemType(f.Type)); {
+		case isInt(v):
+			acc.sums[i] = int64(0)
+		case isUint(v):
+			acc.sums[i] = uint64(0)
+		default:
+			acc.sums[i] = float64(0)
+		}
+	}
+	if len(spec.GroupBy) == 0 {
+		acc.groups = []*group{acc.newGroup([]interface{}{})}
+	}
+	return acc
+}
+
+// Add aggregates a record, records that do not match the conditions of the
+// spec are skipped.
+func (acc *Accumulator) Add(record interface{}) error {
+	return acc.add(func(field string) (interface{}, error) {
+		return Field(record, field)
+	}, 1)
+}
+
+// AddN aggregates n records whose fields referred to by the spec all hold
+// value. Engines use it to aggregate from an index over the only field of a
+// spec, or to count records when the spec refers to no field.
+func (acc *Accumulator) AddN(value interface{}, n int) error {
+	return acc.add(func(string) (interface{}, error) {
+		return value, nil
+	}, n)
+}
+
+func (acc *Accumulator) add(field func(string) (interface{}, error), n int) error {
+	for _, c := range acc.spec.Conditions {
+		value, err := field(c.Field)
+		if err != nil {
+			return err
+		}
+		ok, err := c.Match(value)
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	key := make([]interface{}, len(acc.spec.GroupBy))
+	for i, name := range acc.spec.GroupBy {
+		value, err := field(name)
+		if err != nil {
+			return err
+		}
+		key[i] = deref(value)
+	}
+	g, err := acc.group(key)
+	if err != nil {
+		return err
+	}
+
+	for i, a := range acc.spec.Aggregates {
+		state := g.states[i]
+		if a.Func == Count {
+			state.count += n
+			continue
+		}
+		value, err := field(a.Field)
+		if err != nil {
+			return err
+		}
+		if value = deref(value); value == nil {
+			continue
+		}
+		if err := state.add(a.Func, value, n); err != nil {
+			return fmt.Errorf("%s of %q: %w", a.Func, a.Field, err)
+		}
+	}
+	return nil
+}
+
+func (s *aggregateState) add(function string, value interface{}, n int) error {
+	s.count += n
+	switch function {
+	case CountDistinct:
+		i, found, err := search(s.distinct, func(v interface{}) (int, error) { return Compare(v, value) })
+		if err != nil || found {
+			return err
+		}
+		s.distinct = append(s.distinct, nil)
+		copy(s.distinct[i+1:], s.distinct[i:])
+		s.distinct[i] = value
+	case Sum, Avg:
+		v := reflect.ValueOf(value)
+		switch sum := s.sum.(type) {
+		case int64:
+			s.sum = sum + v.Int()*int64(n)
+		case uint64:
+			s.sum = sum + v.Uint()*uint64(n)
+		case float64:
+			s.sum = sum + toFloat(v)*float64(n)
+		}
+	case Min, Max:
+		if s.value == nil {
+			s.value = value
+			return nil
+		}
+		result, err := Compare(value, s.value)
+		if err != nil {
+			return err
+		}
+		if function == Min && result < 0 || function == Max && result > 0 {
+			s.value = value
+		}
+	}
+	return nil
+}
+
+// group finds or adds the group of a key
+func (acc *Accumulator) group(key []interface{}) (*group, error) {
+	i, found, err := search(acc.groups, func(g *group) (int, error) { return compareKeys(g.key, key) })
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		acc.groups = append(acc.groups, nil)
+		copy(acc.groups[i+1:], acc.groups[i:])
+		acc.groups[i] = acc.newGroup(key)
+	}
+	return acc.groups[i], nil
+}
+
+func (acc *Accumulator) newGroup(key []interface{}) *group {
+	g := &group{key: key, states: make([]*aggregateState, len(acc.spec.Aggregates))}
+	for i := range g.states {
+		g.states[i] = &aggregateState{sum: acc.sums[i]}
+	}
+	return g
+}
+
+// Groups returns the groups aggregated so far sorted by key.
+func (acc *Accumulator) Groups() []Group {
+	groups := make([]Group, 0, len(acc.groups))
+	for _, g := range acc.groups {
+		values := make([]interface{}, len(g.states))
+		for i, a := range acc.spec.Aggregates {
+			state := g.states[i]
+			switch a.Func {
+			case Count:
+				values[i] = state.count
+			case CountDistinct:
+				values[i] = len(state.distinct)
+			case Sum:
+				values[i] = state.sum
+			case Avg:
+				if state.count > 0 {
+					values[i] = toFloat(reflect.ValueOf(state.sum)) / float64(state.count)
+				}
+			case Min, Max:
+				values[i] = state.value
+			}
+		}
+		groups = append(groups, Group{Key: g.key, Values: values})
+	}
+	return groups
+}
+
+// search returns the position of the element comparing equal in a slice
+// sorted by compare, or where it would be inserted
+func search[T any](elements []T, compare func(T) (int, error)) (int, bool, error) {
+	var err error
+	i := sort.Search(len(elements), func(i int) bool {
+		result, e := compare(elements[i])
+		if e != nil && err == nil {
+			err = e
+		}
+		return result >= 0
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	if i < len(elements) {
+		result, err := compare(elements[i])
+		return i, result == 0, err
+	}
+	return i, false, nil
+}
+
+func compareKeys(a, b []interface{}) (int, error) {
+	for i := range a {
+		result, err := Compare(a[i], b[i])
+		if err != nil || result != 0 {
+			return result, err
+		}
+	}
+	return 0, nil
+}
+
+// deref returns the value a pointer or interface holds, or nil
+func deref(value interface{}) interface{} {
+	v := indirect(reflect.ValueOf(value))
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
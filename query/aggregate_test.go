@@ -0,0 +1,156 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type Purchase struct {
+	Id       string
+	Status   Status
+	Region   string
+	Amount   *float64
+	Quantity uint
+	Items    int
+	Tags     []string
+}
+
+func (p Purchase) with(amount float64) Purchase {
+	p.Amount = &amount
+	return p
+}
+
+func TestAggregateSpec_Check(t *testing.T) {
+	recordType := reflect.TypeOf(Purchase{})
+	tests := []struct {
+		name string
+		spec AggregateSpec
+		err  error
+	}{
+		{"valid", AggregateSpec{GroupBy: []string{"Status"}, Aggregates: []Aggregate{{Func: Count}, {Sum, "Amount"}, {Min, "Region"}}}, nil},
+		{"unknown condition field", AggregateSpec{Conditions: []Condition{{"Missing", Eq, 1}}}, ErrInvalidField},
+		{"unknown group field", AggregateSpec{GroupBy: []string{"Missing"}}, ErrInvalidField},
+		{"unordered group field", AggregateSpec{GroupBy: []string{"Tags"}}, ErrIncomparable},
+		{"unknown aggregate field", AggregateSpec{Aggregates: []Aggregate{{Max, "Missing"}}}, ErrInvalidField},
+		{"count with field", AggregateSpec{Aggregates: []Aggregate{{Count, "Id"}}}, ErrInvalidAggregate},
+		{"sum of strings", AggregateSpec{Aggregates: []Aggregate{{Sum, "Region"}}}, ErrInvalidAggregate},
+		{"distinct of slices", AggregateSpec{Aggregates: []Aggregate{{CountDistinct, "Tags"}}}, ErrIncomparable},
+		{"unknown function", AggregateSpec{Aggregates: []Aggregate{{"median", "Items"}}}, ErrInvalidAggregate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.spec.Check(recordType); !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v but got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestAccumulator(t *testing.T) {
+	records := []Purchase{
+		Purchase{Id: "1", Status: "open", Region: "eu", Quantity: 2, Items: -1}.with(10),
+		{Id: "2", Status: "closed", Region: "us", Quantity: 1, Items: 4},
+		Purchase{Id: "3", Status: "open", Region: "us", Quantity: 3, Items: 2}.with(2.5),
+		Purchase{Id: "4", Status: "open", Region: "eu", Quantity: 5, Items: 1}.with(4),
+		Purchase{Id: "5", Status: "void", Region: "eu"}.with(100),
+	}
+
+	t.Run("group by", func(t *testing.T) {
+		spec := AggregateSpec{
+			Conditions: []Condition{{"Status", Ne, "void"}},
+			GroupBy:    []string{"Status", "Region"},
+			Aggregates: []Aggregate{{Func: Count}, {Sum, "Amount"}, {Avg, "Amount"}, {Sum, "Quantity"}, {Sum, "Items"}, {Min, "Id"}, {Max, "Amount"}},
+		}
+		acc := NewAccumulator(spec, reflect.TypeOf(Purchase{}))
+		for _, record := range records {
+			if err := acc.Add(&record); err != nil {
+				t.Fatalf("Failed to add record: %v", err)
+			}
+		}
+		expected := []Group{
+			{Key: []interface{}{Status("closed"), "us"}, Values: []interface{}{1, 0.0, nil, uint64(1), int64(4), "2", nil}},
+			{Key: []interface{}{Status("open"), "eu"}, Values: []interface{}{2, 14.0, 7.0, uint64(7), int64(0), "1", 10.0}},
+			{Key: []interface{}{Status("open"), "us"}, Values: []interface{}{1, 2.5, 2.5, uint64(3), int64(2), "3", 2.5}},
+		}
+		if groups := acc.Groups(); !reflect.DeepEqual(groups, expected) {
+			t.Errorf("Expected %v but got %v", expected, groups)
+		}
+	})
+
+	t.Run("single group", func(t *testing.T) {
+		spec := AggregateSpec{Aggregates: []Aggregate{{Func: Count}, {CountDistinct, "Region"}, {CountDistinct, "Amount"}, {Avg, "Items"}}}
+		acc := NewAccumulator(spec, reflect.TypeOf(Purchase{}))
+		expected := []Group{{Key: []interface{}{}, Values: []interface{}{0, 0, 0, nil}}}
+		if groups := acc.Groups(); !reflect.DeepEqual(groups, expected) {
+			t.Errorf("Expected %v without records but got %v", expected, groups)
+		}
+		for _, record := range records {
+			if err := acc.Add(record); err != nil {
+				t.Fatalf("Failed to add record: %v", err)
+			}
+		}
+		expected = []Group{{Key: []interface{}{}, Values: []interface{}{5, 2, 4, 6.0 / 5}}}
+		if groups := acc.Groups(); !reflect.DeepEqual(groups, expected) {
+			t.Errorf("Expected %v but got %v", expected, groups)
+		}
+	})
+
+	t.Run("add n", func(t *testing.T) {
+		spec := AggregateSpec{
+			Conditions: []Condition{{"Items", Gt, 1}},
+			GroupBy:    []string{"Items"},
+			Aggregates: []Aggregate{{Func: Count}, {Sum, "Items"}, {CountDistinct, "Items"}},
+		}
+		acc := NewAccumulator(spec, reflect.TypeOf(Purchase{}))
+		for value, n := range map[int]int{1: 3, 2: 2, 5: 4} {
+			if err := acc.AddN(value, n); err != nil {
+				t.Fatalf("Failed to add values: %v", err)
+			}
+		}
+		expected := []Group{
+			{Key: []interface{}{2}, Values: []interface{}{2, int64(4), 1}},
+			{Key: []interface{}{5}, Values: []interface{}{4, int64(20), 1}},
+		}
+		if groups := acc.Groups(); !reflect.DeepEqual(groups, expected) {
+			t.Errorf("Expected %v but got %v", expected, groups)
+		}
+	})
+}
+
+type aggregateExecutor struct {
+	spec AggregateSpec
+}
+
+func (e *aggregateExecutor) ExecuteAggregate(ctx context.Context, spec AggregateSpec) ([]Group, error) {
+	e.spec = spec
+	return nil, nil
+}
+
+func TestAggregation(t *testing.T) {
+	ctx := context.Background()
+	exec := &aggregateExecutor{}
+	_, err := NewAggregation(ctx, exec, Purchase{}).Where("Items", ">", 1).GroupBy("Status", "Region").
+		Count().CountDistinct("Id").Sum("Amount").Avg("Items").Min("Id").Max("Id").Run()
+	if err != nil {
+		t.Fatalf("Failed to run aggregation: %v", err)
+	}
+	expected := AggregateSpec{
+		TableType:  Purchase{},
+		Conditions: []Condition{{"Items", Gt, 1}},
+		GroupBy:    []string{"Status", "Region"},
+		Aggregates: []Aggregate{{Func: Count}, {CountDistinct, "Id"}, {Sum, "Amount"}, {Avg, "Items"}, {Min, "Id"}, {Max, "Id"}},
+	}
+	if !reflect.DeepEqual(exec.spec, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, exec.spec)
+	}
+	if fields := exec.spec.Fields(); !reflect.DeepEqual(fields, []string{"Items", "Status", "Region", "Id", "Amount"}) {
+		t.Errorf("Expected the fields in order of use but got %v", fields)
+	}
+
+	_, err = NewAggregation(ctx, exec, Purchase{}).Where("Items", "like", 1).Count().Run()
+	if !errors.Is(err, ErrInvalidOperator) {
+		t.Errorf("Expected %v but got %v", ErrInvalidOperator, err)
+	}
+}
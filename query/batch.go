@@ -0,0 +1,125 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrBatch = errors.New("batch failed")
+var ErrInvalidOp = errors.New("invalid batch operation")
+
+// Kinds of writes of a batch.
+const (
+	InsertOp = "insert"
+	UpdateOp = "update"
+	DeleteOp = "delete"
+)
+
+// Op is a write of a batch. Inserts and updates carry the Record, deletes
+// carry the TableType and the primary key value of the record to delete.
+type Op struct {
+	Kind      string
+	Record    interface{}
+	TableType interface{}
+	Pk        interface{}
+}
+
+// OpError is the failure of the write at Index of a batch.
+type OpError struct {
+	Index int
+	Op    Op
+	Err   error
+}
+
+func (e OpError) Error() string {
+	return fmt.Sprintf("%s %d: %v", e.Op.Kind, e.Index, e.Err)
+}
+
+func (e OpError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError lists every write that kept a batch from being applied, none
+// of the writes of the batch were applied. It matches ErrBatch and the
+// errors of its writes with errors.Is.
+type BatchError struct {
+	Errors []OpError
+}
+
+func (e *BatchError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%v: %s", ErrBatch, strings.Join(messages, "; "))
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := []error{ErrBatch}
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// BatchExecutor applies the writes of a batch to a storage engine, either
+// all of them or none.
+type BatchExecutor interface {
+	ExecuteBatch(ctx context.Context, ops []Op) error
+}
+
+// Batch collects writes across tables and applies them in order on a
+// BatchExecutor. Later writes see the earlier writes of the batch, a record
+// inserted by the batch can be updated or deleted by it.
+type Batch struct {
+	ctx  context.Context
+	exec BatchExecutor
+	ops  []Op
+}
+
+// NewBatch returns an empty batch that is committed with ctx.
+func NewBatch(ctx context.Context, exec BatchExecutor) *Batch {
+	return &Batch{ctx: ctx, exec: exec}
+}
+
+// Insert adds inserts of records, the table of a record is its type.
+func (b *Batch) Insert(records ...interface{}) *Batch {
+	for _, record := range records {
+		b.ops = append(b.ops, Op{Kind: InsertOp, Record: record})
+	}
+	return b
+}
+
+// Update adds updates of records, the record to replace is found by its
+// primary key.
+func (b *Batch) Update(records ...interface{}) *Batch {
+	for _, record := range records {
+		b.ops = append(b.ops, Op{Kind: UpdateOp, Record: record})
+	}
+	return b
+}
+
+// Delete adds deletes of the records of a table by their primary key
+// values.
+func (b *Batch) Delete(tableType interface{}, pks ...interface{}) *Batch {
+	for _, pk := range pks {
+		b.ops = append(b.ops, Op{Kind: DeleteOp, TableType: tableType, Pk: pk})
+	}
+	return b
+}
+
+// Ops returns the writes of the batch.
+func (b *Batch) Ops() []Op {
+	return append([]Op{}, b.ops...)
+}
+
+// Commit applies the writes of the batch. The error is a *BatchError when
+// writes are invalid.
+func (b *Batch) Commit() error {
+	if len(b.ops) == 0 {
+		return nil
+	}
+	return b.exec.ExecuteBatch(b.ctx, b.ops)
+}
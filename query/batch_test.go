@@ -0,0 +1,64 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type batchExecutor struct {
+	ops []Op
+}
+
+func (e *batchExecutor) ExecuteBatch(ctx context.Context, ops []Op) error {
+	e.ops = ops
+	return nil
+}
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	exec := &batchExecutor{}
+	err := NewBatch(ctx, exec).Insert(Customer{Id: "c1"}, &Customer{Id: "c2"}).
+		Update(Customer{Id: "c3"}).Delete(Customer{}, "c4", "c5").Commit()
+	if err != nil {
+		t.Fatalf("Failed to commit batch: %v", err)
+	}
+	expected := []Op{
+		{Kind: InsertOp, Record: Customer{Id: "c1"}},
+		{Kind: InsertOp, Record: &Customer{Id: "c2"}},
+		{Kind: UpdateOp, Record: Customer{Id: "c3"}},
+		{Kind: DeleteOp, TableType: Customer{}, Pk: "c4"},
+		{Kind: DeleteOp, TableType: Customer{}, Pk: "c5"},
+	}
+	if !reflect.DeepEqual(exec.ops, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, exec.ops)
+	}
+
+	exec.ops = nil
+	if err := NewBatch(ctx, exec).Commit(); err != nil || exec.ops != nil {
+		t.Errorf("Expected an empty batch to do nothing but got %v, %v", exec.ops, err)
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	errMissing := errors.New("missing")
+	err := error(&BatchError{Errors: []OpError{
+		{Index: 1, Op: Op{Kind: InsertOp}, Err: ErrInvalidOp},
+		{Index: 3, Op: Op{Kind: DeleteOp}, Err: errMissing},
+	}})
+
+	expected := "batch failed: insert 1: invalid batch operation; delete 3: missing"
+	if err.Error() != expected {
+		t.Errorf("Expected %q but got %q", expected, err.Error())
+	}
+	for _, target := range []error{ErrBatch, ErrInvalidOp, errMissing} {
+		if !errors.Is(err, target) {
+			t.Errorf("Expected %v to match %v", err, target)
+		}
+	}
+	var opErr OpError
+	if !errors.As(err, &opErr) || opErr.Index != 1 {
+		t.Errorf("Expected the error of the first write but got %+v", opErr)
+	}
+}
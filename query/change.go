@@ -0,0 +1,33 @@
+package query
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrWatchOverflow = errors.New("watch buffer overflow")
+var ErrSeqNotRetained = errors.New("sequence not retained")
+
+// LatestSeq passed to Watch starts a watch with the next write, without
+// replaying earlier events.
+const LatestSeq uint64 = math.MaxUint64
+
+// ChangeEvent is a write applied to a table. Every write of an engine takes
+// the next sequence number, Op is InsertOp, UpdateOp or DeleteOp and Old
+// and New are the record values before and after the write, Old is nil for
+// an insert and New for a delete. Pk is the primary key value as passed to
+// Get.
+//
+// An event with Err set is the last event of a watch, sent before its
+// channel is closed when the watch could not start, its consumer fell
+// behind by more events than are buffered, wrapping ErrWatchOverflow, or
+// its context is done.
+type ChangeEvent struct {
+	Seq   uint64
+	Table string
+	Pk    interface{}
+	Op    string
+	Old   interface{}
+	New   interface{}
+	Err   error
+}
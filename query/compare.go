@@ -0,0 +1,385 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Compare returns -1, 0 or 1 as a is less than, equal to or greater than b.
+// Values are compared by kind so that any integer, unsigned or float can be
+// compared to any other number and named types to their underlying kind.
+// Pointers and interfaces are compared by the value they hold, nil sorts
+// before any other value and NaN before any other number. Types with a
+// Compare method, such as time.Time, are compared with it.
+func Compare(a, b interface{}) (int, error) {
+	return compareValues(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func compareValues(a, b reflect.Value) (int, error) {
+	a, b = indirect(a), indirect(b)
+	switch {
+	case !a.IsValid() && !b.IsValid():
+		return 0, nil
+	case !a.IsValid():
+		return -1, nil
+	case !b.IsValid():
+		return 1, nil
+	}
+
+	if a.Type() == b.Type() {
+		if method := a.MethodByName("Compare"); method.IsValid() {
+			methodType := method.Type()
+			if methodType.NumIn() == 1 && methodType.In(0) == b.Type() &&
+				methodType.NumOut() == 1 && methodType.Out(0).Kind() == reflect.Int {
+				return sign(method.Call([]reflect.Value{b})[0].Int()), nil
+			}
+		}
+	}
+
+	switch {
+	case isInt(a) && isInt(b):
+		return compareOrdered(a.Int(), b.Int()), nil
+	case isUint(a) && isUint(b):
+		return compareOrdered(a.Uint(), b.Uint()), nil
+	case isInt(a) && isUint(b):
+		if a.Int() < 0 {
+			return -1, nil
+		}
+		return compareOrdered(uint64(a.Int()), b.Uint()), nil
+	case isUint(a) && isInt(b):
+		if b.Int() < 0 {
+			return 1, nil
+		}
+		return compareOrdered(a.Uint(), uint64(b.Int())), nil
+	case isNumber(a) && isNumber(b):
+		return compareFloats(toFloat(a), toFloat(b)), nil
+	case a.Kind() == reflect.String && b.Kind() == reflect.String:
+		return strings.Compare(a.String(), b.String()), nil
+	case a.Kind() == reflect.Bool && b.Kind() == reflect.Bool:
+		return compareOrdered(boolToInt(a.Bool()), boolToInt(b.Bool())), nil
+	}
+	return 0, fmt.Errorf("%v and %v: %w", a.Type(), b.Type(), ErrIncomparable)
+}
+
+// Comparable reports whether values of type t can be ordered by Compare.
+func Comparable(t reflect.Type) bool {
+	zero := reflect.Zero(t)
+	for zero.Kind() == reflect.Ptr {
+		zero = reflect.Zero(zero.Type().Elem())
+	}
+	_, err := compareValues(zero, zero)
+	return err == nil
+}
+
+// indirect follows pointers and interfaces, a nil one yields the invalid
+// Value
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func isNumber(v reflect.Value) bool {
+	return isInt(v) || isUint(v) || v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch {
+	case isInt(v):
+		return float64(v.Int())
+	case isUint(v):
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	case math.IsNaN(b):
+		return 1
+	}
+	return compareOrdered(a, b)
+}
+
+func compareOrdered[T int64 | uint64 | float64 | int](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func sign(i int64) int {
+	return compareOrdered(i, 0)
+}
+
+// Field returns the value of a field of a struct record or of the struct a
+// record points to.
+func Field(record interface{}, field string) (interface{}, error) {
+	v := reflect.Indirect(reflect.ValueOf(record))
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%q: %w", field, ErrInvalidField)
+	}
+	f, ok := v.Type().FieldByName(field)
+	if !ok || !f.IsExported() {
+		return nil, fmt.Errorf("%q: %w", field, ErrInvalidField)
+	}
+	fieldValue, err := v.FieldByIndexErr(f.Index)
+	if err != nil {
+		// a nil embedded pointer
+		return nil, nil
+	}
+	return fieldValue.Interface(), nil
+}
+
+// Map returns the named fields of a struct record or of the struct a record
+// points to, or every exported field when no field is named.
+func Map(record interface{}, fields ...string) (map[string]interface{}, error) {
+	if len(fields) == 0 {
+		t := reflect.Indirect(reflect.ValueOf(record)).Type()
+		for _, f := range reflect.VisibleFields(t) {
+			if f.IsExported() && !f.Anonymous {
+				fields = append(fields, f.Name)
+			}
+		}
+	}
+	m := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		value, err := Field(record, field)
+		if err != nil {
+			return nil, err
+		}
+		m[field] = value
+	}
+	return m, nil
+}
+
+// Match reports whether a field value satisfies the condition.
+func (c Condition) Match(value interface{}) (bool, error) {
+	if c.Operator == In {
+		values := reflect.ValueOf(c.Value)
+		if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+			return false, fmt.Errorf("%q needs a slice: %w", In, ErrInvalidOperator)
+		}
+		for i := 0; i < values.Len(); i++ {
+			equal, err := Condition{Operator: Eq, Value: values.Index(i).Interface()}.Match(value)
+			if err != nil || equal {
+				return equal, err
+			}
+		}
+		return false, nil
+	}
+
+	result, err := Compare(value, c.Value)
+	if err != nil {
+		if c.Operator != Eq && c.Operator != Ne {
+			return false, err
+		}
+		// values without an order can still be equal
+		equal := reflect.DeepEqual(value, c.Value)
+		return equal == (c.Operator == Eq), nil
+	}
+	switch c.Operator {
+	case Eq:
+		return result == 0, nil
+	case Ne:
+		return result != 0, nil
+	case Lt:
+		return result < 0, nil
+	case Le:
+		return result <= 0, nil
+	case Gt:
+		return result > 0, nil
+	case Ge:
+		return result >= 0, nil
+	}
+	return false, fmt.Errorf("%q: %w", c.Operator, ErrInvalidOperator)
+}
+
+// Check validates the fields of a spec against the record type of its
+// table, and that values can be compared to the fields they are matched
+// with.
+func (s Spec) Check(recordType reflect.Type) error {
+	for _, field := range s.Fields {
+		f, ok := recordType.FieldByName(field)
+		if !ok || !f.IsExported() {
+			return fmt.Errorf("%q: %w", field, ErrInvalidField)
+		}
+	}
+	for _, c := range s.Conditions {
+		f, ok := recordType.FieldByName(c.Field)
+		if !ok || !f.IsExported() {
+			return fmt.Errorf("%q: %w", c.Field, ErrInvalidField)
+		}
+		if !operators[c.Operator] {
+			return fmt.Errorf("%q: %w", c.Operator, ErrInvalidOperator)
+		}
+		if _, err := c.Match(reflect.Zero(f.Type).Interface()); err != nil {
+			return fmt.Errorf("field %q: %w", c.Field, err)
+		}
+	}
+	for _, o := range s.Orders {
+		f, ok := recordType.FieldByName(o.Field)
+		if !ok || !f.IsExported() {
+			return fmt.Errorf("%q: %w", o.Field, ErrInvalidField)
+		}
+		if !Comparable(f.Type) {
+			return fmt.Errorf("order by %q: %v: %w", o.Field, f.Type, ErrIncomparable)
+		}
+	}
+	if s.Limit < 0 || s.Offset < 0 {
+		return ErrInvalidLimit
+	}
+	return nil
+}
+
+// Decoded returns the fields a record must be decoded with to run the spec,
+// nil when every field is selected.
+func (s Spec) Decoded() []string {
+	if s.Fields == nil {
+		return nil
+	}
+	fields := append([]string{}, s.Fields...)
+	for _, c := range s.Conditions {
+		fields = append(fields, c.Field)
+	}
+	for _, o := range s.Orders {
+		fields = append(fields, o.Field)
+	}
+	return fields
+}
+
+// Project returns a copy of a record with only the selected fields set, the
+// record itself when every field is selected.
+func (s Spec) Project(record interface{}) interface{} {
+	if s.Fields == nil {
+		return record
+	}
+	v := reflect.Indirect(reflect.ValueOf(record))
+	projected := reflect.New(v.Type())
+	for _, field := range s.Fields {
+		f, _ := v.Type().FieldByName(field)
+		value, err := v.FieldByIndexErr(f.Index)
+		if err != nil {
+			// a nil embedded pointer on the way to the field
+			continue
+		}
+		target, err := projected.Elem().FieldByIndexErr(f.Index)
+		if err != nil {
+			continue
+		}
+		target.Set(value)
+	}
+	if reflect.TypeOf(record).Kind() != reflect.Ptr {
+		return projected.Elem().Interface()
+	}
+	return projected.Interface()
+}
+
+// Filter returns the records matching all conditions of the spec.
+func (s Spec) Filter(records []interface{}) ([]interface{}, error) {
+	var matched []interface{}
+	for _, record := range records {
+		ok, err := s.Matches(record)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, record)
+		}
+	}
+	return matched, nil
+}
+
+// Matches reports whether a record satisfies all conditions of the spec.
+func (s Spec) Matches(record interface{}) (bool, error) {
+	for _, c := range s.Conditions {
+		value, err := Field(record, c.Field)
+		if err != nil {
+			return false, err
+		}
+		ok, err := c.Match(value)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Sort sorts records by the orders of the spec, keeping the relative order
+// of records that compare equal.
+func (s Spec) Sort(records []interface{}) error {
+	if len(s.Orders) == 0 {
+		return nil
+	}
+	var sortErr error
+	sort.SliceStable(records, func(i, j int) bool {
+		for _, o := range s.Orders {
+			a, err := Field(records[i], o.Field)
+			if err == nil {
+				var b interface{}
+				b, err = Field(records[j], o.Field)
+				if err == nil {
+					var result int
+					result, err = Compare(a, b)
+					if err == nil && result != 0 {
+						return (result < 0) != o.Desc
+					}
+				}
+			}
+			if err != nil && sortErr == nil {
+				sortErr = err
+			}
+		}
+		return false
+	})
+	return sortErr
+}
+
+// Window applies the offset and limit of the spec to sorted records.
+func (s Spec) Window(records []interface{}) []interface{} {
+	if s.Offset >= len(records) {
+		return nil
+	}
+	records = records[s.Offset:]
+	if s.Limited() && s.Limit < len(records) {
+		records = records[:s.Limit]
+	}
+	return records
+}
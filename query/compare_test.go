@@ -0,0 +1,188 @@
+package query
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Status string
+
+func TestCompare(t *testing.T) {
+	one := 1
+	var nilInt *int
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		a, b     interface{}
+		expected int
+		err      error
+	}{
+		{"ints", 1, 2, -1, nil},
+		{"int sizes", int8(5), int64(5), 0, nil},
+		{"int and uint", -1, uint(0), -1, nil},
+		{"uint and int", uint64(math.MaxUint64), math.MaxInt64, 1, nil},
+		{"int and float", 2, 1.5, 1, nil},
+		{"float32 and float64", float32(0.5), 0.5, 0, nil},
+		{"NaN first", math.NaN(), math.Inf(-1), -1, nil},
+		{"strings", "a", "b", -1, nil},
+		{"named string", Status("b"), "a", 1, nil},
+		{"bools", false, true, -1, nil},
+		{"pointer", &one, 1, 0, nil},
+		{"nil pointer first", nilInt, 0, -1, nil},
+		{"nil and nil", nilInt, nil, 0, nil},
+		{"time", now, now.Add(time.Second), -1, nil},
+		{"string and int", "1", 1, 0, ErrIncomparable},
+		{"slices", []int{1}, []int{1}, 0, ErrIncomparable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Compare(tt.a, tt.b)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected error %v but got %v", tt.err, err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %d but got %d", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		value     interface{}
+		expected  bool
+		err       error
+	}{
+		{"eq", Condition{Operator: Eq, Value: 30}, 30, true, nil},
+		{"ne", Condition{Operator: Ne, Value: 30}, 30, false, nil},
+		{"lt", Condition{Operator: Lt, Value: 30}, 29, true, nil},
+		{"le", Condition{Operator: Le, Value: 30}, 30, true, nil},
+		{"gt", Condition{Operator: Gt, Value: 30}, 30, false, nil},
+		{"ge", Condition{Operator: Ge, Value: 30.5}, 31, true, nil},
+		{"in", Condition{Operator: In, Value: []string{"a", "b"}}, Status("b"), true, nil},
+		{"not in", Condition{Operator: In, Value: []int{1, 2}}, 3, false, nil},
+		{"in needs a slice", Condition{Operator: In, Value: 1}, 1, false, ErrInvalidOperator},
+		{"eq without order", Condition{Operator: Eq, Value: []int{1}}, []int{1}, true, nil},
+		{"ne without order", Condition{Operator: Ne, Value: []int{1}}, []int{2}, true, nil},
+		{"lt without order", Condition{Operator: Lt, Value: []int{1}}, []int{1}, false, ErrIncomparable},
+		{"invalid operator", Condition{Operator: "like", Value: "a"}, "a", false, ErrInvalidOperator},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.condition.Match(tt.value)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected error %v but got %v", tt.err, err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %v but got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSpec(t *testing.T) {
+	type Record struct {
+		Name   string
+		Age    int
+		Tags   []string
+		hidden int
+	}
+	recordType := reflect.TypeOf(Record{})
+
+	t.Run("check", func(t *testing.T) {
+		tests := []struct {
+			name string
+			spec Spec
+			err  error
+		}{
+			{"valid", Spec{Conditions: []Condition{{"Age", Gt, 1}, {"Tags", Eq, []string{}}}, Orders: []Order{{Field: "Name"}}}, nil},
+			{"unknown field", Spec{Conditions: []Condition{{"Missing", Eq, 1}}}, ErrInvalidField},
+			{"unexported field", Spec{Conditions: []Condition{{"hidden", Eq, 1}}}, ErrInvalidField},
+			{"incomparable value", Spec{Conditions: []Condition{{"Age", Gt, "1"}}}, ErrIncomparable},
+			{"unknown order field", Spec{Orders: []Order{{Field: "Missing"}}}, ErrInvalidField},
+			{"unordered field", Spec{Orders: []Order{{Field: "Tags"}}}, ErrIncomparable},
+			{"negative limit", Spec{Limit: -1}, ErrInvalidLimit},
+			{"unknown selected field", Spec{Fields: []string{"Name", "Missing"}}, ErrInvalidField},
+			{"unexported selected field", Spec{Fields: []string{"hidden"}}, ErrInvalidField},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if err := tt.spec.Check(recordType); !errors.Is(err, tt.err) {
+					t.Errorf("Expected %v but got %v", tt.err, err)
+				}
+			})
+		}
+	})
+
+	t.Run("filter sort and window", func(t *testing.T) {
+		records := []interface{}{
+			&Record{Name: "c", Age: 30},
+			Record{Name: "a", Age: 40},
+			&Record{Name: "b", Age: 30},
+			&Record{Name: "d", Age: 20},
+		}
+		spec := Spec{
+			Conditions: []Condition{{"Age", Ge, 30}},
+			Orders:     []Order{{Field: "Age", Desc: true}, {Field: "Name"}},
+			Offset:     1,
+			Limit:      5,
+		}
+		matched, err := spec.Filter(records)
+		if err != nil {
+			t.Fatalf("Failed to filter: %v", err)
+		}
+		if err := spec.Sort(matched); err != nil {
+			t.Fatalf("Failed to sort: %v", err)
+		}
+		var names []string
+		for _, record := range spec.Window(matched) {
+			name, _ := Field(record, "Name")
+			names = append(names, name.(string))
+		}
+		if !reflect.DeepEqual(names, []string{"b", "c"}) {
+			t.Errorf("Expected [b c] but got %v", names)
+		}
+
+		if window := (Spec{Offset: 10}).Window(records); window != nil {
+			t.Errorf("Expected no records past the end but got %v", window)
+		}
+		if window := (Spec{HasLimit: true}).Window(records); len(window) != 0 {
+			t.Errorf("Expected no records for a zero limit but got %v", window)
+		}
+		if window := (Spec{}).Window(records); len(window) != len(records) {
+			t.Errorf("Expected every record without a limit but got %v", window)
+		}
+	})
+	t.Run("projection", func(t *testing.T) {
+		spec := Spec{
+			Fields:     []string{"Name"},
+			Conditions: []Condition{{"Age", Gt, 1}},
+			Orders:     []Order{{Field: "Tags"}},
+		}
+		if fields := spec.Decoded(); !reflect.DeepEqual(fields, []string{"Name", "Age", "Tags"}) {
+			t.Errorf("Expected the selected, condition and order fields but got %v", fields)
+		}
+		if fields := (Spec{Conditions: spec.Conditions}).Decoded(); fields != nil {
+			t.Errorf("Expected every field without a selection but got %v", fields)
+		}
+
+		record := &Record{Name: "a", Age: 2, Tags: []string{"t"}, hidden: 3}
+		if projected := spec.Project(record); !reflect.DeepEqual(projected, &Record{Name: "a"}) {
+			t.Errorf("Expected only the name but got %+v", projected)
+		}
+		if projected := spec.Project(*record); !reflect.DeepEqual(projected, Record{Name: "a"}) {
+			t.Errorf("Expected only the name but got %+v", projected)
+		}
+		if projected := (Spec{}).Project(record); projected != record {
+			t.Errorf("Expected the record itself without a selection but got %+v", projected)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+var ErrInvalidJoin = errors.New("invalid join")
+
+// Kinds of joins. An inner join returns the pairs of records whose join
+// fields are equal, a left join also returns each left record without a
+// match once, with a nil right record. Nil values never match.
+const (
+	InnerJoin = "inner"
+	LeftJoin  = "left"
+)
+
+// JoinSpec describes a join for a JoinExecutor. Left and Right are the
+// table types, the conditions restrict the records of each table before
+// they are joined.
+type JoinSpec struct {
+	Kind            string
+	Left            interface{}
+	Right           interface{}
+	LeftField       string
+	RightField      string
+	LeftConditions  []Condition
+	RightConditions []Condition
+}
+
+// Row is a result of a join. Records are returned the way the engine
+// returns them from Get, rows matching the same record share it.
+type Row struct {
+	Left  interface{}
+	Right interface{}
+}
+
+// JoinExecutor runs joins between the tables of a storage engine.
+type JoinExecutor interface {
+	ExecuteJoin(ctx context.Context, spec JoinSpec) ([]Row, error)
+}
+
+// Join builds a JoinSpec and runs it on a JoinExecutor. Errors in the chain
+// are kept and returned when the join is run.
+type Join struct {
+	ctx  context.Context
+	exec JoinExecutor
+	spec JoinSpec
+	err  error
+}
+
+// NewJoin returns a join of a kind between the tables of two table types
+// that runs with ctx.
+func NewJoin(ctx context.Context, exec JoinExecutor, kind string, left, right interface{}) *Join {
+	j := &Join{ctx: ctx, exec: exec, spec: JoinSpec{Kind: kind, Left: left, Right: right}}
+	if kind != InnerJoin && kind != LeftJoin {
+		j.err = fmt.Errorf("%q: %w", kind, ErrInvalidJoin)
+	}
+	return j
+}
+
+// On joins the records whose leftField equals the rightField of the right
+// table.
+func (j *Join) On(leftField, rightField string) *Join {
+	j.spec.LeftField, j.spec.RightField = leftField, rightField
+	return j
+}
+
+// Where restricts the records of a table before they are joined. The field
+// is qualified with the name of the table, as in "Customer.Name", unqualified
+// fields belong to the left table. Conditions on the right table of a left
+// join do not remove left records, they only keep them from matching.
+func (j *Join) Where(field, operator string, value interface{}) *Join {
+	if !operators[operator] && j.err == nil {
+		j.err = fmt.Errorf("%q: %w", operator, ErrInvalidOperator)
+	}
+	condition := Condition{Field: field, Operator: operator, Value: value}
+	table, name, qualified := strings.Cut(field, ".")
+	switch {
+	case !qualified || table == reflect.TypeOf(j.spec.Left).Name():
+		if qualified {
+			condition.Field = name
+		}
+		j.spec.LeftConditions = append(j.spec.LeftConditions, condition)
+	case table == reflect.TypeOf(j.spec.Right).Name():
+		condition.Field = name
+		j.spec.RightConditions = append(j.spec.RightConditions, condition)
+	default:
+		if j.err == nil {
+			j.err = fmt.Errorf("%q: %w", field, ErrInvalidField)
+		}
+	}
+	return j
+}
+
+// Spec returns the description of the join.
+func (j *Join) Spec() JoinSpec {
+	return j.spec
+}
+
+// All runs the join and returns its rows ordered by the left records and
+// then by the right records, in the order the engine scans them.
+func (j *Join) All() ([]Row, error) {
+	if j.err != nil {
+		return nil, j.err
+	}
+	return j.exec.ExecuteJoin(j.ctx, j.spec)
+}
+
+// Check validates the fields of a spec against the record types of its
+// tables.
+func (s JoinSpec) Check(leftType, rightType reflect.Type) error {
+	if s.Kind != InnerJoin && s.Kind != LeftJoin {
+		return fmt.Errorf("%q: %w", s.Kind, ErrInvalidJoin)
+	}
+	if err := (Spec{Conditions: s.LeftConditions}).Check(leftType); err != nil {
+		return err
+	}
+	if err := (Spec{Conditions: s.RightConditions}).Check(rightType); err != nil {
+		return err
+	}
+	for _, side := range []struct {
+		recordType reflect.Type
+		field      string
+	}{{leftType, s.LeftField}, {rightType, s.RightField}} {
+		f, ok := side.recordType.FieldByName(side.field)
+		if !ok || !f.IsExported() {
+			return fmt.Errorf("join on %q: %w", side.field, ErrInvalidField)
+		}
+		if !Comparable(f.Type) {
+			return fmt.Errorf("join on %q: %v: %w", side.field, f.Type, ErrIncomparable)
+		}
+	}
+	return nil
+}
+
+// HashKey returns a value usable as a map key that is equal for values
+// Compare finds equal, so that numbers of any kind and named types match.
+// It returns false for nil and NaN, which match no value in a join, and for
+// values that can not be map keys.
+func HashKey(value interface{}) (interface{}, bool) {
+	v := indirect(reflect.ValueOf(value))
+	if !v.IsValid() {
+		return nil, false
+	}
+	switch {
+	case isInt(v):
+		return v.Int(), true
+	case isUint(v):
+		if v.Uint() <= math.MaxInt64 {
+			return int64(v.Uint()), true
+		}
+		return v.Uint(), true
+	case isNumber(v):
+		f := v.Float()
+		if math.IsNaN(f) {
+			return nil, false
+		}
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return int64(f), true
+		}
+		if f >= math.MaxInt64 && f < math.MaxUint64 && f == math.Trunc(f) {
+			return uint64(f), true
+		}
+		return f, true
+	case v.Kind() == reflect.String:
+		return v.String(), true
+	case v.Kind() == reflect.Bool:
+		return v.Bool(), true
+	case v.Type().Comparable():
+		return v.Interface(), true
+	}
+	return nil, false
+}
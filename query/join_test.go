@@ -0,0 +1,127 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type Customer struct {
+	Id   string
+	Name string
+	Tags []string
+}
+
+type joinExecutor struct {
+	spec JoinSpec
+}
+
+func (e *joinExecutor) ExecuteJoin(ctx context.Context, spec JoinSpec) ([]Row, error) {
+	e.spec = spec
+	return nil, nil
+}
+
+func TestJoin(t *testing.T) {
+	ctx := context.Background()
+	exec := &joinExecutor{}
+	_, err := NewJoin(ctx, exec, LeftJoin, Purchase{}, Customer{}).On("Region", "Id").
+		Where("Items", ">", 1).Where("Purchase.Status", "=", "open").Where("Customer.Name", "!=", "Ann").All()
+	if err != nil {
+		t.Fatalf("Failed to run join: %v", err)
+	}
+	expected := JoinSpec{
+		Kind:            LeftJoin,
+		Left:            Purchase{},
+		Right:           Customer{},
+		LeftField:       "Region",
+		RightField:      "Id",
+		LeftConditions:  []Condition{{"Items", Gt, 1}, {"Status", Eq, "open"}},
+		RightConditions: []Condition{{"Name", Ne, "Ann"}},
+	}
+	if !reflect.DeepEqual(exec.spec, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, exec.spec)
+	}
+
+	tests := []struct {
+		name string
+		join *Join
+		err  error
+	}{
+		{"invalid kind", NewJoin(ctx, exec, "outer", Purchase{}, Customer{}), ErrInvalidJoin},
+		{"invalid operator", NewJoin(ctx, exec, InnerJoin, Purchase{}, Customer{}).Where("Items", "like", 1), ErrInvalidOperator},
+		{"unknown table", NewJoin(ctx, exec, InnerJoin, Purchase{}, Customer{}).Where("Order.Items", "=", 1), ErrInvalidField},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.join.All(); !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v but got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestJoinSpec_Check(t *testing.T) {
+	valid := JoinSpec{Kind: InnerJoin, LeftField: "Region", RightField: "Id"}
+	with := func(change func(s *JoinSpec)) JoinSpec {
+		s := valid
+		change(&s)
+		return s
+	}
+	tests := []struct {
+		name string
+		spec JoinSpec
+		err  error
+	}{
+		{"valid", valid, nil},
+		{"invalid kind", with(func(s *JoinSpec) { s.Kind = "cross" }), ErrInvalidJoin},
+		{"unknown left field", with(func(s *JoinSpec) { s.LeftField = "Missing" }), ErrInvalidField},
+		{"unknown right field", with(func(s *JoinSpec) { s.RightField = "Region" }), ErrInvalidField},
+		{"unordered field", with(func(s *JoinSpec) { s.RightField = "Tags" }), ErrIncomparable},
+		{"left condition", with(func(s *JoinSpec) { s.LeftConditions = []Condition{{"Name", Eq, 1}} }), ErrInvalidField},
+		{"right condition", with(func(s *JoinSpec) { s.RightConditions = []Condition{{"Name", Gt, 1}} }), ErrIncomparable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.spec.Check(reflect.TypeOf(Purchase{}), reflect.TypeOf(Customer{})); !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v but got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	three := 3
+	var nilInt *int
+	tests := []struct {
+		name string
+		a, b interface{}
+	}{
+		{"int sizes", int8(3), int64(3)},
+		{"int and uint", 3, uint16(3)},
+		{"int and float", 3, 3.0},
+		{"large uint and float", uint64(math.MaxUint64 - 2047), float64(math.MaxUint64 - 2047)},
+		{"pointer", &three, 3},
+		{"named string", Status("open"), "open"},
+		{"bools", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, okA := HashKey(tt.a)
+			b, okB := HashKey(tt.b)
+			if !okA || !okB || a != b {
+				t.Errorf("Expected equal keys but got %v and %v", a, b)
+			}
+		})
+	}
+
+	if a, _ := HashKey(3.5); a == 3 || a == int64(3) {
+		t.Errorf("Expected 3.5 to keep its fraction but got %v", a)
+	}
+	for _, value := range []interface{}{nil, nilInt, math.NaN(), []int{1}} {
+		if _, ok := HashKey(value); ok {
+			t.Errorf("Expected no key for %v", value)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package query
+
+import "errors"
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions select a page of a table. Records are ordered by the primary
+// key, or by OrderBy and then the primary key. After is the Next cursor of
+// the previous page and must be used with the same order. A zero Limit
+// returns all remaining records.
+type ListOptions struct {
+	After   string
+	Limit   int
+	OrderBy string
+	Desc    bool
+}
+
+// Page is a page of records. Next is an opaque cursor safe to use in URLs
+// that continues after the last record of the page, it is empty when there
+// are no more records. A cursor stays valid when records are inserted or
+// deleted, the next page starts after the position of the last record even
+// when it was deleted.
+type Page struct {
+	Records []interface{}
+	Next    string
+}
@@ -0,0 +1,182 @@
+// Package query describes queries over the tables of a storage engine. A
+// Query is built with chained calls and handed to the Executor of the
+// engine as a Spec when it is run. The context a query is created with is
+// passed to the Executor, it can cancel a running query.
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidOperator = errors.New("invalid operator")
+	ErrInvalidField    = errors.New("invalid field")
+	ErrInvalidLimit    = errors.New("invalid limit or offset")
+	ErrIncomparable    = errors.New("incomparable values")
+	ErrNoRecords       = errors.New("no records")
+)
+
+// Operators supported by Where. In matches when the field equals one of the
+// elements of a slice or array value.
+const (
+	Eq = "="
+	Ne = "!="
+	Lt = "<"
+	Le = "<="
+	Gt = ">"
+	Ge = ">="
+	In = "in"
+)
+
+var operators = map[string]bool{Eq: true, Ne: true, Lt: true, Le: true, Gt: true, Ge: true, In: true}
+
+// Condition is a predicate on a field of a record.
+type Condition struct {
+	Field    string
+	Operator string
+	Value    interface{}
+}
+
+// Order sorts records by a field.
+type Order struct {
+	Field string
+	Desc  bool
+}
+
+// Spec describes a query for an Executor. A zero Limit means no limit unless
+// HasLimit is set, nil Fields select every field.
+type Spec struct {
+	TableType  interface{}
+	Fields     []string
+	Conditions []Condition
+	Orders     []Order
+	Limit      int
+	// HasLimit is set by Query.Limit so that a limit of zero returns no
+	// records
+	HasLimit bool
+	Offset   int
+}
+
+// Limited reports whether the spec limits the number of records.
+func (s Spec) Limited() bool {
+	return s.HasLimit || s.Limit > 0
+}
+
+// Executor runs queries against the tables of a storage engine. Records
+// are returned the way the engine returns them from Get.
+type Executor interface {
+	Execute(ctx context.Context, spec Spec) ([]interface{}, error)
+}
+
+// Query builds a Spec and runs it on an Executor. Errors in the chain are
+// kept and returned when the query is run.
+type Query struct {
+	ctx  context.Context
+	exec Executor
+	spec Spec
+	err  error
+}
+
+// New returns a query over the table of tableType that runs with ctx.
+func New(ctx context.Context, exec Executor, tableType interface{}) *Query {
+	return &Query{ctx: ctx, exec: exec, spec: Spec{TableType: tableType}}
+}
+
+// Select returns only the fields of the records, the other fields are not
+// decoded and keep their zero value.
+func (q *Query) Select(fields ...string) *Query {
+	q.spec.Fields = append(q.spec.Fields, fields...)
+	return q
+}
+
+// Where keeps the records whose field compares to value with the operator.
+// Conditions are combined with AND.
+func (q *Query) Where(field, operator string, value interface{}) *Query {
+	if !operators[operator] && q.err == nil {
+		q.err = fmt.Errorf("%q: %w", operator, ErrInvalidOperator)
+	}
+	q.spec.Conditions = append(q.spec.Conditions, Condition{Field: field, Operator: operator, Value: value})
+	return q
+}
+
+// OrderBy sorts the records by field in ascending order, later calls break
+// ties of earlier ones.
+func (q *Query) OrderBy(field string) *Query {
+	q.spec.Orders = append(q.spec.Orders, Order{Field: field})
+	return q
+}
+
+// OrderByDesc sorts the records by field in descending order.
+func (q *Query) OrderByDesc(field string) *Query {
+	q.spec.Orders = append(q.spec.Orders, Order{Field: field, Desc: true})
+	return q
+}
+
+// Limit returns at most n records, none when n is zero.
+func (q *Query) Limit(n int) *Query {
+	if n < 0 && q.err == nil {
+		q.err = fmt.Errorf("limit %d: %w", n, ErrInvalidLimit)
+	}
+	q.spec.Limit, q.spec.HasLimit = n, true
+	return q
+}
+
+// Offset skips the first n records.
+func (q *Query) Offset(n int) *Query {
+	if n < 0 && q.err == nil {
+		q.err = fmt.Errorf("offset %d: %w", n, ErrInvalidLimit)
+	}
+	q.spec.Offset = n
+	return q
+}
+
+// Spec returns the description of the query.
+func (q *Query) Spec() Spec {
+	return q.spec
+}
+
+// All runs the query and returns the matching records.
+func (q *Query) All() ([]interface{}, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return q.exec.Execute(q.ctx, q.spec)
+}
+
+// AllMaps runs the query and returns the selected fields of the matching
+// records as maps.
+func (q *Query) AllMaps() ([]map[string]interface{}, error) {
+	records, err := q.All()
+	if err != nil {
+		return nil, err
+	}
+	maps := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		m, err := Map(record, q.spec.Fields...)
+		if err != nil {
+			return nil, err
+		}
+		maps = append(maps, m)
+	}
+	return maps, nil
+}
+
+// First runs the query and returns the first matching record, or
+// ErrNoRecords.
+func (q *Query) First() (interface{}, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	spec := q.spec
+	spec.Limit, spec.HasLimit = 1, true
+	records, err := q.exec.Execute(q.ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrNoRecords
+	}
+	return records[0], nil
+}
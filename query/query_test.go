@@ -0,0 +1,130 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type Person struct {
+	Name string
+	Age  int
+}
+
+// recorder is an Executor returning fixed records and keeping the last
+// context and spec
+type recorder struct {
+	ctx     context.Context
+	spec    Spec
+	records []interface{}
+}
+
+func (r *recorder) Execute(ctx context.Context, spec Spec) ([]interface{}, error) {
+	r.ctx, r.spec = ctx, spec
+	return spec.Window(r.records), nil
+}
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	t.Run("builds spec", func(t *testing.T) {
+		exec := &recorder{}
+		_, err := New(ctx, exec, Person{}).
+			Select("Name").
+			Where("Age", ">", 30).
+			Where("Name", "in", []string{"a", "b"}).
+			OrderBy("Name").
+			OrderByDesc("Age").
+			Limit(20).
+			Offset(40).
+			All()
+		if err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		expected := Spec{
+			TableType: Person{},
+			Fields:    []string{"Name"},
+			Conditions: []Condition{
+				{Field: "Age", Operator: Gt, Value: 30},
+				{Field: "Name", Operator: In, Value: []string{"a", "b"}},
+			},
+			Orders:   []Order{{Field: "Name"}, {Field: "Age", Desc: true}},
+			Limit:    20,
+			HasLimit: true,
+			Offset:   40,
+		}
+		if !reflect.DeepEqual(exec.spec, expected) {
+			t.Errorf("Expected %+v but got %+v", expected, exec.spec)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			query *Query
+			err   error
+		}{
+			{"invalid operator", New(ctx, &recorder{}, Person{}).Where("Age", "~", 1), ErrInvalidOperator},
+			{"negative limit", New(ctx, &recorder{}, Person{}).Limit(-1), ErrInvalidLimit},
+			{"negative offset", New(ctx, &recorder{}, Person{}).Offset(-1), ErrInvalidLimit},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := tt.query.All(); !errors.Is(err, tt.err) {
+					t.Errorf("Expected %v but got %v", tt.err, err)
+				}
+				if _, err := tt.query.First(); !errors.Is(err, tt.err) {
+					t.Errorf("Expected %v but got %v", tt.err, err)
+				}
+			})
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		type key struct{}
+		exec := &recorder{}
+		queryCtx := context.WithValue(ctx, key{}, "query")
+		if _, err := New(queryCtx, exec, Person{}).All(); err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		if exec.ctx != queryCtx {
+			t.Errorf("Expected the context of the query to be passed to the executor")
+		}
+	})
+
+	t.Run("first", func(t *testing.T) {
+		exec := &recorder{records: []interface{}{&Person{Name: "a"}, &Person{Name: "b"}}}
+		record, err := New(ctx, exec, Person{}).Limit(5).First()
+		if err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		if record.(*Person).Name != "a" || exec.spec.Limit != 1 {
+			t.Errorf("Expected the first record with limit 1 but got %+v with limit %d", record, exec.spec.Limit)
+		}
+
+		_, err = New(ctx, &recorder{}, Person{}).First()
+		if err != ErrNoRecords {
+			t.Errorf("Expected ErrNoRecords but got %v", err)
+		}
+	})
+	t.Run("maps", func(t *testing.T) {
+		exec := &recorder{records: []interface{}{&Person{Name: "a", Age: 1}, Person{Name: "b", Age: 2}}}
+		maps, err := New(ctx, exec, Person{}).AllMaps()
+		if err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		expected := []map[string]interface{}{{"Name": "a", "Age": 1}, {"Name": "b", "Age": 2}}
+		if !reflect.DeepEqual(maps, expected) {
+			t.Errorf("Expected %v but got %v", expected, maps)
+		}
+
+		maps, err = New(ctx, exec, Person{}).Select("Age").AllMaps()
+		if err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		expected = []map[string]interface{}{{"Age": 1}, {"Age": 2}}
+		if !reflect.DeepEqual(maps, expected) {
+			t.Errorf("Expected %v but got %v", expected, maps)
+		}
+	})
+}
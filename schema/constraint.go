@@ -0,0 +1,315 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrConstraint = errors.New("constraint violated")
+var ErrInvalidConstraint = errors.New("invalid constraint")
+
+// Constraints are declared on the fields of a record type with a validate
+// tag listing rules separated by commas, as in
+//
+//	Age   int    `validate:"required,min=18,max=130"`
+//	Code  string `validate:"len=3,match=^[A-Z]+$"`
+//	State string `validate:"oneof=open closed"`
+//
+// The rules are:
+//
+//   - required: the value is not the zero value of its type
+//   - min=n, max=n: the value of a number, or the length of a string, slice,
+//     map or array, is at least or at most n
+//   - len=n: the length is exactly n, strings are measured in characters
+//   - oneof=a b c: a string or integer is one of the values separated by
+//     spaces
+//   - match=re: a string matches the regular expression re, match is the
+//     last rule of a tag as the expression takes the rest of the tag
+//
+// A record type may also have a Validate() error method, it is called once
+// the rules of every field hold.
+
+// Validator is implemented by record types that check their own values.
+type Validator interface {
+	Validate() error
+}
+
+// ConstraintError is a violated constraint of a record. Rule is the rule as
+// declared, or "Validate" for an error of the Validate method of the record
+// which is then in Err.
+type ConstraintError struct {
+	Table string
+	Field string
+	Rule  string
+	Err   error
+}
+
+func (e *ConstraintError) Error() string {
+	name := e.Table
+	if e.Field != "" {
+		name += "." + e.Field
+	}
+	err := e.Err
+	if err == nil {
+		err = ErrConstraint
+	}
+	return fmt.Sprintf("%s: %s: %v", name, e.Rule, err)
+}
+
+// Unwrap returns ErrConstraint and the error of the Validate method
+func (e *ConstraintError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrConstraint}
+	}
+	return []error{ErrConstraint, e.Err}
+}
+
+// Constraints are the parsed constraints of a record type.
+type Constraints struct {
+	table  string
+	fields []fieldRules
+}
+
+type fieldRules struct {
+	field reflect.StructField
+	rules []rule
+}
+
+// rule is a parsed rule, check reports whether a value holds it
+type rule struct {
+	text  string
+	check func(v reflect.Value) bool
+}
+
+// ParseConstraints returns the constraints declared on the fields of a
+// record type, rules that do not apply to the type of their field are
+// invalid.
+func ParseConstraints(t reflect.Type) (*Constraints, error) {
+	c := &Constraints{table: t.Name()}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("validate")
+		if !ok || tag == "" {
+			continue
+		}
+		if !f.IsExported() {
+			return nil, fmt.Errorf("%s.%s is not exported: %w", c.table, f.Name, ErrInvalidConstraint)
+		}
+		rules, err := parseRules(f.Type, tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v: %w", c.table, f.Name, err, ErrInvalidConstraint)
+		}
+		c.fields = append(c.fields, fieldRules{field: f, rules: rules})
+	}
+	return c, nil
+}
+
+// Check returns a ConstraintError for the first violated rule of a record,
+// in the order of the fields, then calls its Validate method.
+func (c *Constraints) Check(record interface{}) error {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	for _, f := range c.fields {
+		value := v.FieldByIndex(f.field.Index)
+		for _, r := range f.rules {
+			if !r.check(value) {
+				return &ConstraintError{Table: c.table, Field: f.field.Name, Rule: r.text}
+			}
+		}
+	}
+
+	// the method may have a pointer receiver
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	if validator, ok := p.Interface().(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return &ConstraintError{Table: c.table, Rule: "Validate", Err: err}
+		}
+	}
+	return nil
+}
+
+// parseRules parses the rules of a tag for a field of type t
+func parseRules(t reflect.Type, tag string) ([]rule, error) {
+	var rules []rule
+	for tag = strings.TrimSpace(tag); tag != ""; tag = strings.TrimSpace(tag) {
+		var text string
+		if strings.HasPrefix(tag, "match=") {
+			text, tag = tag, ""
+		} else {
+			text, tag, _ = strings.Cut(tag, ",")
+		}
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(text), "=")
+		if (name == "required") == hasArg {
+			return nil, fmt.Errorf("rule %q", text)
+		}
+		check, err := parseRule(t, name, arg)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", text, err)
+		}
+		rules = append(rules, rule{text: strings.TrimSpace(text), check: check})
+	}
+	return rules, nil
+}
+
+// parseRule returns the check of a rule for a field of type t
+func parseRule(t reflect.Type, name, arg string) (func(reflect.Value) bool, error) {
+	switch name {
+	case "required":
+		return func(v reflect.Value) bool {
+			return !v.IsZero()
+		}, nil
+	case "min", "max":
+		return parseBound(t, name == "min", arg)
+	case "len":
+		n, err := strconv.Atoi(arg)
+		if err != nil || !sized(t) {
+			return nil, errors.New("a length of a string, slice, map or array")
+		}
+		return func(v reflect.Value) bool {
+			return length(v) == n
+		}, nil
+	case "oneof":
+		if !integer(t) && t.Kind() != reflect.String {
+			return nil, errors.New("values of a string or integer")
+		}
+		var values []interface{}
+		for _, text := range strings.Fields(arg) {
+			value, err := parseValue(t, text)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value.Interface())
+		}
+		return func(v reflect.Value) bool {
+			for _, value := range values {
+				if value == v.Interface() {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case "match":
+		if t.Kind() != reflect.String {
+			return nil, errors.New("an expression for a string")
+		}
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) bool {
+			return re.MatchString(v.String())
+		}, nil
+	}
+	return nil, errors.New("unknown rule")
+}
+
+// parseBound returns the check of a min or max rule
+func parseBound(t reflect.Type, min bool, arg string) (func(reflect.Value) bool, error) {
+	within := func(c int) bool {
+		if min {
+			return c >= 0
+		}
+		return c <= 0
+	}
+	if sized(t) {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) bool {
+			return within(compare(length(v), n))
+		}, nil
+	}
+	bound, err := parseValue(t, arg)
+	if err != nil {
+		return nil, err
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value) bool {
+			return within(compare(v.Int(), bound.Int()))
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value) bool {
+			return within(compare(v.Uint(), bound.Uint()))
+		}, nil
+	default:
+		return func(v reflect.Value) bool {
+			return within(compare(v.Float(), bound.Float()))
+		}, nil
+	}
+}
+
+// parseValue parses the text of a value for a field of type t
+func parseValue(t reflect.Type, text string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		v.SetString(text)
+	default:
+		return reflect.Value{}, fmt.Errorf("a value for %v", t)
+	}
+	return v, nil
+}
+
+func integer(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// sized reports whether values of type t are measured by their length
+func sized(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// length returns the length of a value, in characters for a string
+func length(v reflect.Value) int {
+	if v.Kind() == reflect.String {
+		return utf8.RuneCountInString(v.String())
+	}
+	return v.Len()
+}
+
+func compare[T int | int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
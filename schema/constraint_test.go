@@ -0,0 +1,146 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type Customer struct {
+	Name    string   `validate:"required,max=5"`
+	Age     int      `validate:"min=18,max=130"`
+	Score   float64  `validate:"min=0.5"`
+	Level   uint8    `validate:"oneof=1 2 3"`
+	Country string   `validate:"len=2,match=^[A-Z]{2}$"`
+	State   string   `validate:"oneof=open closed"`
+	Tags    []string `validate:"max=2"`
+	Note    string
+}
+
+var errBanned = errors.New("banned")
+
+// Validate rejects a banned name
+func (c *Customer) Validate() error {
+	if c.Name == "eve" {
+		return errBanned
+	}
+	return nil
+}
+
+func TestConstraints_Check(t *testing.T) {
+	constraints, err := ParseConstraints(reflect.TypeOf(Customer{}))
+	if err != nil {
+		t.Fatalf("Failed to parse constraints: %v", err)
+	}
+	valid := Customer{Name: "ann", Age: 30, Score: 1, Level: 2, Country: "IN", State: "open"}
+	if err := constraints.Check(valid); err != nil {
+		t.Fatalf("Expected a valid record but got %v", err)
+	}
+	if err := constraints.Check(&valid); err != nil {
+		t.Fatalf("Expected a valid record but got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Customer)
+		field  string
+		rule   string
+	}{
+		{"required", func(c *Customer) { c.Name = "" }, "Name", "required"},
+		{"max length", func(c *Customer) { c.Name = "annabel" }, "Name", "max=5"},
+		{"min", func(c *Customer) { c.Age = 17 }, "Age", "min=18"},
+		{"max", func(c *Customer) { c.Age = 131 }, "Age", "max=130"},
+		{"float min", func(c *Customer) { c.Score = 0.4 }, "Score", "min=0.5"},
+		{"integer oneof", func(c *Customer) { c.Level = 4 }, "Level", "oneof=1 2 3"},
+		{"len", func(c *Customer) { c.Country = "IND" }, "Country", "len=2"},
+		{"match", func(c *Customer) { c.Country = "in" }, "Country", "match=^[A-Z]{2}$"},
+		{"string oneof", func(c *Customer) { c.State = "opened" }, "State", "oneof=open closed"},
+		{"slice max", func(c *Customer) { c.Tags = []string{"a", "b", "c"} }, "Tags", "max=2"},
+		{"validate", func(c *Customer) { c.Name = "eve" }, "", "Validate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := valid
+			tt.change(&record)
+			err := constraints.Check(record)
+			var constraintErr *ConstraintError
+			if !errors.As(err, &constraintErr) || !errors.Is(err, ErrConstraint) {
+				t.Fatalf("Expected a %v but got %v", ErrConstraint, err)
+			}
+			expected := ConstraintError{Table: "Customer", Field: tt.field, Rule: tt.rule, Err: constraintErr.Err}
+			if *constraintErr != expected {
+				t.Errorf("Expected %+v but got %+v", expected, *constraintErr)
+			}
+		})
+	}
+
+	record := valid
+	record.Name = "eve"
+	err = constraints.Check(record)
+	if !errors.Is(err, errBanned) {
+		t.Errorf("Expected %v but got %v", errBanned, err)
+	}
+	if expected := "Customer: Validate: banned"; err.Error() != expected {
+		t.Errorf("Expected %q but got %q", expected, err.Error())
+	}
+	record.Name = ""
+	if expected := "Customer.Name: required: constraint violated"; constraints.Check(record).Error() != expected {
+		t.Errorf("Expected %q but got %q", expected, constraints.Check(record).Error())
+	}
+}
+
+func TestParseConstraints(t *testing.T) {
+	tests := []struct {
+		name   string
+		record interface{}
+	}{
+		{"unknown rule", struct {
+			A string `validate:"email"`
+		}{}},
+		{"missing argument", struct {
+			A int `validate:"min"`
+		}{}},
+		{"required with argument", struct {
+			A int `validate:"required=1"`
+		}{}},
+		{"invalid bound", struct {
+			A int `validate:"max=1.5"`
+		}{}},
+		{"bound out of range", struct {
+			A int8 `validate:"max=300"`
+		}{}},
+		{"len of a number", struct {
+			A int `validate:"len=2"`
+		}{}},
+		{"match of a number", struct {
+			A int `validate:"match=^1$"`
+		}{}},
+		{"invalid expression", struct {
+			A string `validate:"match=("`
+		}{}},
+		{"oneof of a float", struct {
+			A float64 `validate:"oneof=1 2"`
+		}{}},
+		{"unexported", struct {
+			a int `validate:"required"`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseConstraints(reflect.TypeOf(tt.record)); !errors.Is(err, ErrInvalidConstraint) {
+				t.Errorf("Expected %v but got %v", ErrInvalidConstraint, err)
+			}
+		})
+	}
+
+	type Code struct {
+		Value string `validate:"required, match=^[a-z]+(,[a-z]+)*$"`
+	}
+	constraints, err := ParseConstraints(reflect.TypeOf(Code{}))
+	if err != nil {
+		t.Fatalf("Failed to parse constraints: %v", err)
+	}
+	if err := constraints.Check(Code{Value: "a,b"}); err != nil {
+		t.Errorf("Expected the expression to take the rest of the tag but got %v", err)
+	}
+}
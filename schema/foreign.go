@@ -0,0 +1,81 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var ErrInvalidForeignKey = errors.New("invalid foreign key")
+
+// Action is what deleting a referenced record does to the records that
+// reference it.
+type Action string
+
+// Actions on delete. Restrict fails the delete while records reference the
+// record, Cascade deletes them and SetNull sets their field to its zero
+// value.
+const (
+	Restrict Action = "restrict"
+	Cascade  Action = "cascade"
+	SetNull  Action = "set null"
+)
+
+// ForeignKey is a field referencing the primary key of a table. A field
+// holding its zero value references nothing.
+type ForeignKey struct {
+	Field    string
+	Table    string
+	OnDelete Action
+}
+
+// References declares a foreign key, it is the option form of a references
+// tag on the field, as in
+//
+//	CustomerId int `references:"Customer"`
+//	ParentId   int `references:"Folder,cascade"`
+//	OwnerId    int `references:"User,set null"`
+//
+// The action on delete defaults to Restrict.
+func References(field, table string, onDelete Action) Option {
+	return func(t *Table) {
+		t.ForeignKeys = append(t.ForeignKeys, ForeignKey{Field: field, Table: table, OnDelete: onDelete})
+	}
+}
+
+// ParseForeignKeys returns the foreign keys declared by the references tags
+// of a record type.
+func ParseForeignKeys(t reflect.Type) ([]ForeignKey, error) {
+	var keys []ForeignKey
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("references")
+		if !ok {
+			continue
+		}
+		table, action, _ := strings.Cut(tag, ",")
+		fk := ForeignKey{Field: f.Name, Table: strings.TrimSpace(table), OnDelete: Action(strings.TrimSpace(action))}
+		if err := fk.Check(); err != nil {
+			return nil, err
+		}
+		keys = append(keys, fk)
+	}
+	return keys, nil
+}
+
+// Check validates the declaration of a foreign key, an empty action is set
+// to Restrict.
+func (fk *ForeignKey) Check() error {
+	if fk.OnDelete == "" {
+		fk.OnDelete = Restrict
+	}
+	if fk.Field == "" || fk.Table == "" {
+		return fmt.Errorf("%q references %q: %w", fk.Field, fk.Table, ErrInvalidForeignKey)
+	}
+	switch fk.OnDelete {
+	case Restrict, Cascade, SetNull:
+		return nil
+	}
+	return fmt.Errorf("%q on delete of %q: %w", fk.OnDelete, fk.Field, ErrInvalidForeignKey)
+}
@@ -0,0 +1,52 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseForeignKeys(t *testing.T) {
+	type Order struct {
+		Id         int
+		CustomerId int    `references:"Customer"`
+		ShopId     string `references:"Shop, cascade"`
+		CouponId   string `references:"Coupon,set null"`
+	}
+	keys, err := ParseForeignKeys(reflect.TypeOf(Order{}))
+	if err != nil {
+		t.Fatalf("Failed to parse foreign keys: %v", err)
+	}
+	expected := []ForeignKey{
+		{Field: "CustomerId", Table: "Customer", OnDelete: Restrict},
+		{Field: "ShopId", Table: "Shop", OnDelete: Cascade},
+		{Field: "CouponId", Table: "Coupon", OnDelete: SetNull},
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected %v but got %v", expected, keys)
+	}
+
+	tests := []struct {
+		name   string
+		record interface{}
+	}{
+		{"no table", struct {
+			A int `references:""`
+		}{}},
+		{"unknown action", struct {
+			A int `references:"B,ignore"`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseForeignKeys(reflect.TypeOf(tt.record)); !errors.Is(err, ErrInvalidForeignKey) {
+				t.Errorf("Expected %v but got %v", ErrInvalidForeignKey, err)
+			}
+		})
+	}
+
+	table := Options(References("A", "B", ""), References("C", "D", Cascade))
+	if len(table.ForeignKeys) != 2 || table.ForeignKeys[1].OnDelete != Cascade {
+		t.Errorf("Expected two foreign keys but got %v", table.ForeignKeys)
+	}
+}
@@ -0,0 +1,110 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidEvent = errors.New("invalid hook event")
+var ErrAfterHook = errors.New("after hook failed")
+
+// Event names when a hook runs.
+type Event string
+
+// Events of hooks. Before hooks run as part of the write, they may change
+// the record about to be written and an error aborts the write, or the
+// whole batch the write belongs to. Before hooks run before the constraints
+// of the record are checked. After hooks run once the write is applied, an
+// error of an after hook does not undo the write and is returned wrapped
+// with ErrAfterHook, telling it apart from an error of the write.
+const (
+	BeforeInsert Event = "before insert"
+	AfterInsert  Event = "after insert"
+	BeforeUpdate Event = "before update"
+	AfterUpdate  Event = "after update"
+	BeforeDelete Event = "before delete"
+	AfterDelete  Event = "after delete"
+	AfterGet     Event = "after get"
+)
+
+// Check validates the event.
+func (e Event) Check() error {
+	switch e {
+	case BeforeInsert, AfterInsert, BeforeUpdate, AfterUpdate, BeforeDelete, AfterDelete, AfterGet:
+		return nil
+	}
+	return fmt.Errorf("%q: %w", e, ErrInvalidEvent)
+}
+
+// Hook is a hook registered for every table of an engine. Record is a
+// pointer to the record, the hooks of a delete get the deleted record.
+type Hook func(ctx context.Context, table string, record interface{}) error
+
+// Record types implement the hooks of the events they handle, usually with
+// a pointer receiver to change the record. The hook of the record type runs
+// before the hooks registered on the engine.
+
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context) error
+}
+
+type AfterInserter interface {
+	AfterInsert(ctx context.Context) error
+}
+
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+type AfterUpdater interface {
+	AfterUpdate(ctx context.Context) error
+}
+
+type BeforeDeleter interface {
+	BeforeDelete(ctx context.Context) error
+}
+
+type AfterDeleter interface {
+	AfterDelete(ctx context.Context) error
+}
+
+type AfterGetter interface {
+	AfterGet(ctx context.Context) error
+}
+
+// RecordHook returns the hook of a record for an event, record is a pointer
+// to the record.
+func RecordHook(event Event, record interface{}) (func(ctx context.Context) error, bool) {
+	switch event {
+	case BeforeInsert:
+		if h, ok := record.(BeforeInserter); ok {
+			return h.BeforeInsert, true
+		}
+	case AfterInsert:
+		if h, ok := record.(AfterInserter); ok {
+			return h.AfterInsert, true
+		}
+	case BeforeUpdate:
+		if h, ok := record.(BeforeUpdater); ok {
+			return h.BeforeUpdate, true
+		}
+	case AfterUpdate:
+		if h, ok := record.(AfterUpdater); ok {
+			return h.AfterUpdate, true
+		}
+	case BeforeDelete:
+		if h, ok := record.(BeforeDeleter); ok {
+			return h.BeforeDelete, true
+		}
+	case AfterDelete:
+		if h, ok := record.(AfterDeleter); ok {
+			return h.AfterDelete, true
+		}
+	case AfterGet:
+		if h, ok := record.(AfterGetter); ok {
+			return h.AfterGet, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type Note struct {
+	Text string
+}
+
+func (n *Note) BeforeInsert(ctx context.Context) error {
+	n.Text = "before insert"
+	return nil
+}
+
+func (n Note) AfterGet(ctx context.Context) error {
+	return errors.New("after get")
+}
+
+func TestRecordHook(t *testing.T) {
+	note := &Note{}
+	tests := []struct {
+		event Event
+		found bool
+	}{
+		{BeforeInsert, true},
+		{AfterGet, true},
+		{AfterInsert, false},
+		{BeforeUpdate, false},
+		{BeforeDelete, false},
+	}
+	for _, tt := range tests {
+		hook, ok := RecordHook(tt.event, note)
+		if ok != tt.found {
+			t.Errorf("Expected %v for %q but got %v", tt.found, tt.event, ok)
+		}
+		if ok {
+			_ = hook(context.Background())
+		}
+	}
+	if note.Text != "before insert" {
+		t.Errorf("Expected the hook to change the record but got %q", note.Text)
+	}
+	if _, ok := RecordHook(BeforeInsert, Note{}); ok {
+		t.Errorf("Expected no hook with a pointer receiver on a record value")
+	}
+}
+
+func TestEvent_Check(t *testing.T) {
+	for _, event := range []Event{BeforeInsert, AfterInsert, BeforeUpdate, AfterUpdate, BeforeDelete, AfterDelete, AfterGet} {
+		if err := event.Check(); err != nil {
+			t.Errorf("Expected %q to be valid but got %v", event, err)
+		}
+	}
+	if err := Event("before get").Check(); !errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("Expected %v but got %v", ErrInvalidEvent, err)
+	}
+}
@@ -0,0 +1,100 @@
+package schema
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// NewUUIDv4 returns a random UUID.
+func NewUUIDv4() [16]byte {
+	var id [16]byte
+	random(id[:])
+	id[6] = id[6]&0x0F | 0x40
+	id[8] = id[8]&0x3F | 0x80
+	return id
+}
+
+// NewUUIDv7 returns a UUID starting with the unix time of now in
+// milliseconds followed by random bits.
+func NewUUIDv7(now time.Time) [16]byte {
+	var id [16]byte
+	putMillis(id[:], now)
+	random(id[6:])
+	id[6] = id[6]&0x0F | 0x70
+	id[8] = id[8]&0x3F | 0x80
+	return id
+}
+
+// NewULID returns a ULID, 48 bits of the unix time of now in milliseconds
+// followed by 80 random bits.
+func NewULID(now time.Time) [16]byte {
+	var id [16]byte
+	putMillis(id[:], now)
+	random(id[6:])
+	return id
+}
+
+// FormatUUID returns the text form of a UUID, as in
+// "0188e4f4-ce2a-7d3e-9c1b-5f2a3b4c5d6e".
+func FormatUUID(id [16]byte) string {
+	s := hex.EncodeToString(id[:])
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// FormatULID returns the 26 characters of the Crockford base32 text form of
+// a ULID.
+func FormatULID(id [16]byte) string {
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	var text [26]byte
+	for i := len(text) - 1; i >= 0; i-- {
+		text[i] = crockford[lo&0x1F]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(text[:])
+}
+
+// Value returns a new identifier of the generator as a value of a key field
+// of type t, a [16]byte or a string. Sequences are issued by the engine.
+func (g Generator) Value(t reflect.Type, now time.Time) (reflect.Value, error) {
+	if err := g.Check(t); err != nil {
+		return reflect.Value{}, err
+	}
+	var id [16]byte
+	text := FormatUUID
+	switch g {
+	case UUIDv4:
+		id = NewUUIDv4()
+	case UUIDv7:
+		id = NewUUIDv7(now)
+	case ULID:
+		id, text = NewULID(now), FormatULID
+	default:
+		return reflect.Value{}, fmt.Errorf("%q is issued by the engine: %w", g, ErrInvalidGenerator)
+	}
+	v := reflect.New(t).Elem()
+	if t.Kind() == reflect.String {
+		v.SetString(text(id))
+	} else {
+		reflect.Copy(v, reflect.ValueOf(id[:]))
+	}
+	return v, nil
+}
+
+func putMillis(b []byte, now time.Time) {
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(now.UnixMilli()))
+	copy(b[:6], ms[2:])
+}
+
+func random(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("schema: reading random bytes: %v", err))
+	}
+}
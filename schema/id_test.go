@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNewUUIDv4(t *testing.T) {
+	a, b := NewUUIDv4(), NewUUIDv4()
+	if a == b {
+		t.Errorf("Expected distinct UUIDs but got %v twice", FormatUUID(a))
+	}
+	if a[6]>>4 != 4 || a[8]>>6 != 2 {
+		t.Errorf("Expected version 4 and the RFC variant but got %v", FormatUUID(a))
+	}
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if text := FormatUUID(a); !pattern.MatchString(text) {
+		t.Errorf("Expected the text form of a UUID but got %q", text)
+	}
+}
+
+func TestNewUUIDv7(t *testing.T) {
+	now := time.UnixMilli(1700000000123)
+	id := NewUUIDv7(now)
+	var ms [8]byte
+	copy(ms[2:], id[:6])
+	if got := binary.BigEndian.Uint64(ms[:]); got != 1700000000123 {
+		t.Errorf("Expected the time in milliseconds but got %d", got)
+	}
+	if id[6]>>4 != 7 || id[8]>>6 != 2 {
+		t.Errorf("Expected version 7 and the RFC variant but got %v", FormatUUID(id))
+	}
+	if later := NewUUIDv7(now.Add(time.Millisecond)); string(later[:]) <= string(id[:]) {
+		t.Errorf("Expected %v to sort after %v", FormatUUID(later), FormatUUID(id))
+	}
+}
+
+func TestFormatULID(t *testing.T) {
+	// the example of the ULID specification
+	id := NewULID(time.UnixMilli(1469918176385))
+	text := FormatULID(id)
+	if len(text) != 26 || text[:10] != "01ARYZ6S41" {
+		t.Errorf("Expected a ULID starting with 01ARYZ6S41 but got %q", text)
+	}
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xFF
+	}
+	if text := FormatULID(max); text != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Errorf("Expected the largest ULID but got %q", text)
+	}
+}
+
+func TestGenerator_Value(t *testing.T) {
+	now := time.UnixMilli(1469918176385)
+	v, err := ULID.Value(reflect.TypeOf(""), now)
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if text := v.String(); len(text) != 26 || text[:10] != "01ARYZ6S41" {
+		t.Errorf("Expected the text of a ULID but got %q", text)
+	}
+	v, err = UUIDv4.Value(reflect.TypeOf([16]byte{}), now)
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if id := v.Interface().([16]byte); id[6]>>4 != 4 {
+		t.Errorf("Expected a version 4 UUID but got %v", FormatUUID(id))
+	}
+	if _, err := Sequence.Value(reflect.TypeOf(0), now); !errors.Is(err, ErrInvalidGenerator) {
+		t.Errorf("Expected %v but got %v", ErrInvalidGenerator, err)
+	}
+}
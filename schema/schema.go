@@ -0,0 +1,95 @@
+// Package schema describes the options a table of a storage engine is
+// created with and the constraints its records are checked against. Options
+// are passed to CreateTable and applied by the engine, constraints are
+// declared by the tags of the record type.
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var ErrInvalidGenerator = errors.New("invalid key generator")
+
+// Generator names how the values of a generated primary key are made.
+type Generator string
+
+// Generators of primary key values. A Sequence issues 1, 2, 3 and so on per
+// table and needs an integer key field, its values are never issued twice,
+// not even when the write they were issued for fails. The others make
+// random 128 bit identifiers stored in a [16]byte field or as text in a
+// string field. UUIDv7 and ULID start with the time in milliseconds so that
+// their keys sort by creation time.
+const (
+	Sequence Generator = "sequence"
+	UUIDv4   Generator = "uuidv4"
+	UUIDv7   Generator = "uuidv7"
+	ULID     Generator = "ulid"
+)
+
+// Table holds the options of a table.
+type Table struct {
+	// Generator fills the primary key of inserted records when set
+	Generator Generator
+	// Sequence is the last value a Sequence generator issued before the
+	// table was created again
+	Sequence uint64
+	// ForeignKeys are declared in addition to the references tags
+	ForeignKeys []ForeignKey
+	// TTL is the time records live for unless they set their expiry
+	TTL time.Duration
+}
+
+// Option sets an option of a table.
+type Option func(*Table)
+
+// Options returns the options of a table set by opts.
+func Options(opts ...Option) Table {
+	var t Table
+	for _, opt := range opts {
+		opt(&t)
+	}
+	return t
+}
+
+// GeneratedKey makes the engine generate the primary key of the records
+// inserted with a zero key, a record with a key set keeps it. The key must
+// be a single field. The generated key is set on records inserted through a
+// pointer once the insert succeeded.
+func GeneratedKey(g Generator) Option {
+	return func(t *Table) {
+		t.Generator = g
+	}
+}
+
+// ResumeSequence makes a Sequence generator continue after last, the last
+// value it issued before the table was created again. A persistent engine
+// saves the sequence of a table and resumes it when it opens the table so
+// that no value is issued twice across restarts, not even the values of
+// deleted records or failed writes.
+func ResumeSequence(last uint64) Option {
+	return func(t *Table) {
+		t.Sequence = last
+	}
+}
+
+// Check validates that the generator can fill a key field of type t.
+func (g Generator) Check(t reflect.Type) error {
+	switch g {
+	case Sequence:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return nil
+		}
+	case UUIDv4, UUIDv7, ULID:
+		if t.Kind() == reflect.String || (t.Kind() == reflect.Array && t.Len() == 16 && t.Elem().Kind() == reflect.Uint8) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("%q: %w", g, ErrInvalidGenerator)
+	}
+	return fmt.Errorf("%q for a key of type %v: %w", g, t, ErrInvalidGenerator)
+}
@@ -0,0 +1,48 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	if table := Options(); !reflect.DeepEqual(table, Table{}) {
+		t.Errorf("Expected no options but got %+v", table)
+	}
+	if table := Options(GeneratedKey(UUIDv4), GeneratedKey(Sequence)); table.Generator != Sequence {
+		t.Errorf("Expected the last generator but got %q", table.Generator)
+	}
+	if table := Options(GeneratedKey(Sequence), ResumeSequence(7)); table.Sequence != 7 {
+		t.Errorf("Expected the sequence to resume after 7 but got %d", table.Sequence)
+	}
+}
+
+func TestGenerator_Check(t *testing.T) {
+	type Name string
+	tests := []struct {
+		generator Generator
+		value     interface{}
+		valid     bool
+	}{
+		{Sequence, int64(0), true},
+		{Sequence, uint8(0), true},
+		{Sequence, "", false},
+		{UUIDv4, [16]byte{}, true},
+		{UUIDv7, Name(""), true},
+		{ULID, "", true},
+		{ULID, [8]byte{}, false},
+		{UUIDv4, []byte{}, false},
+		{UUIDv4, 0, false},
+		{"random", "", false},
+	}
+	for _, tt := range tests {
+		err := tt.generator.Check(reflect.TypeOf(tt.value))
+		if tt.valid && err != nil {
+			t.Errorf("Expected %q to fill %T but got %v", tt.generator, tt.value, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidGenerator) {
+			t.Errorf("Expected %v for %q and %T but got %v", ErrInvalidGenerator, tt.generator, tt.value, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var ErrInvalidTTL = errors.New("invalid ttl")
+
+// TTL makes the records of a table expire d after they were inserted,
+// unless their insert or expiry field sets another expiry.
+func TTL(d time.Duration) Option {
+	return func(t *Table) {
+		t.TTL = d
+	}
+}
+
+// ExpiryField returns the name of the field of a record type marked with
+// a ttl:"expiry" tag, as in
+//
+//	ExpiresAt int64 `ttl:"expiry"`
+//
+// The field must be an exported int64 holding the time the record expires
+// at in Unix milliseconds, as from time.Time.UnixMilli, zero when the record
+// does not set its expiry. Other types, time.Time included, are rejected
+// with ErrInvalidTTL. The name is empty when no field is marked.
+func ExpiryField(t reflect.Type) (string, error) {
+	var name string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("ttl")
+		if !ok {
+			continue
+		}
+		switch {
+		case tag != "expiry":
+			return "", fmt.Errorf("%s.%s: unknown ttl tag %q: %w", t.Name(), f.Name, tag, ErrInvalidTTL)
+		case !f.IsExported() || f.Type.Kind() != reflect.Int64:
+			return "", fmt.Errorf("%s.%s: expiry field must be an exported int64 of Unix milliseconds, not %v: %w", t.Name(), f.Name, f.Type, ErrInvalidTTL)
+		case name != "":
+			return "", fmt.Errorf("%s.%s: second expiry field after %s: %w", t.Name(), f.Name, name, ErrInvalidTTL)
+		}
+		name = f.Name
+	}
+	return name, nil
+}
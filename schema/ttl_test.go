@@ -0,0 +1,55 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpiryField(t *testing.T) {
+	type Session struct {
+		Id        string
+		ExpiresAt int64 `ttl:"expiry"`
+	}
+	name, err := ExpiryField(reflect.TypeOf(Session{}))
+	if err != nil || name != "ExpiresAt" {
+		t.Errorf("Expected ExpiresAt but got %q, %v", name, err)
+	}
+	if name, err := ExpiryField(reflect.TypeOf(struct{ Id int }{})); err != nil || name != "" {
+		t.Errorf("Expected no field but got %q, %v", name, err)
+	}
+
+	tests := []struct {
+		name   string
+		record interface{}
+	}{
+		{"not int64", struct {
+			A int `ttl:"expiry"`
+		}{}},
+		{"time", struct {
+			A time.Time `ttl:"expiry"`
+		}{}},
+		{"unknown tag", struct {
+			A int64 `ttl:"expires"`
+		}{}},
+		{"unexported", struct {
+			a int64 `ttl:"expiry"`
+		}{}},
+		{"two fields", struct {
+			A int64 `ttl:"expiry"`
+			B int64 `ttl:"expiry"`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ExpiryField(reflect.TypeOf(tt.record)); !errors.Is(err, ErrInvalidTTL) {
+				t.Errorf("Expected %v but got %v", ErrInvalidTTL, err)
+			}
+		})
+	}
+
+	if table := Options(TTL(time.Minute)); table.TTL != time.Minute {
+		t.Errorf("Expected %v but got %v", time.Minute, table.TTL)
+	}
+}
@@ -0,0 +1,473 @@
+package sql
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	storage "github.com/priyanshujain/go-storage"
+	"github.com/priyanshujain/go-storage/query"
+	"github.com/priyanshujain/go-storage/schema"
+)
+
+var ErrUnknownType = errors.New("unknown record type")
+var ErrStatement = errors.New("unexpected statement")
+var ErrInvalidValue = errors.New("invalid value")
+var ErrPrimaryKeyUpdate = errors.New("primary key can not be updated")
+var ErrInvalidDestination = errors.New("invalid destination")
+
+// DB runs statements against a storage engine. Tables are found by the name
+// of the struct type they were created with, CREATE TABLE needs the type to
+// be registered first.
+type DB struct {
+	storage storage.Storage
+	types   map[string]reflect.Type
+}
+
+func New(s storage.Storage) *DB {
+	return &DB{storage: s, types: make(map[string]reflect.Type)}
+}
+
+// Register makes the struct types of the values available to CREATE TABLE,
+// a pointer registers the type it points to.
+func (db *DB) Register(values ...interface{}) {
+	for _, value := range values {
+		t := reflect.TypeOf(value)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		db.types[t.Name()] = t
+	}
+}
+
+// table describes a table a statement refers to
+type table struct {
+	recordType reflect.Type
+	pk         []string
+}
+
+// zero returns the value the engine expects as the table type
+func (t table) zero() interface{} {
+	return reflect.Zero(t.recordType).Interface()
+}
+
+// field finds the exported field of a column, names are matched without
+// regard to case when there is no exact match
+func (t table) field(column string) (reflect.StructField, error) {
+	f, ok := t.recordType.FieldByName(column)
+	if !ok {
+		f, ok = t.recordType.FieldByNameFunc(func(name string) bool {
+			return strings.EqualFold(name, column)
+		})
+	}
+	if !ok || !f.IsExported() {
+		return reflect.StructField{}, fmt.Errorf("%q: %w", column, query.ErrInvalidField)
+	}
+	return f, nil
+}
+
+// key returns the primary key value of a record, the values of a composite
+// key in a []interface{}
+func (t table) key(record reflect.Value) interface{} {
+	if len(t.pk) == 1 {
+		return record.FieldByName(t.pk[0]).Interface()
+	}
+	values := make([]interface{}, len(t.pk))
+	for i, name := range t.pk {
+		values[i] = record.FieldByName(name).Interface()
+	}
+	return values
+}
+
+// columns returns the exported fields in declaration order
+func (t table) columns() []string {
+	var columns []string
+	for _, f := range reflect.VisibleFields(t.recordType) {
+		if f.IsExported() && !f.Anonymous {
+			columns = append(columns, f.Name)
+		}
+	}
+	return columns
+}
+
+func (db *DB) table(ctx context.Context, name string) (table, error) {
+	recordType, pk, err := db.storage.TableSchema(ctx, name)
+	if err != nil {
+		return table{}, err
+	}
+	return table{recordType: recordType, pk: strings.Split(pk, ",")}, nil
+}
+
+// Exec runs an INSERT, UPDATE, DELETE or CREATE TABLE statement and returns
+// the number of records it changed. The writes of a statement are applied
+// as one batch, a statement failing on any record changes none.
+func (db *DB) Exec(ctx context.Context, statement string) (int, error) {
+	stmt, err := Parse(statement)
+	if err != nil {
+		return 0, err
+	}
+	switch stmt := stmt.(type) {
+	case *Insert:
+		return db.insert(ctx, stmt)
+	case *Update:
+		return db.update(ctx, stmt)
+	case *Delete:
+		return db.delete(ctx, stmt)
+	case *CreateTable:
+		return 0, db.createTable(ctx, stmt)
+	}
+	return 0, fmt.Errorf("SELECT in Exec: %w", ErrStatement)
+}
+
+// Query runs a SELECT statement and returns a map of the selected columns
+// for each record.
+func (db *DB) Query(ctx context.Context, statement string) ([]map[string]interface{}, error) {
+	t, columns, records, err := db.selectRecords(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		v := reflect.Indirect(reflect.ValueOf(record))
+		row := make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			f, _ := t.field(column)
+			row[f.Name] = v.FieldByIndex(f.Index).Interface()
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// QueryStructs runs a SELECT statement and stores the records in dest, a
+// pointer to a slice of structs or of pointers to structs. The selected
+// columns are copied to the fields of the same name, so dest does not have
+// to be the record type of the table.
+func (db *DB) QueryStructs(ctx context.Context, statement string, dest interface{}) error {
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Ptr || slice.IsNil() || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("%T: %w", dest, ErrInvalidDestination)
+	}
+	slice = slice.Elem()
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if elemType.Kind() == reflect.Ptr {
+		structType = elemType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("%T: %w", dest, ErrInvalidDestination)
+	}
+
+	t, columns, records, err := db.selectRecords(ctx, statement)
+	if err != nil {
+		return err
+	}
+	result := reflect.MakeSlice(slice.Type(), 0, len(records))
+	for _, record := range records {
+		v := reflect.Indirect(reflect.ValueOf(record))
+		elem := reflect.New(structType).Elem()
+		for _, column := range columns {
+			f, _ := t.field(column)
+			target := elem.FieldByName(f.Name)
+			if !target.IsValid() || !target.CanSet() {
+				continue
+			}
+			value := v.FieldByIndex(f.Index)
+			if !value.Type().AssignableTo(target.Type()) {
+				return fmt.Errorf("column %q of type %v into %v: %w", f.Name, value.Type(), target.Type(), ErrInvalidDestination)
+			}
+			target.Set(value)
+		}
+		if elemType.Kind() == reflect.Ptr {
+			elem = elem.Addr()
+		}
+		result = reflect.Append(result, elem)
+	}
+	slice.Set(result)
+	return nil
+}
+
+// selectRecords runs a SELECT statement and returns its table, the selected
+// columns and the records
+func (db *DB) selectRecords(ctx context.Context, statement string) (table, []string, []interface{}, error) {
+	stmt, err := Parse(statement)
+	if err != nil {
+		return table{}, nil, nil, err
+	}
+	sel, ok := stmt.(*Select)
+	if !ok {
+		return table{}, nil, nil, fmt.Errorf("%T in Query: %w", stmt, ErrStatement)
+	}
+	t, err := db.table(ctx, sel.Table)
+	if err != nil {
+		return table{}, nil, nil, err
+	}
+
+	columns := sel.Columns
+	if columns == nil {
+		columns = t.columns()
+	}
+	fields := make([]string, len(columns))
+	for i, column := range columns {
+		f, err := t.field(column)
+		if err != nil {
+			return table{}, nil, nil, err
+		}
+		fields[i] = f.Name
+	}
+
+	q, err := db.query(ctx, t, sel.Where)
+	if err != nil {
+		return table{}, nil, nil, err
+	}
+	if sel.Columns != nil {
+		// decode only the selected columns
+		q.Select(fields...)
+	}
+	for _, order := range sel.OrderBy {
+		f, err := t.field(order.Field)
+		if err != nil {
+			return table{}, nil, nil, err
+		}
+		if order.Desc {
+			q.OrderByDesc(f.Name)
+		} else {
+			q.OrderBy(f.Name)
+		}
+	}
+	if sel.HasLimit {
+		q.Limit(sel.Limit)
+	}
+	q.Offset(sel.Offset)
+
+	records, err := q.All()
+	if err != nil {
+		return table{}, nil, nil, err
+	}
+	return t, columns, records, nil
+}
+
+// query builds a query over the table with the conditions of a WHERE clause
+func (db *DB) query(ctx context.Context, t table, where []query.Condition) (*query.Query, error) {
+	q := db.storage.Query(ctx, t.zero())
+	for _, c := range where {
+		f, err := t.field(c.Field)
+		if err != nil {
+			return nil, err
+		}
+		value := c.Value
+		if c.Operator == query.In {
+			values := value.([]interface{})
+			converted := make([]interface{}, len(values))
+			for i, v := range values {
+				converted[i] = conditionValue(f.Type, v)
+			}
+			value = converted
+		} else {
+			value = conditionValue(f.Type, value)
+		}
+		q.Where(f.Name, c.Operator, value)
+	}
+	return q, nil
+}
+
+// conditionValue parses a string literal compared with a field that
+// unmarshals from text, other literals are compared as they are
+func conditionValue(fieldType reflect.Type, value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	t := fieldType
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return value
+	}
+	v := reflect.New(t)
+	if err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+		return value
+	}
+	return v.Elem().Interface()
+}
+
+// matching returns the records a WHERE clause selects
+func (db *DB) matching(ctx context.Context, t table, where []query.Condition) ([]interface{}, error) {
+	q, err := db.query(ctx, t, where)
+	if err != nil {
+		return nil, err
+	}
+	return q.All()
+}
+
+func (db *DB) insert(ctx context.Context, stmt *Insert) (int, error) {
+	t, err := db.table(ctx, stmt.Table)
+	if err != nil {
+		return 0, err
+	}
+	columns := stmt.Columns
+	if columns == nil {
+		columns = t.columns()
+	}
+	fields := make([]reflect.StructField, len(columns))
+	for i, column := range columns {
+		if fields[i], err = t.field(column); err != nil {
+			return 0, err
+		}
+	}
+
+	batch := db.storage.Batch(ctx)
+	for _, row := range stmt.Rows {
+		if len(row) != len(fields) {
+			return 0, fmt.Errorf("%d values for %d columns: %w", len(row), len(fields), ErrInvalidValue)
+		}
+		record := reflect.New(t.recordType).Elem()
+		for i, f := range fields {
+			if err := assign(record.FieldByIndex(f.Index), row[i]); err != nil {
+				return 0, fmt.Errorf("column %q: %w", f.Name, err)
+			}
+		}
+		batch.Insert(record.Interface())
+	}
+	return commit(batch)
+}
+
+// commit applies the writes of a statement, either all of them or none, and
+// returns their number. The writes are applied when only after hooks fail.
+func commit(batch *query.Batch) (int, error) {
+	err := batch.Commit()
+	if err != nil && !errors.Is(err, schema.ErrAfterHook) {
+		return 0, err
+	}
+	return len(batch.Ops()), err
+}
+
+func (db *DB) update(ctx context.Context, stmt *Update) (int, error) {
+	t, err := db.table(ctx, stmt.Table)
+	if err != nil {
+		return 0, err
+	}
+	fields := make([]reflect.StructField, len(stmt.Set))
+	for i, assignment := range stmt.Set {
+		if fields[i], err = t.field(assignment.Column); err != nil {
+			return 0, err
+		}
+		for _, pk := range t.pk {
+			if fields[i].Name == pk {
+				return 0, fmt.Errorf("%q: %w", pk, ErrPrimaryKeyUpdate)
+			}
+		}
+	}
+
+	records, err := db.matching(ctx, t, stmt.Where)
+	if err != nil {
+		return 0, err
+	}
+	batch := db.storage.Batch(ctx)
+	for _, r := range records {
+		record := reflect.Indirect(reflect.ValueOf(r))
+		for i, f := range fields {
+			if err := assign(record.FieldByIndex(f.Index), stmt.Set[i].Value); err != nil {
+				return 0, fmt.Errorf("column %q: %w", f.Name, err)
+			}
+		}
+		batch.Update(record.Interface())
+	}
+	return commit(batch)
+}
+
+func (db *DB) delete(ctx context.Context, stmt *Delete) (int, error) {
+	t, err := db.table(ctx, stmt.Table)
+	if err != nil {
+		return 0, err
+	}
+	records, err := db.matching(ctx, t, stmt.Where)
+	if err != nil {
+		return 0, err
+	}
+	batch := db.storage.Batch(ctx)
+	for _, r := range records {
+		batch.Delete(t.zero(), t.key(reflect.Indirect(reflect.ValueOf(r))))
+	}
+	return commit(batch)
+}
+
+func (db *DB) createTable(ctx context.Context, stmt *CreateTable) error {
+	recordType, ok := db.types[stmt.Table]
+	if !ok {
+		return fmt.Errorf("%q: %w", stmt.Table, ErrUnknownType)
+	}
+	t := table{recordType: recordType}
+	pk := make([]string, len(stmt.PrimaryKey))
+	for i, column := range stmt.PrimaryKey {
+		f, err := t.field(column)
+		if err != nil {
+			return err
+		}
+		pk[i] = f.Name
+	}
+	return db.storage.CreateTable(ctx, t.zero(), strings.Join(pk, ","))
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// assign sets a field to a literal of a statement, NULL sets the zero value
+func assign(field reflect.Value, value interface{}) error {
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if err := assign(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+	if s, ok := value.(string); ok && field.Addr().Type().Implements(textUnmarshalerType) {
+		if err := field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("%v: %w", err, ErrInvalidValue)
+		}
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := value.(int64); ok && !field.OverflowInt(i) {
+			field.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := value.(int64); ok && i >= 0 && !field.OverflowUint(uint64(i)) {
+			field.SetUint(uint64(i))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v := value.(type) {
+		case int64:
+			field.SetFloat(float64(v))
+			return nil
+		case float64:
+			if !field.OverflowFloat(v) {
+				field.SetFloat(v)
+				return nil
+			}
+		}
+	case reflect.String:
+		if s, ok := value.(string); ok {
+			field.SetString(s)
+			return nil
+		}
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			field.SetBool(b)
+			return nil
+		}
+	}
+	return fmt.Errorf("%v into %v: %w", value, field.Type(), ErrInvalidValue)
+}
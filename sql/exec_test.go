@@ -0,0 +1,348 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/priyanshujain/go-storage/drivers/inmemory"
+	"github.com/priyanshujain/go-storage/query"
+)
+
+// Level is stored as a number and written as text in statements
+type Level int
+
+var levels = []string{"junior", "senior", "principal"}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	for i, level := range levels {
+		if level == string(text) {
+			*l = Level(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown level %q", text)
+}
+
+type Employee struct {
+	Id     string
+	Name   string
+	Age    int
+	Salary *float64
+	Level  Level
+	Active bool
+}
+
+func newEmployeeDB(t *testing.T) *DB {
+	ctx := context.Background()
+	db := New(inmemory.New())
+	db.Register(Employee{})
+	if _, err := db.Exec(ctx, "CREATE TABLE Employee PRIMARY KEY (id)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	n, err := db.Exec(ctx, `INSERT INTO Employee (Id, Name, Age, Salary, Level, Active) VALUES
+		('e1', 'Ann', 34, 5000, 'senior', TRUE),
+		('e2', 'Bob', 28, NULL, 'junior', FALSE),
+		('e3', 'Cid', 45, 7200.5, 'principal', TRUE)`)
+	if err != nil {
+		t.Fatalf("Failed to insert records: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("Expected 3 inserted records but got %d", n)
+	}
+	return db
+}
+
+func names(rows []map[string]interface{}) []string {
+	names := []string{}
+	for _, row := range rows {
+		names = append(names, row["Name"].(string))
+	}
+	return names
+}
+
+func TestDB_Query(t *testing.T) {
+	ctx := context.Background()
+	db := newEmployeeDB(t)
+
+	tests := []struct {
+		name      string
+		statement string
+		expected  []string
+	}{
+		{"all", "SELECT * FROM Employee", []string{"Ann", "Bob", "Cid"}},
+		{"where", "SELECT Name FROM Employee WHERE Age > 30 AND Active = TRUE", []string{"Ann", "Cid"}},
+		{"order limit offset", "SELECT name FROM Employee ORDER BY Age DESC LIMIT 2 OFFSET 1", []string{"Ann", "Bob"}},
+		{"limit 0", "SELECT Name FROM Employee LIMIT 0", []string{}},
+		{"in", "SELECT Name FROM Employee WHERE Id IN ('e3', 'e2')", []string{"Bob", "Cid"}},
+		{"null", "SELECT Name FROM Employee WHERE Salary IS NULL", []string{"Bob"}},
+		{"not null", "SELECT Name FROM Employee WHERE Salary IS NOT NULL AND Salary > 6000", []string{"Cid"}},
+		{"value from text", "SELECT Name FROM Employee WHERE Level >= 'senior' ORDER BY Level DESC", []string{"Cid", "Ann"}},
+		{"values from text", "SELECT Name FROM Employee WHERE Level IN ('junior', 'principal')", []string{"Bob", "Cid"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := db.Query(ctx, tt.statement)
+			if err != nil {
+				t.Fatalf("Failed to run query: %v", err)
+			}
+			if got := names(rows); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v but got %v", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("columns", func(t *testing.T) {
+		rows, err := db.Query(ctx, "SELECT Id, Age FROM Employee WHERE Id = 'e2'")
+		if err != nil {
+			t.Fatalf("Failed to run query: %v", err)
+		}
+		expected := []map[string]interface{}{{"Id": "e2", "Age": 28}}
+		if !reflect.DeepEqual(rows, expected) {
+			t.Errorf("Expected %v but got %v", expected, rows)
+		}
+	})
+}
+
+func TestDB_QueryStructs(t *testing.T) {
+	ctx := context.Background()
+	db := newEmployeeDB(t)
+
+	var employees []Employee
+	if err := db.QueryStructs(ctx, "SELECT * FROM Employee WHERE Id = 'e1'", &employees); err != nil {
+		t.Fatalf("Failed to run query: %v", err)
+	}
+	salary := 5000.0
+	expected := []Employee{{
+		Id:     "e1",
+		Name:   "Ann",
+		Age:    34,
+		Salary: &salary,
+		Level:  1,
+		Active: true,
+	}}
+	if !reflect.DeepEqual(employees, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, employees)
+	}
+
+	type summary struct {
+		Name    string
+		Age     int
+		Missing string
+	}
+	var summaries []*summary
+	if err := db.QueryStructs(ctx, "SELECT Name, Age FROM Employee ORDER BY Age", &summaries); err != nil {
+		t.Fatalf("Failed to run query: %v", err)
+	}
+	if len(summaries) != 3 || *summaries[0] != (summary{Name: "Bob", Age: 28}) {
+		t.Errorf("Expected Bob first but got %+v", summaries)
+	}
+
+	tests := []struct {
+		name string
+		dest interface{}
+	}{
+		{"not a pointer", employees},
+		{"not a slice", &employees[0]},
+		{"not structs", &[]string{}},
+		{"mismatched field", &[]struct{ Age string }{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := db.QueryStructs(ctx, "SELECT Age FROM Employee", tt.dest)
+			if !errors.Is(err, ErrInvalidDestination) {
+				t.Errorf("Expected %v but got %v", ErrInvalidDestination, err)
+			}
+		})
+	}
+}
+
+func TestDB_Exec(t *testing.T) {
+	ctx := context.Background()
+	t.Run("update", func(t *testing.T) {
+		db := newEmployeeDB(t)
+		n, err := db.Exec(ctx, "UPDATE Employee SET Salary = 6000, Active = FALSE WHERE Age > 30")
+		if err != nil {
+			t.Fatalf("Failed to update: %v", err)
+		}
+		if n != 2 {
+			t.Errorf("Expected 2 updated records but got %d", n)
+		}
+		rows, _ := db.Query(ctx, "SELECT Name FROM Employee WHERE Salary = 6000 AND Active = FALSE")
+		if got := names(rows); !reflect.DeepEqual(got, []string{"Ann", "Cid"}) {
+			t.Errorf("Expected [Ann Cid] but got %v", got)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		db := newEmployeeDB(t)
+		n, err := db.Exec(ctx, "DELETE FROM Employee WHERE Active = TRUE")
+		if err != nil {
+			t.Fatalf("Failed to delete: %v", err)
+		}
+		if n != 2 {
+			t.Errorf("Expected 2 deleted records but got %d", n)
+		}
+		rows, _ := db.Query(ctx, "SELECT Name FROM Employee")
+		if got := names(rows); !reflect.DeepEqual(got, []string{"Bob"}) {
+			t.Errorf("Expected [Bob] but got %v", got)
+		}
+	})
+
+	t.Run("insert in declaration order", func(t *testing.T) {
+		db := newEmployeeDB(t)
+		if _, err := db.Exec(ctx, "INSERT INTO Employee VALUES ('e4', 'Dee', 51, 1.5, 'junior', TRUE)"); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		rows, _ := db.Query(ctx, "SELECT Name FROM Employee WHERE Age = 51")
+		if got := names(rows); !reflect.DeepEqual(got, []string{"Dee"}) {
+			t.Errorf("Expected [Dee] but got %v", got)
+		}
+	})
+
+	t.Run("all or nothing", func(t *testing.T) {
+		db := newEmployeeDB(t)
+		n, err := db.Exec(ctx, "INSERT INTO Employee (Id, Name) VALUES ('e4', 'Dee'), ('e1', 'Eve')")
+		if !errors.Is(err, inmemory.ErrDuplicateRecord) || n != 0 {
+			t.Errorf("Expected 0 and %v but got %d, %v", inmemory.ErrDuplicateRecord, n, err)
+		}
+		rows, _ := db.Query(ctx, "SELECT Name FROM Employee WHERE Id = 'e4'")
+		if got := names(rows); len(got) != 0 {
+			t.Errorf("Expected the first row to be rolled back but got %v", got)
+		}
+	})
+
+	t.Run("composite key", func(t *testing.T) {
+		type Assignment struct {
+			Project string
+			Seq     int
+			Owner   string
+		}
+		db := New(inmemory.New())
+		db.Register(Assignment{})
+		if _, err := db.Exec(ctx, "CREATE TABLE Assignment PRIMARY KEY (project, seq)"); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		if _, err := db.Exec(ctx, "INSERT INTO Assignment VALUES ('x', 10, 'ann'), ('x', 9, 'bob'), ('w', 11, 'cid')"); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		if _, err := db.Exec(ctx, "UPDATE Assignment SET Seq = 1"); !errors.Is(err, ErrPrimaryKeyUpdate) {
+			t.Errorf("Expected %v but got %v", ErrPrimaryKeyUpdate, err)
+		}
+		n, err := db.Exec(ctx, "DELETE FROM Assignment WHERE Owner = 'bob'")
+		if err != nil || n != 1 {
+			t.Fatalf("Expected 1 deleted record but got %d, %v", n, err)
+		}
+		rows, _ := db.Query(ctx, "SELECT Owner FROM Assignment")
+		if len(rows) != 2 || rows[0]["Owner"] != "cid" || rows[1]["Owner"] != "ann" {
+			t.Errorf("Expected [cid ann] in key order but got %v", rows)
+		}
+	})
+
+	t.Run("quoted identifiers", func(t *testing.T) {
+		type Setting struct {
+			Key   string
+			Value string
+		}
+		db := New(inmemory.New())
+		db.Register(&Setting{})
+		if _, err := db.Exec(ctx, `CREATE TABLE Setting PRIMARY KEY "Key"`); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		if _, err := db.Exec(ctx, `INSERT INTO Setting ("Key", "Value") VALUES ('mode', 'fast')`); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		if _, err := db.Exec(ctx, `UPDATE Setting SET "Value" = 'slow' WHERE "Key" = 'mode'`); err != nil {
+			t.Fatalf("Failed to update: %v", err)
+		}
+		rows, err := db.Query(ctx, `SELECT "Value" FROM Setting WHERE "Key" = 'mode'`)
+		if err != nil || len(rows) != 1 || rows[0]["Value"] != "slow" {
+			t.Errorf("Expected [map[Value:slow]] but got %v, %v", rows, err)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		db := newEmployeeDB(t)
+		tests := []struct {
+			name      string
+			statement string
+			err       error
+		}{
+			{"syntax", "DELETE Employee", ErrSyntax},
+			{"select", "SELECT * FROM Employee", ErrStatement},
+			{"unknown table", "DELETE FROM Missing", inmemory.ErrInvalidTableName},
+			{"unregistered type", "CREATE TABLE Missing PRIMARY KEY (Id)", ErrUnknownType},
+			{"existing table", "CREATE TABLE Employee PRIMARY KEY (Id)", inmemory.ErrTableExists},
+			{"unknown column", "UPDATE Employee SET Missing = 1", query.ErrInvalidField},
+			{"primary key", "UPDATE Employee SET Id = 'x'", ErrPrimaryKeyUpdate},
+			{"string into int", "INSERT INTO Employee (Id, Age) VALUES ('e9', 'old')", ErrInvalidValue},
+			{"int into bool", "INSERT INTO Employee (Id, Active) VALUES ('e9', 1)", ErrInvalidValue},
+			{"invalid text", "INSERT INTO Employee (Id, Level) VALUES ('e9', 'intern')", ErrInvalidValue},
+			{"row length", "INSERT INTO Employee VALUES ('e9')", ErrInvalidValue},
+			{"duplicate", "INSERT INTO Employee (Id) VALUES ('e1')", inmemory.ErrDuplicateRecord},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := db.Exec(ctx, tt.statement); !errors.Is(err, tt.err) {
+					t.Errorf("Expected %v but got %v", tt.err, err)
+				}
+			})
+		}
+		if _, err := db.Query(ctx, "DELETE FROM Employee"); !errors.Is(err, ErrStatement) {
+			t.Errorf("Expected %v but got %v", ErrStatement, err)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		db := newEmployeeDB(t)
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := db.Exec(cancelled, "DELETE FROM Employee"); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v but got %v", context.Canceled, err)
+		}
+		if _, err := db.Query(cancelled, "SELECT * FROM Employee"); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v but got %v", context.Canceled, err)
+		}
+	})
+}
+
+func TestAssign(t *testing.T) {
+	var record struct {
+		I8  int8
+		U   uint
+		F32 float32
+		P   *int
+	}
+	v := reflect.ValueOf(&record).Elem()
+
+	tests := []struct {
+		name  string
+		field string
+		value interface{}
+		err   error
+	}{
+		{"int", "I8", int64(-128), nil},
+		{"int overflow", "I8", int64(128), ErrInvalidValue},
+		{"uint", "U", int64(7), nil},
+		{"negative uint", "U", int64(-1), ErrInvalidValue},
+		{"float from int", "F32", int64(2), nil},
+		{"float overflow", "F32", 1e300, ErrInvalidValue},
+		{"pointer", "P", int64(3), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := assign(v.FieldByName(tt.field), tt.value); !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v but got %v", tt.err, err)
+			}
+		})
+	}
+	if record.I8 != -128 || record.U != 7 || record.F32 != 2 || record.P == nil || *record.P != 3 {
+		t.Errorf("Expected assigned values but got %+v", record)
+	}
+	if err := assign(v.FieldByName("P"), nil); err != nil || record.P != nil {
+		t.Errorf("Expected NULL to clear the pointer but got %v %v", record.P, err)
+	}
+}
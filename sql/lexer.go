@@ -0,0 +1,240 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrSyntax = errors.New("syntax error")
+
+// Position is a location in a statement. Line and Column start at 1, Column
+// counts characters and Offset counts bytes from the start of the statement.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// SyntaxError reports where a statement could not be parsed.
+type SyntaxError struct {
+	Position
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return ErrSyntax
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenKeyword
+	tokenNumber
+	tokenString
+	tokenOperator
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	// text is upper case for keywords and unquoted for strings and quoted
+	// identifiers
+	text string
+	pos  Position
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of statement"
+	case tokenString:
+		return fmt.Sprintf("string '%s'", strings.ReplaceAll(t.text, "'", "''"))
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+var keywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "AND": true, "OR": true,
+	"ORDER": true, "BY": true, "ASC": true, "DESC": true, "LIMIT": true,
+	"OFFSET": true, "INSERT": true, "INTO": true, "VALUES": true,
+	"UPDATE": true, "SET": true, "DELETE": true, "CREATE": true,
+	"TABLE": true, "PRIMARY": true, "KEY": true, "IN": true, "IS": true,
+	"NOT": true, "NULL": true, "TRUE": true, "FALSE": true,
+}
+
+type lexer struct {
+	input string
+	pos   Position
+}
+
+func newLexer(input string) *lexer {
+	return &lexer{input: input, pos: Position{Line: 1, Column: 1}}
+}
+
+func syntaxError(pos Position, format string, args ...interface{}) error {
+	return &SyntaxError{Position: pos, Message: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) peekRune() rune {
+	r, _ := utf8.DecodeRuneInString(l.input[l.pos.Offset:])
+	return r
+}
+
+func (l *lexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(l.input[l.pos.Offset:])
+	l.pos.Offset += size
+	if r == '\n' {
+		l.pos.Line++
+		l.pos.Column = 1
+	} else {
+		l.pos.Column++
+	}
+	return r
+}
+
+func (l *lexer) done() bool {
+	return l.pos.Offset >= len(l.input)
+}
+
+// skip moves past white space and -- comments
+func (l *lexer) skip() {
+	for !l.done() {
+		switch r := l.peekRune(); {
+		case unicode.IsSpace(r):
+			l.advance()
+		case strings.HasPrefix(l.input[l.pos.Offset:], "--"):
+			for !l.done() && l.peekRune() != '\n' {
+				l.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// tokens splits the input into tokens ending with tokenEOF
+func (l *lexer) tokens() ([]token, error) {
+	var tokens []token
+	for {
+		l.skip()
+		start := l.pos
+		if l.done() {
+			return append(tokens, token{kind: tokenEOF, pos: start}), nil
+		}
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		t.pos = start
+		tokens = append(tokens, t)
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	start := l.pos
+	r := l.advance()
+	switch {
+	case r == '_' || unicode.IsLetter(r):
+		for !l.done() && (l.peekRune() == '_' || unicode.IsLetter(l.peekRune()) || unicode.IsDigit(l.peekRune())) {
+			l.advance()
+		}
+		text := l.input[start.Offset:l.pos.Offset]
+		if keywords[strings.ToUpper(text)] {
+			return token{kind: tokenKeyword, text: strings.ToUpper(text)}, nil
+		}
+		return token{kind: tokenIdent, text: text}, nil
+
+	case r >= '0' && r <= '9' || r == '.':
+		l.digits()
+		if r != '.' && l.peekRune() == '.' {
+			l.advance()
+			l.digits()
+		}
+		if l.peekRune() == 'e' || l.peekRune() == 'E' {
+			l.advance()
+			if l.peekRune() == '+' || l.peekRune() == '-' {
+				l.advance()
+			}
+			if !l.digits() {
+				return token{}, syntaxError(l.pos, "missing exponent in number %q", l.input[start.Offset:l.pos.Offset])
+			}
+		}
+		text := l.input[start.Offset:l.pos.Offset]
+		if text == "." {
+			return token{}, syntaxError(start, "unexpected %q", text)
+		}
+		return token{kind: tokenNumber, text: text}, nil
+
+	case r == '\'':
+		text, ok := l.quoted(r)
+		if !ok {
+			return token{}, syntaxError(start, "unterminated string")
+		}
+		return token{kind: tokenString, text: text}, nil
+
+	case r == '"':
+		// a quoted identifier is never a keyword
+		text, ok := l.quoted(r)
+		if !ok {
+			return token{}, syntaxError(start, "unterminated identifier")
+		}
+		if text == "" {
+			return token{}, syntaxError(start, "empty identifier")
+		}
+		return token{kind: tokenIdent, text: text}, nil
+
+	case r == '<' || r == '>' || r == '!' || r == '=':
+		if l.peekRune() == '=' || r == '<' && l.peekRune() == '>' {
+			l.advance()
+		}
+		text := l.input[start.Offset:l.pos.Offset]
+		if text == "!" {
+			return token{}, syntaxError(start, "unexpected %q", text)
+		}
+		return token{kind: tokenOperator, text: text}, nil
+
+	case r == '-':
+		return token{kind: tokenOperator, text: "-"}, nil
+
+	case strings.ContainsRune("(),*;", r):
+		return token{kind: tokenPunct, text: string(r)}, nil
+	}
+	return token{}, syntaxError(start, "unexpected %q", r)
+}
+
+// quoted reads up to the closing quote, a doubled quote stands for one. It
+// reports false when the input ends first.
+func (l *lexer) quoted(quote rune) (string, bool) {
+	var b strings.Builder
+	for {
+		if l.done() {
+			return "", false
+		}
+		c := l.advance()
+		if c == quote {
+			if l.peekRune() != quote {
+				return b.String(), true
+			}
+			l.advance()
+		}
+		b.WriteRune(c)
+	}
+}
+
+// digits moves past decimal digits and reports whether there were any
+func (l *lexer) digits() bool {
+	start := l.pos.Offset
+	for !l.done() && l.peekRune() >= '0' && l.peekRune() <= '9' {
+		l.advance()
+	}
+	return l.pos.Offset > start
+}
@@ -0,0 +1,77 @@
+package sql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLexer(t *testing.T) {
+	tokens, err := newLexer("select Name, -1.5e3 from\n  T -- comment\nwhere x <> 'it''s' and \"Key\" = \"a\"\"b\";").tokens()
+	if err != nil {
+		t.Fatalf("Failed to split tokens: %v", err)
+	}
+	type tok struct {
+		kind tokenKind
+		text string
+		line int
+		col  int
+	}
+	expected := []tok{
+		{tokenKeyword, "SELECT", 1, 1},
+		{tokenIdent, "Name", 1, 8},
+		{tokenPunct, ",", 1, 12},
+		{tokenOperator, "-", 1, 14},
+		{tokenNumber, "1.5e3", 1, 15},
+		{tokenKeyword, "FROM", 1, 21},
+		{tokenIdent, "T", 2, 3},
+		{tokenKeyword, "WHERE", 3, 1},
+		{tokenIdent, "x", 3, 7},
+		{tokenOperator, "<>", 3, 9},
+		{tokenString, "it's", 3, 12},
+		{tokenKeyword, "AND", 3, 20},
+		{tokenIdent, "Key", 3, 24},
+		{tokenOperator, "=", 3, 30},
+		{tokenIdent, "a\"b", 3, 32},
+		{tokenPunct, ";", 3, 38},
+		{tokenEOF, "", 3, 39},
+	}
+	var got []tok
+	for _, t := range tokens {
+		got = append(got, tok{t.kind, t.text, t.pos.Line, t.pos.Column})
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		line   int
+		column int
+	}{
+		{"unterminated string", "SELECT 'abc", 1, 8},
+		{"unterminated identifier", "SELECT \"Key", 1, 8},
+		{"empty identifier", "SELECT \"\" FROM T", 1, 8},
+		{"unexpected character", "SELECT * FROM T WHERE a = #", 1, 27},
+		{"lone bang", "a ! b", 1, 3},
+		{"missing exponent", "1e+", 1, 4},
+		{"second line", "SELECT *\nFROM ?", 2, 6},
+		{"multi byte characters", "'é' @", 1, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newLexer(tt.input).tokens()
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) || !errors.Is(err, ErrSyntax) {
+				t.Fatalf("Expected a syntax error but got %v", err)
+			}
+			if syntaxErr.Line != tt.line || syntaxErr.Column != tt.column {
+				t.Errorf("Expected line %d column %d but got %v", tt.line, tt.column, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,505 @@
+package sql
+
+import (
+	"strconv"
+
+	"github.com/priyanshujain/go-storage/query"
+)
+
+// Statement is a parsed SQL statement.
+type Statement interface {
+	statement()
+}
+
+// Select reads records. Nil Columns select every field. HasLimit reports
+// whether there was a LIMIT, so LIMIT 0 selects no records.
+type Select struct {
+	Columns  []string
+	Table    string
+	Where    []query.Condition
+	OrderBy  []query.Order
+	Limit    int
+	HasLimit bool
+	Offset   int
+}
+
+// Insert adds one record per row of values. Nil Columns stand for every
+// field of the record in declaration order.
+type Insert struct {
+	Table   string
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Update sets columns of the records matching the conditions.
+type Update struct {
+	Table string
+	Set   []Assignment
+	Where []query.Condition
+}
+
+type Assignment struct {
+	Column string
+	Value  interface{}
+}
+
+// Delete removes the records matching the conditions.
+type Delete struct {
+	Table string
+	Where []query.Condition
+}
+
+// CreateTable creates a table for a registered record type.
+type CreateTable struct {
+	Table      string
+	PrimaryKey []string
+}
+
+func (*Select) statement()      {}
+func (*Insert) statement()      {}
+func (*Update) statement()      {}
+func (*Delete) statement()      {}
+func (*CreateTable) statement() {}
+
+// Parse parses a single statement of the dialect:
+//
+//	SELECT * | column, ... FROM table [WHERE condition AND ...]
+//		[ORDER BY column [ASC | DESC], ...] [LIMIT n [OFFSET n]]
+//	INSERT INTO table [(column, ...)] VALUES (value, ...), ...
+//	UPDATE table SET column = value, ... [WHERE condition AND ...]
+//	DELETE FROM table [WHERE condition AND ...]
+//	CREATE TABLE table PRIMARY KEY column
+//	CREATE TABLE table PRIMARY KEY (column, ...)
+//
+// A condition is column op value with op one of = != <> < <= > >=,
+// column IN (value, ...) or column IS [NOT] NULL. Values are numbers,
+// 'quoted strings', TRUE, FALSE and NULL. Keywords are case insensitive and
+// a trailing semicolon is allowed. A table or column named like a keyword is
+// written as a "quoted identifier", as in WHERE "Key" = 'k1'.
+func Parse(statement string) (Statement, error) {
+	tokens, err := newLexer(statement).tokens()
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+
+	var stmt Statement
+	switch t := p.peek(); {
+	case p.isKeyword("SELECT"):
+		stmt, err = p.parseSelect()
+	case p.isKeyword("INSERT"):
+		stmt, err = p.parseInsert()
+	case p.isKeyword("UPDATE"):
+		stmt, err = p.parseUpdate()
+	case p.isKeyword("DELETE"):
+		stmt, err = p.parseDelete()
+	case p.isKeyword("CREATE"):
+		stmt, err = p.parseCreateTable()
+	default:
+		return nil, p.errorf(t, "expected SELECT, INSERT, UPDATE, DELETE or CREATE, found %v", t)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isPunct(";") {
+		p.next()
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %v", t)
+	}
+	return stmt, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return syntaxError(t.pos, format, args...)
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenKeyword && t.text == keyword
+}
+
+func (p *parser) isPunct(punct string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.text == punct
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if !p.isKeyword(keyword) {
+		t := p.peek()
+		return p.errorf(t, "expected %s, found %v", keyword, t)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) expectPunct(punct string) error {
+	if !p.isPunct(punct) {
+		t := p.peek()
+		return p.errorf(t, "expected %q, found %v", punct, t)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseIdent(what string) (string, error) {
+	t := p.peek()
+	if t.kind != tokenIdent {
+		return "", p.errorf(t, "expected %s name, found %v", what, t)
+	}
+	p.next()
+	return t.text, nil
+}
+
+// parseIdents parses a comma separated list of names
+func (p *parser) parseIdents(what string) ([]string, error) {
+	var names []string
+	for {
+		name, err := p.parseIdent(what)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.isPunct(",") {
+			return names, nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) parseSelect() (*Select, error) {
+	p.next()
+	stmt := &Select{}
+	if p.isPunct("*") {
+		p.next()
+	} else {
+		columns, err := p.parseIdents("column")
+		if err != nil {
+			return nil, err
+		}
+		stmt.Columns = columns
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	table, err := p.parseIdent("table")
+	if err != nil {
+		return nil, err
+	}
+	stmt.Table = table
+	if stmt.Where, err = p.parseWhere(); err != nil {
+		return nil, err
+	}
+
+	if p.isKeyword("ORDER") {
+		p.next()
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			column, err := p.parseIdent("column")
+			if err != nil {
+				return nil, err
+			}
+			order := query.Order{Field: column}
+			if p.isKeyword("ASC") {
+				p.next()
+			} else if p.isKeyword("DESC") {
+				p.next()
+				order.Desc = true
+			}
+			stmt.OrderBy = append(stmt.OrderBy, order)
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if p.isKeyword("LIMIT") {
+		p.next()
+		stmt.HasLimit = true
+		if stmt.Limit, err = p.parseCount(); err != nil {
+			return nil, err
+		}
+		if p.isKeyword("OFFSET") {
+			p.next()
+			if stmt.Offset, err = p.parseCount(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return stmt, nil
+}
+
+// parseCount parses the non-negative integer of LIMIT and OFFSET
+func (p *parser) parseCount() (int, error) {
+	t := p.peek()
+	if t.kind != tokenNumber {
+		return 0, p.errorf(t, "expected a number, found %v", t)
+	}
+	n, err := strconv.Atoi(t.text)
+	if err != nil {
+		return 0, p.errorf(t, "invalid count %v", t)
+	}
+	p.next()
+	return n, nil
+}
+
+func (p *parser) parseWhere() ([]query.Condition, error) {
+	if !p.isKeyword("WHERE") {
+		return nil, nil
+	}
+	p.next()
+	var conditions []query.Condition
+	for {
+		condition, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		if p.isKeyword("OR") {
+			return nil, p.errorf(p.peek(), "OR is not supported, conditions can only be combined with AND")
+		}
+		if !p.isKeyword("AND") {
+			return conditions, nil
+		}
+		p.next()
+	}
+}
+
+var operators = map[string]string{
+	"=": query.Eq, "!=": query.Ne, "<>": query.Ne,
+	"<": query.Lt, "<=": query.Le, ">": query.Gt, ">=": query.Ge,
+}
+
+func (p *parser) parseCondition() (query.Condition, error) {
+	column, err := p.parseIdent("column")
+	if err != nil {
+		return query.Condition{}, err
+	}
+	condition := query.Condition{Field: column}
+
+	t := p.peek()
+	switch {
+	case p.isKeyword("IS"):
+		p.next()
+		condition.Operator = query.Eq
+		if p.isKeyword("NOT") {
+			p.next()
+			condition.Operator = query.Ne
+		}
+		return condition, p.expectKeyword("NULL")
+
+	case p.isKeyword("IN"):
+		p.next()
+		if err := p.expectPunct("("); err != nil {
+			return condition, err
+		}
+		values, err := p.parseValues()
+		if err != nil {
+			return condition, err
+		}
+		condition.Operator, condition.Value = query.In, values
+		return condition, nil
+
+	case t.kind == tokenOperator && operators[t.text] != "":
+		p.next()
+		condition.Operator = operators[t.text]
+		condition.Value, err = p.parseValue()
+		return condition, err
+	}
+	return condition, p.errorf(t, "expected an operator, IN or IS, found %v", t)
+}
+
+// parseValues parses values up to a closing parenthesis
+func (p *parser) parseValues() ([]interface{}, error) {
+	var values []interface{}
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if !p.isPunct(",") {
+			return values, p.expectPunct(")")
+		}
+		p.next()
+	}
+}
+
+// parseValue parses a literal, integers are int64 and other numbers float64
+func (p *parser) parseValue() (interface{}, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenString:
+		return t.text, nil
+	case t.kind == tokenKeyword && t.text == "TRUE":
+		return true, nil
+	case t.kind == tokenKeyword && t.text == "FALSE":
+		return false, nil
+	case t.kind == tokenKeyword && t.text == "NULL":
+		return nil, nil
+	case t.kind == tokenOperator && t.text == "-":
+		number := p.peek()
+		if number.kind != tokenNumber {
+			return nil, p.errorf(number, "expected a number after '-', found %v", number)
+		}
+		p.next()
+		return p.parseNumber(number, "-")
+	case t.kind == tokenNumber:
+		return p.parseNumber(t, "")
+	}
+	return nil, p.errorf(t, "expected a value, found %v", t)
+}
+
+func (p *parser) parseNumber(t token, sign string) (interface{}, error) {
+	if i, err := strconv.ParseInt(sign+t.text, 10, 64); err == nil {
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(sign+t.text, 64)
+	if err != nil {
+		return nil, p.errorf(t, "invalid number %v", t)
+	}
+	return f, nil
+}
+
+func (p *parser) parseInsert() (*Insert, error) {
+	p.next()
+	if err := p.expectKeyword("INTO"); err != nil {
+		return nil, err
+	}
+	table, err := p.parseIdent("table")
+	if err != nil {
+		return nil, err
+	}
+	stmt := &Insert{Table: table}
+	if p.isPunct("(") {
+		p.next()
+		if stmt.Columns, err = p.parseIdents("column"); err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectKeyword("VALUES"); err != nil {
+		return nil, err
+	}
+	for {
+		start := p.peek()
+		if err := p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		values, err := p.parseValues()
+		if err != nil {
+			return nil, err
+		}
+		if stmt.Columns != nil && len(values) != len(stmt.Columns) {
+			return nil, p.errorf(start, "expected %d values, found %d", len(stmt.Columns), len(values))
+		}
+		stmt.Rows = append(stmt.Rows, values)
+		if !p.isPunct(",") {
+			return stmt, nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) parseUpdate() (*Update, error) {
+	p.next()
+	table, err := p.parseIdent("table")
+	if err != nil {
+		return nil, err
+	}
+	stmt := &Update{Table: table}
+	if err := p.expectKeyword("SET"); err != nil {
+		return nil, err
+	}
+	for {
+		column, err := p.parseIdent("column")
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t.kind != tokenOperator || t.text != "=" {
+			return nil, p.errorf(t, "expected \"=\", found %v", t)
+		}
+		p.next()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Set = append(stmt.Set, Assignment{Column: column, Value: value})
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	stmt.Where, err = p.parseWhere()
+	return stmt, err
+}
+
+func (p *parser) parseDelete() (*Delete, error) {
+	p.next()
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	table, err := p.parseIdent("table")
+	if err != nil {
+		return nil, err
+	}
+	stmt := &Delete{Table: table}
+	stmt.Where, err = p.parseWhere()
+	return stmt, err
+}
+
+func (p *parser) parseCreateTable() (*CreateTable, error) {
+	p.next()
+	if err := p.expectKeyword("TABLE"); err != nil {
+		return nil, err
+	}
+	table, err := p.parseIdent("table")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("PRIMARY"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("KEY"); err != nil {
+		return nil, err
+	}
+	if !p.isPunct("(") {
+		pk, err := p.parseIdent("column")
+		if err != nil {
+			return nil, err
+		}
+		return &CreateTable{Table: table, PrimaryKey: []string{pk}}, nil
+	}
+	p.next()
+	pk, err := p.parseIdents("column")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+	return &CreateTable{Table: table, PrimaryKey: pk}, nil
+}
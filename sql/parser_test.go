@@ -0,0 +1,147 @@
+package sql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/priyanshujain/go-storage/query"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		expected  Statement
+	}{
+		{
+			name:      "select all",
+			statement: "SELECT * FROM Person",
+			expected:  &Select{Table: "Person"},
+		},
+		{
+			name:      "select",
+			statement: "select Name, Age from Person where Age >= 30 and City in ('Pune', 'Delhi') and Score is not null order by Age desc, Name asc limit 10 offset 5;",
+			expected: &Select{
+				Columns: []string{"Name", "Age"},
+				Table:   "Person",
+				Where: []query.Condition{
+					{Field: "Age", Operator: query.Ge, Value: int64(30)},
+					{Field: "City", Operator: query.In, Value: []interface{}{"Pune", "Delhi"}},
+					{Field: "Score", Operator: query.Ne, Value: nil},
+				},
+				OrderBy:  []query.Order{{Field: "Age", Desc: true}, {Field: "Name"}},
+				Limit:    10,
+				HasLimit: true,
+				Offset:   5,
+			},
+		},
+		{
+			name:      "values",
+			statement: "SELECT * FROM T WHERE a = -2 AND b <> 1.5 AND c = TRUE AND d IS NULL AND e < -0.5",
+			expected: &Select{
+				Table: "T",
+				Where: []query.Condition{
+					{Field: "a", Operator: query.Eq, Value: int64(-2)},
+					{Field: "b", Operator: query.Ne, Value: 1.5},
+					{Field: "c", Operator: query.Eq, Value: true},
+					{Field: "d", Operator: query.Eq, Value: nil},
+					{Field: "e", Operator: query.Lt, Value: -0.5},
+				},
+			},
+		},
+		{
+			name:      "insert",
+			statement: "INSERT INTO Person (Id, Name) VALUES ('p1', 'Ann'), ('p2', NULL)",
+			expected: &Insert{
+				Table:   "Person",
+				Columns: []string{"Id", "Name"},
+				Rows:    [][]interface{}{{"p1", "Ann"}, {"p2", nil}},
+			},
+		},
+		{
+			name:      "insert without columns",
+			statement: "INSERT INTO Person VALUES ('p1', 30)",
+			expected:  &Insert{Table: "Person", Rows: [][]interface{}{{"p1", int64(30)}}},
+		},
+		{
+			name:      "update",
+			statement: "UPDATE Person SET Name = 'Bob', Age = 31 WHERE Id = 'p1'",
+			expected: &Update{
+				Table: "Person",
+				Set:   []Assignment{{"Name", "Bob"}, {"Age", int64(31)}},
+				Where: []query.Condition{{Field: "Id", Operator: query.Eq, Value: "p1"}},
+			},
+		},
+		{
+			name:      "delete",
+			statement: "DELETE FROM Person WHERE Age < 18",
+			expected: &Delete{
+				Table: "Person",
+				Where: []query.Condition{{Field: "Age", Operator: query.Lt, Value: int64(18)}},
+			},
+		},
+		{
+			name:      "create table",
+			statement: "CREATE TABLE Person PRIMARY KEY (Id)",
+			expected:  &CreateTable{Table: "Person", PrimaryKey: []string{"Id"}},
+		},
+		{
+			name:      "create table without parentheses",
+			statement: "create table Person primary key Id",
+			expected:  &CreateTable{Table: "Person", PrimaryKey: []string{"Id"}},
+		},
+		{
+			name:      "create table with a composite key",
+			statement: "CREATE TABLE Member PRIMARY KEY (TenantId, UserId)",
+			expected:  &CreateTable{Table: "Member", PrimaryKey: []string{"TenantId", "UserId"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, err := Parse(tt.statement)
+			if err != nil {
+				t.Fatalf("Failed to parse: %v", err)
+			}
+			if !reflect.DeepEqual(stmt, tt.expected) {
+				t.Errorf("Expected %#v but got %#v", tt.expected, stmt)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		column    int
+		message   string
+	}{
+		{"empty", "", 1, "expected SELECT, INSERT, UPDATE, DELETE or CREATE, found end of statement"},
+		{"missing from", "SELECT * Person", 10, `expected FROM, found "Person"`},
+		{"keyword as table", "SELECT * FROM Select", 15, `expected table name, found "SELECT"`},
+		{"or", "SELECT * FROM T WHERE a = 1 OR b = 2", 29, "OR is not supported, conditions can only be combined with AND"},
+		{"missing operator", "SELECT * FROM T WHERE a 1", 25, `expected an operator, IN or IS, found "1"`},
+		{"missing value", "DELETE FROM T WHERE a =", 24, "expected a value, found end of statement"},
+		{"unclosed in", "SELECT * FROM T WHERE a IN (1, 2", 33, `expected ")", found end of statement`},
+		{"invalid limit", "SELECT * FROM T LIMIT 1.5", 23, `invalid count "1.5"`},
+		{"row length", "INSERT INTO T (a, b) VALUES (1, 2), (3)", 37, "expected 2 values, found 1"},
+		{"trailing tokens", "DELETE FROM T; DELETE", 16, `unexpected "DELETE"`},
+		{"update without equals", "UPDATE T SET a < 1", 16, `expected "=", found "<"`},
+		{"minus without number", "SELECT * FROM T WHERE a = -'x'", 28, "expected a number after '-', found string 'x'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.statement)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Expected a syntax error but got %v", err)
+			}
+			if syntaxErr.Column != tt.column || syntaxErr.Message != tt.message {
+				t.Errorf("Expected column %d %q but got column %d %q", tt.column, tt.message, syntaxErr.Column, syntaxErr.Message)
+			}
+		})
+	}
+}
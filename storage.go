@@ -1,14 +1,47 @@
 package storage
 
 import (
+	"context"
+	"reflect"
+
 	"github.com/priyanshujain/go-storage/drivers/inmemory"
+	"github.com/priyanshujain/go-storage/query"
+	"github.com/priyanshujain/go-storage/schema"
 )
 
+// Storage is a storage engine. Every call takes a context, a call gives up
+// with the error of the context when it is done before the engine could
+// take its lock or while it scans records. Builders run with the context
+// they were created with.
+//
+// The primary key of a table names one or more fields separated by commas.
+// Get, GetFields and Delete take the value of the key, the values of a
+// composite key are passed in a []interface{} in the declared order. Options
+// of CreateTable such as schema.GeneratedKey let the engine generate keys.
+// Insert returns the value of the key of the record inserted, generated or
+// not, the way Get takes it, and sets a record passed through a pointer to
+// the record written.
+//
+// Watch sends the writes of a table after the sequence number fromSeq, or
+// after query.LatestSeq for the writes to come, until cancel is called or
+// the context is done.
 type Storage interface {
 	Init()
-	CreateTable(tableType interface{}, pk string) error
-	Insert(record interface{}) error
-	Get(tableType interface{}, pk string) (interface{}, error)
+	CreateTable(ctx context.Context, tableType interface{}, pk string, opts ...schema.Option) error
+	Insert(ctx context.Context, record interface{}) (interface{}, error)
+	InsertMany(ctx context.Context, records ...interface{}) error
+	Get(ctx context.Context, tableType interface{}, pk interface{}) (interface{}, error)
+	GetFields(ctx context.Context, tableType interface{}, pk interface{}, fields ...string) (interface{}, error)
+	Update(ctx context.Context, record interface{}) error
+	Delete(ctx context.Context, tableType interface{}, pk interface{}) error
+	Batch(ctx context.Context) *query.Batch
+	Query(ctx context.Context, tableType interface{}) *query.Query
+	List(ctx context.Context, tableType interface{}, opts query.ListOptions) (query.Page, error)
+	Aggregate(ctx context.Context, tableType interface{}) *query.Aggregation
+	Join(ctx context.Context, left, right interface{}) *query.Join
+	LeftJoin(ctx context.Context, left, right interface{}) *query.Join
+	TableSchema(ctx context.Context, name string) (reflect.Type, string, error)
+	Watch(ctx context.Context, tableType interface{}, fromSeq uint64) (<-chan query.ChangeEvent, func())
 }
 
 type EngineType string
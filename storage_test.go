@@ -1,10 +1,14 @@
 package storage
 
 import (
+	"context"
 	"testing"
+
+	"github.com/priyanshujain/go-storage/drivers/inmemory"
 )
 
 func TestStorageEngine_InMemory(t *testing.T) {
+	ctx := context.Background()
 	// Create an instance of the storage engine
 	engine := StorageEngine[EngineType("inmemory")]
 	engine.Init()
@@ -15,7 +19,7 @@ func TestStorageEngine_InMemory(t *testing.T) {
 	}
 
 	// Test CreateTable method
-	err := engine.CreateTable(Person{}, "Id")
+	err := engine.CreateTable(ctx, Person{}, "Id")
 	if err != nil {
 		t.Errorf("Failed to create table: %v", err)
 	}
@@ -25,14 +29,71 @@ func TestStorageEngine_InMemory(t *testing.T) {
 		Name: "John Doe",
 		Id:   "123",
 	}
-	err = engine.Insert(record)
+	_, err = engine.Insert(ctx, record)
 	if err != nil {
 		t.Errorf("Failed to insert record: %v", err)
 	}
 
 	// Test Get method
-	_, err = engine.Get(Person{}, "123")
+	_, err = engine.Get(ctx, Person{}, "123")
 	if err != nil {
 		t.Errorf("Failed to get record: %v", err)
 	}
 }
+
+func TestStorageEngine_InMemoryQuery(t *testing.T) {
+	ctx := context.Background()
+	engine := StorageEngine[EngineType("inmemory")]
+	engine.Init()
+
+	type Person struct {
+		Name string
+		Id   string
+	}
+	if err := engine.CreateTable(ctx, Person{}, "Id"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if err := engine.InsertMany(ctx, Person{Name: "John Doe", Id: "123"}, Person{Name: "Jane Doe", Id: "456"}); err != nil {
+		t.Fatalf("Failed to insert records: %v", err)
+	}
+	records, err := engine.Query(ctx, Person{}).Where("Name", "=", "John Doe").All()
+	if err != nil {
+		t.Fatalf("Failed to query records: %v", err)
+	}
+	if len(records) != 1 || records[0].(*Person).Id != "123" {
+		t.Errorf("Expected John Doe but got %v", records)
+	}
+}
+
+func TestWithoutContext(t *testing.T) {
+	engine := WithoutContext(&inmemory.Database{})
+	engine.Init()
+
+	type Person struct {
+		Name string
+		Id   string
+	}
+	if err := engine.CreateTable(Person{}, "Id"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if err := engine.InsertMany(Person{Name: "John Doe", Id: "123"}, Person{Name: "Jane Doe", Id: "456"}); err != nil {
+		t.Fatalf("Failed to insert records: %v", err)
+	}
+	if err := engine.Update(Person{Name: "Jane Roe", Id: "456"}); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	record, err := engine.Get(Person{}, "456")
+	if err != nil {
+		t.Fatalf("Failed to get record: %v", err)
+	}
+	if record.(*Person).Name != "Jane Roe" {
+		t.Errorf("Expected Jane Roe but got %v", record.(*Person).Name)
+	}
+	records, err := engine.Query(Person{}).Where("Name", "=", "John Doe").All()
+	if err != nil {
+		t.Fatalf("Failed to query records: %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("Expected 1 record, got %d", len(records))
+	}
+}